@@ -1,60 +1,189 @@
 package main
 
 import (
+	"context"
+	"flag"
+	"fmt"
 	"os"
+	"path"
+	"path/filepath"
+	"slices"
+	"strconv"
 
 	"github.com/gofiber/fiber/v2"
-	"github.com/gofiber/fiber/v2/middleware/cors"
 	"github.com/rs/zerolog"
+	"github.com/rs/zerolog/log"
 
 	"github.com/vkr-mtuci/allure-service/config"
 	"github.com/vkr-mtuci/allure-service/internal/adapter"
+	"github.com/vkr-mtuci/allure-service/internal/archive"
+	"github.com/vkr-mtuci/allure-service/internal/auth"
 	"github.com/vkr-mtuci/allure-service/internal/handler"
+	"github.com/vkr-mtuci/allure-service/internal/logging"
+	"github.com/vkr-mtuci/allure-service/internal/reportcache"
 	"github.com/vkr-mtuci/allure-service/internal/service"
 )
 
 func main() {
-	// Настройка логирования
+	// Настройка логирования: секреты скрываются во всех записях, включая записи пакетов сервиса
 	output := zerolog.ConsoleWriter{Out: os.Stdout}
-	logger := zerolog.New(output).With().Timestamp().Logger()
+	logger := zerolog.New(logging.Writer(output)).With().Timestamp().Logger()
+	log.Logger = logger
+
+	// Файл конфигурации: флаг --config или переменная CONFIG_FILE
+	configPath := flag.String("config", os.Getenv("CONFIG_FILE"), "путь к файлу конфигурации (.yaml, .yml или .toml)")
+	printConfig := flag.Bool("print-config", false, "вывести итоговую конфигурацию без секретов и выйти")
+	flag.Parse()
 
 	// Загрузка конфигурации
-	cfg := config.LoadConfig()
+	cfg, err := config.LoadConfig(*configPath)
+	if err != nil {
+		logger.Fatal().Err(err).Msg("❌ Ошибка загрузки конфигурации")
+	}
+	logging.Configure(cfg.Logging, cfg.Secrets())
+	if *printConfig {
+		if err := cfg.WriteRedacted(os.Stdout); err != nil {
+			logger.Fatal().Err(err).Msg("❌ Ошибка вывода конфигурации")
+		}
+		return
+	}
 	logger.Info().Msg("📢 Запуск Allure-сервиса...")
 
-	// Создание клиента
-	allureClient := adapter.NewAllureClient(cfg)
+	// Инициализация Fiber
+	app := fiber.New()
 
-	// Создание сервиса
-	allureService := service.NewAllureService(allureClient)
+	// Заголовки безопасности и CORS: у скачивания файлов своя политика
+	app.Use(handler.SecurityHeaders(cfg.HTTP.Security))
+	app.Use(handler.CORS(cfg.HTTP.CORS, cfg.HTTP.DownloadCORS))
+	if slices.Contains(cfg.HTTP.CORS.AllowOrigins, "*") {
+		logger.Warn().Msg("⚠️ CORS разрешает запросы с любых источников, задайте CORS_ALLOW_ORIGINS в продакшене")
+	}
 
-	// Создание обработчика
-	allureHandler := handler.NewAllureHandler(allureService)
+	// ID запроса и контекст, который обработчики передают в сервис
+	app.Use(handler.RequestContext)
 
-	// Инициализация Fiber
-	app := fiber.New()
+	// Аутентификация вызывающих; проверка работоспособности доступна без нее
+	authenticator, err := auth.NewAuthenticator(cfg.Auth)
+	if err != nil {
+		logger.Fatal().Err(err).Msg("❌ Ошибка инициализации аутентификации")
+	}
+	if !authenticator.Enabled() {
+		logger.Warn().Msg("⚠️ Аутентификация отключена (AUTH_DISABLED), API доступен без учетных данных")
+	}
+	app.Use(handler.Authenticate(authenticator, "/", "/health"))
 
-	// Включение CORS
-	app.Use(cors.New(cors.Config{
-		AllowOrigins: "*",
-		AllowMethods: "GET,POST,PUT,DELETE,OPTIONS",
-		AllowHeaders: "Origin, Content-Type, Accept",
-	}))
+	// Обращения к Allure от имени вызывающих, передавших свой API-токен Allure
+	if cfg.TokenPassthrough.Enabled() {
+		app.Use(handler.AllureToken(cfg.TokenPassthrough, "/", "/health"))
+		logger.Info().Msgf("🔑 API-токены Allure вызывающих принимаются в заголовке %s (режим %s)", cfg.TokenPassthrough.Header, cfg.TokenPassthrough.Mode)
+	}
 
 	// Маршруты API
 	app.Get("/", func(c *fiber.Ctx) error {
 		return c.JSON(fiber.Map{"message": "✅ Allure-service is running"})
 	})
 
-	app.Get("/next-launch", allureHandler.GetNextLaunch)
-	app.Post("/export/pdf/:id", allureHandler.GeneratePDFReport)
-	app.Get("/export/download/:id", allureHandler.GetPDFDownloadLink)
-	app.Get("/export/pdf/download/:id", allureHandler.DownloadPDFReport)
+	// У каждого экземпляра Allure свой клиент, токен и сервис
+	instances := make([]fiber.Map, 0, len(cfg.AllureInstances))
+	running := make(map[string]runningInstance, len(cfg.AllureInstances))
+	circuits := make([]handler.InstanceCircuit, 0, len(cfg.AllureInstances))
+	for i, instance := range cfg.AllureInstances {
+		allureClient, allureService, err := newInstanceService(cfg, instance, i == 0)
+		if err != nil {
+			logger.Fatal().Err(err).Msgf("❌ Ошибка инициализации экземпляра Allure %s", instance.Name)
+		}
+		running[instance.Name] = runningInstance{client: allureClient, service: allureService}
+		circuits = append(circuits, handler.InstanceCircuit{Name: instance.Name, Client: allureClient})
+
+		// Создание обработчика
+		allureHandler := handler.NewAllureHandler(allureService)
+
+		handler.RegisterInstanceRoutes(app, instance.Name, i == 0, allureHandler)
+
+		instances = append(instances, fiber.Map{"name": instance.Name, "baseUrl": instance.BaseURL, "default": i == 0})
+		logger.Info().Msgf("🔌 Экземпляр Allure %s: %s", instance.Name, instance.BaseURL)
+	}
+
+	app.Get("/instances", handler.Require(config.OperationRead), func(c *fiber.Ctx) error {
+		return c.JSON(instances)
+	})
+
+	// Состояние автоматов защиты экземпляров Allure
+	app.Get("/health", handler.Health(circuits))
+
+	// Перезагрузка конфигурации по SIGHUP и при изменении файла конфигурации
+	current := cfg
+	go config.Watch(context.Background(), *configPath, config.DefaultWatchInterval, func() {
+		current = reloadConfig(*configPath, current, running, authenticator)
+	})
 
 	// Запуск сервера
 	logger.Info().Msgf("🚀 Сервис запущен на порту %s", cfg.ServerPort)
-	err := app.Listen(":" + cfg.ServerPort)
-	if err != nil {
+	if err := app.Listen(":" + cfg.ServerPort); err != nil {
 		logger.Fatal().Err(err).Msg("❌ Ошибка запуска сервера")
 	}
 }
+
+// newInstanceService - создает клиент и сервис для экземпляра Allure.
+// Идентификаторы отчетов разных экземпляров пересекаются, поэтому кэш и архив
+// каждого экземпляра, кроме экземпляра по умолчанию, лежат в отдельном подкаталоге.
+func newInstanceService(cfg *config.Config, instance config.AllureInstance, isDefault bool) (*adapter.AllureClient, *service.AllureService, error) {
+	// Создание клиента
+	allureClient := adapter.NewAllureClient(cfg.ForInstance(instance))
+
+	subdir := ""
+	if !isDefault {
+		subdir = instance.Name
+	}
+
+	// Создание сервиса
+	defaultProjectID, _ := strconv.ParseInt(instance.ProjectID, 10, 64)
+	serviceOptions := []service.Option{
+		service.WithProjects(defaultProjectID, instance.Projects),
+		service.WithTimeouts(serviceTimeouts(cfg)),
+	}
+	if cfg.ReportCacheMaxSize > 0 {
+		reports, err := reportcache.New(filepath.Join(cfg.ReportCacheDir, subdir), cfg.ReportCacheMaxSize)
+		if err != nil {
+			return nil, nil, fmt.Errorf("ошибка инициализации кэша отчетов: %w", err)
+		}
+		serviceOptions = append(serviceOptions, service.WithReportCache(reports))
+	}
+	switch cfg.ArchiveBackend {
+	case config.ArchiveBackendLocal:
+		reportArchive, err := archive.NewLocal(filepath.Join(cfg.ArchiveDir, subdir))
+		if err != nil {
+			return nil, nil, fmt.Errorf("ошибка инициализации архива отчетов: %w", err)
+		}
+		serviceOptions = append(serviceOptions, service.WithReportArchive(reportArchive))
+	case config.ArchiveBackendS3:
+		reportArchive, err := archive.NewS3(archiveS3Config(cfg.ArchiveS3, subdir))
+		if err != nil {
+			return nil, nil, fmt.Errorf("ошибка инициализации архива отчетов: %w", err)
+		}
+		serviceOptions = append(serviceOptions, service.WithReportArchive(reportArchive))
+	}
+
+	return allureClient, service.NewAllureService(allureClient, serviceOptions...), nil
+}
+
+// serviceTimeouts - таймауты операций сервиса из конфигурации
+func serviceTimeouts(cfg *config.Config) service.Timeouts {
+	return service.Timeouts{
+		List:     cfg.Timeouts.List,
+		Export:   cfg.Timeouts.Export,
+		Download: cfg.Timeouts.Download,
+	}
+}
+
+// archiveS3Config - параметры S3-хранилища архива экземпляра; ключи экземпляра лежат под префиксом subdir
+func archiveS3Config(s3 config.ArchiveS3, subdir string) archive.S3Config {
+	return archive.S3Config{
+		Endpoint:  s3.Endpoint,
+		Region:    s3.Region,
+		Bucket:    s3.Bucket,
+		Prefix:    path.Join(s3.Prefix, subdir),
+		AccessKey: s3.AccessKey,
+		SecretKey: s3.SecretKey,
+	}
+}
@@ -0,0 +1,79 @@
+package main
+
+import (
+	"reflect"
+	"slices"
+	"strconv"
+
+	"github.com/rs/zerolog/log"
+
+	"github.com/vkr-mtuci/allure-service/config"
+	"github.com/vkr-mtuci/allure-service/internal/adapter"
+	"github.com/vkr-mtuci/allure-service/internal/auth"
+	"github.com/vkr-mtuci/allure-service/internal/logging"
+	"github.com/vkr-mtuci/allure-service/internal/service"
+)
+
+// runningInstance - работающий экземпляр Allure, который можно перенастроить без перезапуска
+type runningInstance struct {
+	client  *adapter.AllureClient
+	service *service.AllureService
+}
+
+// reloadConfig - перечитывает конфигурацию и применяет учетные данные, таймауты и разрешенные проекты
+// экземпляров, API-ключи и настройки JWT. Остальное требует перезапуска; при ошибке загрузки
+// возвращается и продолжает действовать старая конфигурация.
+func reloadConfig(path string, current *config.Config, running map[string]runningInstance, authenticator *auth.Authenticator) *config.Config {
+	log.Info().Msg("🔁 Перезагрузка конфигурации...")
+
+	cfg, err := config.LoadConfig(path)
+	if err != nil {
+		log.Error().Err(err).Msg("❌ Конфигурация не перезагружена, сервис работает со старыми настройками")
+		return current
+	}
+	logging.Configure(cfg.Logging, cfg.Secrets())
+
+	if err := authenticator.Reload(cfg.Auth); err != nil {
+		log.Error().Err(err).Msg("❌ Настройки аутентификации не перезагружены, действуют прежние")
+	}
+
+	for _, instance := range cfg.AllureInstances {
+		runtime, ok := running[instance.Name]
+		if !ok {
+			log.Warn().Msgf("⚠️ Новый экземпляр Allure %s будет подключен только после перезапуска", instance.Name)
+			continue
+		}
+
+		instanceCfg := cfg.ForInstance(instance)
+		defaultProjectID, _ := strconv.ParseInt(instance.ProjectID, 10, 64)
+		runtime.client.Reload(instanceCfg)
+		runtime.service.Reload(serviceTimeouts(cfg), defaultProjectID, instance.Projects)
+		log.Info().Msgf("🔌 Экземпляр Allure %s перенастроен: %s", instance.Name, instance.BaseURL)
+	}
+	for _, instance := range current.AllureInstances {
+		if !slices.ContainsFunc(cfg.AllureInstances, func(other config.AllureInstance) bool { return other.Name == instance.Name }) {
+			log.Warn().Msgf("⚠️ Экземпляр Allure %s удален из конфигурации, но работает до перезапуска", instance.Name)
+		}
+	}
+
+	if cfg.AllureInstances[0].Name != current.AllureInstances[0].Name {
+		log.Warn().Msg("⚠️ Смена экземпляра Allure по умолчанию применится после перезапуска")
+	}
+	if cfg.ServerPort != current.ServerPort {
+		log.Warn().Msg("⚠️ Смена порта применится после перезапуска")
+	}
+	if cfg.TokenPassthrough.Mode != current.TokenPassthrough.Mode || cfg.TokenPassthrough.Header != current.TokenPassthrough.Header {
+		log.Warn().Msg("⚠️ Смена режима и заголовка API-токенов вызывающих применится после перезапуска")
+	}
+	if !reflect.DeepEqual(cfg.HTTP, current.HTTP) {
+		log.Warn().Msg("⚠️ Изменения CORS и заголовков безопасности применятся после перезапуска")
+	}
+	if cfg.ReportCacheDir != current.ReportCacheDir || cfg.ReportCacheMaxSize != current.ReportCacheMaxSize ||
+		cfg.ArchiveBackend != current.ArchiveBackend || cfg.ArchiveDir != current.ArchiveDir ||
+		!reflect.DeepEqual(cfg.ArchiveS3, current.ArchiveS3) {
+		log.Warn().Msg("⚠️ Изменения кэша и архива отчетов применятся после перезапуска")
+	}
+
+	log.Info().Msg("✅ Конфигурация перезагружена")
+	return cfg
+}
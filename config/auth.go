@@ -0,0 +1,180 @@
+package config
+
+import (
+	"fmt"
+	"os"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Auth - аутентификация вызывающих API сервиса. Без API-ключей и JWT сервис не запускается,
+// если аутентификация не отключена явно.
+type Auth struct {
+	Disabled bool // API открыт всем без учетных данных
+	APIKeys  []APIKey
+	JWT      JWTAuth
+}
+
+// APIKey - статический API-ключ вызывающего. Права выдаются явно: пустой список не разрешает ничего.
+type APIKey struct {
+	Name       string
+	Key        string
+	Projects   []ProjectRef // Разрешенные проекты, AllowAll - все проекты всех экземпляров
+	Operations []string     // Разрешенные операции, AllowAll - все
+}
+
+// AllowAll - элемент списка прав, разрешающий все проекты или все операции
+const AllowAll = "*"
+
+// ProjectRef - разрешенный проект: AllowAll, "<экземпляр>:<ID>", "<экземпляр>:*" или "<ID>" - проект
+// экземпляра по умолчанию. ID одного проекта в разных экземплярах Allure означают разные проекты.
+// В файле задается числом или строкой.
+type ProjectRef string
+
+// UnmarshalText - принимает ID проекта и числом, и строкой
+func (p *ProjectRef) UnmarshalText(text []byte) error {
+	*p = ProjectRef(text)
+	return nil
+}
+
+// Parse - экземпляр и проект; пустой экземпляр - экземпляр по умолчанию, projectID 0 - все проекты
+// экземпляра. AllowAll без экземпляра разбирать нельзя: он разрешает все экземпляры.
+func (p ProjectRef) Parse() (instance string, projectID int64, err error) {
+	instance, project, qualified := strings.Cut(string(p), ":")
+	if !qualified {
+		instance, project = "", string(p)
+	} else if !instanceNamePattern.MatchString(instance) {
+		return "", 0, fmt.Errorf("некорректное имя экземпляра в %q", p)
+	}
+
+	if qualified && project == AllowAll {
+		return instance, 0, nil
+	}
+	projectID, err = strconv.ParseInt(project, 10, 64)
+	if err != nil || projectID <= 0 {
+		return "", 0, fmt.Errorf("некорректный ID проекта в %q", p)
+	}
+	return instance, projectID, nil
+}
+
+// JWTAuth - проверка JWT по ключам JWKS из файла или по URL
+type JWTAuth struct {
+	JWKSURL         string
+	JWKSFile        string
+	JWKSRefresh     time.Duration // Как часто перечитываются ключи по URL
+	Issuer          string        // Ожидаемый iss, пусто - не проверяется
+	Audience        string        // Ожидаемый aud, пусто - не проверяется
+	ProjectsClaim   string        // Claim со списком разрешенных проектов или "*"
+	OperationsClaim string        // Claim со списком разрешенных операций или "*"
+}
+
+// Операции API, которые разрешаются вызывающим
+const (
+	OperationRead     = "read"     // Проекты, запуски, результаты тестов, статус экспорта
+	OperationExport   = "export"   // Генерация PDF-отчетов и выгрузок CSV, Excel, JUnit
+	OperationDownload = "download" // Скачивание вложений, PDF-отчетов и архивных отчетов
+)
+
+// Operations - все операции API
+var Operations = []string{OperationRead, OperationExport, OperationDownload}
+
+// Enabled - включена ли аутентификация
+func (a Auth) Enabled() bool {
+	return len(a.APIKeys) > 0 || a.JWT.Enabled()
+}
+
+// Enabled - задан ли источник ключей JWT
+func (j JWTAuth) Enabled() bool {
+	return j.JWKSURL != "" || j.JWKSFile != ""
+}
+
+// EnvName - переменная окружения с ключом: AUTH_API_KEY_<ИМЯ>
+func (key APIKey) EnvName() string {
+	return "AUTH_API_KEY_" + strings.ToUpper(strings.ReplaceAll(key.Name, "-", "_"))
+}
+
+// applyEnv - переопределяет параметры аутентификации переменными AUTH_*
+func (a *Auth) applyEnv(errs *ValidationError) {
+	envBool("AUTH_DISABLED", "auth.disabled", &a.Disabled, errs)
+	envString("AUTH_JWKS_URL", &a.JWT.JWKSURL)
+	envString("AUTH_JWKS_FILE", &a.JWT.JWKSFile)
+	envDuration("AUTH_JWKS_REFRESH", "auth.jwt.jwksRefresh", &a.JWT.JWKSRefresh, errs)
+	envString("AUTH_JWT_ISSUER", &a.JWT.Issuer)
+	envString("AUTH_JWT_AUDIENCE", &a.JWT.Audience)
+	envString("AUTH_JWT_PROJECTS_CLAIM", &a.JWT.ProjectsClaim)
+	envString("AUTH_JWT_OPERATIONS_CLAIM", &a.JWT.OperationsClaim)
+
+	// AUTH_API_KEYS - имена ключей через запятую; он заменяет список ключей из файла,
+	// но права одноименных ключей из файла сохраняются
+	if names := os.Getenv("AUTH_API_KEYS"); names != "" {
+		fromFile := a.APIKeys
+		a.APIKeys = nil
+		for _, name := range strings.Split(names, ",") {
+			key := APIKey{Name: strings.TrimSpace(name)}
+			for _, existing := range fromFile {
+				if existing.Name == key.Name {
+					key = existing
+				}
+			}
+			a.APIKeys = append(a.APIKeys, key)
+		}
+	}
+	for i := range a.APIKeys {
+		envString(a.APIKeys[i].EnvName(), &a.APIKeys[i].Key)
+	}
+}
+
+// minAPIKeyLength - минимальная длина API-ключа, чтобы его нельзя было подобрать
+const minAPIKeyLength = 16
+
+// validate - проверяет параметры аутентификации; права на проекты ссылаются на экземпляры instances
+func (a *Auth) validate(instances []AllureInstance, errs *ValidationError) {
+	for i, key := range a.APIKeys {
+		field := fmt.Sprintf("auth.apiKeys[%d]", i)
+		if !instanceNamePattern.MatchString(key.Name) {
+			errs.add(field+".name", "имя ключа должно состоять из строчных латинских букв, цифр, '-' и '_', получено %q", key.Name)
+		}
+		if slices.ContainsFunc(a.APIKeys[:i], func(other APIKey) bool { return other.Name == key.Name }) {
+			errs.add(field+".name", "ключ %q указан дважды", key.Name)
+		}
+		if len(key.Key) < minAPIKeyLength {
+			errs.add(field+".key", "ключ должен быть не короче %d символов (%s)", minAPIKeyLength, key.EnvName())
+		}
+		for _, project := range key.Projects {
+			if project == AllowAll {
+				continue
+			}
+			instance, _, err := project.Parse()
+			switch {
+			case err != nil:
+				errs.add(field+".projects", "проект задается как ID, <экземпляр>:<ID>, <экземпляр>:* или %q: %s", AllowAll, err)
+			case instance != "" && !slices.ContainsFunc(instances, func(known AllureInstance) bool { return known.Name == instance }):
+				errs.add(field+".projects", "неизвестный экземпляр Allure %q в %q", instance, project)
+			}
+		}
+		for _, operation := range key.Operations {
+			if operation != AllowAll && !slices.Contains(Operations, operation) {
+				errs.add(field+".operations", "операция может быть %s или %q, получено %q", strings.Join(Operations, ", "), AllowAll, operation)
+			}
+		}
+	}
+
+	jwt := &a.JWT
+	if jwt.JWKSURL != "" && jwt.JWKSFile != "" {
+		errs.add("auth.jwt.jwksFile", "укажите либо файл ключей (AUTH_JWKS_FILE), либо URL (AUTH_JWKS_URL)")
+	}
+	if !jwt.Enabled() && (jwt.Issuer != "" || jwt.Audience != "") {
+		errs.add("auth.jwt", "для проверки JWT нужно задать файл ключей (AUTH_JWKS_FILE) или URL (AUTH_JWKS_URL)")
+	}
+	if jwt.JWKSRefresh <= 0 {
+		errs.add("auth.jwt.jwksRefresh", "значение должно быть положительным (AUTH_JWKS_REFRESH)")
+	}
+	if jwt.ProjectsClaim == "" {
+		errs.add("auth.jwt.projectsClaim", "обязательный параметр не задан (AUTH_JWT_PROJECTS_CLAIM)")
+	}
+	if jwt.OperationsClaim == "" {
+		errs.add("auth.jwt.operationsClaim", "обязательный параметр не задан (AUTH_JWT_OPERATIONS_CLAIM)")
+	}
+}
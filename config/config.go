@@ -3,40 +3,429 @@ package config
 import (
 	"log"
 	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
 	"time"
 
 	"github.com/joho/godotenv"
 )
 
-// Config - структура для хранения конфигурации приложения
+// Config - структура для хранения конфигурации приложения.
+// Поля Allure* описывают экземпляр Allure по умолчанию - первый из AllureInstances.
 type Config struct {
 	ServerPort      string
 	AllureBaseURL   string
 	AllureAPIURL    string
 	AllureUserToken string
-	AllureProjectID string
-	TokenExpiry     time.Duration
+	AllureProjectID string  // Проект по умолчанию для маршрутов без /projects/:projectId
+	AllureProjects  []int64 // Разрешенные проекты; проект по умолчанию входит всегда
+
+	TokenExpiry        time.Duration // Время жизни токена, если Allure не сообщил его сам
+	TokenRefreshMargin time.Duration // За сколько до истечения токен обновляется
+	Timeouts           Timeouts
+	Retry              RetryPolicy
+	CircuitBreaker     CircuitBreaker
+	TokenPassthrough   TokenPassthrough
+
+	AllureInstances []AllureInstance // Все экземпляры Allure TestOps, первый - по умолчанию
+
+	ReportCacheDir     string // Каталог кэша скачанных PDF-отчетов
+	ReportCacheMaxSize int64  // Максимальный размер кэша в байтах, 0 - кэш отключен
+
+	ArchiveBackend string    // Хранилище архива отчетов: "", local или s3
+	ArchiveDir     string    // Каталог архива для хранилища local
+	ArchiveS3      ArchiveS3 // Параметры хранилища s3
+
+	Logging Logging
+	Auth    Auth
+	HTTP    HTTP
+}
+
+// AllureInstance - подключение к одному экземпляру Allure TestOps
+type AllureInstance struct {
+	Name      string
+	BaseURL   string
+	APIURL    string
+	UserToken string
+	ProjectID string
+	Projects  []int64
+}
+
+// ArchiveS3 - параметры S3-совместимого хранилища архива отчетов
+type ArchiveS3 struct {
+	Endpoint  string // Адрес хранилища, например http://minio:9000
+	Region    string // Регион для подписи запросов, по умолчанию us-east-1
+	Bucket    string
+	Prefix    string // Необязательный префикс ключей внутри бакета
+	AccessKey string
+	SecretKey string
 }
 
-// LoadConfig загружает переменные окружения в структуру Config
-func LoadConfig() *Config {
-	envPath := ".env"
-	if err := godotenv.Load(envPath); err != nil {
+// Timeouts - таймауты обращений к Allure
+type Timeouts struct {
+	Request  time.Duration // Один HTTP-запрос к Allure API
+	List     time.Duration // Получение проектов, запусков и результатов тестов
+	Export   time.Duration // Генерация PDF-отчетов и выгрузок результатов
+	Download time.Duration // Скачивание вложений и PDF-отчетов
+}
+
+// RetryPolicy - повторы неудачных запросов к Allure
+type RetryPolicy struct {
+	MaxAttempts    int           // Всего попыток, 1 - без повторов
+	InitialBackoff time.Duration // Пауза перед первым повтором
+	MaxBackoff     time.Duration // Предельная пауза между повторами
+}
+
+// CircuitBreaker - автомат защиты, который перестает обращаться к недоступному Allure
+type CircuitBreaker struct {
+	FailureThreshold int           // Неудачных запросов подряд до размыкания, 0 - автомат отключен
+	OpenTimeout      time.Duration // Сколько запросы не отправляются перед пробным
+}
+
+// TokenPassthrough - обращения к Allure от имени вызывающих по их собственным API-токенам
+type TokenPassthrough struct {
+	Mode      string // off, optional или required
+	Header    string // Заголовок с API-токеном Allure вызывающего
+	CacheSize int    // Сколько токенов доступа вызывающих хранится одновременно
+}
+
+// Режимы передачи API-токенов вызывающих
+const (
+	TokenPassthroughOff      = "off"      // Все запросы идут от имени API-токена сервиса
+	TokenPassthroughOptional = "optional" // Запросы без токена вызывающего идут от имени сервиса
+	TokenPassthroughRequired = "required" // Запросы без токена вызывающего отклоняются
+)
+
+// tokenPassthroughModes - допустимые режимы передачи API-токенов
+var tokenPassthroughModes = []string{TokenPassthroughOff, TokenPassthroughOptional, TokenPassthroughRequired}
+
+// Enabled - принимаются ли API-токены вызывающих
+func (t TokenPassthrough) Enabled() bool {
+	return t.Mode != TokenPassthroughOff
+}
+
+// Logging - параметры логирования
+type Logging struct {
+	Level           string   // Уровень логов: debug, info, warn или error
+	MaxBodyBytes    int      // Сколько байт тела ответа Allure попадает в лог уровня debug
+	SensitiveFields []string // Поля, значения которых скрываются в логах, в дополнение к стандартным
+}
+
+// Хранилища архива PDF-отчетов
+const (
+	ArchiveBackendLocal = "local"
+	ArchiveBackendS3    = "s3"
+)
+
+// DefaultInstanceName - имя единственного экземпляра, если экземпляры не перечислены явно
+const DefaultInstanceName = "default"
+
+// defaultReportCacheMaxMB - размер кэша PDF-отчетов по умолчанию, МБ
+const defaultReportCacheMaxMB = 1024
+
+// Defaults - конфигурация по умолчанию, поверх которой применяются файл и переменные окружения
+func Defaults() *Config {
+	return &Config{
+		ServerPort:         "8080",
+		TokenExpiry:        55 * time.Minute,
+		TokenRefreshMargin: 5 * time.Minute,
+		Timeouts: Timeouts{
+			Request:  10 * time.Second,
+			List:     10 * time.Second,
+			Export:   30 * time.Second,
+			Download: 5 * time.Minute,
+		},
+		Retry: RetryPolicy{
+			MaxAttempts:    3,
+			InitialBackoff: 200 * time.Millisecond,
+			MaxBackoff:     5 * time.Second,
+		},
+		CircuitBreaker: CircuitBreaker{
+			FailureThreshold: 5,
+			OpenTimeout:      30 * time.Second,
+		},
+		TokenPassthrough: TokenPassthrough{
+			Mode:      TokenPassthroughOff,
+			Header:    "X-Allure-Token",
+			CacheSize: 1000,
+		},
+		ReportCacheDir:     filepath.Join(os.TempDir(), "allure-service", "reports"),
+		ReportCacheMaxSize: defaultReportCacheMaxMB << 20,
+		Logging: Logging{
+			Level:        "info",
+			MaxBodyBytes: 2048,
+		},
+		Auth: Auth{
+			JWT: JWTAuth{
+				JWKSRefresh:     10 * time.Minute,
+				ProjectsClaim:   "allure_projects",
+				OperationsClaim: "allure_operations",
+			},
+		},
+		HTTP: defaultHTTP(),
+	}
+}
+
+// LoadConfig загружает конфигурацию: значения по умолчанию, затем файл path (YAML или TOML,
+// пустой path - без файла), затем переменные окружения. Ошибки возвращаются по полям.
+func LoadConfig(path string) (*Config, error) {
+	loadDotenv(".env")
+
+	config := Defaults()
+	var errs ValidationError
+
+	if path != "" {
+		if err := config.applyFile(path); err != nil {
+			return nil, err
+		}
+	}
+
+	config.applyEnv(&errs)
+	config.validate(&errs)
+	if len(errs) > 0 {
+		return nil, errs
+	}
+
+	defaultInstance := config.AllureInstances[0]
+	config.AllureBaseURL = defaultInstance.BaseURL
+	config.AllureAPIURL = defaultInstance.APIURL
+	config.AllureUserToken = defaultInstance.UserToken
+	config.AllureProjectID = defaultInstance.ProjectID
+	config.AllureProjects = defaultInstance.Projects
+
+	return config, nil
+}
+
+// dotenv - переменные, взятые из .env, а не из окружения процесса
+var dotenv = struct {
+	sync.Mutex
+	keys map[string]bool
+}{keys: make(map[string]bool)}
+
+// loadDotenv - загружает .env в окружение. Переменные окружения процесса важнее .env,
+// а значения, взятые из .env, при повторной загрузке обновляются - так при перезагрузке
+// конфигурации подхватывается, например, новый API-токен.
+func loadDotenv(envPath string) {
+	values, err := godotenv.Read(envPath)
+	if err != nil {
 		log.Println("⚠ Нет .env файла, используем переменные окружения")
+		return
 	}
 
-	config := &Config{
-		ServerPort:      os.Getenv("SERVER_PORT"),
-		AllureBaseURL:   os.Getenv("ALLURE_BASE_URL"),
-		AllureAPIURL:    os.Getenv("ALLURE_API_URL"),
-		AllureUserToken: os.Getenv("ALLURE_API_TOKEN"),
-		AllureProjectID: os.Getenv("ALLURE_PROJECT_ID"),
-		TokenExpiry:     55 * time.Minute, // Настройка истечения токена
+	dotenv.Lock()
+	defer dotenv.Unlock()
+	for key, value := range values {
+		if _, fromProcess := os.LookupEnv(key); fromProcess && !dotenv.keys[key] {
+			continue
+		}
+		dotenv.keys[key] = true
+		os.Setenv(key, value)
 	}
+}
 
-	if config.AllureBaseURL == "" || config.AllureUserToken == "" || config.AllureAPIURL == "" || config.AllureProjectID == "" {
-		log.Fatal("❌ Ошибка: Не заданы все обязательные переменные окружения для Allure")
+// ForInstance - копия конфигурации, в которой экземпляром по умолчанию выбран instance
+func (c *Config) ForInstance(instance AllureInstance) *Config {
+	copied := *c
+	copied.AllureBaseURL = instance.BaseURL
+	copied.AllureAPIURL = instance.APIURL
+	copied.AllureUserToken = instance.UserToken
+	copied.AllureProjectID = instance.ProjectID
+	copied.AllureProjects = instance.Projects
+	return &copied
+}
+
+// Secrets - секреты из конфигурации, которые не должны попадать в логи:
+// API-токены экземпляров Allure, ключи хранилища s3 и API-ключи вызывающих
+func (c *Config) Secrets() []string {
+	var secrets []string
+	for _, instance := range c.AllureInstances {
+		if instance.UserToken != "" {
+			secrets = append(secrets, instance.UserToken)
+		}
+	}
+	for _, key := range []string{c.ArchiveS3.AccessKey, c.ArchiveS3.SecretKey} {
+		if key != "" {
+			secrets = append(secrets, key)
+		}
+	}
+	for _, key := range c.Auth.APIKeys {
+		if key.Key != "" {
+			secrets = append(secrets, key.Key)
+		}
+	}
+	return secrets
+}
+
+// applyEnv - переопределяет значения переменными окружения
+func (c *Config) applyEnv(errs *ValidationError) {
+	envString("SERVER_PORT", &c.ServerPort)
+
+	envDuration("ALLURE_TOKEN_EXPIRY", "allure.tokenExpiry", &c.TokenExpiry, errs)
+	envDuration("ALLURE_TOKEN_REFRESH_MARGIN", "allure.tokenRefreshMargin", &c.TokenRefreshMargin, errs)
+	envDuration("ALLURE_TIMEOUT_REQUEST", "allure.timeouts.request", &c.Timeouts.Request, errs)
+	envDuration("ALLURE_TIMEOUT_LIST", "allure.timeouts.list", &c.Timeouts.List, errs)
+	envDuration("ALLURE_TIMEOUT_EXPORT", "allure.timeouts.export", &c.Timeouts.Export, errs)
+	envDuration("ALLURE_TIMEOUT_DOWNLOAD", "allure.timeouts.download", &c.Timeouts.Download, errs)
+	envInt("ALLURE_RETRY_MAX_ATTEMPTS", "allure.retry.maxAttempts", &c.Retry.MaxAttempts, errs)
+	envDuration("ALLURE_RETRY_INITIAL_BACKOFF", "allure.retry.initialBackoff", &c.Retry.InitialBackoff, errs)
+	envDuration("ALLURE_RETRY_MAX_BACKOFF", "allure.retry.maxBackoff", &c.Retry.MaxBackoff, errs)
+	envInt("ALLURE_CIRCUIT_FAILURE_THRESHOLD", "allure.circuitBreaker.failureThreshold", &c.CircuitBreaker.FailureThreshold, errs)
+	envDuration("ALLURE_CIRCUIT_OPEN_TIMEOUT", "allure.circuitBreaker.openTimeout", &c.CircuitBreaker.OpenTimeout, errs)
+	envString("ALLURE_TOKEN_PASSTHROUGH", &c.TokenPassthrough.Mode)
+	envString("ALLURE_TOKEN_PASSTHROUGH_HEADER", &c.TokenPassthrough.Header)
+	envInt("ALLURE_TOKEN_CACHE_SIZE", "allure.tokenPassthrough.cacheSize", &c.TokenPassthrough.CacheSize, errs)
+
+	envString("REPORT_CACHE_DIR", &c.ReportCacheDir)
+	if value, ok := os.LookupEnv("REPORT_CACHE_MAX_MB"); ok && value != "" {
+		parsed, err := strconv.ParseInt(value, 10, 64)
+		if err != nil {
+			errs.add("reportCache.maxMB", "REPORT_CACHE_MAX_MB должен быть целым числом, получено %q", value)
+		} else {
+			c.ReportCacheMaxSize = parsed << 20
+		}
+	}
+
+	envString("ARCHIVE_BACKEND", &c.ArchiveBackend)
+	envString("ARCHIVE_DIR", &c.ArchiveDir)
+	envString("ARCHIVE_S3_ENDPOINT", &c.ArchiveS3.Endpoint)
+	envString("ARCHIVE_S3_REGION", &c.ArchiveS3.Region)
+	envString("ARCHIVE_S3_BUCKET", &c.ArchiveS3.Bucket)
+	envString("ARCHIVE_S3_PREFIX", &c.ArchiveS3.Prefix)
+	envString("ARCHIVE_S3_ACCESS_KEY", &c.ArchiveS3.AccessKey)
+	envString("ARCHIVE_S3_SECRET_KEY", &c.ArchiveS3.SecretKey)
+
+	envString("LOG_LEVEL", &c.Logging.Level)
+	envInt("LOG_MAX_BODY_BYTES", "logging.maxBodyBytes", &c.Logging.MaxBodyBytes, errs)
+	envList("LOG_SENSITIVE_FIELDS", &c.Logging.SensitiveFields)
+
+	c.Auth.applyEnv(errs)
+	c.HTTP.applyEnv(errs)
+
+	// ALLURE_INSTANCES - имена экземпляров через запятую; он заменяет список экземпляров из файла,
+	// но параметры одноименных экземпляров из файла сохраняются
+	if names := os.Getenv("ALLURE_INSTANCES"); names != "" {
+		fromFile := c.AllureInstances
+		c.AllureInstances = nil
+		for _, name := range strings.Split(names, ",") {
+			instance := AllureInstance{Name: strings.TrimSpace(name)}
+			for _, existing := range fromFile {
+				if existing.Name == instance.Name {
+					instance = existing
+				}
+			}
+			c.AllureInstances = append(c.AllureInstances, instance)
+		}
+	}
+	if len(c.AllureInstances) == 0 {
+		c.AllureInstances = []AllureInstance{{Name: DefaultInstanceName}}
+	}
+
+	for i := range c.AllureInstances {
+		c.AllureInstances[i].applyEnv(c.instanceField(i), errs)
+	}
+}
+
+// applyEnv - переопределяет параметры экземпляра переменными ALLURE_<ИМЯ>_*;
+// для экземпляра default используются переменные ALLURE_* без имени
+func (instance *AllureInstance) applyEnv(field string, errs *ValidationError) {
+	prefix := instance.EnvPrefix()
+
+	envString(prefix+"BASE_URL", &instance.BaseURL)
+	envString(prefix+"API_URL", &instance.APIURL)
+	envString(prefix+"API_TOKEN", &instance.UserToken)
+	envString(prefix+"PROJECT_ID", &instance.ProjectID)
+
+	// <prefix>PROJECT_IDS - разрешенные проекты через запятую
+	if value, ok := os.LookupEnv(prefix + "PROJECT_IDS"); ok && value != "" {
+		instance.Projects = nil
+		for _, item := range strings.Split(value, ",") {
+			item = strings.TrimSpace(item)
+			if item == "" {
+				continue
+			}
+			projectID, err := strconv.ParseInt(item, 10, 64)
+			if err != nil {
+				errs.add(field+".projectIds", "%sPROJECT_IDS должен содержать ID проектов через запятую, получено %q", prefix, item)
+				continue
+			}
+			instance.Projects = append(instance.Projects, projectID)
+		}
+	}
+}
+
+// EnvPrefix - префикс переменных окружения экземпляра
+func (instance AllureInstance) EnvPrefix() string {
+	if instance.Name == DefaultInstanceName {
+		return "ALLURE_"
+	}
+	return "ALLURE_" + strings.ToUpper(strings.ReplaceAll(instance.Name, "-", "_")) + "_"
+}
+
+// envString - заменяет значение непустой переменной окружения
+func envString(name string, target *string) {
+	if value := os.Getenv(name); value != "" {
+		*target = value
+	}
+}
+
+// envInt - заменяет значение целочисленной переменной окружения
+func envInt(name, field string, target *int, errs *ValidationError) {
+	value := os.Getenv(name)
+	if value == "" {
+		return
+	}
+
+	parsed, err := strconv.Atoi(value)
+	if err != nil {
+		errs.add(field, "%s должен быть целым числом, получено %q", name, value)
+		return
+	}
+	*target = parsed
+}
+
+// envDuration - заменяет значение переменной окружения с длительностью вида "30s" или "5m"
+func envDuration(name, field string, target *time.Duration, errs *ValidationError) {
+	value := os.Getenv(name)
+	if value == "" {
+		return
 	}
 
-	return config
+	parsed, err := time.ParseDuration(value)
+	if err != nil {
+		errs.add(field, "%s должен быть длительностью вида 30s или 5m, получено %q", name, value)
+		return
+	}
+	*target = parsed
+}
+
+// envList - заменяет список значениями непустой переменной окружения через запятую
+func envList(name string, target *[]string) {
+	value := os.Getenv(name)
+	if value == "" {
+		return
+	}
+
+	*target = nil
+	for _, item := range strings.Split(value, ",") {
+		if item = strings.TrimSpace(item); item != "" {
+			*target = append(*target, item)
+		}
+	}
+}
+
+// envBool - заменяет значение логической переменной окружения: true/false, 1/0
+func envBool(name, field string, target *bool, errs *ValidationError) {
+	value := os.Getenv(name)
+	if value == "" {
+		return
+	}
+
+	parsed, err := strconv.ParseBool(value)
+	if err != nil {
+		errs.add(field, "%s должен быть true или false, получено %q", name, value)
+		return
+	}
+	*target = parsed
 }
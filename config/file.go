@@ -0,0 +1,467 @@
+package config
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/BurntSushi/toml"
+	"gopkg.in/yaml.v3"
+)
+
+// redacted - подстановка вместо секретов при выводе конфигурации
+const redacted = "******"
+
+// Duration - длительность в файле конфигурации в виде строки "30s", "5m" или "1h30m"
+type Duration time.Duration
+
+// UnmarshalText - разбирает длительность из файла конфигурации
+func (d *Duration) UnmarshalText(text []byte) error {
+	parsed, err := time.ParseDuration(string(text))
+	if err != nil {
+		return fmt.Errorf("длительность должна иметь вид 30s или 5m, получено %q", text)
+	}
+	*d = Duration(parsed)
+	return nil
+}
+
+// MarshalText - выводит длительность в том же виде, в каком она задается
+func (d Duration) MarshalText() ([]byte, error) {
+	return []byte(time.Duration(d).String()), nil
+}
+
+// fileConfig - схема файла конфигурации; пустые поля не меняют значения по умолчанию
+type fileConfig struct {
+	Server struct {
+		Port *int `yaml:"port,omitempty" toml:"port,omitempty"`
+	} `yaml:"server" toml:"server"`
+	Allure      fileAllure      `yaml:"allure" toml:"allure"`
+	ReportCache fileReportCache `yaml:"reportCache" toml:"reportCache"`
+	Archive     fileArchive     `yaml:"archive,omitempty" toml:"archive,omitempty"`
+	Logging     fileLogging     `yaml:"logging" toml:"logging"`
+	Auth        fileAuth        `yaml:"auth,omitempty" toml:"auth,omitempty"`
+	HTTP        fileHTTP        `yaml:"http" toml:"http"`
+}
+
+// fileAllure - секция allure: параметры экземпляра default или список экземпляров
+type fileAllure struct {
+	BaseURL    string  `yaml:"baseUrl,omitempty" toml:"baseUrl,omitempty"`
+	APIURL     string  `yaml:"apiUrl,omitempty" toml:"apiUrl,omitempty"`
+	APIToken   string  `yaml:"apiToken,omitempty" toml:"apiToken,omitempty"`
+	ProjectID  int64   `yaml:"projectId,omitempty" toml:"projectId,omitempty"`
+	ProjectIDs []int64 `yaml:"projectIds,omitempty" toml:"projectIds,omitempty"`
+
+	Instances []fileInstance `yaml:"instances,omitempty" toml:"instances,omitempty"`
+
+	TokenExpiry        *Duration `yaml:"tokenExpiry,omitempty" toml:"tokenExpiry,omitempty"`
+	TokenRefreshMargin *Duration `yaml:"tokenRefreshMargin,omitempty" toml:"tokenRefreshMargin,omitempty"`
+	Timeouts           struct {
+		Request  *Duration `yaml:"request,omitempty" toml:"request,omitempty"`
+		List     *Duration `yaml:"list,omitempty" toml:"list,omitempty"`
+		Export   *Duration `yaml:"export,omitempty" toml:"export,omitempty"`
+		Download *Duration `yaml:"download,omitempty" toml:"download,omitempty"`
+	} `yaml:"timeouts" toml:"timeouts"`
+	Retry struct {
+		MaxAttempts    *int      `yaml:"maxAttempts,omitempty" toml:"maxAttempts,omitempty"`
+		InitialBackoff *Duration `yaml:"initialBackoff,omitempty" toml:"initialBackoff,omitempty"`
+		MaxBackoff     *Duration `yaml:"maxBackoff,omitempty" toml:"maxBackoff,omitempty"`
+	} `yaml:"retry" toml:"retry"`
+	CircuitBreaker struct {
+		FailureThreshold *int      `yaml:"failureThreshold,omitempty" toml:"failureThreshold,omitempty"`
+		OpenTimeout      *Duration `yaml:"openTimeout,omitempty" toml:"openTimeout,omitempty"`
+	} `yaml:"circuitBreaker" toml:"circuitBreaker"`
+	TokenPassthrough struct {
+		Mode      *string `yaml:"mode,omitempty" toml:"mode,omitempty"`
+		Header    *string `yaml:"header,omitempty" toml:"header,omitempty"`
+		CacheSize *int    `yaml:"cacheSize,omitempty" toml:"cacheSize,omitempty"`
+	} `yaml:"tokenPassthrough" toml:"tokenPassthrough"`
+}
+
+// fileInstance - элемент списка allure.instances
+type fileInstance struct {
+	Name       string  `yaml:"name" toml:"name"`
+	BaseURL    string  `yaml:"baseUrl,omitempty" toml:"baseUrl,omitempty"`
+	APIURL     string  `yaml:"apiUrl,omitempty" toml:"apiUrl,omitempty"`
+	APIToken   string  `yaml:"apiToken,omitempty" toml:"apiToken,omitempty"`
+	ProjectID  int64   `yaml:"projectId,omitempty" toml:"projectId,omitempty"`
+	ProjectIDs []int64 `yaml:"projectIds,omitempty" toml:"projectIds,omitempty"`
+}
+
+// fileReportCache - секция reportCache
+type fileReportCache struct {
+	Dir   *string `yaml:"dir,omitempty" toml:"dir,omitempty"`
+	MaxMB *int64  `yaml:"maxMB,omitempty" toml:"maxMB,omitempty"`
+}
+
+// fileLogging - секция logging
+type fileLogging struct {
+	Level           *string  `yaml:"level,omitempty" toml:"level,omitempty"`
+	MaxBodyBytes    *int     `yaml:"maxBodyBytes,omitempty" toml:"maxBodyBytes,omitempty"`
+	SensitiveFields []string `yaml:"sensitiveFields,omitempty" toml:"sensitiveFields,omitempty"`
+}
+
+// fileAuth - секция auth
+type fileAuth struct {
+	Disabled *bool        `yaml:"disabled,omitempty" toml:"disabled,omitempty"`
+	APIKeys  []fileAPIKey `yaml:"apiKeys,omitempty" toml:"apiKeys,omitempty"`
+	JWT      struct {
+		JWKSURL         *string   `yaml:"jwksUrl,omitempty" toml:"jwksUrl,omitempty"`
+		JWKSFile        *string   `yaml:"jwksFile,omitempty" toml:"jwksFile,omitempty"`
+		JWKSRefresh     *Duration `yaml:"jwksRefresh,omitempty" toml:"jwksRefresh,omitempty"`
+		Issuer          *string   `yaml:"issuer,omitempty" toml:"issuer,omitempty"`
+		Audience        *string   `yaml:"audience,omitempty" toml:"audience,omitempty"`
+		ProjectsClaim   *string   `yaml:"projectsClaim,omitempty" toml:"projectsClaim,omitempty"`
+		OperationsClaim *string   `yaml:"operationsClaim,omitempty" toml:"operationsClaim,omitempty"`
+	} `yaml:"jwt,omitempty" toml:"jwt,omitempty"`
+}
+
+// fileAPIKey - элемент списка auth.apiKeys
+type fileAPIKey struct {
+	Name       string       `yaml:"name" toml:"name"`
+	Key        string       `yaml:"key,omitempty" toml:"key,omitempty"`
+	Projects   []ProjectRef `yaml:"projects,omitempty" toml:"projects,omitempty"`
+	Operations []string     `yaml:"operations,omitempty" toml:"operations,omitempty"`
+}
+
+// fileHTTP - секция http
+type fileHTTP struct {
+	CORS            fileCORS `yaml:"cors" toml:"cors"`
+	DownloadCORS    fileCORS `yaml:"downloadCors" toml:"downloadCors"`
+	SecurityHeaders struct {
+		HSTSMaxAge            *Duration `yaml:"hstsMaxAge,omitempty" toml:"hstsMaxAge,omitempty"`
+		HSTSIncludeSubdomains *bool     `yaml:"hstsIncludeSubdomains,omitempty" toml:"hstsIncludeSubdomains,omitempty"`
+		ContentTypeNosniff    *bool     `yaml:"contentTypeNosniff,omitempty" toml:"contentTypeNosniff,omitempty"`
+		FrameOptions          *string   `yaml:"frameOptions,omitempty" toml:"frameOptions,omitempty"`
+		ReferrerPolicy        *string   `yaml:"referrerPolicy,omitempty" toml:"referrerPolicy,omitempty"`
+	} `yaml:"securityHeaders" toml:"securityHeaders"`
+}
+
+// fileCORS - секции http.cors и http.downloadCors
+type fileCORS struct {
+	AllowOrigins     []string `yaml:"allowOrigins,omitempty" toml:"allowOrigins,omitempty"`
+	AllowMethods     []string `yaml:"allowMethods,omitempty" toml:"allowMethods,omitempty"`
+	AllowHeaders     []string `yaml:"allowHeaders,omitempty" toml:"allowHeaders,omitempty"`
+	ExposeHeaders    []string `yaml:"exposeHeaders,omitempty" toml:"exposeHeaders,omitempty"`
+	AllowCredentials *bool    `yaml:"allowCredentials,omitempty" toml:"allowCredentials,omitempty"`
+}
+
+// fileArchive - секция archive
+type fileArchive struct {
+	Backend *string `yaml:"backend,omitempty" toml:"backend,omitempty"`
+	Dir     *string `yaml:"dir,omitempty" toml:"dir,omitempty"`
+	S3      struct {
+		Endpoint  *string `yaml:"endpoint,omitempty" toml:"endpoint,omitempty"`
+		Region    *string `yaml:"region,omitempty" toml:"region,omitempty"`
+		Bucket    *string `yaml:"bucket,omitempty" toml:"bucket,omitempty"`
+		Prefix    *string `yaml:"prefix,omitempty" toml:"prefix,omitempty"`
+		AccessKey *string `yaml:"accessKey,omitempty" toml:"accessKey,omitempty"`
+		SecretKey *string `yaml:"secretKey,omitempty" toml:"secretKey,omitempty"`
+	} `yaml:"s3,omitempty" toml:"s3,omitempty"`
+}
+
+// applyFile - накладывает на конфигурацию файл path; формат определяется по расширению
+func (c *Config) applyFile(path string) error {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return fmt.Errorf("ошибка чтения файла конфигурации: %w", err)
+	}
+
+	var file fileConfig
+	switch strings.ToLower(filepath.Ext(path)) {
+	case ".yaml", ".yml":
+		decoder := yaml.NewDecoder(bytes.NewReader(data))
+		decoder.KnownFields(true)
+		if err := decoder.Decode(&file); err != nil && err != io.EOF {
+			return fmt.Errorf("ошибка разбора файла конфигурации %s: %w", path, err)
+		}
+	case ".toml":
+		meta, err := toml.Decode(string(data), &file)
+		if err != nil {
+			return fmt.Errorf("ошибка разбора файла конфигурации %s: %w", path, err)
+		}
+		if undecoded := meta.Undecoded(); len(undecoded) > 0 {
+			var errs ValidationError
+			for _, key := range undecoded {
+				errs.add(key.String(), "неизвестный параметр")
+			}
+			return errs
+		}
+	default:
+		return fmt.Errorf("файл конфигурации %s должен иметь расширение .yaml, .yml или .toml", path)
+	}
+
+	return c.merge(&file)
+}
+
+// merge - переносит в конфигурацию заданные в файле значения
+func (c *Config) merge(file *fileConfig) error {
+	if file.Server.Port != nil {
+		c.ServerPort = strconv.Itoa(*file.Server.Port)
+	}
+
+	allure := &file.Allure
+	mergeDuration(allure.TokenExpiry, &c.TokenExpiry)
+	mergeDuration(allure.TokenRefreshMargin, &c.TokenRefreshMargin)
+	mergeDuration(allure.Timeouts.Request, &c.Timeouts.Request)
+	mergeDuration(allure.Timeouts.List, &c.Timeouts.List)
+	mergeDuration(allure.Timeouts.Export, &c.Timeouts.Export)
+	mergeDuration(allure.Timeouts.Download, &c.Timeouts.Download)
+	if allure.Retry.MaxAttempts != nil {
+		c.Retry.MaxAttempts = *allure.Retry.MaxAttempts
+	}
+	mergeDuration(allure.Retry.InitialBackoff, &c.Retry.InitialBackoff)
+	mergeDuration(allure.Retry.MaxBackoff, &c.Retry.MaxBackoff)
+	if allure.CircuitBreaker.FailureThreshold != nil {
+		c.CircuitBreaker.FailureThreshold = *allure.CircuitBreaker.FailureThreshold
+	}
+	mergeDuration(allure.CircuitBreaker.OpenTimeout, &c.CircuitBreaker.OpenTimeout)
+	if allure.TokenPassthrough.Mode != nil {
+		c.TokenPassthrough.Mode = *allure.TokenPassthrough.Mode
+	}
+	if allure.TokenPassthrough.Header != nil {
+		c.TokenPassthrough.Header = *allure.TokenPassthrough.Header
+	}
+	if allure.TokenPassthrough.CacheSize != nil {
+		c.TokenPassthrough.CacheSize = *allure.TokenPassthrough.CacheSize
+	}
+
+	defaultInstance := fileInstance{
+		Name:       DefaultInstanceName,
+		BaseURL:    allure.BaseURL,
+		APIURL:     allure.APIURL,
+		APIToken:   allure.APIToken,
+		ProjectID:  allure.ProjectID,
+		ProjectIDs: allure.ProjectIDs,
+	}
+	hasDefault := defaultInstance.BaseURL != "" || defaultInstance.APIURL != "" || defaultInstance.APIToken != "" ||
+		defaultInstance.ProjectID != 0 || len(defaultInstance.ProjectIDs) > 0
+	switch {
+	case len(allure.Instances) > 0 && hasDefault:
+		return ValidationError{{Field: "allure.instances", Message: "при списке экземпляров параметры подключения задаются только внутри allure.instances"}}
+	case len(allure.Instances) > 0:
+		c.AllureInstances = nil
+		for _, instance := range allure.Instances {
+			c.AllureInstances = append(c.AllureInstances, instance.toInstance())
+		}
+	case hasDefault:
+		c.AllureInstances = []AllureInstance{defaultInstance.toInstance()}
+	}
+
+	mergeString(file.ReportCache.Dir, &c.ReportCacheDir)
+	if file.ReportCache.MaxMB != nil {
+		c.ReportCacheMaxSize = *file.ReportCache.MaxMB << 20
+	}
+
+	mergeString(file.Archive.Backend, &c.ArchiveBackend)
+	mergeString(file.Archive.Dir, &c.ArchiveDir)
+	mergeString(file.Archive.S3.Endpoint, &c.ArchiveS3.Endpoint)
+	mergeString(file.Archive.S3.Region, &c.ArchiveS3.Region)
+	mergeString(file.Archive.S3.Bucket, &c.ArchiveS3.Bucket)
+	mergeString(file.Archive.S3.Prefix, &c.ArchiveS3.Prefix)
+	mergeString(file.Archive.S3.AccessKey, &c.ArchiveS3.AccessKey)
+	mergeString(file.Archive.S3.SecretKey, &c.ArchiveS3.SecretKey)
+
+	mergeBool(file.Auth.Disabled, &c.Auth.Disabled)
+	for _, key := range file.Auth.APIKeys {
+		c.Auth.APIKeys = append(c.Auth.APIKeys, APIKey(key))
+	}
+	jwt := &file.Auth.JWT
+	mergeString(jwt.JWKSURL, &c.Auth.JWT.JWKSURL)
+	mergeString(jwt.JWKSFile, &c.Auth.JWT.JWKSFile)
+	mergeDuration(jwt.JWKSRefresh, &c.Auth.JWT.JWKSRefresh)
+	mergeString(jwt.Issuer, &c.Auth.JWT.Issuer)
+	mergeString(jwt.Audience, &c.Auth.JWT.Audience)
+	mergeString(jwt.ProjectsClaim, &c.Auth.JWT.ProjectsClaim)
+	mergeString(jwt.OperationsClaim, &c.Auth.JWT.OperationsClaim)
+
+	file.HTTP.CORS.merge(&c.HTTP.CORS)
+	file.HTTP.DownloadCORS.merge(&c.HTTP.DownloadCORS)
+	security := &file.HTTP.SecurityHeaders
+	mergeDuration(security.HSTSMaxAge, &c.HTTP.Security.HSTSMaxAge)
+	mergeBool(security.HSTSIncludeSubdomains, &c.HTTP.Security.HSTSIncludeSubdomains)
+	mergeBool(security.ContentTypeNosniff, &c.HTTP.Security.ContentTypeNosniff)
+	mergeString(security.FrameOptions, &c.HTTP.Security.FrameOptions)
+	mergeString(security.ReferrerPolicy, &c.HTTP.Security.ReferrerPolicy)
+
+	mergeString(file.Logging.Level, &c.Logging.Level)
+	if file.Logging.MaxBodyBytes != nil {
+		c.Logging.MaxBodyBytes = *file.Logging.MaxBodyBytes
+	}
+	if file.Logging.SensitiveFields != nil {
+		c.Logging.SensitiveFields = file.Logging.SensitiveFields
+	}
+
+	return nil
+}
+
+// merge - переносит в политику CORS заданные в файле значения
+func (f fileCORS) merge(target *CORS) {
+	if f.AllowOrigins != nil {
+		target.AllowOrigins = f.AllowOrigins
+	}
+	if f.AllowMethods != nil {
+		target.AllowMethods = f.AllowMethods
+	}
+	if f.AllowHeaders != nil {
+		target.AllowHeaders = f.AllowHeaders
+	}
+	if f.ExposeHeaders != nil {
+		target.ExposeHeaders = f.ExposeHeaders
+	}
+	mergeBool(f.AllowCredentials, &target.AllowCredentials)
+}
+
+// toInstance - экземпляр Allure из файла конфигурации
+func (f fileInstance) toInstance() AllureInstance {
+	instance := AllureInstance{
+		Name:      f.Name,
+		BaseURL:   f.BaseURL,
+		APIURL:    f.APIURL,
+		UserToken: f.APIToken,
+		Projects:  f.ProjectIDs,
+	}
+	if f.ProjectID != 0 {
+		instance.ProjectID = strconv.FormatInt(f.ProjectID, 10)
+	}
+	return instance
+}
+
+// WriteRedacted - выводит итоговую конфигурацию в формате YAML; токены и ключи скрываются
+func (c *Config) WriteRedacted(w io.Writer) error {
+	var file fileConfig
+
+	port, _ := strconv.Atoi(c.ServerPort)
+	file.Server.Port = &port
+
+	allure := &file.Allure
+	for _, instance := range c.AllureInstances {
+		projectID, _ := strconv.ParseInt(instance.ProjectID, 10, 64)
+		allure.Instances = append(allure.Instances, fileInstance{
+			Name:       instance.Name,
+			BaseURL:    instance.BaseURL,
+			APIURL:     instance.APIURL,
+			APIToken:   redact(instance.UserToken),
+			ProjectID:  projectID,
+			ProjectIDs: instance.Projects,
+		})
+	}
+	allure.TokenExpiry = durationPtr(c.TokenExpiry)
+	allure.TokenRefreshMargin = durationPtr(c.TokenRefreshMargin)
+	allure.Timeouts.Request = durationPtr(c.Timeouts.Request)
+	allure.Timeouts.List = durationPtr(c.Timeouts.List)
+	allure.Timeouts.Export = durationPtr(c.Timeouts.Export)
+	allure.Timeouts.Download = durationPtr(c.Timeouts.Download)
+	allure.Retry.MaxAttempts = &c.Retry.MaxAttempts
+	allure.Retry.InitialBackoff = durationPtr(c.Retry.InitialBackoff)
+	allure.Retry.MaxBackoff = durationPtr(c.Retry.MaxBackoff)
+	allure.CircuitBreaker.FailureThreshold = &c.CircuitBreaker.FailureThreshold
+	allure.CircuitBreaker.OpenTimeout = durationPtr(c.CircuitBreaker.OpenTimeout)
+	allure.TokenPassthrough.Mode = &c.TokenPassthrough.Mode
+	allure.TokenPassthrough.Header = &c.TokenPassthrough.Header
+	allure.TokenPassthrough.CacheSize = &c.TokenPassthrough.CacheSize
+
+	maxMB := c.ReportCacheMaxSize >> 20
+	file.ReportCache.Dir = &c.ReportCacheDir
+	file.ReportCache.MaxMB = &maxMB
+
+	if c.ArchiveBackend != "" {
+		file.Archive.Backend = &c.ArchiveBackend
+	}
+	switch c.ArchiveBackend {
+	case ArchiveBackendLocal:
+		file.Archive.Dir = &c.ArchiveDir
+	case ArchiveBackendS3:
+		s3 := c.ArchiveS3
+		accessKey, secretKey := redact(s3.AccessKey), redact(s3.SecretKey)
+		file.Archive.S3.Endpoint = &s3.Endpoint
+		file.Archive.S3.Region = &s3.Region
+		file.Archive.S3.Bucket = &s3.Bucket
+		file.Archive.S3.Prefix = &s3.Prefix
+		file.Archive.S3.AccessKey = &accessKey
+		file.Archive.S3.SecretKey = &secretKey
+	}
+
+	if c.Auth.Disabled {
+		file.Auth.Disabled = &c.Auth.Disabled
+	}
+	for _, key := range c.Auth.APIKeys {
+		key.Key = redact(key.Key)
+		file.Auth.APIKeys = append(file.Auth.APIKeys, fileAPIKey(key))
+	}
+	if jwt := c.Auth.JWT; jwt.Enabled() {
+		file.Auth.JWT.JWKSURL = &jwt.JWKSURL
+		file.Auth.JWT.JWKSFile = &jwt.JWKSFile
+		file.Auth.JWT.JWKSRefresh = durationPtr(jwt.JWKSRefresh)
+		file.Auth.JWT.Issuer = &jwt.Issuer
+		file.Auth.JWT.Audience = &jwt.Audience
+		file.Auth.JWT.ProjectsClaim = &jwt.ProjectsClaim
+		file.Auth.JWT.OperationsClaim = &jwt.OperationsClaim
+	}
+
+	file.HTTP.CORS = newFileCORS(c.HTTP.CORS)
+	file.HTTP.DownloadCORS = newFileCORS(c.HTTP.DownloadCORS)
+	security := c.HTTP.Security
+	file.HTTP.SecurityHeaders.HSTSMaxAge = durationPtr(security.HSTSMaxAge)
+	file.HTTP.SecurityHeaders.HSTSIncludeSubdomains = &security.HSTSIncludeSubdomains
+	file.HTTP.SecurityHeaders.ContentTypeNosniff = &security.ContentTypeNosniff
+	file.HTTP.SecurityHeaders.FrameOptions = &security.FrameOptions
+	file.HTTP.SecurityHeaders.ReferrerPolicy = &security.ReferrerPolicy
+
+	file.Logging.Level = &c.Logging.Level
+	file.Logging.MaxBodyBytes = &c.Logging.MaxBodyBytes
+	file.Logging.SensitiveFields = c.Logging.SensitiveFields
+
+	encoder := yaml.NewEncoder(w)
+	encoder.SetIndent(2)
+	if err := encoder.Encode(&file); err != nil {
+		return err
+	}
+	return encoder.Close()
+}
+
+// newFileCORS - политика CORS в виде секции файла конфигурации
+func newFileCORS(policy CORS) fileCORS {
+	return fileCORS{
+		AllowOrigins:     policy.AllowOrigins,
+		AllowMethods:     policy.AllowMethods,
+		AllowHeaders:     policy.AllowHeaders,
+		ExposeHeaders:    policy.ExposeHeaders,
+		AllowCredentials: &policy.AllowCredentials,
+	}
+}
+
+// redact - скрывает непустой секрет
+func redact(secret string) string {
+	if secret == "" {
+		return ""
+	}
+	return redacted
+}
+
+func mergeString(value *string, target *string) {
+	if value != nil {
+		*target = *value
+	}
+}
+
+func mergeBool(value *bool, target *bool) {
+	if value != nil {
+		*target = *value
+	}
+}
+
+func mergeDuration(value *Duration, target *time.Duration) {
+	if value != nil {
+		*target = time.Duration(*value)
+	}
+}
+
+func durationPtr(value time.Duration) *Duration {
+	d := Duration(value)
+	return &d
+}
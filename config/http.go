@@ -0,0 +1,155 @@
+package config
+
+import (
+	"fmt"
+	"net/url"
+	"slices"
+	"strings"
+	"time"
+)
+
+// HTTP - заголовки ответов сервиса: CORS и заголовки безопасности
+type HTTP struct {
+	CORS         CORS // Маршруты API
+	DownloadCORS CORS // Скачивание файлов: вложения, PDF-отчеты, архивные отчеты, JUnit XML
+	Security     SecurityHeaders
+}
+
+// CORS - политика CORS для группы маршрутов
+type CORS struct {
+	AllowOrigins     []string // Разрешенные источники вида https://app.example.com, "*" - любые
+	AllowMethods     []string
+	AllowHeaders     []string // Заголовки запроса, которые может передать браузер
+	ExposeHeaders    []string // Заголовки ответа, доступные скрипту на странице
+	AllowCredentials bool     // Разрешены ли cookie и заголовок Authorization браузера
+}
+
+// SecurityHeaders - заголовки безопасности во всех ответах
+type SecurityHeaders struct {
+	HSTSMaxAge            time.Duration // Strict-Transport-Security, 0 - заголовок не отправляется
+	HSTSIncludeSubdomains bool
+	ContentTypeNosniff    bool   // X-Content-Type-Options: nosniff
+	FrameOptions          string // X-Frame-Options: DENY или SAMEORIGIN, пусто - не отправляется
+	ReferrerPolicy        string // Referrer-Policy, пусто - не отправляется
+}
+
+// HSTS - значение заголовка Strict-Transport-Security
+func (s SecurityHeaders) HSTS() string {
+	if s.HSTSMaxAge <= 0 {
+		return ""
+	}
+	value := fmt.Sprintf("max-age=%d", int64(s.HSTSMaxAge/time.Second))
+	if s.HSTSIncludeSubdomains {
+		value += "; includeSubDomains"
+	}
+	return value
+}
+
+// corsMethods - методы, которые можно разрешить в CORS
+var corsMethods = []string{"GET", "HEAD", "POST", "PUT", "PATCH", "DELETE", "OPTIONS"}
+
+// frameOptions - допустимые значения X-Frame-Options
+var frameOptions = []string{"DENY", "SAMEORIGIN"}
+
+// defaultHTTP - политики по умолчанию: любые источники без учетных данных браузера
+func defaultHTTP() HTTP {
+	return HTTP{
+		CORS: CORS{
+			AllowOrigins:  []string{"*"},
+			AllowMethods:  []string{"GET", "HEAD", "POST"},
+			AllowHeaders:  []string{"Origin", "Content-Type", "Accept", "Authorization", "X-API-Key", "X-Request-ID"},
+			ExposeHeaders: []string{"X-Request-ID"},
+		},
+		DownloadCORS: CORS{
+			AllowOrigins:  []string{"*"},
+			AllowMethods:  []string{"GET", "HEAD"},
+			AllowHeaders:  []string{"Origin", "Accept", "Authorization", "X-API-Key", "X-Request-ID", "Range", "If-None-Match"},
+			ExposeHeaders: []string{"Content-Disposition", "Content-Length", "Content-Range", "Accept-Ranges", "ETag", "X-Request-ID"},
+		},
+		Security: SecurityHeaders{
+			ContentTypeNosniff: true,
+			FrameOptions:       "DENY",
+			ReferrerPolicy:     "no-referrer",
+		},
+	}
+}
+
+// applyEnv - переопределяет политики переменными CORS_*, DOWNLOAD_CORS_* и SECURITY_*
+func (h *HTTP) applyEnv(errs *ValidationError) {
+	h.CORS.applyEnv("CORS_", "http.cors", errs)
+	h.DownloadCORS.applyEnv("DOWNLOAD_CORS_", "http.downloadCors", errs)
+
+	envDuration("SECURITY_HSTS_MAX_AGE", "http.securityHeaders.hstsMaxAge", &h.Security.HSTSMaxAge, errs)
+	envBool("SECURITY_HSTS_INCLUDE_SUBDOMAINS", "http.securityHeaders.hstsIncludeSubdomains", &h.Security.HSTSIncludeSubdomains, errs)
+	envBool("SECURITY_CONTENT_TYPE_NOSNIFF", "http.securityHeaders.contentTypeNosniff", &h.Security.ContentTypeNosniff, errs)
+	envString("SECURITY_FRAME_OPTIONS", &h.Security.FrameOptions)
+	envString("SECURITY_REFERRER_POLICY", &h.Security.ReferrerPolicy)
+}
+
+// applyEnv - переопределяет политику переменными <prefix>ALLOW_ORIGINS, ALLOW_METHODS и другими;
+// списки задаются через запятую
+func (c *CORS) applyEnv(prefix, field string, errs *ValidationError) {
+	envList(prefix+"ALLOW_ORIGINS", &c.AllowOrigins)
+	envList(prefix+"ALLOW_METHODS", &c.AllowMethods)
+	envList(prefix+"ALLOW_HEADERS", &c.AllowHeaders)
+	envList(prefix+"EXPOSE_HEADERS", &c.ExposeHeaders)
+	envBool(prefix+"ALLOW_CREDENTIALS", field+".allowCredentials", &c.AllowCredentials, errs)
+}
+
+// validate - проверяет политики; заголовок с API-токеном Allure вызывающего добавляется
+// в разрешенные заголовки запроса, если передача токенов включена
+func (h *HTTP) validate(passthrough TokenPassthrough, errs *ValidationError) {
+	h.CORS.validate("CORS_", "http.cors", errs)
+	h.DownloadCORS.validate("DOWNLOAD_CORS_", "http.downloadCors", errs)
+	if passthrough.Enabled() {
+		h.CORS.allowHeader(passthrough.Header)
+		h.DownloadCORS.allowHeader(passthrough.Header)
+	}
+
+	if h.Security.HSTSMaxAge < 0 {
+		errs.add("http.securityHeaders.hstsMaxAge", "значение должно быть неотрицательным (SECURITY_HSTS_MAX_AGE)")
+	}
+	if h.Security.FrameOptions != "" && !slices.Contains(frameOptions, h.Security.FrameOptions) {
+		errs.add("http.securityHeaders.frameOptions", "значение может быть %s или пустым (SECURITY_FRAME_OPTIONS), получено %q", strings.Join(frameOptions, ", "), h.Security.FrameOptions)
+	}
+}
+
+// validate - проверяет источники и методы политики
+func (c *CORS) validate(prefix, field string, errs *ValidationError) {
+	if len(c.AllowOrigins) == 0 {
+		errs.add(field+".allowOrigins", "нужно указать хотя бы один источник или \"*\" (%sALLOW_ORIGINS)", prefix)
+	}
+	for _, origin := range c.AllowOrigins {
+		switch {
+		case origin == "*" && len(c.AllowOrigins) > 1:
+			errs.add(field+".allowOrigins", "\"*\" нельзя сочетать с другими источниками (%sALLOW_ORIGINS)", prefix)
+		case origin == "*" && c.AllowCredentials:
+			errs.add(field+".allowOrigins", "при разрешенных учетных данных (%sALLOW_CREDENTIALS) источники нужно перечислить явно, \"*\" недопустим", prefix)
+		case origin != "*" && !validOrigin(origin):
+			errs.add(field+".allowOrigins", "источник должен иметь вид https://app.example.com или https://*.example.com (%sALLOW_ORIGINS), получено %q", prefix, origin)
+		}
+	}
+
+	if len(c.AllowMethods) == 0 {
+		errs.add(field+".allowMethods", "нужно указать хотя бы один метод (%sALLOW_METHODS)", prefix)
+	}
+	for _, method := range c.AllowMethods {
+		if !slices.Contains(corsMethods, method) {
+			errs.add(field+".allowMethods", "метод может быть %s (%sALLOW_METHODS), получено %q", strings.Join(corsMethods, ", "), prefix, method)
+		}
+	}
+}
+
+// allowHeader - добавляет заголовок в разрешенные, если его там нет
+func (c *CORS) allowHeader(header string) {
+	if !slices.ContainsFunc(c.AllowHeaders, func(allowed string) bool { return strings.EqualFold(allowed, header) }) {
+		c.AllowHeaders = append(c.AllowHeaders, header)
+	}
+}
+
+// validOrigin - источник: схема http или https и хост без пути; поддомены задаются как https://*.example.com
+func validOrigin(origin string) bool {
+	parsed, err := url.Parse(strings.Replace(origin, "://*.", "://", 1))
+	return err == nil && (parsed.Scheme == "http" || parsed.Scheme == "https") && parsed.Host != "" &&
+		parsed.Path == "" && parsed.RawQuery == "" && parsed.Fragment == "" && parsed.User == nil
+}
@@ -0,0 +1,188 @@
+package config
+
+import (
+	"fmt"
+	"regexp"
+	"slices"
+	"strconv"
+	"strings"
+)
+
+// FieldError - ошибка в значении одного параметра конфигурации
+type FieldError struct {
+	Field   string // Путь к параметру в файле конфигурации, например allure.timeouts.export
+	Message string
+}
+
+func (e FieldError) Error() string {
+	return e.Field + ": " + e.Message
+}
+
+// ValidationError - все ошибки конфигурации, найденные при загрузке
+type ValidationError []FieldError
+
+func (e ValidationError) Error() string {
+	lines := make([]string, 0, len(e))
+	for _, fieldErr := range e {
+		lines = append(lines, fieldErr.Error())
+	}
+	return "некорректная конфигурация: " + strings.Join(lines, "; ")
+}
+
+// add - добавляет ошибку параметра field
+func (e *ValidationError) add(field, format string, args ...any) {
+	*e = append(*e, FieldError{Field: field, Message: fmt.Sprintf(format, args...)})
+}
+
+// logLevels - допустимые уровни логов
+var logLevels = []string{"debug", "info", "warn", "error"}
+
+// instanceNamePattern - допустимое имя экземпляра: оно становится частью URL и пути к кэшу
+var instanceNamePattern = regexp.MustCompile(`^[a-z0-9][a-z0-9_-]*$`)
+
+// validate - проверяет итоговую конфигурацию и дополняет производные значения
+func (c *Config) validate(errs *ValidationError) {
+	if port, err := strconv.Atoi(c.ServerPort); err != nil || port <= 0 || port > 65535 {
+		errs.add("server.port", "порт должен быть числом от 1 до 65535 (SERVER_PORT), получено %q", c.ServerPort)
+	}
+
+	positive := []struct {
+		field, env string
+		value      int64
+	}{
+		{"allure.tokenExpiry", "ALLURE_TOKEN_EXPIRY", int64(c.TokenExpiry)},
+		{"allure.timeouts.request", "ALLURE_TIMEOUT_REQUEST", int64(c.Timeouts.Request)},
+		{"allure.timeouts.list", "ALLURE_TIMEOUT_LIST", int64(c.Timeouts.List)},
+		{"allure.timeouts.export", "ALLURE_TIMEOUT_EXPORT", int64(c.Timeouts.Export)},
+		{"allure.timeouts.download", "ALLURE_TIMEOUT_DOWNLOAD", int64(c.Timeouts.Download)},
+		{"allure.retry.maxAttempts", "ALLURE_RETRY_MAX_ATTEMPTS", int64(c.Retry.MaxAttempts)},
+		{"allure.retry.initialBackoff", "ALLURE_RETRY_INITIAL_BACKOFF", int64(c.Retry.InitialBackoff)},
+		{"allure.circuitBreaker.openTimeout", "ALLURE_CIRCUIT_OPEN_TIMEOUT", int64(c.CircuitBreaker.OpenTimeout)},
+		{"allure.tokenPassthrough.cacheSize", "ALLURE_TOKEN_CACHE_SIZE", int64(c.TokenPassthrough.CacheSize)},
+	}
+	for _, param := range positive {
+		if param.value <= 0 {
+			errs.add(param.field, "значение должно быть положительным (%s)", param.env)
+		}
+	}
+	// Нулевой порог отключает автомат защиты
+	if c.CircuitBreaker.FailureThreshold < 0 {
+		errs.add("allure.circuitBreaker.failureThreshold", "значение должно быть неотрицательным, 0 отключает автомат защиты (ALLURE_CIRCUIT_FAILURE_THRESHOLD)")
+	}
+	if c.TokenRefreshMargin < 0 || c.TokenRefreshMargin >= c.TokenExpiry {
+		errs.add("allure.tokenRefreshMargin", "запас обновления токена должен быть неотрицательным и меньше allure.tokenExpiry (ALLURE_TOKEN_REFRESH_MARGIN), получено %s", c.TokenRefreshMargin)
+	}
+	if c.Retry.MaxBackoff < c.Retry.InitialBackoff {
+		errs.add("allure.retry.maxBackoff", "предельная пауза не может быть меньше allure.retry.initialBackoff (ALLURE_RETRY_MAX_BACKOFF), получено %s", c.Retry.MaxBackoff)
+	}
+
+	if !slices.Contains(tokenPassthroughModes, c.TokenPassthrough.Mode) {
+		errs.add("allure.tokenPassthrough.mode", "режим может быть %s (ALLURE_TOKEN_PASSTHROUGH), получено %q", strings.Join(tokenPassthroughModes, ", "), c.TokenPassthrough.Mode)
+	}
+	if c.TokenPassthrough.Header == "" {
+		errs.add("allure.tokenPassthrough.header", "обязательный параметр не задан (ALLURE_TOKEN_PASSTHROUGH_HEADER)")
+	}
+
+	if c.ReportCacheMaxSize < 0 {
+		errs.add("reportCache.maxMB", "размер кэша должен быть неотрицательным (REPORT_CACHE_MAX_MB)")
+	}
+
+	if !slices.Contains(logLevels, c.Logging.Level) {
+		errs.add("logging.level", "уровень логов может быть %s (LOG_LEVEL), получено %q", strings.Join(logLevels, ", "), c.Logging.Level)
+	}
+	if c.Logging.MaxBodyBytes < 0 {
+		errs.add("logging.maxBodyBytes", "размер тела в логе должен быть неотрицательным (LOG_MAX_BODY_BYTES)")
+	}
+
+	// Открытый API - только по явному решению: иначе забытые ключи открыли бы экспорт и архив всем
+	switch {
+	case !c.Auth.Disabled && !c.Auth.Enabled():
+		errs.add("auth", "не заданы ни API-ключи (AUTH_API_KEYS), ни ключи JWT (AUTH_JWKS_URL, AUTH_JWKS_FILE); "+
+			"чтобы открыть API без аутентификации, задайте AUTH_DISABLED=true")
+	case c.Auth.Disabled && c.Auth.Enabled():
+		errs.add("auth.disabled", "аутентификация отключена, но заданы API-ключи или ключи JWT (AUTH_DISABLED)")
+	}
+	c.Auth.validate(c.AllureInstances, errs)
+	c.HTTP.validate(c.TokenPassthrough, errs)
+
+	switch c.ArchiveBackend {
+	case "":
+	case ArchiveBackendLocal:
+		if c.ArchiveDir == "" {
+			errs.add("archive.dir", "для хранилища local нужно задать каталог архива (ARCHIVE_DIR)")
+		}
+	case ArchiveBackendS3:
+		if c.ArchiveS3.Endpoint == "" {
+			errs.add("archive.s3.endpoint", "для хранилища s3 нужно задать адрес хранилища (ARCHIVE_S3_ENDPOINT)")
+		}
+		if c.ArchiveS3.Bucket == "" {
+			errs.add("archive.s3.bucket", "для хранилища s3 нужно задать бакет (ARCHIVE_S3_BUCKET)")
+		}
+	default:
+		errs.add("archive.backend", "хранилище может быть local или s3 (ARCHIVE_BACKEND), получено %q", c.ArchiveBackend)
+	}
+
+	for i := range c.AllureInstances {
+		instance := &c.AllureInstances[i]
+		field := c.instanceField(i)
+
+		if !instanceNamePattern.MatchString(instance.Name) {
+			errs.add(field+".name", "имя экземпляра должно состоять из строчных латинских букв, цифр, '-' и '_', получено %q", instance.Name)
+		}
+		if slices.ContainsFunc(c.AllureInstances[:i], func(other AllureInstance) bool { return other.Name == instance.Name }) {
+			errs.add(field+".name", "экземпляр %q указан дважды", instance.Name)
+		}
+		instance.validate(field, errs)
+	}
+}
+
+// validate - проверяет параметры экземпляра; первый из разрешенных проектов становится
+// проектом по умолчанию, если он не задан, а проект по умолчанию всегда разрешен
+func (instance *AllureInstance) validate(field string, errs *ValidationError) {
+	prefix := instance.EnvPrefix()
+
+	required := []struct {
+		name, env string
+		value     string
+	}{
+		{"baseUrl", "BASE_URL", instance.BaseURL},
+		{"apiUrl", "API_URL", instance.APIURL},
+		{"apiToken", "API_TOKEN", instance.UserToken},
+	}
+	for _, param := range required {
+		if param.value == "" {
+			errs.add(field+"."+param.name, "обязательный параметр не задан (%s%s)", prefix, param.env)
+		}
+	}
+
+	for _, projectID := range instance.Projects {
+		if projectID <= 0 {
+			errs.add(field+".projectIds", "ID проектов должны быть положительными (%sPROJECT_IDS), получено %d", prefix, projectID)
+		}
+	}
+	if instance.ProjectID == "" && len(instance.Projects) > 0 {
+		instance.ProjectID = strconv.FormatInt(instance.Projects[0], 10)
+	}
+	if instance.ProjectID == "" {
+		errs.add(field+".projectId", "обязательный параметр не задан (%sPROJECT_ID или %sPROJECT_IDS)", prefix, prefix)
+		return
+	}
+
+	projectID, err := strconv.ParseInt(instance.ProjectID, 10, 64)
+	if err != nil || projectID <= 0 {
+		errs.add(field+".projectId", "ID проекта должен быть положительным целым числом (%sPROJECT_ID), получено %q", prefix, instance.ProjectID)
+		return
+	}
+	if !slices.Contains(instance.Projects, projectID) {
+		instance.Projects = append([]int64{projectID}, instance.Projects...)
+	}
+}
+
+// instanceField - путь к параметрам экземпляра в файле конфигурации.
+// Единственный экземпляр default задается прямо в секции allure.
+func (c *Config) instanceField(index int) string {
+	if len(c.AllureInstances) == 1 && c.AllureInstances[0].Name == DefaultInstanceName {
+		return "allure"
+	}
+	return fmt.Sprintf("allure.instances[%d]", index)
+}
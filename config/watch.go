@@ -0,0 +1,68 @@
+package config
+
+import (
+	"context"
+	"os"
+	"os/signal"
+	"syscall"
+	"time"
+)
+
+// DefaultWatchInterval - период проверки файла конфигурации на изменения
+const DefaultWatchInterval = 5 * time.Second
+
+// Watch - вызывает reload при получении SIGHUP и при изменении файла конфигурации path,
+// который проверяется раз в interval. Пустой path - только SIGHUP. Завершается с отменой ctx.
+func Watch(ctx context.Context, path string, interval time.Duration, reload func()) {
+	signals := make(chan os.Signal, 1)
+	signal.Notify(signals, syscall.SIGHUP)
+	defer signal.Stop(signals)
+
+	var ticks <-chan time.Time
+	var last fileVersion
+	if path != "" {
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+		ticks = ticker.C
+		last = statFile(path)
+	}
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-signals:
+			if path != "" {
+				last = statFile(path)
+			}
+			reload()
+		case <-ticks:
+			// Время изменения может совпасть при быстрой перезаписи, поэтому сравнивается и размер
+			if current := statFile(path); !current.same(last) {
+				last = current
+				reload()
+			}
+		}
+	}
+}
+
+// fileVersion - признаки, по которым замечается изменение файла
+type fileVersion struct {
+	modTime time.Time
+	size    int64
+	exists  bool
+}
+
+// same - файл не изменился
+func (v fileVersion) same(other fileVersion) bool {
+	return v.exists == other.exists && v.size == other.size && v.modTime.Equal(other.modTime)
+}
+
+// statFile - текущая версия файла; отсутствующий файл - тоже версия
+func statFile(path string) fileVersion {
+	info, err := os.Stat(path)
+	if err != nil {
+		return fileVersion{}
+	}
+	return fileVersion{modTime: info.ModTime(), size: info.Size(), exists: true}
+}
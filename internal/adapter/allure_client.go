@@ -3,175 +3,420 @@ package adapter
 import (
 	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
+	"mime"
 	"net/http"
+	"path"
+	"strconv"
+	"strings"
 	"sync"
+	"sync/atomic"
 	"time"
 
 	"github.com/go-resty/resty/v2"
 	"github.com/rs/zerolog/log"
 	"github.com/vkr-mtuci/allure-service/config"
+	"github.com/vkr-mtuci/allure-service/internal/logging"
+	"github.com/vkr-mtuci/allure-service/internal/reqctx"
 )
 
 // AllureClientInterface - интерфейс клиента Allure API
 type AllureClientInterface interface {
 	Authenticate(ctx context.Context) error
-	GetLaunches(ctx context.Context) ([]Launch, error)
-	GeneratePDFReport(ctx context.Context, launchID int64, launchName string) (*PDFReport, error)
+	GetProjects(ctx context.Context) ([]Project, error)
+	GetLaunches(ctx context.Context, query LaunchQuery) ([]Launch, error)
+	IterateLaunches(ctx context.Context, query LaunchQuery, fn func(Launch) bool) error
+	SearchLaunches(ctx context.Context, query LaunchQuery) (*LaunchPage, error)
+	GetLaunch(ctx context.Context, launchID int64) (*Launch, error)
+	GetLaunchStatistic(ctx context.Context, launchID int64) (*LaunchStatistic, error)
+	GetLaunchDuration(ctx context.Context, launchID int64) (int64, error)
+	GetLaunchEnvironment(ctx context.Context, launchID int64) ([]EnvVarValue, error)
+	GetLaunchJobRun(ctx context.Context, launchID int64) (*JobRun, error)
+	SearchTestResults(ctx context.Context, query TestResultQuery) (*TestResultPage, error)
+	IterateTestResults(ctx context.Context, query TestResultQuery, fn func(TestResult) bool) error
+	GetTestResult(ctx context.Context, resultID int64) (*TestResult, error)
+	GetTestResultExecution(ctx context.Context, resultID int64) (*Step, error)
+	DownloadAttachment(ctx context.Context, attachmentID int64, rangeHeader string) (*FileContent, error)
+	GeneratePDFReport(ctx context.Context, launchID int64, launchName string, opts PDFExportOptions) (*PDFReport, error)
+	GetPDFReport(ctx context.Context, reportID string) (*PDFReport, error)
 	GetPDFDownloadLink(reportID string) string
-	DownloadPDFReport(ctx context.Context, reportID string) ([]byte, string, error)
+	DownloadPDFReport(ctx context.Context, reportID string) (*FileContent, error)
 }
 
+const (
+	// launchPageSize - размер страницы при постраничном обходе запусков
+	launchPageSize = 100
+	// defaultLaunchSort - сортировка запусков, если в запросе она не указана
+	defaultLaunchSort = "createdDate,DESC"
+)
+
+var (
+	// ErrNotFound - запрошенный объект не найден в Allure
+	ErrNotFound = errors.New("объект не найден в Allure")
+	// ErrRangeNotSatisfiable - запрошенный диапазон байт выходит за пределы файла
+	ErrRangeNotSatisfiable = errors.New("некорректный диапазон")
+	// ErrForbidden - Allure запретил доступ к объекту пользователю, от имени которого выполнен запрос
+	ErrForbidden = errors.New("доступ к объекту в Allure запрещен")
+	// ErrUserTokenRejected - Allure не принял API-токен вызывающего
+	ErrUserTokenRejected = errors.New("Allure не принял API-токен вызывающего")
+
+	// errTokenRejected - Allure не принял API-токен при получении токена доступа
+	errTokenRejected = errors.New("API-токен отклонен")
+)
+
 // AllureClient - клиент API Allure
 type AllureClient struct {
+	conn    atomic.Pointer[connection]
+	mu      sync.Mutex     // Защищает refresh, refreshTimer и замену настроек; сетевые запросы под ним не выполняются
+	breaker circuitBreaker // Переживает замену настроек: здоровье Allure от них не зависит
+	users   *userTokens    // Токены доступа вызывающих, передавших свой API-токен
+
+	refresh      *tokenRefresh // Обновление токена, которое выполняется сейчас
+	refreshTimer *time.Timer   // Плановое обновление токена до истечения
+}
+
+// connection - неизменяемый снимок настроек подключения и токена доступа.
+// Запрос берет снимок один раз, поэтому замена настроек не прерывает начатые запросы.
+type connection struct {
 	client       *resty.Client
+	downloads    *resty.Client // Клиент потоковых скачиваний: его таймаут покрывает и чтение тела
 	baseURL      string
 	apiURL       string
-	token        string
-	tokenExpires time.Time
 	projectID    string
 	cfg          *config.Config
-	mu           sync.Mutex // Добавляем мьютекс
+	token        string
+	tokenExpires time.Time
 }
 
-// NewAllureClient - создание клиента API Allure
+// NewAllureClient - создание клиента API Allure.
+// Нулевые таймауты в cfg означают отсутствие ограничения, нулевые повторы и
+// автомат защиты - их отсутствие.
 func NewAllureClient(cfg *config.Config) *AllureClient {
-	client := resty.New().
+	a := &AllureClient{users: newUserTokens(cfg.TokenPassthrough.CacheSize)}
+	a.conn.Store(newConnection(cfg))
+	return a
+}
+
+// newConnection - подключение с настройками cfg, токен доступа еще не получен
+func newConnection(cfg *config.Config) *connection {
+	client := withDebugLog(resty.New()).
 		SetBaseURL(cfg.AllureBaseURL).
-		SetTimeout(10*time.Second).
-		SetHeader("Accept", "application/json")
+		SetTimeout(cfg.Timeouts.Request).
+		SetHeader("Accept", "application/json").
+		OnBeforeRequest(forwardRequestID)
 
-	return &AllureClient{
+	downloads := withDebugLog(resty.New()).
+		SetBaseURL(cfg.AllureBaseURL).
+		SetTimeout(cfg.Timeouts.Download).
+		OnBeforeRequest(forwardRequestID)
+
+	return &connection{
 		client:    client,
+		downloads: downloads,
 		baseURL:   cfg.AllureBaseURL,
 		apiURL:    cfg.AllureAPIURL,
-		token:     cfg.AllureUserToken,
 		projectID: cfg.AllureProjectID,
 		cfg:       cfg,
 	}
 }
 
-// Authenticate - проверяет и обновляет токен, если он истек
-func (a *AllureClient) Authenticate(ctx context.Context) error {
+// forwardRequestID - передает в Allure ID входящего запроса из контекста, чтобы связать логи
+func forwardRequestID(_ *resty.Client, r *resty.Request) error {
+	if requestID := reqctx.RequestID(r.Context()); requestID != "" {
+		r.SetHeader(reqctx.RequestIDHeader, requestID)
+	}
+	return nil
+}
+
+// Reload - заменяет настройки подключения без перезапуска. Начатые запросы завершаются
+// со старыми настройками; токен доступа сохраняется, если не изменились адрес и API-токен.
+func (a *AllureClient) Reload(cfg *config.Config) {
 	a.mu.Lock()
 	defer a.mu.Unlock()
 
-	// Если токен еще валиден, используем его
-	if time.Until(a.tokenExpires) > 5*time.Minute {
-		return nil
+	previous := a.conn.Load()
+	conn := newConnection(cfg)
+	if sameCredentials(previous, conn) {
+		conn.token = previous.token
+		conn.tokenExpires = previous.tokenExpires
+	} else {
+		// Начатое обновление получит токен для старых учетных данных, новые запросы его не ждут
+		a.refresh = nil
+		log.Info().Msg("🔑 Учетные данные Allure изменились, токен будет получен заново")
 	}
+	a.conn.Store(conn)
+	// Плановое обновление старого снимка отменяется; для сохраненного токена оно планируется с новым запасом
+	a.scheduleRefresh(conn)
+	a.users.resize(cfg.TokenPassthrough.CacheSize)
+}
 
-	log.Info().Msg("🔄 Обновление токена Allure API...")
-
-	// Отправляем запрос на обновление токена
-	resp, err := a.client.R().
-		SetContext(ctx).
-		SetFormData(map[string]string{
-			"grant_type": "apitoken",
-			"scope":      "openid",
-			"token":      a.cfg.AllureUserToken,
-		}).
-		Post(a.baseURL + "/api/uaa/oauth/token")
+// Authenticate - проверяет и обновляет токен, если он истек или скоро истечет
+func (a *AllureClient) Authenticate(ctx context.Context) error {
+	_, err := a.session(ctx)
+	return err
+}
 
+// GetLaunches - получает все запуски, подходящие под фильтры запроса (Page и Size игнорируются)
+func (a *AllureClient) GetLaunches(ctx context.Context, query LaunchQuery) ([]Launch, error) {
+	var launches []Launch
+	err := a.IterateLaunches(ctx, query, func(launch Launch) bool {
+		launches = append(launches, launch)
+		return true
+	})
 	if err != nil {
-		log.Error().Err(err).Msg("❌ Ошибка обновления токена")
-		return fmt.Errorf("ошибка обновления токена: %w", err)
+		return nil, err
 	}
 
-	// Логируем полный ответ от Allure API
-	log.Info().Msgf("📨 Ответ от Allure API (токен): статус %d, тело: %s", resp.StatusCode(), resp.String())
+	return launches, nil
+}
 
-	// Проверяем статус ответа
-	if resp.StatusCode() != http.StatusOK {
-		return fmt.Errorf("ошибка обновления токена: статус %d", resp.StatusCode())
+// IterateLaunches - постранично обходит запуски, подходящие под фильтры запроса, и передает их в fn.
+// Обход прекращается, когда fn возвращает false или закончились страницы.
+func (a *AllureClient) IterateLaunches(ctx context.Context, query LaunchQuery, fn func(Launch) bool) error {
+	if query.Size <= 0 {
+		query.Size = launchPageSize
 	}
 
-	// Парсим JSON-ответ
-	var authResp struct {
-		AccessToken string `json:"access_token"`
-		ExpiresIn   int    `json:"expires_in"`
+	for query.Page = 0; ; query.Page++ {
+		launchPage, err := a.SearchLaunches(ctx, query)
+		if err != nil {
+			return err
+		}
+
+		for _, launch := range launchPage.Content {
+			if !fn(launch) {
+				return nil
+			}
+		}
+
+		if launchPage.Last || len(launchPage.Content) == 0 || query.Page+1 >= launchPage.TotalPages {
+			return nil
+		}
 	}
-	if err := json.Unmarshal(resp.Body(), &authResp); err != nil {
-		log.Error().Err(err).Msg("❌ Ошибка парсинга токена")
-		return err
+}
+
+// SearchLaunches - получает одну страницу запусков, отфильтрованных и отсортированных на стороне Allure
+func (a *AllureClient) SearchLaunches(ctx context.Context, query LaunchQuery) (*LaunchPage, error) {
+	size := query.Size
+	if size <= 0 {
+		size = launchPageSize
+	}
+	sort := query.Sort
+	if sort == "" {
+		sort = defaultLaunchSort
 	}
 
-	// Сохраняем новый токен
-	a.token = authResp.AccessToken
-	a.tokenExpires = time.Now().Add(time.Duration(authResp.ExpiresIn) * time.Second)
-	log.Info().Msg("✅ Токен успешно обновлен!")
-	return nil
+	projectID := a.conn.Load().projectID
+	if query.ProjectID != 0 {
+		projectID = strconv.FormatInt(query.ProjectID, 10)
+	}
+
+	params := map[string]string{
+		"projectId": projectID,
+		"page":      strconv.Itoa(query.Page),
+		"size":      strconv.Itoa(size),
+		"sort":      sort,
+	}
+
+	// Фильтры передаются через RQL в поисковый эндпоинт
+	path := "launch"
+	if rql := query.rql(); rql != "" {
+		path += "/__search"
+		params["rql"] = rql
+	}
+
+	var launchPage LaunchPage
+	if err := a.getJSON(ctx, path, params, &launchPage); err != nil {
+		return nil, err
+	}
+
+	return &launchPage, nil
 }
 
-// GetLaunches - получает список запусков
-func (a *AllureClient) GetLaunches(ctx context.Context) ([]Launch, error) {
-	// Убеждаемся, что токен актуален
-	if err := a.Authenticate(ctx); err != nil {
+// GetLaunch - получает запуск по ID
+func (a *AllureClient) GetLaunch(ctx context.Context, launchID int64) (*Launch, error) {
+	var launch Launch
+	if err := a.getJSON(ctx, fmt.Sprintf("launch/%d", launchID), nil, &launch); err != nil {
 		return nil, err
 	}
 
-	url := fmt.Sprintf("%s%slaunch?projectId=%s&page=0&size=100", a.baseURL, a.apiURL, a.projectID)
+	return &launch, nil
+}
+
+// GetLaunchStatistic - получает количество тестов запуска по статусам
+func (a *AllureClient) GetLaunchStatistic(ctx context.Context, launchID int64) (*LaunchStatistic, error) {
+	var counts []StatusCount
+	if err := a.getJSON(ctx, fmt.Sprintf("launch/%d/statistic", launchID), nil, &counts); err != nil {
+		return nil, err
+	}
+
+	statistic := &LaunchStatistic{}
+	for _, item := range counts {
+		switch strings.ToLower(item.Status) {
+		case StatusPassed:
+			statistic.Passed += item.Count
+		case StatusFailed:
+			statistic.Failed += item.Count
+		case StatusBroken:
+			statistic.Broken += item.Count
+		case StatusSkipped:
+			statistic.Skipped += item.Count
+		default:
+			statistic.Unknown += item.Count
+		}
+		statistic.Total += item.Count
+	}
+
+	return statistic, nil
+}
 
-	resp, err := a.client.R().
-		SetContext(ctx).
-		SetAuthToken(a.token).
-		Get(url)
+// GetLaunchDuration - получает длительность запуска в миллисекундах
+func (a *AllureClient) GetLaunchDuration(ctx context.Context, launchID int64) (int64, error) {
+	var duration struct {
+		Duration int64 `json:"duration"`
+	}
+	if err := a.getJSON(ctx, fmt.Sprintf("launch/%d/duration", launchID), nil, &duration); err != nil {
+		return 0, err
+	}
 
+	return duration.Duration, nil
+}
+
+// GetLaunchEnvironment - получает переменные окружения запуска
+func (a *AllureClient) GetLaunchEnvironment(ctx context.Context, launchID int64) ([]EnvVarValue, error) {
+	var environment []EnvVarValue
+	if err := a.getJSON(ctx, fmt.Sprintf("launch/%d/env", launchID), nil, &environment); err != nil {
+		return nil, err
+	}
+
+	return environment, nil
+}
+
+// GetLaunchJobRun - получает запуск CI-джобы, связанный с запуском (nil, если связи нет)
+func (a *AllureClient) GetLaunchJobRun(ctx context.Context, launchID int64) (*JobRun, error) {
+	var jobRun JobRun
+	err := a.getJSON(ctx, fmt.Sprintf("launch/%d/jobrun", launchID), nil, &jobRun)
+	if errors.Is(err, ErrNotFound) {
+		return nil, nil
+	}
 	if err != nil {
 		return nil, err
 	}
 
-	if resp.StatusCode() != http.StatusOK {
-		return nil, fmt.Errorf("ошибка Allure API: статус %d", resp.StatusCode())
+	return &jobRun, nil
+}
+
+// getJSON - выполняет авторизованный GET-запрос к Allure API и разбирает JSON-ответ в out
+func (a *AllureClient) getJSON(ctx context.Context, path string, params map[string]string, out interface{}) error {
+	// Убеждаемся, что токен актуален
+	conn, err := a.session(ctx)
+	if err != nil {
+		return err
 	}
 
-	var launchesResponse struct {
-		Content []Launch `json:"content"`
+	resp, err := a.authorized(ctx, conn, true, func(conn *connection) (*resty.Response, error) {
+		return conn.client.R().
+			SetContext(ctx).
+			SetAuthToken(conn.token).
+			SetQueryParams(params).
+			Get(conn.baseURL + conn.apiURL + path)
+	})
+
+	if err != nil {
+		return err
 	}
 
-	if err := json.Unmarshal(resp.Body(), &launchesResponse); err != nil {
-		return nil, err
+	switch resp.StatusCode() {
+	case http.StatusOK:
+	case http.StatusNotFound:
+		return fmt.Errorf("%w: %s", ErrNotFound, path)
+	case http.StatusForbidden:
+		return fmt.Errorf("%w: %s", ErrForbidden, path)
+	default:
+		return fmt.Errorf("ошибка Allure API: статус %d", resp.StatusCode())
 	}
 
-	return launchesResponse.Content, nil
+	return json.Unmarshal(resp.Body(), out)
+}
+
+// rql - формирует RQL-фильтр Allure TestOps по параметрам запроса
+func (q LaunchQuery) rql() string {
+	var conditions []string
+	if !q.From.IsZero() {
+		conditions = append(conditions, fmt.Sprintf("createdDate >= %d", q.From.UnixMilli()))
+	}
+	if !q.To.IsZero() {
+		conditions = append(conditions, fmt.Sprintf("createdDate < %d", q.To.UnixMilli()))
+	}
+	if q.Name != "" {
+		conditions = append(conditions, fmt.Sprintf("name ~= %s", rqlString(q.Name)))
+	}
+	if q.Tag != "" {
+		conditions = append(conditions, fmt.Sprintf("tag = %s", rqlString(q.Tag)))
+	}
+
+	return strings.Join(conditions, " and ")
+}
+
+// rqlString - экранирует строковое значение для RQL
+func rqlString(value string) string {
+	return strconv.Quote(value)
 }
 
 // GeneratePDFReport - инициирует создание PDF-отчета в Allure
-func (a *AllureClient) GeneratePDFReport(ctx context.Context, launchID int64, launchName string) (*PDFReport, error) {
+func (a *AllureClient) GeneratePDFReport(ctx context.Context, launchID int64, launchName string, opts PDFExportOptions) (*PDFReport, error) {
 	// Обновляем токен перед запросом
-	err := a.Authenticate(ctx)
+	conn, err := a.session(ctx)
 	if err != nil {
 		return nil, fmt.Errorf("❌ Ошибка авторизации перед генерацией PDF: %w", err)
 	}
 
-	url := fmt.Sprintf("%s%sexport/launch/pdf", a.baseURL, a.apiURL)
+	url := fmt.Sprintf("%s%sexport/launch/pdf", conn.baseURL, conn.apiURL)
 	log.Info().Msgf("📡 Отправка запроса на генерацию PDF: URL=%s, LaunchID=%d", url, launchID)
 
 	// Формируем JSON-запрос
 	requestBody := map[string]interface{}{
 		"launchId":        launchID,
 		"name":            launchName,
-		"withPageNumbers": true,
+		"withPageNumbers": opts.WithPageNumbers,
+		"withTitlePage":   opts.TitlePage,
+		"withOverview":    opts.Sections.Overview,
+		"withFailures":    opts.Sections.Failures,
+		"withSteps":       opts.Sections.Steps,
+		"withAttachments": opts.Sections.Attachments,
+	}
+	if len(opts.Statuses) > 0 {
+		requestBody["statuses"] = opts.Statuses
+	}
+	if opts.Locale != "" {
+		requestBody["locale"] = opts.Locale
 	}
 
-	// Отправляем запрос
-	resp, err := a.client.R().
-		SetContext(ctx).
-		SetHeader("Authorization", "Bearer "+a.token). // ✅ Добавляем Bearer-токен
-		SetHeader("Content-Type", "application/json").
-		SetBody(requestBody).
-		Post(url)
+	// Отправляем запрос; повтор после ошибки 5xx мог бы запустить генерацию дважды
+	resp, err := a.authorized(ctx, conn, false, func(conn *connection) (*resty.Response, error) {
+		return conn.client.R().
+			SetContext(ctx).
+			SetHeader("Authorization", "Bearer "+conn.token). // ✅ Добавляем Bearer-токен
+			SetHeader("Content-Type", "application/json").
+			SetBody(requestBody).
+			Post(url)
+	})
 
 	if err != nil {
 		log.Error().Err(err).Msg("❌ Ошибка запроса на генерацию PDF")
 		return nil, err
 	}
 
-	// Логируем полный ответ от Allure API
-	log.Info().Msgf("📨 Ответ от Allure API: статус %d, тело: %s", resp.StatusCode(), resp.String())
+	// Тело ответа - только на уровне debug, без секретов и ограниченное по размеру
+	log.Debug().Msgf("📨 Ответ от Allure API: статус %d, тело: %s", resp.StatusCode(), logging.Body(resp.Body()))
 
 	// Проверяем статус ответа
-	if resp.StatusCode() != http.StatusOK {
+	switch resp.StatusCode() {
+	case http.StatusOK:
+	case http.StatusForbidden:
+		return nil, fmt.Errorf("%w: запуск %d", ErrForbidden, launchID)
+	default:
 		return nil, fmt.Errorf("ошибка генерации PDF: статус %d", resp.StatusCode())
 	}
 
@@ -186,38 +431,88 @@ func (a *AllureClient) GeneratePDFReport(ctx context.Context, launchID int64, la
 	return &report, nil
 }
 
+// GetPDFReport - получает текущее состояние экспорта PDF-отчета
+func (a *AllureClient) GetPDFReport(ctx context.Context, reportID string) (*PDFReport, error) {
+	var report PDFReport
+	if err := a.getJSON(ctx, "export/"+reportID, nil, &report); err != nil {
+		return nil, err
+	}
+
+	return &report, nil
+}
+
 // GetPDFDownloadLink - получает ссылку на скачивание PDF-отчета
 func (a *AllureClient) GetPDFDownloadLink(reportID string) string {
-	return fmt.Sprintf("%s%sexport/download/%s", a.baseURL, a.apiURL, reportID)
+	conn := a.conn.Load()
+	return fmt.Sprintf("%s%sexport/download/%s", conn.baseURL, conn.apiURL, reportID)
 }
 
-// DownloadPDFReport - загружает PDF-отчет с Allure API
-func (a *AllureClient) DownloadPDFReport(ctx context.Context, reportID string) ([]byte, string, error) {
+// DownloadPDFReport - открывает поток PDF-отчета с Allure API.
+// Вызывающий обязан закрыть Body.
+func (a *AllureClient) DownloadPDFReport(ctx context.Context, reportID string) (*FileContent, error) {
 	// Обновляем токен перед скачиванием
-	if err := a.Authenticate(ctx); err != nil {
-		return nil, "", fmt.Errorf("❌ Ошибка авторизации перед скачиванием PDF: %w", err)
+	conn, err := a.session(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("❌ Ошибка авторизации перед скачиванием PDF: %w", err)
 	}
 
-	url := fmt.Sprintf("%s%sexport/download/%s", a.baseURL, a.apiURL, reportID)
+	url := fmt.Sprintf("%s%sexport/download/%s", conn.baseURL, conn.apiURL, reportID)
 	log.Info().Msgf("📡 Запрос на скачивание PDF: %s", url)
 
-	resp, err := a.client.R().
-		SetContext(ctx).
-		SetHeader("Authorization", "Bearer "+a.token).
-		Get(url)
+	resp, err := a.authorized(ctx, conn, true, func(conn *connection) (*resty.Response, error) {
+		return conn.downloads.R().
+			SetContext(ctx).
+			SetDoNotParseResponse(true).
+			SetHeader("Authorization", "Bearer "+conn.token).
+			SetHeader("Accept", "application/pdf, */*").
+			Get(url)
+	})
 
 	if err != nil {
 		log.Error().Err(err).Msg("❌ Ошибка при скачивании PDF")
-		return nil, "", err
+		return nil, err
 	}
 
-	if resp.StatusCode() != http.StatusOK {
+	switch resp.StatusCode() {
+	case http.StatusOK:
+	case http.StatusNotFound:
+		resp.RawBody().Close()
+		return nil, fmt.Errorf("%w: отчет %s", ErrNotFound, reportID)
+	case http.StatusForbidden:
+		resp.RawBody().Close()
+		return nil, fmt.Errorf("%w: отчет %s", ErrForbidden, reportID)
+	default:
+		resp.RawBody().Close()
 		log.Warn().Msgf("⚠️ Ошибка скачивания PDF: статус %d", resp.StatusCode())
-		return nil, "", fmt.Errorf("ошибка скачивания PDF: статус %d", resp.StatusCode())
+		return nil, fmt.Errorf("ошибка скачивания PDF: статус %d", resp.StatusCode())
+	}
+
+	contentType := resp.Header().Get("Content-Type")
+	if contentType == "" {
+		contentType = "application/pdf"
 	}
 
-	// Определяем имя файла
-	fileName := fmt.Sprintf("allure-report-%s.pdf", reportID)
+	return &FileContent{
+		Body:          resp.RawBody(),
+		StatusCode:    resp.StatusCode(),
+		ContentType:   contentType,
+		ContentLength: resp.RawResponse.ContentLength,
+		FileName:      attachmentFileName(resp.Header().Get("Content-Disposition"), fmt.Sprintf("allure-report-%s.pdf", reportID)),
+	}, nil
+}
+
+// attachmentFileName - извлекает имя файла из Content-Disposition, иначе возвращает fallback
+func attachmentFileName(contentDisposition, fallback string) string {
+	_, params, err := mime.ParseMediaType(contentDisposition)
+	if err != nil {
+		return fallback
+	}
+
+	// Путь в имени файла от сервера не используем
+	fileName := path.Base(strings.ReplaceAll(params["filename"], "\\", "/"))
+	if fileName == "" || fileName == "." || fileName == "/" {
+		return fallback
+	}
 
-	return resp.Body(), fileName, nil
+	return fileName
 }
@@ -0,0 +1,35 @@
+package adapter
+
+import (
+	"github.com/go-resty/resty/v2"
+	"github.com/rs/zerolog"
+	"github.com/rs/zerolog/log"
+
+	"github.com/vkr-mtuci/allure-service/internal/logging"
+)
+
+// withDebugLog - на уровне логов debug включает отладочный лог resty. Заголовки с учетными
+// данными и секреты в телах скрываются, тела ограничены размером logging.MaxBodyBytes.
+func withDebugLog(client *resty.Client) *resty.Client {
+	return client.
+		SetLogger(restyLogger{}).
+		SetDebug(zerolog.GlobalLevel() <= zerolog.DebugLevel).
+		SetDebugBodyLimit(int64(logging.MaxBodyBytes())).
+		OnRequestLog(func(r *resty.RequestLog) error {
+			logging.RedactHeader(r.Header)
+			r.Body = logging.Redact(r.Body)
+			return nil
+		}).
+		OnResponseLog(func(r *resty.ResponseLog) error {
+			logging.RedactHeader(r.Header)
+			r.Body = logging.Redact(r.Body)
+			return nil
+		})
+}
+
+// restyLogger - направляет сообщения resty в общий лог
+type restyLogger struct{}
+
+func (restyLogger) Errorf(format string, v ...interface{}) { log.Error().Msgf(format, v...) }
+func (restyLogger) Warnf(format string, v ...interface{})  { log.Warn().Msgf(format, v...) }
+func (restyLogger) Debugf(format string, v ...interface{}) { log.Debug().Msgf(format, v...) }
@@ -1,12 +1,224 @@
 package adapter
 
+import (
+	"io"
+	"time"
+)
+
+// Статусы тестов в Allure
+const (
+	StatusPassed  = "passed"
+	StatusFailed  = "failed"
+	StatusBroken  = "broken"
+	StatusSkipped = "skipped"
+	StatusUnknown = "unknown"
+)
+
 // Launch - структура данных для запуска
 type Launch struct {
-	ID               int64  `json:"id"`
-	Name             string `json:"name"`
-	ProjectID        int    `json:"projectId"`
-	CreatedDate      int64  `json:"createdDate"`
-	LastModifiedDate int64  `json:"lastModifiedDate"`
+	ID               int64            `json:"id"`
+	Name             string           `json:"name"`
+	ProjectID        int              `json:"projectId"`
+	CreatedDate      int64            `json:"createdDate"`
+	LastModifiedDate int64            `json:"lastModifiedDate"`
+	Closed           bool             `json:"closed"`
+	Tags             []Tag            `json:"tags,omitempty"`
+	Statistic        *LaunchStatistic `json:"statistic,omitempty"`
+	Duration         int64            `json:"duration,omitempty"` // Длительность в миллисекундах
+	Environment      []EnvVarValue    `json:"environment,omitempty"`
+	JobRun           *JobRun          `json:"jobRun,omitempty"`
+}
+
+// Tag - тег запуска или результата теста
+type Tag struct {
+	ID   int64  `json:"id"`
+	Name string `json:"name"`
+}
+
+// StatusCount - количество тестов с указанным статусом в ответе Allure API
+type StatusCount struct {
+	Status string `json:"status"`
+	Count  int    `json:"count"`
+}
+
+// LaunchStatistic - количество тестов запуска по статусам
+type LaunchStatistic struct {
+	Passed  int `json:"passed"`
+	Failed  int `json:"failed"`
+	Broken  int `json:"broken"`
+	Skipped int `json:"skipped"`
+	Unknown int `json:"unknown"`
+	Total   int `json:"total"`
+}
+
+// EnvVarValue - значение переменной окружения запуска
+type EnvVarValue struct {
+	Name  string `json:"name"`
+	Value string `json:"value"`
+}
+
+// JobRun - запуск CI-джобы, связанный с запуском Allure
+type JobRun struct {
+	ID     int64  `json:"id"`
+	JobID  int64  `json:"jobId"`
+	Name   string `json:"name"`
+	URL    string `json:"url"`
+	Status string `json:"status"`
+}
+
+// LaunchPage - страница запусков в ответе Allure API
+type LaunchPage struct {
+	Content       []Launch `json:"content"`
+	TotalElements int64    `json:"totalElements"`
+	TotalPages    int      `json:"totalPages"`
+	Number        int      `json:"number"`
+	Size          int      `json:"size"`
+	Last          bool     `json:"last"`
+}
+
+// LaunchQuery - параметры выборки запусков, передаваемые в Allure TestOps
+type LaunchQuery struct {
+	ProjectID int64     // Проект (0 - проект по умолчанию из конфигурации)
+	From      time.Time // Запуски, созданные не раньше From (нулевое время - без ограничения)
+	To        time.Time // Запуски, созданные раньше To (нулевое время - без ограничения)
+	Name      string    // Подстрока в имени запуска
+	Tag       string    // Тег запуска
+	Sort      string    // Сортировка в формате Allure, например "createdDate,asc"
+	Page      int       // Номер страницы, начиная с 0
+	Size      int       // Размер страницы (0 - размер по умолчанию)
+}
+
+// Project - проект Allure TestOps
+type Project struct {
+	ID          int64  `json:"id"`
+	Name        string `json:"name"`
+	Abbr        string `json:"abbr,omitempty"`
+	Description string `json:"description,omitempty"`
+	IsPublic    bool   `json:"isPublic"`
+}
+
+// ProjectPage - страница проектов в ответе Allure API
+type ProjectPage struct {
+	Content       []Project `json:"content"`
+	TotalElements int64     `json:"totalElements"`
+	TotalPages    int       `json:"totalPages"`
+	Number        int       `json:"number"`
+	Size          int       `json:"size"`
+	Last          bool      `json:"last"`
+}
+
+// TestResult - результат теста в запуске
+type TestResult struct {
+	ID          int64        `json:"id"`
+	Name        string       `json:"name"`
+	FullName    string       `json:"fullName"`
+	Status      string       `json:"status"`
+	LaunchID    int64        `json:"launchId"`
+	ProjectID   int          `json:"projectId"`
+	Start       int64        `json:"start"`
+	Stop        int64        `json:"stop"`
+	Duration    int64        `json:"duration"` // Длительность в миллисекундах
+	Message     string       `json:"message,omitempty"`
+	Trace       string       `json:"trace,omitempty"`
+	Tags        []Tag        `json:"tags,omitempty"`
+	Labels      []Label      `json:"labels,omitempty"`
+	Parameters  []Parameter  `json:"parameters,omitempty"`
+	Steps       []Step       `json:"steps,omitempty"`
+	Attachments []Attachment `json:"attachments,omitempty"`
+}
+
+// Label - метка результата теста (owner, suite, feature и т.д.)
+type Label struct {
+	Name  string `json:"name"`
+	Value string `json:"value"`
+}
+
+// Step - шаг выполнения теста
+type Step struct {
+	Name        string       `json:"name"`
+	Status      string       `json:"status"`
+	Start       int64        `json:"start"`
+	Stop        int64        `json:"stop"`
+	Duration    int64        `json:"duration"`
+	Message     string       `json:"message,omitempty"`
+	Parameters  []Parameter  `json:"parameters,omitempty"`
+	Steps       []Step       `json:"steps,omitempty"`
+	Attachments []Attachment `json:"attachments,omitempty"`
+}
+
+// Parameter - параметр теста или шага
+type Parameter struct {
+	Name  string `json:"name"`
+	Value string `json:"value"`
+}
+
+// Attachment - метаданные вложения результата теста
+type Attachment struct {
+	ID            int64  `json:"id"`
+	Name          string `json:"name"`
+	ContentType   string `json:"contentType"`
+	ContentLength int64  `json:"contentLength"`
+}
+
+// FileContent - потоковое содержимое файла, полученное из Allure API.
+// Вызывающий обязан закрыть Body.
+type FileContent struct {
+	Body          io.ReadCloser
+	StatusCode    int // 200 или 206 для частичного ответа
+	ContentType   string
+	ContentLength int64 // -1, если размер неизвестен
+	ContentRange  string
+	AcceptRanges  string
+	FileName      string
+}
+
+// TestResultPage - страница результатов тестов в ответе Allure API
+type TestResultPage struct {
+	Content       []TestResult `json:"content"`
+	TotalElements int64        `json:"totalElements"`
+	TotalPages    int          `json:"totalPages"`
+	Number        int          `json:"number"`
+	Size          int          `json:"size"`
+	Last          bool         `json:"last"`
+}
+
+// TestResultQuery - параметры выборки результатов тестов запуска
+type TestResultQuery struct {
+	LaunchID int64    // ID запуска
+	Statuses []string // Статусы результатов (пусто - все статусы)
+	Page     int      // Номер страницы, начиная с 0
+	Size     int      // Размер страницы (0 - размер по умолчанию)
+}
+
+// PDFExportOptions - параметры экспорта PDF-отчета в Allure
+type PDFExportOptions struct {
+	WithPageNumbers bool              `json:"withPageNumbers"`
+	TitlePage       bool              `json:"titlePage"`
+	Sections        PDFExportSections `json:"sections"`
+	Statuses        []string          `json:"statuses,omitempty"` // Статусы тестов в отчете (пусто - все)
+	Locale          string            `json:"locale,omitempty"`   // Язык отчета (пусто - по умолчанию Allure)
+}
+
+// PDFExportSections - разделы, включаемые в PDF-отчет
+type PDFExportSections struct {
+	Overview    bool `json:"overview"`
+	Failures    bool `json:"failures"`
+	Steps       bool `json:"steps"`
+	Attachments bool `json:"attachments"`
+}
+
+// DefaultPDFExportOptions - параметры экспорта по умолчанию: все разделы, титульная страница и нумерация
+func DefaultPDFExportOptions() PDFExportOptions {
+	return PDFExportOptions{
+		WithPageNumbers: true,
+		TitlePage:       true,
+		Sections: PDFExportSections{
+			Overview:    true,
+			Failures:    true,
+			Steps:       true,
+			Attachments: true,
+		},
+	}
 }
 
 // PDFReport - структура данных для PDF-отчета
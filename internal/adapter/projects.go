@@ -0,0 +1,32 @@
+package adapter
+
+import (
+	"context"
+	"strconv"
+)
+
+// projectPageSize - размер страницы при постраничном обходе проектов
+const projectPageSize = 100
+
+// GetProjects - получает все проекты Allure, доступные по токену сервиса
+func (a *AllureClient) GetProjects(ctx context.Context) ([]Project, error) {
+	var projects []Project
+
+	for page := 0; ; page++ {
+		params := map[string]string{
+			"page": strconv.Itoa(page),
+			"size": strconv.Itoa(projectPageSize),
+			"sort": "id,asc",
+		}
+
+		var projectPage ProjectPage
+		if err := a.getJSON(ctx, "project", params, &projectPage); err != nil {
+			return nil, err
+		}
+		projects = append(projects, projectPage.Content...)
+
+		if projectPage.Last || len(projectPage.Content) == 0 || page+1 >= projectPage.TotalPages {
+			return projects, nil
+		}
+	}
+}
@@ -0,0 +1,223 @@
+package adapter
+
+import (
+	"context"
+	"errors"
+	"math/rand/v2"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/go-resty/resty/v2"
+	"github.com/rs/zerolog/log"
+	"github.com/vkr-mtuci/allure-service/config"
+)
+
+// ErrCircuitOpen - Allure недоступен, поэтому запросы к нему временно не отправляются
+var ErrCircuitOpen = errors.New("Allure временно недоступен")
+
+// Состояния автомата защиты
+const (
+	CircuitClosed   = "closed"    // Запросы идут в Allure
+	CircuitOpen     = "open"      // Allure недоступен, запросы сразу завершаются ошибкой
+	CircuitHalfOpen = "half-open" // Пробный запрос проверяет, восстановился ли Allure
+)
+
+// CircuitState - состояние автомата защиты экземпляра Allure
+type CircuitState struct {
+	State    string     `json:"state"`
+	Failures int        `json:"failures"`           // Неудачных запросов подряд
+	OpenedAt *time.Time `json:"openedAt,omitempty"` // Когда автомат разомкнулся
+	RetryAt  *time.Time `json:"retryAt,omitempty"`  // Когда будет пробный запрос
+}
+
+// circuitBreaker - автомат защиты: после FailureThreshold неудач подряд запросы к Allure
+// не отправляются OpenTimeout, затем один пробный запрос решает, замкнуть ли автомат снова
+type circuitBreaker struct {
+	mu       sync.Mutex
+	state    string
+	failures int
+	openedAt time.Time
+	probing  bool // Пробный запрос в полуоткрытом состоянии уже отправлен
+}
+
+// outcome - результат запроса для автомата защиты
+type outcome int
+
+const (
+	outcomeSuccess outcome = iota // Allure ответил, пусть и ошибкой 4xx
+	outcomeFailure                // Сетевая ошибка или ответ 5xx
+	outcomeIgnored                // Запрос отменен вызывающим - о здоровье Allure он ничего не говорит
+)
+
+// allow - можно ли отправить запрос. Нулевой FailureThreshold отключает автомат.
+func (b *circuitBreaker) allow(settings config.CircuitBreaker) bool {
+	if settings.FailureThreshold <= 0 {
+		return true
+	}
+
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	switch b.state {
+	case CircuitOpen:
+		if time.Since(b.openedAt) < settings.OpenTimeout {
+			return false
+		}
+		b.state = CircuitHalfOpen
+		b.probing = true
+		log.Info().Msg("🔌 Автомат защиты Allure: пробный запрос")
+		return true
+	case CircuitHalfOpen:
+		if b.probing {
+			return false
+		}
+		b.probing = true
+		return true
+	default:
+		return true
+	}
+}
+
+// done - учитывает результат запроса, пропущенного allow
+func (b *circuitBreaker) done(result outcome, settings config.CircuitBreaker) {
+	if settings.FailureThreshold <= 0 {
+		return
+	}
+
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	if b.state == CircuitHalfOpen {
+		b.probing = false
+	}
+
+	switch result {
+	case outcomeSuccess:
+		if b.state != CircuitClosed {
+			log.Info().Msg("✅ Автомат защиты Allure замкнут: Allure снова отвечает")
+		}
+		b.state = CircuitClosed
+		b.failures = 0
+	case outcomeFailure:
+		b.failures++
+		if b.state == CircuitHalfOpen || (b.state != CircuitOpen && b.failures >= settings.FailureThreshold) {
+			b.state = CircuitOpen
+			b.openedAt = time.Now()
+			log.Warn().Msgf("⚠️ Автомат защиты Allure разомкнут после %d неудачных запросов подряд", b.failures)
+		}
+	}
+}
+
+// snapshot - текущее состояние автомата
+func (b *circuitBreaker) snapshot(settings config.CircuitBreaker) CircuitState {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	state := CircuitState{State: b.state, Failures: b.failures}
+	if state.State == "" {
+		state.State = CircuitClosed
+	}
+	if b.state == CircuitOpen || b.state == CircuitHalfOpen {
+		openedAt := b.openedAt
+		retryAt := openedAt.Add(settings.OpenTimeout)
+		state.OpenedAt = &openedAt
+		state.RetryAt = &retryAt
+	}
+	return state
+}
+
+// Circuit - состояние автомата защиты экземпляра Allure
+func (a *AllureClient) Circuit() CircuitState {
+	return a.breaker.snapshot(a.conn.Load().cfg.CircuitBreaker)
+}
+
+// do - отправляет запрос send через автомат защиты и повторяет его по политике повторов.
+// Идемпотентные запросы повторяются после сетевых ошибок, ответов 5xx и 429;
+// остальные - только после 429, когда Allure точно не выполнил запрос.
+// Перед повтором непрочитанное тело ответа закрывается.
+func (a *AllureClient) do(ctx context.Context, conn *connection, idempotent bool, send func() (*resty.Response, error)) (*resty.Response, error) {
+	retry := conn.cfg.Retry
+	attempts := max(retry.MaxAttempts, 1)
+
+	for attempt := 1; ; attempt++ {
+		if !a.breaker.allow(conn.cfg.CircuitBreaker) {
+			return nil, ErrCircuitOpen
+		}
+
+		resp, err := send()
+		result := classify(ctx, resp, err)
+		a.breaker.done(result, conn.cfg.CircuitBreaker)
+
+		retryable := result == outcomeFailure && idempotent
+		if err == nil && resp.StatusCode() == http.StatusTooManyRequests {
+			retryable = true
+		}
+		if !retryable || attempt >= attempts {
+			return resp, err
+		}
+
+		delay := backoff(retry, attempt)
+		if err == nil {
+			if retryAfter, ok := parseRetryAfter(resp.Header().Get("Retry-After")); ok {
+				if retryAfter > retry.MaxBackoff {
+					// Allure просит подождать дольше, чем мы готовы держать запрос
+					return resp, err
+				}
+				delay = max(delay, retryAfter)
+			}
+			resp.RawBody().Close()
+		}
+
+		log.Warn().Err(err).Msgf("🔁 Повтор запроса к Allure через %s (попытка %d из %d)", delay, attempt+1, attempts)
+		timer := time.NewTimer(delay)
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return nil, ctx.Err()
+		case <-timer.C:
+		}
+	}
+}
+
+// classify - результат запроса для автомата защиты
+func classify(ctx context.Context, resp *resty.Response, err error) outcome {
+	switch {
+	case err != nil && ctx.Err() != nil:
+		return outcomeIgnored
+	case err != nil:
+		return outcomeFailure
+	case resp.StatusCode() >= http.StatusInternalServerError:
+		return outcomeFailure
+	default:
+		return outcomeSuccess
+	}
+}
+
+// backoff - экспоненциальная пауза перед повтором со случайным разбросом в пределах половины паузы
+func backoff(retry config.RetryPolicy, attempt int) time.Duration {
+	delay := retry.InitialBackoff
+	for i := 1; i < attempt && delay < retry.MaxBackoff; i++ {
+		delay *= 2
+	}
+	delay = min(delay, retry.MaxBackoff)
+	if delay <= 1 {
+		return delay
+	}
+	return delay/2 + rand.N(delay/2)
+}
+
+// parseRetryAfter - разбирает заголовок Retry-After: число секунд или HTTP-дата
+func parseRetryAfter(value string) (time.Duration, bool) {
+	if value == "" {
+		return 0, false
+	}
+	if seconds, err := strconv.Atoi(value); err == nil && seconds >= 0 {
+		return time.Duration(seconds) * time.Second, true
+	}
+	if date, err := http.ParseTime(value); err == nil {
+		return max(time.Until(date), 0), true
+	}
+	return 0, false
+}
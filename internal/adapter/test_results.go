@@ -0,0 +1,158 @@
+package adapter
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/go-resty/resty/v2"
+	"github.com/rs/zerolog/log"
+)
+
+// resultPageSize - размер страницы результатов тестов по умолчанию
+const resultPageSize = 100
+
+// SearchTestResults - получает страницу результатов тестов запуска с фильтром по статусам
+func (a *AllureClient) SearchTestResults(ctx context.Context, query TestResultQuery) (*TestResultPage, error) {
+	size := query.Size
+	if size <= 0 {
+		size = resultPageSize
+	}
+
+	params := map[string]string{
+		"launchId": strconv.FormatInt(query.LaunchID, 10),
+		"page":     strconv.Itoa(query.Page),
+		"size":     strconv.Itoa(size),
+		"sort":     "id,asc",
+	}
+
+	// Фильтр по статусам передается через RQL в поисковый эндпоинт
+	path := "testresult"
+	if rql := query.rql(); rql != "" {
+		path += "/__search"
+		params["rql"] = rql
+	}
+
+	var resultPage TestResultPage
+	if err := a.getJSON(ctx, path, params, &resultPage); err != nil {
+		return nil, err
+	}
+
+	return &resultPage, nil
+}
+
+// IterateTestResults - постранично обходит результаты тестов запуска и передает их в fn.
+// Обход прекращается, когда fn возвращает false или закончились страницы.
+func (a *AllureClient) IterateTestResults(ctx context.Context, query TestResultQuery, fn func(TestResult) bool) error {
+	if query.Size <= 0 {
+		query.Size = resultPageSize
+	}
+
+	for query.Page = 0; ; query.Page++ {
+		resultPage, err := a.SearchTestResults(ctx, query)
+		if err != nil {
+			return err
+		}
+
+		for _, result := range resultPage.Content {
+			if !fn(result) {
+				return nil
+			}
+		}
+
+		if resultPage.Last || len(resultPage.Content) == 0 || query.Page+1 >= resultPage.TotalPages {
+			return nil
+		}
+	}
+}
+
+// GetTestResult - получает результат теста по ID
+func (a *AllureClient) GetTestResult(ctx context.Context, resultID int64) (*TestResult, error) {
+	var result TestResult
+	if err := a.getJSON(ctx, fmt.Sprintf("testresult/%d", resultID), nil, &result); err != nil {
+		return nil, err
+	}
+
+	return &result, nil
+}
+
+// GetTestResultExecution - получает дерево шагов и вложений результата теста.
+// Корневой шаг содержит шаги и вложения верхнего уровня.
+func (a *AllureClient) GetTestResultExecution(ctx context.Context, resultID int64) (*Step, error) {
+	var execution Step
+	if err := a.getJSON(ctx, fmt.Sprintf("testresult/%d/execution", resultID), nil, &execution); err != nil {
+		return nil, err
+	}
+
+	return &execution, nil
+}
+
+// DownloadAttachment - открывает поток содержимого вложения.
+// rangeHeader передается в Allure как есть, чтобы поддержать частичную загрузку видео.
+func (a *AllureClient) DownloadAttachment(ctx context.Context, attachmentID int64, rangeHeader string) (*FileContent, error) {
+	conn, err := a.session(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("❌ Ошибка авторизации перед скачиванием вложения: %w", err)
+	}
+
+	url := fmt.Sprintf("%s%stestresult/attachment/%d/content", conn.baseURL, conn.apiURL, attachmentID)
+	log.Info().Msgf("📡 Запрос на скачивание вложения: %s", url)
+
+	resp, err := a.authorized(ctx, conn, true, func(conn *connection) (*resty.Response, error) {
+		req := conn.downloads.R().
+			SetContext(ctx).
+			SetDoNotParseResponse(true).
+			SetHeader("Authorization", "Bearer "+conn.token).
+			SetHeader("Accept", "*/*")
+		if rangeHeader != "" {
+			req.SetHeader("Range", rangeHeader)
+		}
+		return req.Get(url)
+	})
+	if err != nil {
+		log.Error().Err(err).Msg("❌ Ошибка при скачивании вложения")
+		return nil, err
+	}
+
+	switch resp.StatusCode() {
+	case http.StatusOK, http.StatusPartialContent:
+	case http.StatusNotFound:
+		resp.RawBody().Close()
+		return nil, fmt.Errorf("%w: вложение %d", ErrNotFound, attachmentID)
+	case http.StatusForbidden:
+		resp.RawBody().Close()
+		return nil, fmt.Errorf("%w: вложение %d", ErrForbidden, attachmentID)
+	case http.StatusRequestedRangeNotSatisfiable:
+		resp.RawBody().Close()
+		return nil, fmt.Errorf("%w: %s", ErrRangeNotSatisfiable, rangeHeader)
+	default:
+		resp.RawBody().Close()
+		log.Warn().Msgf("⚠️ Ошибка скачивания вложения: статус %d", resp.StatusCode())
+		return nil, fmt.Errorf("ошибка скачивания вложения: статус %d", resp.StatusCode())
+	}
+
+	return &FileContent{
+		Body:          resp.RawBody(),
+		StatusCode:    resp.StatusCode(),
+		ContentType:   resp.Header().Get("Content-Type"),
+		ContentLength: resp.RawResponse.ContentLength,
+		ContentRange:  resp.Header().Get("Content-Range"),
+		AcceptRanges:  resp.Header().Get("Accept-Ranges"),
+	}, nil
+}
+
+// rql - формирует RQL-фильтр Allure TestOps по статусам результатов
+func (q TestResultQuery) rql() string {
+	if len(q.Statuses) == 0 {
+		return ""
+	}
+
+	statuses := make([]string, 0, len(q.Statuses))
+	for _, status := range q.Statuses {
+		statuses = append(statuses, rqlString(status))
+	}
+
+	return fmt.Sprintf("status in [%s]", strings.Join(statuses, ", "))
+}
@@ -0,0 +1,220 @@
+package adapter
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/go-resty/resty/v2"
+	"github.com/rs/zerolog/log"
+
+	"github.com/vkr-mtuci/allure-service/internal/logging"
+	"github.com/vkr-mtuci/allure-service/internal/reqctx"
+)
+
+// tokenRefresh - одно обновление токена, результат которого ждут все запросы, которым нужен токен.
+// conn и err заполняются до закрытия done.
+type tokenRefresh struct {
+	done chan struct{}
+	conn *connection
+	err  error
+}
+
+// session - возвращает снимок подключения с действующим токеном: токеном вызывающего,
+// если в ctx передан его API-токен, иначе токеном сервиса.
+// Токен, который скоро истечет, обновляется в фоне, а запрос идет с ним; запрос ждет
+// обновления, только если токена нет или он уже истек.
+func (a *AllureClient) session(ctx context.Context) (*connection, error) {
+	if apiToken := reqctx.AllureToken(ctx); apiToken != "" {
+		return a.userSession(ctx, apiToken)
+	}
+
+	conn := a.conn.Load()
+	remaining := time.Until(conn.tokenExpires)
+	if remaining > conn.cfg.TokenRefreshMargin {
+		return conn, nil
+	}
+
+	// Обновление не привязано к отмене запроса, который его начал: его ждут и другие запросы
+	refresh := a.startRefresh(context.WithoutCancel(ctx), conn)
+	if conn.token != "" && remaining > 0 {
+		return conn, nil
+	}
+
+	return refresh.wait(ctx)
+}
+
+// forceRefresh - получает новый токен вместо отклоненного Allure токена снимка stale
+func (a *AllureClient) forceRefresh(ctx context.Context, stale *connection) (*connection, error) {
+	if apiToken := reqctx.AllureToken(ctx); apiToken != "" {
+		return a.forceUserRefresh(ctx, apiToken, stale)
+	}
+	log.Warn().Msg("🔑 Allure отклонил токен доступа, токен будет получен заново")
+	return a.startRefresh(context.WithoutCancel(ctx), stale).wait(ctx)
+}
+
+// startRefresh - начинает обновление токена, которым пользовался снимок stale, или возвращает
+// уже начатое. Если токен уже заменен действующим, возвращает готовый результат.
+func (a *AllureClient) startRefresh(ctx context.Context, stale *connection) *tokenRefresh {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	if a.refresh != nil {
+		return a.refresh
+	}
+
+	current := a.conn.Load()
+	if current.token != "" && current.token != stale.token && time.Until(current.tokenExpires) > current.cfg.TokenRefreshMargin {
+		refresh := &tokenRefresh{done: make(chan struct{}), conn: current}
+		close(refresh.done)
+		return refresh
+	}
+
+	refresh := &tokenRefresh{done: make(chan struct{})}
+	a.refresh = refresh
+	go a.runRefresh(ctx, current, refresh)
+	return refresh
+}
+
+// runRefresh - получает токен для снимка conn и публикует его, если настройки не заменены другими учетными данными
+func (a *AllureClient) runRefresh(ctx context.Context, conn *connection, refresh *tokenRefresh) {
+	token, expires, err := a.fetchToken(ctx, conn, conn.cfg.AllureUserToken)
+
+	a.mu.Lock()
+	if a.refresh == refresh {
+		a.refresh = nil
+	}
+	if err == nil {
+		base, publish := conn, false
+		if current := a.conn.Load(); sameCredentials(current, conn) {
+			// Настройки могли замениться во время обновления, но с теми же учетными данными
+			base, publish = current, true
+		}
+		refreshed := *base
+		refreshed.token = token
+		refreshed.tokenExpires = expires
+		if publish {
+			a.conn.Store(&refreshed)
+			a.scheduleRefresh(&refreshed)
+		}
+		refresh.conn = &refreshed
+	}
+	refresh.err = err
+	a.mu.Unlock()
+
+	close(refresh.done)
+}
+
+// scheduleRefresh - планирует фоновое обновление токена снимка conn за TokenRefreshMargin до истечения,
+// отменяя обновление, запланированное для прежнего снимка. Вызывается под a.mu.
+func (a *AllureClient) scheduleRefresh(conn *connection) {
+	if a.refreshTimer != nil {
+		a.refreshTimer.Stop()
+		a.refreshTimer = nil
+	}
+
+	delay := time.Until(conn.tokenExpires) - conn.cfg.TokenRefreshMargin
+	if conn.token == "" || delay <= 0 {
+		return
+	}
+
+	var timer *time.Timer
+	timer = time.AfterFunc(delay, func() {
+		// Таймер мог сработать одновременно с заменой снимка - тогда обновление уже не нужно
+		a.mu.Lock()
+		current := a.refreshTimer == timer
+		a.mu.Unlock()
+		if !current {
+			return
+		}
+
+		log.Info().Msg("⏰ Плановое обновление токена Allure API")
+		a.startRefresh(context.Background(), conn)
+	})
+	a.refreshTimer = timer
+}
+
+// wait - ждет результата обновления токена или отмены запроса
+func (r *tokenRefresh) wait(ctx context.Context) (*connection, error) {
+	select {
+	case <-r.done:
+		return r.conn, r.err
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	}
+}
+
+// fetchToken - обменивает API-токен пользователя на токен доступа Allure
+func (a *AllureClient) fetchToken(ctx context.Context, conn *connection, apiToken string) (string, time.Time, error) {
+	log.Info().Msg("🔄 Обновление токена Allure API...")
+
+	// Отправляем запрос на обновление токена
+	resp, err := a.do(ctx, conn, true, func() (*resty.Response, error) {
+		return conn.client.R().
+			SetContext(ctx).
+			SetFormData(map[string]string{
+				"grant_type": "apitoken",
+				"scope":      "openid",
+				"token":      apiToken,
+			}).
+			Post(conn.baseURL + "/api/uaa/oauth/token")
+	})
+
+	if err != nil {
+		log.Error().Err(err).Msg("❌ Ошибка обновления токена")
+		return "", time.Time{}, fmt.Errorf("ошибка обновления токена: %w", err)
+	}
+
+	// В теле ответа токен доступа, поэтому тело пишется только на уровне debug и без секретов
+	log.Debug().Msgf("📨 Ответ от Allure API (токен): статус %d, тело: %s", resp.StatusCode(), logging.Body(resp.Body()))
+
+	// Проверяем статус ответа
+	switch resp.StatusCode() {
+	case http.StatusOK:
+	case http.StatusBadRequest, http.StatusUnauthorized:
+		return "", time.Time{}, fmt.Errorf("ошибка обновления токена: %w (статус %d)", errTokenRejected, resp.StatusCode())
+	default:
+		return "", time.Time{}, fmt.Errorf("ошибка обновления токена: статус %d", resp.StatusCode())
+	}
+
+	// Парсим JSON-ответ
+	var authResp struct {
+		AccessToken string `json:"access_token"`
+		ExpiresIn   int    `json:"expires_in"`
+	}
+	if err := json.Unmarshal(resp.Body(), &authResp); err != nil {
+		log.Error().Err(err).Msg("❌ Ошибка парсинга токена")
+		return "", time.Time{}, err
+	}
+
+	// Если Allure не сообщил срок жизни, берем его из конфигурации
+	expiresIn := time.Duration(authResp.ExpiresIn) * time.Second
+	if expiresIn <= 0 {
+		expiresIn = conn.cfg.TokenExpiry
+	}
+	log.Info().Msg("✅ Токен успешно обновлен!")
+	return authResp.AccessToken, time.Now().Add(expiresIn), nil
+}
+
+// authorized - отправляет запрос send с токеном снимка conn. Если Allure отклонил токен (401),
+// токен один раз принудительно обновляется и запрос повторяется.
+func (a *AllureClient) authorized(ctx context.Context, conn *connection, idempotent bool, send func(conn *connection) (*resty.Response, error)) (*resty.Response, error) {
+	resp, err := a.do(ctx, conn, idempotent, func() (*resty.Response, error) { return send(conn) })
+	if err != nil || resp.StatusCode() != http.StatusUnauthorized {
+		return resp, err
+	}
+	resp.RawBody().Close()
+
+	conn, err = a.forceRefresh(ctx, conn)
+	if err != nil {
+		return nil, err
+	}
+	return a.do(ctx, conn, idempotent, func() (*resty.Response, error) { return send(conn) })
+}
+
+// sameCredentials - снимки подключаются к одному Allure с одним API-токеном
+func sameCredentials(a, b *connection) bool {
+	return a.baseURL == b.baseURL && a.cfg.AllureUserToken == b.cfg.AllureUserToken
+}
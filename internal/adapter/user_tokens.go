@@ -0,0 +1,154 @@
+package adapter
+
+import (
+	"container/list"
+	"context"
+	"crypto/sha256"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/rs/zerolog/log"
+)
+
+// defaultUserTokenCacheSize - размер кэша токенов вызывающих, если он не задан
+const defaultUserTokenCacheSize = 1000
+
+// userTokens - токены доступа, полученные по API-токенам вызывающих. Хранится не больше
+// maxSize токенов, дольше всех не использованные вытесняются.
+type userTokens struct {
+	mu      sync.Mutex
+	maxSize int
+	entries map[[sha256.Size]byte]*list.Element // Ключ - хеш адреса Allure и API-токена
+	order   *list.List                          // Элементы *userToken, недавно использованные - в начале
+}
+
+// userToken - токен доступа одного вызывающего. Поля, кроме key и apiToken, защищены userTokens.mu.
+type userToken struct {
+	key      [sha256.Size]byte
+	apiToken string
+
+	token   string
+	expires time.Time
+	refresh *tokenRefresh // Обновление токена, которое выполняется сейчас
+}
+
+// newUserTokens - кэш на maxSize вызывающих
+func newUserTokens(maxSize int) *userTokens {
+	if maxSize <= 0 {
+		maxSize = defaultUserTokenCacheSize
+	}
+	return &userTokens{
+		maxSize: maxSize,
+		entries: make(map[[sha256.Size]byte]*list.Element),
+		order:   list.New(),
+	}
+}
+
+// entry - запись вызывающего с API-токеном apiToken для Allure baseURL; создается при первом запросе
+func (u *userTokens) entry(baseURL, apiToken string) *userToken {
+	key := sha256.Sum256([]byte(baseURL + "\x00" + apiToken))
+
+	u.mu.Lock()
+	defer u.mu.Unlock()
+
+	if element, ok := u.entries[key]; ok {
+		u.order.MoveToFront(element)
+		return element.Value.(*userToken)
+	}
+
+	entry := &userToken{key: key, apiToken: apiToken}
+	u.entries[key] = u.order.PushFront(entry)
+	for u.order.Len() > u.maxSize {
+		oldest := u.order.Back()
+		u.order.Remove(oldest)
+		delete(u.entries, oldest.Value.(*userToken).key)
+	}
+	return entry
+}
+
+// resize - меняет размер кэша, лишние записи вытесняются при следующем запросе
+func (u *userTokens) resize(maxSize int) {
+	if maxSize <= 0 {
+		maxSize = defaultUserTokenCacheSize
+	}
+	u.mu.Lock()
+	defer u.mu.Unlock()
+	u.maxSize = maxSize
+}
+
+// userSession - снимок подключения с токеном доступа вызывающего, API-токен которого передан в ctx.
+// Токен обновляется так же, как токен сервиса: заранее в фоне, а без действующего токена запрос ждет.
+func (a *AllureClient) userSession(ctx context.Context, apiToken string) (*connection, error) {
+	base := a.conn.Load()
+	entry := a.users.entry(base.baseURL, apiToken)
+
+	a.users.mu.Lock()
+	token, expires := entry.token, entry.expires
+	a.users.mu.Unlock()
+
+	remaining := time.Until(expires)
+	if remaining > base.cfg.TokenRefreshMargin {
+		return base.withToken(token, expires), nil
+	}
+
+	refresh := a.startUserRefresh(context.WithoutCancel(ctx), base, entry, token)
+	if token != "" && remaining > 0 {
+		return base.withToken(token, expires), nil
+	}
+	return refresh.wait(ctx)
+}
+
+// startUserRefresh - начинает обновление токена вызывающего вместо токена stale или возвращает
+// уже начатое. Если токен уже заменен действующим, возвращает готовый результат.
+func (a *AllureClient) startUserRefresh(ctx context.Context, base *connection, entry *userToken, stale string) *tokenRefresh {
+	a.users.mu.Lock()
+	defer a.users.mu.Unlock()
+
+	if entry.refresh != nil {
+		return entry.refresh
+	}
+	if entry.token != "" && entry.token != stale && time.Until(entry.expires) > base.cfg.TokenRefreshMargin {
+		refresh := &tokenRefresh{done: make(chan struct{}), conn: base.withToken(entry.token, entry.expires)}
+		close(refresh.done)
+		return refresh
+	}
+
+	refresh := &tokenRefresh{done: make(chan struct{})}
+	entry.refresh = refresh
+	go func() {
+		token, expires, err := a.fetchToken(ctx, base, entry.apiToken)
+		if errors.Is(err, errTokenRejected) {
+			err = fmt.Errorf("%w: %w", ErrUserTokenRejected, err)
+		}
+
+		a.users.mu.Lock()
+		entry.refresh = nil
+		if err == nil {
+			entry.token, entry.expires = token, expires
+			refresh.conn = base.withToken(token, expires)
+		}
+		refresh.err = err
+		a.users.mu.Unlock()
+
+		close(refresh.done)
+	}()
+	return refresh
+}
+
+// forceUserRefresh - получает новый токен вызывающего вместо отклоненного Allure токена снимка stale
+func (a *AllureClient) forceUserRefresh(ctx context.Context, apiToken string, stale *connection) (*connection, error) {
+	log.Warn().Msg("🔑 Allure отклонил токен доступа вызывающего, токен будет получен заново")
+	base := a.conn.Load()
+	entry := a.users.entry(base.baseURL, apiToken)
+	return a.startUserRefresh(context.WithoutCancel(ctx), base, entry, stale.token).wait(ctx)
+}
+
+// withToken - копия снимка с токеном доступа вызывающего
+func (c *connection) withToken(token string, expires time.Time) *connection {
+	copied := *c
+	copied.token = token
+	copied.tokenExpires = expires
+	return &copied
+}
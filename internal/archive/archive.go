@@ -0,0 +1,85 @@
+package archive
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"path"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+)
+
+var (
+	// ErrNotFound - отчет отсутствует в архиве
+	ErrNotFound = errors.New("отчет не найден в архиве")
+	// ErrInvalidReport - некорректные идентификаторы или имя файла отчета
+	ErrInvalidReport = errors.New("некорректные данные отчета для архива")
+)
+
+// reportIDPattern - допустимый ID отчета: он становится частью пути или ключа объекта
+var reportIDPattern = regexp.MustCompile(`^[A-Za-z0-9_-]+$`)
+
+// Report - отчет, сохраненный в архиве
+type Report struct {
+	LaunchID   int64     `json:"launchId"`
+	ReportID   string    `json:"reportId"`
+	FileName   string    `json:"fileName"`
+	Size       int64     `json:"size"`
+	ArchivedAt time.Time `json:"archivedAt"`
+}
+
+// ReportArchive - долговременное хранилище отчетов для аудита.
+// Отчеты раскладываются по ключам вида "<launchID>/<reportID>/<fileName>".
+type ReportArchive interface {
+	// Put - сохраняет отчет целиком; при ошибке чтения r отчет не сохраняется
+	Put(ctx context.Context, report Report, r io.Reader) (*Report, error)
+	// List - возвращает отчеты запуска, при launchID == 0 - все отчеты архива
+	List(ctx context.Context, launchID int64) ([]Report, error)
+	// Open - открывает отчет запуска; вызывающий обязан закрыть поток
+	Open(ctx context.Context, launchID int64, reportID string) (*Report, io.ReadCloser, error)
+}
+
+// objectKey - ключ отчета в архиве
+func objectKey(report Report) (string, error) {
+	if report.LaunchID < 0 || !reportIDPattern.MatchString(report.ReportID) {
+		return "", fmt.Errorf("%w: запуск %d, отчет %q", ErrInvalidReport, report.LaunchID, report.ReportID)
+	}
+
+	fileName := path.Base(strings.ReplaceAll(report.FileName, "\\", "/"))
+	if fileName == "" || fileName == "." || fileName == "/" || fileName == ".." {
+		return "", fmt.Errorf("%w: имя файла %q", ErrInvalidReport, report.FileName)
+	}
+
+	return path.Join(strconv.FormatInt(report.LaunchID, 10), report.ReportID, fileName), nil
+}
+
+// reportPrefix - префикс ключей отчета или всех отчетов запуска, если reportID пустой
+func reportPrefix(launchID int64, reportID string) (string, error) {
+	if launchID < 0 || (reportID != "" && !reportIDPattern.MatchString(reportID)) {
+		return "", fmt.Errorf("%w: запуск %d, отчет %q", ErrInvalidReport, launchID, reportID)
+	}
+
+	prefix := strconv.FormatInt(launchID, 10) + "/"
+	if reportID != "" {
+		prefix += reportID + "/"
+	}
+	return prefix, nil
+}
+
+// parseObjectKey - восстанавливает описание отчета по ключу; false для посторонних ключей
+func parseObjectKey(key string) (Report, bool) {
+	parts := strings.Split(key, "/")
+	if len(parts) != 3 || !reportIDPattern.MatchString(parts[1]) || parts[2] == "" {
+		return Report{}, false
+	}
+
+	launchID, err := strconv.ParseInt(parts[0], 10, 64)
+	if err != nil || launchID < 0 {
+		return Report{}, false
+	}
+
+	return Report{LaunchID: launchID, ReportID: parts[1], FileName: parts[2]}, true
+}
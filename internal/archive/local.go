@@ -0,0 +1,222 @@
+package archive
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/rs/zerolog/log"
+)
+
+// Local - архив отчетов в каталоге локальной файловой системы
+type Local struct {
+	dir string
+}
+
+// NewLocal - открывает архив в каталоге dir, создавая его при необходимости
+func NewLocal(dir string) (*Local, error) {
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return nil, fmt.Errorf("ошибка создания каталога архива отчетов: %w", err)
+	}
+
+	log.Info().Msgf("🗃️ Архив отчетов: каталог %s", dir)
+	return &Local{dir: dir}, nil
+}
+
+// Put - сохраняет отчет через временный файл, чтобы в архиве не оставалось недописанных отчетов
+func (l *Local) Put(ctx context.Context, report Report, r io.Reader) (*Report, error) {
+	key, err := objectKey(report)
+	if err != nil {
+		return nil, err
+	}
+
+	target := filepath.Join(l.dir, filepath.FromSlash(key))
+	if err := os.MkdirAll(filepath.Dir(target), 0o755); err != nil {
+		return nil, err
+	}
+
+	tmp, err := os.CreateTemp(filepath.Dir(target), ".upload-*")
+	if err != nil {
+		return nil, err
+	}
+	defer os.Remove(tmp.Name()) // После переименования удалять уже нечего
+
+	size, err := io.Copy(tmp, contextReader{ctx: ctx, r: r})
+	if closeErr := tmp.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	// Отчет с тем же ID мог быть сохранен под другим именем файла
+	if err := l.removeReport(report.LaunchID, report.ReportID); err != nil {
+		return nil, err
+	}
+	if err := os.Rename(tmp.Name(), target); err != nil {
+		return nil, err
+	}
+
+	info, err := os.Stat(target)
+	if err != nil {
+		return nil, err
+	}
+
+	stored, _ := parseObjectKey(key)
+	stored.Size = size
+	stored.ArchivedAt = info.ModTime()
+	return &stored, nil
+}
+
+// List - возвращает отчеты запуска в порядке сохранения
+func (l *Local) List(ctx context.Context, launchID int64) ([]Report, error) {
+	root := l.dir
+	if launchID != 0 {
+		prefix, err := reportPrefix(launchID, "")
+		if err != nil {
+			return nil, err
+		}
+		root = filepath.Join(l.dir, filepath.FromSlash(prefix))
+	}
+
+	reports := []Report{}
+	err := filepath.WalkDir(root, func(path string, entry fs.DirEntry, err error) error {
+		if err != nil {
+			if os.IsNotExist(err) {
+				return nil
+			}
+			return err
+		}
+		if ctx.Err() != nil {
+			return ctx.Err()
+		}
+		if entry.IsDir() || strings.HasPrefix(entry.Name(), ".upload-") {
+			return nil
+		}
+
+		rel, err := filepath.Rel(l.dir, path)
+		if err != nil {
+			return err
+		}
+		report, ok := parseObjectKey(filepath.ToSlash(rel))
+		if !ok {
+			return nil
+		}
+
+		info, err := entry.Info()
+		if err != nil {
+			return err
+		}
+		report.Size = info.Size()
+		report.ArchivedAt = info.ModTime()
+		reports = append(reports, report)
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	sortReports(reports)
+	return reports, nil
+}
+
+// Open - открывает отчет запуска
+func (l *Local) Open(ctx context.Context, launchID int64, reportID string) (*Report, io.ReadCloser, error) {
+	path, err := l.findReport(launchID, reportID)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	file, err := os.Open(path)
+	if os.IsNotExist(err) {
+		return nil, nil, fmt.Errorf("%w: запуск %d, отчет %s", ErrNotFound, launchID, reportID)
+	}
+	if err != nil {
+		return nil, nil, err
+	}
+
+	info, err := file.Stat()
+	if err != nil {
+		file.Close()
+		return nil, nil, err
+	}
+
+	return &Report{
+		LaunchID:   launchID,
+		ReportID:   reportID,
+		FileName:   filepath.Base(path),
+		Size:       info.Size(),
+		ArchivedAt: info.ModTime(),
+	}, file, nil
+}
+
+// findReport - ищет файл отчета в его каталоге
+func (l *Local) findReport(launchID int64, reportID string) (string, error) {
+	prefix, err := reportPrefix(launchID, reportID)
+	if err != nil {
+		return "", err
+	}
+
+	files, err := os.ReadDir(filepath.Join(l.dir, filepath.FromSlash(prefix)))
+	if err != nil && !os.IsNotExist(err) {
+		return "", err
+	}
+	for _, file := range files {
+		if !file.IsDir() && !strings.HasPrefix(file.Name(), ".upload-") {
+			return filepath.Join(l.dir, filepath.FromSlash(prefix), file.Name()), nil
+		}
+	}
+
+	return "", fmt.Errorf("%w: запуск %d, отчет %s", ErrNotFound, launchID, reportID)
+}
+
+// removeReport - удаляет ранее сохраненные файлы отчета
+func (l *Local) removeReport(launchID int64, reportID string) error {
+	prefix, err := reportPrefix(launchID, reportID)
+	if err != nil {
+		return err
+	}
+
+	files, err := os.ReadDir(filepath.Join(l.dir, filepath.FromSlash(prefix)))
+	if err != nil {
+		return nil
+	}
+	for _, file := range files {
+		if !file.IsDir() && !strings.HasPrefix(file.Name(), ".upload-") {
+			if err := os.Remove(filepath.Join(l.dir, filepath.FromSlash(prefix), file.Name())); err != nil {
+				return err
+			}
+		}
+	}
+
+	return nil
+}
+
+// sortReports - упорядочивает отчеты по времени сохранения
+func sortReports(reports []Report) {
+	sort.Slice(reports, func(i, j int) bool {
+		if !reports[i].ArchivedAt.Equal(reports[j].ArchivedAt) {
+			return reports[i].ArchivedAt.Before(reports[j].ArchivedAt)
+		}
+		return reports[i].ReportID < reports[j].ReportID
+	})
+}
+
+// contextReader - поток, прерывающий чтение после отмены контекста
+type contextReader struct {
+	ctx context.Context
+	r   io.Reader
+}
+
+// Read - читает из исходного потока, пока контекст жив
+func (c contextReader) Read(p []byte) (int, error) {
+	if err := c.ctx.Err(); err != nil {
+		return 0, err
+	}
+	return c.r.Read(p)
+}
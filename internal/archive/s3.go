@@ -0,0 +1,381 @@
+package archive
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"path"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/rs/zerolog/log"
+)
+
+// S3Config - параметры S3-совместимого хранилища (AWS S3, MinIO и т.п.)
+type S3Config struct {
+	Endpoint  string // Адрес хранилища, например http://minio:9000
+	Region    string // Регион для подписи запросов, по умолчанию us-east-1
+	Bucket    string
+	Prefix    string // Необязательный префикс ключей внутри бакета
+	AccessKey string
+	SecretKey string
+}
+
+// emptyPayloadHash - SHA-256 пустого тела запроса
+const emptyPayloadHash = "e3b0c44298fc1c149afbfc8996fb92427ae41e4649b934ca495991b7852b855"
+
+// S3 - архив отчетов в S3-совместимом хранилище.
+// Используется адресация бакета в пути (path-style), которую поддерживают и AWS, и MinIO.
+type S3 struct {
+	cfg      S3Config
+	endpoint *url.URL
+	client   *http.Client
+}
+
+// NewS3 - создание архива в S3-совместимом хранилище
+func NewS3(cfg S3Config) (*S3, error) {
+	endpoint, err := url.Parse(strings.TrimRight(cfg.Endpoint, "/"))
+	if err != nil || endpoint.Scheme == "" || endpoint.Host == "" {
+		return nil, fmt.Errorf("некорректный адрес S3-хранилища: %q", cfg.Endpoint)
+	}
+	if cfg.Bucket == "" || cfg.AccessKey == "" || cfg.SecretKey == "" {
+		return nil, fmt.Errorf("для S3-архива нужно задать бакет и ключи доступа")
+	}
+	if cfg.Region == "" {
+		cfg.Region = "us-east-1"
+	}
+	cfg.Prefix = strings.Trim(cfg.Prefix, "/")
+
+	log.Info().Msgf("🗃️ Архив отчетов: S3 %s, бакет %s", endpoint.Host, cfg.Bucket)
+	return &S3{
+		cfg:      cfg,
+		endpoint: endpoint,
+		client:   &http.Client{Timeout: 5 * time.Minute},
+	}, nil
+}
+
+// Put - загружает отчет в бакет. Поток сначала сохраняется во временный файл:
+// S3 требует заранее известный размер и контрольную сумму тела.
+func (s *S3) Put(ctx context.Context, report Report, r io.Reader) (*Report, error) {
+	key, err := objectKey(report)
+	if err != nil {
+		return nil, err
+	}
+
+	tmp, err := os.CreateTemp("", "allure-archive-*")
+	if err != nil {
+		return nil, err
+	}
+	defer os.Remove(tmp.Name())
+	defer tmp.Close()
+
+	hash := sha256.New()
+	size, err := io.Copy(io.MultiWriter(tmp, hash), contextReader{ctx: ctx, r: r})
+	if err != nil {
+		return nil, err
+	}
+	if _, err := tmp.Seek(0, io.SeekStart); err != nil {
+		return nil, err
+	}
+
+	// Отчет с тем же ID мог быть сохранен под другим именем файла
+	existing, err := s.listKeys(ctx, s.fullKey(path.Dir(key)+"/"))
+	if err != nil {
+		return nil, err
+	}
+
+	req, err := s.newRequest(ctx, http.MethodPut, s.fullKey(key), nil, io.NopCloser(tmp), hex.EncodeToString(hash.Sum(nil)))
+	if err != nil {
+		return nil, err
+	}
+	req.ContentLength = size
+	req.Header.Set("Content-Type", "application/pdf")
+
+	resp, err := s.do(req)
+	if err != nil {
+		return nil, err
+	}
+	resp.Body.Close()
+
+	for _, object := range existing {
+		if object.Key != s.fullKey(key) {
+			s.deleteObject(ctx, object.Key)
+		}
+	}
+
+	stored, _ := parseObjectKey(key)
+	stored.Size = size
+	stored.ArchivedAt = time.Now().UTC()
+	return &stored, nil
+}
+
+// List - возвращает отчеты запуска в порядке сохранения
+func (s *S3) List(ctx context.Context, launchID int64) ([]Report, error) {
+	prefix := ""
+	if launchID != 0 {
+		var err error
+		if prefix, err = reportPrefix(launchID, ""); err != nil {
+			return nil, err
+		}
+	}
+
+	objects, err := s.listKeys(ctx, s.fullKey(prefix))
+	if err != nil {
+		return nil, err
+	}
+
+	reports := []Report{}
+	for _, object := range objects {
+		report, ok := parseObjectKey(s.relativeKey(object.Key))
+		if !ok {
+			continue
+		}
+		report.Size = object.Size
+		report.ArchivedAt = object.LastModified
+		reports = append(reports, report)
+	}
+
+	sortReports(reports)
+	return reports, nil
+}
+
+// Open - открывает отчет запуска потоком из бакета
+func (s *S3) Open(ctx context.Context, launchID int64, reportID string) (*Report, io.ReadCloser, error) {
+	prefix, err := reportPrefix(launchID, reportID)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	objects, err := s.listKeys(ctx, s.fullKey(prefix))
+	if err != nil {
+		return nil, nil, err
+	}
+	if len(objects) == 0 {
+		return nil, nil, fmt.Errorf("%w: запуск %d, отчет %s", ErrNotFound, launchID, reportID)
+	}
+
+	object := objects[0]
+	report, ok := parseObjectKey(s.relativeKey(object.Key))
+	if !ok {
+		return nil, nil, fmt.Errorf("%w: запуск %d, отчет %s", ErrNotFound, launchID, reportID)
+	}
+
+	req, err := s.newRequest(ctx, http.MethodGet, object.Key, nil, nil, emptyPayloadHash)
+	if err != nil {
+		return nil, nil, err
+	}
+	resp, err := s.do(req)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	report.Size = resp.ContentLength
+	report.ArchivedAt = object.LastModified
+	return &report, resp.Body, nil
+}
+
+// s3Object - объект из ответа ListObjectsV2
+type s3Object struct {
+	Key          string    `xml:"Key"`
+	Size         int64     `xml:"Size"`
+	LastModified time.Time `xml:"LastModified"`
+}
+
+// listKeys - перечисляет все объекты бакета с префиксом, проходя по страницам ответа
+func (s *S3) listKeys(ctx context.Context, prefix string) ([]s3Object, error) {
+	var objects []s3Object
+	token := ""
+
+	for {
+		query := url.Values{"list-type": {"2"}, "prefix": {prefix}}
+		if token != "" {
+			query.Set("continuation-token", token)
+		}
+
+		req, err := s.newRequest(ctx, http.MethodGet, "", query, nil, emptyPayloadHash)
+		if err != nil {
+			return nil, err
+		}
+		resp, err := s.do(req)
+		if err != nil {
+			return nil, err
+		}
+
+		var page struct {
+			Contents              []s3Object `xml:"Contents"`
+			IsTruncated           bool       `xml:"IsTruncated"`
+			NextContinuationToken string     `xml:"NextContinuationToken"`
+		}
+		err = xml.NewDecoder(resp.Body).Decode(&page)
+		resp.Body.Close()
+		if err != nil {
+			return nil, fmt.Errorf("ошибка разбора списка объектов S3: %w", err)
+		}
+
+		objects = append(objects, page.Contents...)
+		if !page.IsTruncated || page.NextContinuationToken == "" {
+			return objects, nil
+		}
+		token = page.NextContinuationToken
+	}
+}
+
+// deleteObject - удаляет объект; ошибка только логируется, так как новый отчет уже сохранен
+func (s *S3) deleteObject(ctx context.Context, key string) {
+	req, err := s.newRequest(ctx, http.MethodDelete, key, nil, nil, emptyPayloadHash)
+	if err == nil {
+		var resp *http.Response
+		if resp, err = s.do(req); err == nil {
+			resp.Body.Close()
+		}
+	}
+	if err != nil {
+		log.Warn().Err(err).Msgf("⚠️ Ошибка удаления устаревшего объекта архива %s", key)
+	}
+}
+
+// fullKey - ключ объекта с учетом префикса архива
+func (s *S3) fullKey(key string) string {
+	if s.cfg.Prefix == "" {
+		return key
+	}
+	return s.cfg.Prefix + "/" + key
+}
+
+// relativeKey - ключ объекта без префикса архива
+func (s *S3) relativeKey(key string) string {
+	if s.cfg.Prefix == "" {
+		return key
+	}
+	return strings.TrimPrefix(key, s.cfg.Prefix+"/")
+}
+
+// do - выполняет запрос и превращает ответы с ошибкой в error
+func (s *S3) do(req *http.Request) (*http.Response, error) {
+	resp, err := s.client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+
+	switch {
+	case resp.StatusCode >= 200 && resp.StatusCode < 300:
+		return resp, nil
+	case resp.StatusCode == http.StatusNotFound:
+		resp.Body.Close()
+		return nil, fmt.Errorf("%w: %s", ErrNotFound, req.URL.Path)
+	default:
+		body, _ := io.ReadAll(io.LimitReader(resp.Body, 1024))
+		resp.Body.Close()
+		return nil, fmt.Errorf("ошибка S3-хранилища: %s %s: статус %d: %s", req.Method, req.URL.Path, resp.StatusCode, strings.TrimSpace(string(body)))
+	}
+}
+
+// newRequest - формирует запрос к объекту бакета (или к самому бакету при пустом key),
+// подписанный по AWS Signature Version 4
+func (s *S3) newRequest(ctx context.Context, method, key string, query url.Values, body io.ReadCloser, payloadHash string) (*http.Request, error) {
+	target := *s.endpoint
+	target.Path = s.endpoint.Path + "/" + s.cfg.Bucket
+	if key != "" {
+		target.Path += "/" + key
+	}
+	target.RawPath = s.endpoint.Path + "/" + uriEncode(s.cfg.Bucket, false)
+	if key != "" {
+		target.RawPath += "/" + uriEncode(key, false)
+	}
+	target.RawQuery = canonicalQuery(query)
+
+	req, err := http.NewRequestWithContext(ctx, method, target.String(), body)
+	if err != nil {
+		return nil, err
+	}
+
+	s.sign(req, target.RawPath, payloadHash, time.Now().UTC())
+	return req, nil
+}
+
+// sign - добавляет заголовки подписи AWS Signature Version 4
+func (s *S3) sign(req *http.Request, canonicalURI, payloadHash string, now time.Time) {
+	amzDate := now.Format("20060102T150405Z")
+	day := now.Format("20060102")
+
+	req.Header.Set("X-Amz-Date", amzDate)
+	req.Header.Set("X-Amz-Content-Sha256", payloadHash)
+
+	signedHeaders := "host;x-amz-content-sha256;x-amz-date"
+	canonicalRequest := strings.Join([]string{
+		req.Method,
+		canonicalURI,
+		req.URL.RawQuery,
+		"host:" + req.URL.Host,
+		"x-amz-content-sha256:" + payloadHash,
+		"x-amz-date:" + amzDate,
+		"",
+		signedHeaders,
+		payloadHash,
+	}, "\n")
+
+	scope := day + "/" + s.cfg.Region + "/s3/aws4_request"
+	requestHash := sha256.Sum256([]byte(canonicalRequest))
+	stringToSign := "AWS4-HMAC-SHA256\n" + amzDate + "\n" + scope + "\n" + hex.EncodeToString(requestHash[:])
+
+	key := hmacSHA256([]byte("AWS4"+s.cfg.SecretKey), day)
+	key = hmacSHA256(key, s.cfg.Region)
+	key = hmacSHA256(key, "s3")
+	key = hmacSHA256(key, "aws4_request")
+	signature := hex.EncodeToString(hmacSHA256(key, stringToSign))
+
+	req.Header.Set("Authorization", fmt.Sprintf("AWS4-HMAC-SHA256 Credential=%s/%s, SignedHeaders=%s, Signature=%s",
+		s.cfg.AccessKey, scope, signedHeaders, signature))
+}
+
+// hmacSHA256 - HMAC-SHA256 строки data на ключе key
+func hmacSHA256(key []byte, data string) []byte {
+	mac := hmac.New(sha256.New, key)
+	mac.Write([]byte(data))
+	return mac.Sum(nil)
+}
+
+// canonicalQuery - строка запроса в каноническом для подписи виде: ключи по алфавиту, RFC 3986
+func canonicalQuery(query url.Values) string {
+	keys := make([]string, 0, len(query))
+	for key := range query {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	var parts []string
+	for _, key := range keys {
+		values := append([]string(nil), query[key]...)
+		sort.Strings(values)
+		for _, value := range values {
+			parts = append(parts, uriEncode(key, true)+"="+uriEncode(value, true))
+		}
+	}
+	return strings.Join(parts, "&")
+}
+
+// uriEncode - кодирование по правилам AWS: не кодируются только незарезервированные символы RFC 3986,
+// а "/" сохраняется в путях
+func uriEncode(value string, encodeSlash bool) string {
+	var b strings.Builder
+	for i := 0; i < len(value); i++ {
+		c := value[i]
+		switch {
+		case c >= 'A' && c <= 'Z', c >= 'a' && c <= 'z', c >= '0' && c <= '9', c == '-', c == '_', c == '.', c == '~':
+			b.WriteByte(c)
+		case c == '/' && !encodeSlash:
+			b.WriteByte(c)
+		default:
+			fmt.Fprintf(&b, "%%%02X", c)
+		}
+	}
+	return b.String()
+}
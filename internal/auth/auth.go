@@ -0,0 +1,254 @@
+package auth
+
+import (
+	"context"
+	"crypto/sha256"
+	"crypto/subtle"
+	"errors"
+	"fmt"
+	"slices"
+	"strings"
+	"sync/atomic"
+
+	"github.com/rs/zerolog/log"
+	"github.com/vkr-mtuci/allure-service/config"
+)
+
+var (
+	// ErrUnauthenticated - учетные данные не переданы или не подошли
+	ErrUnauthenticated = errors.New("требуется аутентификация")
+	// ErrForbidden - вызывающему не разрешены проект или операция
+	ErrForbidden = errors.New("доступ запрещен")
+)
+
+// Caller - вызывающий, прошедший аутентификацию, и его права
+type Caller struct {
+	Name       string
+	projects   map[ProjectGrant]bool // nil - все проекты всех экземпляров
+	operations map[string]bool       // nil - все операции
+}
+
+// ProjectGrant - проект экземпляра Allure, разрешенный вызывающему. Пустой Instance - экземпляр
+// по умолчанию, ProjectID 0 - все проекты экземпляра.
+type ProjectGrant struct {
+	Instance  string
+	ProjectID int64
+}
+
+// Instance - экземпляр Allure, к которому относится запрос
+type Instance struct {
+	Name    string
+	Default bool // К экземпляру по умолчанию относятся и права на проекты без имени экземпляра
+}
+
+// NewCaller - вызывающий с правами на проекты и операции; nil означает все проекты или все операции,
+// пустой список - ни одного
+func NewCaller(name string, projects []ProjectGrant, operations []string) *Caller {
+	caller := &Caller{Name: name}
+	if projects != nil {
+		caller.projects = make(map[ProjectGrant]bool, len(projects))
+		for _, grant := range projects {
+			caller.projects[grant] = true
+		}
+	}
+	if operations != nil {
+		caller.operations = make(map[string]bool, len(operations))
+		for _, operation := range operations {
+			caller.operations[operation] = true
+		}
+	}
+	return caller
+}
+
+// CanAccessProject - разрешен ли вызывающему проект экземпляра
+func (c *Caller) CanAccessProject(instance Instance, projectID int64) bool {
+	return c.AllProjects(instance) || c.granted(instance, projectID)
+}
+
+// AllProjects - разрешены ли вызывающему все проекты экземпляра
+func (c *Caller) AllProjects(instance Instance) bool {
+	return c.projects == nil || c.granted(instance, 0)
+}
+
+// granted - выдано ли право на проект экземпляра по имени экземпляра или как экземпляру по умолчанию
+func (c *Caller) granted(instance Instance, projectID int64) bool {
+	return c.projects[ProjectGrant{Instance: instance.Name, ProjectID: projectID}] ||
+		(instance.Default && c.projects[ProjectGrant{ProjectID: projectID}])
+}
+
+// Can - разрешена ли вызывающему операция
+func (c *Caller) Can(operation string) bool {
+	return c.operations == nil || c.operations[operation]
+}
+
+// callerKey - ключ вызывающего в контексте запроса
+type callerKey struct{}
+
+// WithCaller - контекст с вызывающим
+func WithCaller(ctx context.Context, caller *Caller) context.Context {
+	return context.WithValue(ctx, callerKey{}, caller)
+}
+
+// CallerFrom - вызывающий из контекста; nil, если аутентификация отключена
+func CallerFrom(ctx context.Context) *Caller {
+	caller, _ := ctx.Value(callerKey{}).(*Caller)
+	return caller
+}
+
+// instanceKey - ключ экземпляра Allure в контексте запроса
+type instanceKey struct{}
+
+// WithInstance - контекст с экземпляром Allure, к которому относится запрос
+func WithInstance(ctx context.Context, instance Instance) context.Context {
+	return context.WithValue(ctx, instanceKey{}, instance)
+}
+
+// InstanceFrom - экземпляр Allure из контекста; без него запрос относится к экземпляру по умолчанию
+func InstanceFrom(ctx context.Context) Instance {
+	if instance, ok := ctx.Value(instanceKey{}).(Instance); ok {
+		return instance
+	}
+	return Instance{Default: true}
+}
+
+// CheckProject - проверяет, что вызывающему из контекста разрешен проект
+func CheckProject(ctx context.Context, projectID int64) error {
+	if caller := CallerFrom(ctx); caller != nil && !caller.CanAccessProject(InstanceFrom(ctx), projectID) {
+		return fmt.Errorf("%w: %s не имеет доступа к проекту %d", ErrForbidden, caller.Name, projectID)
+	}
+	return nil
+}
+
+// CheckAllProjects - проверяет, что вызывающему из контекста разрешены все проекты экземпляра.
+// Нужна для данных, не разделенных по проектам, например архива отчетов.
+func CheckAllProjects(ctx context.Context) error {
+	if caller := CallerFrom(ctx); caller != nil && !caller.AllProjects(InstanceFrom(ctx)) {
+		return fmt.Errorf("%w: %s имеет доступ не ко всем проектам", ErrForbidden, caller.Name)
+	}
+	return nil
+}
+
+// Authenticator - проверяет API-ключи и JWT вызывающих.
+// Настройки заменяются целиком, поэтому Reload не мешает начатым проверкам.
+type Authenticator struct {
+	settings atomic.Pointer[settings]
+}
+
+// settings - API-ключи и проверка JWT
+type settings struct {
+	disabled bool // Аутентификация отключена явно
+	keys     []apiKey
+	jwt      *jwtVerifier // nil - JWT не принимаются
+}
+
+// apiKey - хеш ключа: сравнение хешей одинаковой длины не раскрывает длину ключа
+type apiKey struct {
+	hash   [sha256.Size]byte
+	caller *Caller
+}
+
+// NewAuthenticator - аутентификация по настройкам cfg
+func NewAuthenticator(cfg config.Auth) (*Authenticator, error) {
+	a := &Authenticator{}
+	if err := a.Reload(cfg); err != nil {
+		return nil, err
+	}
+	return a, nil
+}
+
+// Reload - заменяет API-ключи и настройки JWT; при ошибке остаются прежние настройки.
+// Без ключей и JWT аутентификацию нужно отключить явно.
+func (a *Authenticator) Reload(cfg config.Auth) error {
+	if !cfg.Disabled && !cfg.Enabled() {
+		return errors.New("не заданы ни API-ключи, ни ключи JWT, а аутентификация не отключена")
+	}
+
+	next := &settings{disabled: cfg.Disabled}
+	for _, key := range cfg.APIKeys {
+		projects, err := projectGrants(key.Projects)
+		if err != nil {
+			return fmt.Errorf("API-ключ %s: %w", key.Name, err)
+		}
+		if len(key.Projects) == 0 || len(key.Operations) == 0 {
+			log.Warn().Msgf("⚠️ API-ключу %s не разрешены проекты или операции, укажите %q, чтобы разрешить все", key.Name, config.AllowAll)
+		}
+
+		next.keys = append(next.keys, apiKey{
+			hash:   sha256.Sum256([]byte(key.Key)),
+			caller: NewCaller(key.Name, projects, operationList(key.Operations)),
+		})
+	}
+
+	if cfg.JWT.Enabled() {
+		verifier, err := newJWTVerifier(cfg.JWT)
+		if err != nil {
+			return err
+		}
+		next.jwt = verifier
+	}
+
+	a.settings.Store(next)
+	return nil
+}
+
+// Enabled - включена ли аутентификация
+func (a *Authenticator) Enabled() bool {
+	return !a.settings.Load().disabled
+}
+
+// Authenticate - определяет вызывающего по API-ключу или bearer-токену.
+// Bearer-токен проверяется как JWT, если похож на него и JWT настроены, иначе как API-ключ.
+func (a *Authenticator) Authenticate(ctx context.Context, key, bearer string) (*Caller, error) {
+	current := a.settings.Load()
+	switch {
+	case key != "":
+		return current.matchKey(key)
+	case bearer == "":
+		return nil, ErrUnauthenticated
+	case current.jwt != nil && strings.Count(bearer, ".") == 2:
+		return current.jwt.verify(ctx, bearer)
+	default:
+		return current.matchKey(bearer)
+	}
+}
+
+// matchKey - ищет вызывающего по API-ключу; сравниваются все ключи, чтобы время ответа не зависело от совпадения
+func (s *settings) matchKey(key string) (*Caller, error) {
+	hash := sha256.Sum256([]byte(key))
+	var found *Caller
+	for _, candidate := range s.keys {
+		if subtle.ConstantTimeCompare(hash[:], candidate.hash[:]) == 1 {
+			found = candidate.caller
+		}
+	}
+	if found == nil {
+		return nil, fmt.Errorf("%w: неизвестный API-ключ", ErrUnauthenticated)
+	}
+	return found, nil
+}
+
+// projectGrants - разрешенные проекты; config.AllowAll - все проекты всех экземпляров (nil),
+// пустой список не разрешает ни одного
+func projectGrants(refs []config.ProjectRef) ([]ProjectGrant, error) {
+	if slices.Contains(refs, config.AllowAll) {
+		return nil, nil
+	}
+
+	grants := make([]ProjectGrant, 0, len(refs))
+	for _, ref := range refs {
+		instance, projectID, err := ref.Parse()
+		if err != nil {
+			return nil, err
+		}
+		grants = append(grants, ProjectGrant{Instance: instance, ProjectID: projectID})
+	}
+	return grants, nil
+}
+
+// operationList - разрешенные операции; config.AllowAll - все (nil), пустой список не разрешает ни одной
+func operationList(items []string) []string {
+	if slices.Contains(items, config.AllowAll) {
+		return nil
+	}
+	return append([]string{}, items...)
+}
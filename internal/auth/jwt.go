@@ -0,0 +1,370 @@
+package auth
+
+import (
+	"context"
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rsa"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math/big"
+	"net/http"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/go-resty/resty/v2"
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/rs/zerolog/log"
+	"github.com/vkr-mtuci/allure-service/config"
+)
+
+const (
+	// jwksTimeout - ограничение на загрузку ключей по URL
+	jwksTimeout = 10 * time.Second
+	// jwksMissInterval - не чаще этого ключи перезагружаются из-за неизвестного kid
+	jwksMissInterval = time.Minute
+	// jwtLeeway - допустимое расхождение часов с сервером, выпустившим токен
+	jwtLeeway = 30 * time.Second
+)
+
+// jwtMethods - алгоритмы подписи с открытым ключом; HS* не принимаются, ключи JWKS - открытые
+var jwtMethods = []string{"RS256", "RS384", "RS512", "PS256", "PS384", "PS512", "ES256", "ES384", "ES512"}
+
+// errUnknownKey - в JWKS нет ключа, которым подписан токен
+var errUnknownKey = errors.New("неизвестный ключ подписи")
+
+// jwtVerifier - проверка JWT по ключам JWKS из файла или по URL
+type jwtVerifier struct {
+	cfg    config.JWTAuth
+	parser *jwt.Parser
+	client *resty.Client
+
+	keys atomic.Pointer[jwkSet] // Загруженные ключи, заменяются целиком
+
+	mu       sync.Mutex // Защищает loading и missedAt; загрузка ключей идет без него
+	loading  *jwksLoad
+	missedAt time.Time
+}
+
+// jwkSet - загруженные ключи по kid и время их загрузки
+type jwkSet struct {
+	keys     map[string]crypto.PublicKey
+	loadedAt time.Time
+}
+
+// jwksLoad - одна загрузка ключей по URL, результата которой ждут все проверки токенов.
+// err заполняется до закрытия done.
+type jwksLoad struct {
+	done chan struct{}
+	err  error
+}
+
+// newJWTVerifier - загружает ключи JWKS. Файл с ошибкой не дает запустить проверку;
+// недоступный URL только пишется в лог, ключи загрузятся при первом токене.
+func newJWTVerifier(cfg config.JWTAuth) (*jwtVerifier, error) {
+	options := []jwt.ParserOption{
+		jwt.WithValidMethods(jwtMethods),
+		jwt.WithExpirationRequired(),
+		jwt.WithLeeway(jwtLeeway),
+	}
+	if cfg.Issuer != "" {
+		options = append(options, jwt.WithIssuer(cfg.Issuer))
+	}
+	if cfg.Audience != "" {
+		options = append(options, jwt.WithAudience(cfg.Audience))
+	}
+
+	v := &jwtVerifier{
+		cfg:    cfg,
+		parser: jwt.NewParser(options...),
+		client: resty.New().SetTimeout(jwksTimeout),
+	}
+
+	if cfg.JWKSFile != "" {
+		data, err := os.ReadFile(cfg.JWKSFile)
+		if err != nil {
+			return nil, fmt.Errorf("ошибка чтения JWKS %s: %w", cfg.JWKSFile, err)
+		}
+		keys, err := parseJWKS(data)
+		if err != nil {
+			return nil, fmt.Errorf("ошибка разбора JWKS %s: %w", cfg.JWKSFile, err)
+		}
+		v.keys.Store(&jwkSet{keys: keys, loadedAt: time.Now()})
+		return v, nil
+	}
+
+	if err := v.load(context.Background()); err != nil {
+		log.Warn().Err(err).Msgf("⚠️ Ключи JWT не загружены из %s, повторим при первом токене", cfg.JWKSURL)
+	}
+	return v, nil
+}
+
+// verify - проверяет подпись и сроки токена и определяет права вызывающего по claims
+func (v *jwtVerifier) verify(ctx context.Context, token string) (*Caller, error) {
+	claims := jwt.MapClaims{}
+	_, err := v.parser.ParseWithClaims(token, claims, func(token *jwt.Token) (interface{}, error) {
+		kid, _ := token.Header["kid"].(string)
+		return v.key(ctx, kid)
+	})
+	if err != nil {
+		return nil, fmt.Errorf("%w: %w", ErrUnauthenticated, err)
+	}
+
+	name, _ := claims.GetSubject()
+	projects, err := projectsClaim(claims[v.cfg.ProjectsClaim])
+	if err != nil {
+		return nil, fmt.Errorf("%w: claim %s: %w", ErrUnauthenticated, v.cfg.ProjectsClaim, err)
+	}
+	operations, err := operationsClaim(claims[v.cfg.OperationsClaim])
+	if err != nil {
+		return nil, fmt.Errorf("%w: claim %s: %w", ErrUnauthenticated, v.cfg.OperationsClaim, err)
+	}
+
+	return NewCaller(name, projects, operations), nil
+}
+
+// key - открытый ключ по kid. Ключи по URL перезагружаются раз в JWKSRefresh
+// и при неизвестном kid, но не чаще jwksMissInterval.
+func (v *jwtVerifier) key(ctx context.Context, kid string) (crypto.PublicKey, error) {
+	set := v.keys.Load()
+	if v.cfg.JWKSURL != "" && (set == nil || time.Since(set.loadedAt) > v.cfg.JWKSRefresh) {
+		if err := v.load(ctx); err != nil {
+			log.Warn().Err(err).Msg("⚠️ Ошибка обновления ключей JWT, используются прежние")
+		}
+		set = v.keys.Load()
+	}
+	if key, ok := set.lookup(kid); ok {
+		return key, nil
+	}
+
+	if v.cfg.JWKSURL != "" && v.markMiss() {
+		if err := v.load(ctx); err != nil {
+			return nil, err
+		}
+		if key, ok := v.keys.Load().lookup(kid); ok {
+			return key, nil
+		}
+	}
+	return nil, fmt.Errorf("%w: kid %q", errUnknownKey, kid)
+}
+
+// markMiss - отмечает неизвестный kid; false, если ключи уже перезагружались из-за него недавно
+func (v *jwtVerifier) markMiss() bool {
+	v.mu.Lock()
+	defer v.mu.Unlock()
+
+	if time.Since(v.missedAt) <= jwksMissInterval {
+		return false
+	}
+	v.missedAt = time.Now()
+	return true
+}
+
+// lookup - ключ по kid; токен без kid подходит, если ключ один
+func (s *jwkSet) lookup(kid string) (crypto.PublicKey, bool) {
+	if s == nil {
+		return nil, false
+	}
+	if key, ok := s.keys[kid]; ok {
+		return key, true
+	}
+	if kid == "" && len(s.keys) == 1 {
+		for _, key := range s.keys {
+			return key, true
+		}
+	}
+	return nil, false
+}
+
+// load - загружает ключи по URL или ждет уже начатой загрузки.
+// Прежние ключи остаются доступны другим проверкам, пока идет загрузка.
+func (v *jwtVerifier) load(ctx context.Context) error {
+	v.mu.Lock()
+	load := v.loading
+	if load == nil {
+		load = &jwksLoad{done: make(chan struct{})}
+		v.loading = load
+		// Загрузка не привязана к отмене запроса, который ее начал: ее ждут и другие запросы
+		go v.runLoad(context.WithoutCancel(ctx), load)
+	}
+	v.mu.Unlock()
+
+	select {
+	case <-load.done:
+		return load.err
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// runLoad - загружает ключи по URL и публикует их
+func (v *jwtVerifier) runLoad(ctx context.Context, load *jwksLoad) {
+	keys, err := v.fetch(ctx)
+	if err == nil {
+		v.keys.Store(&jwkSet{keys: keys, loadedAt: time.Now()})
+		log.Info().Msgf("🔑 Загружено ключей JWT: %d", len(keys))
+	}
+	load.err = err
+
+	v.mu.Lock()
+	v.loading = nil
+	v.mu.Unlock()
+
+	close(load.done)
+}
+
+// fetch - получает ключи по URL
+func (v *jwtVerifier) fetch(ctx context.Context) (map[string]crypto.PublicKey, error) {
+	resp, err := v.client.R().SetContext(ctx).Get(v.cfg.JWKSURL)
+	if err != nil {
+		return nil, fmt.Errorf("ошибка загрузки JWKS: %w", err)
+	}
+	if resp.StatusCode() != http.StatusOK {
+		return nil, fmt.Errorf("ошибка загрузки JWKS: статус %d", resp.StatusCode())
+	}
+
+	keys, err := parseJWKS(resp.Body())
+	if err != nil {
+		return nil, fmt.Errorf("ошибка разбора JWKS: %w", err)
+	}
+	return keys, nil
+}
+
+// jsonWebKey - ключ JWKS (RFC 7517), поддерживаются RSA и EC
+type jsonWebKey struct {
+	Kty string `json:"kty"`
+	Kid string `json:"kid"`
+	Use string `json:"use"`
+	N   string `json:"n"`
+	E   string `json:"e"`
+	Crv string `json:"crv"`
+	X   string `json:"x"`
+	Y   string `json:"y"`
+}
+
+// parseJWKS - открытые ключи подписи из JWKS; ключи других типов и назначений пропускаются
+func parseJWKS(data []byte) (map[string]crypto.PublicKey, error) {
+	var set struct {
+		Keys []jsonWebKey `json:"keys"`
+	}
+	if err := json.Unmarshal(data, &set); err != nil {
+		return nil, err
+	}
+
+	keys := make(map[string]crypto.PublicKey, len(set.Keys))
+	for _, jwk := range set.Keys {
+		if jwk.Use != "" && jwk.Use != "sig" {
+			continue
+		}
+		key, err := jwk.publicKey()
+		if err != nil {
+			return nil, fmt.Errorf("ключ %q: %w", jwk.Kid, err)
+		}
+		if key != nil {
+			keys[jwk.Kid] = key
+		}
+	}
+	if len(keys) == 0 {
+		return nil, errors.New("нет ключей подписи RSA или EC")
+	}
+	return keys, nil
+}
+
+// publicKey - открытый ключ JWK; nil для неподдерживаемого типа
+func (jwk jsonWebKey) publicKey() (crypto.PublicKey, error) {
+	switch jwk.Kty {
+	case "RSA":
+		n, err := base64Int(jwk.N)
+		if err != nil {
+			return nil, err
+		}
+		e, err := base64Int(jwk.E)
+		if err != nil {
+			return nil, err
+		}
+		return &rsa.PublicKey{N: n, E: int(e.Int64())}, nil
+	case "EC":
+		curves := map[string]elliptic.Curve{"P-256": elliptic.P256(), "P-384": elliptic.P384(), "P-521": elliptic.P521()}
+		curve, ok := curves[jwk.Crv]
+		if !ok {
+			return nil, fmt.Errorf("неподдерживаемая кривая %q", jwk.Crv)
+		}
+		x, err := base64Int(jwk.X)
+		if err != nil {
+			return nil, err
+		}
+		y, err := base64Int(jwk.Y)
+		if err != nil {
+			return nil, err
+		}
+		return &ecdsa.PublicKey{Curve: curve, X: x, Y: y}, nil
+	default:
+		return nil, nil
+	}
+}
+
+// base64Int - целое число в base64url без выравнивания
+func base64Int(value string) (*big.Int, error) {
+	data, err := base64.RawURLEncoding.DecodeString(value)
+	if err != nil || len(data) == 0 {
+		return nil, fmt.Errorf("некорректное значение %q", value)
+	}
+	return new(big.Int).SetBytes(data), nil
+}
+
+// projectsClaim - разрешенные проекты: "*" - все, иначе список ID числами или строками
+// в том же виде, что и у API-ключей
+func projectsClaim(value interface{}) ([]ProjectGrant, error) {
+	items, err := claimList(value)
+	if err != nil {
+		return nil, err
+	}
+
+	refs := make([]config.ProjectRef, 0, len(items))
+	for _, item := range items {
+		refs = append(refs, config.ProjectRef(item))
+	}
+	return projectGrants(refs)
+}
+
+// operationsClaim - разрешенные операции: "*" - все, иначе список или строка через пробел
+func operationsClaim(value interface{}) ([]string, error) {
+	items, err := claimList(value)
+	if err != nil {
+		return nil, err
+	}
+	return operationList(items), nil
+}
+
+// claimList - значения claim списком строк.
+// Отсутствующий claim - ошибка: права должны быть выданы явно.
+func claimList(value interface{}) ([]string, error) {
+	switch value := value.(type) {
+	case nil:
+		return nil, errors.New("claim отсутствует")
+	case string:
+		return strings.Fields(value), nil
+	case []interface{}:
+		items := make([]string, 0, len(value))
+		for _, item := range value {
+			switch item := item.(type) {
+			case string:
+				items = append(items, item)
+			case float64:
+				items = append(items, strconv.FormatFloat(item, 'f', -1, 64))
+			default:
+				return nil, fmt.Errorf("некорректное значение %v", item)
+			}
+		}
+		return items, nil
+	default:
+		return nil, fmt.Errorf("некорректное значение %v", value)
+	}
+}
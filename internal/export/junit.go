@@ -0,0 +1,178 @@
+package export
+
+import (
+	"encoding/xml"
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+
+	"github.com/vkr-mtuci/allure-service/internal/adapter"
+)
+
+// JUnitContentType - MIME-тип JUnit XML
+const JUnitContentType = "application/xml; charset=utf-8"
+
+// defaultSuiteName - имя набора для результатов без метки suite
+const defaultSuiteName = "default"
+
+// junitTestSuites - корневой элемент JUnit XML
+type junitTestSuites struct {
+	XMLName  xml.Name         `xml:"testsuites"`
+	Name     string           `xml:"name,attr"`
+	Tests    int              `xml:"tests,attr"`
+	Failures int              `xml:"failures,attr"`
+	Errors   int              `xml:"errors,attr"`
+	Skipped  int              `xml:"skipped,attr"`
+	Time     string           `xml:"time,attr"`
+	Suites   []junitTestSuite `xml:"testsuite"`
+}
+
+// junitTestSuite - набор тестов, сгруппированный по метке suite
+type junitTestSuite struct {
+	Name      string          `xml:"name,attr"`
+	Tests     int             `xml:"tests,attr"`
+	Failures  int             `xml:"failures,attr"`
+	Errors    int             `xml:"errors,attr"`
+	Skipped   int             `xml:"skipped,attr"`
+	Time      string          `xml:"time,attr"`
+	TestCases []junitTestCase `xml:"testcase"`
+
+	duration int64
+}
+
+// junitTestCase - отдельный тест
+type junitTestCase struct {
+	Name      string        `xml:"name,attr"`
+	ClassName string        `xml:"classname,attr"`
+	Time      string        `xml:"time,attr"`
+	Failure   *junitProblem `xml:"failure,omitempty"`
+	Error     *junitProblem `xml:"error,omitempty"`
+	Skipped   *junitSkipped `xml:"skipped,omitempty"`
+	SystemOut string        `xml:"system-out,omitempty"`
+}
+
+// junitProblem - описание падения (failure) или ошибки (error) теста
+type junitProblem struct {
+	Message string `xml:"message,attr,omitempty"`
+	Type    string `xml:"type,attr"`
+	Trace   string `xml:",chardata"`
+}
+
+// junitSkipped - отметка о пропуске теста
+type junitSkipped struct {
+	Message string `xml:"message,attr,omitempty"`
+}
+
+// WriteJUnit - записывает результаты тестов запуска в формате JUnit XML.
+// Наборы группируются по метке suite; failed становится failure, broken - error.
+// Итоги корневого элемента и наборов стоят в атрибутах перед тестами, поэтому до записи
+// в памяти копятся только описания тестов, а не полные результаты с шагами и метками.
+func WriteJUnit(w io.Writer, launchName string, results Results) error {
+	suitesByName := make(map[string]*junitTestSuite)
+	report := junitTestSuites{Name: launchName}
+	var totalDuration int64
+
+	err := results.each(func(result adapter.TestResult) error {
+		suiteName := labelValue(result, "suite")
+		if suiteName == "" {
+			suiteName = defaultSuiteName
+		}
+
+		suite, ok := suitesByName[suiteName]
+		if !ok {
+			suite = &junitTestSuite{Name: suiteName}
+			suitesByName[suiteName] = suite
+		}
+
+		testCase := junitTestCase{
+			Name:      result.Name,
+			ClassName: className(result),
+			Time:      seconds(result.Duration),
+			SystemOut: systemOut(result),
+		}
+
+		switch strings.ToLower(result.Status) {
+		case adapter.StatusFailed:
+			testCase.Failure = &junitProblem{Message: result.Message, Type: adapter.StatusFailed, Trace: result.Trace}
+			suite.Failures++
+		case adapter.StatusBroken:
+			testCase.Error = &junitProblem{Message: result.Message, Type: adapter.StatusBroken, Trace: result.Trace}
+			suite.Errors++
+		case adapter.StatusSkipped:
+			testCase.Skipped = &junitSkipped{Message: result.Message}
+			suite.Skipped++
+		}
+
+		suite.Tests++
+		suite.duration += result.Duration
+		suite.TestCases = append(suite.TestCases, testCase)
+		totalDuration += result.Duration
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+
+	// Порядок наборов стабилен, чтобы выгрузки одного запуска совпадали
+	names := make([]string, 0, len(suitesByName))
+	for name := range suitesByName {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	for _, name := range names {
+		suite := suitesByName[name]
+		suite.Time = seconds(suite.duration)
+		report.Tests += suite.Tests
+		report.Failures += suite.Failures
+		report.Errors += suite.Errors
+		report.Skipped += suite.Skipped
+		report.Suites = append(report.Suites, *suite)
+	}
+	report.Time = seconds(totalDuration)
+
+	if _, err := io.WriteString(w, xml.Header); err != nil {
+		return err
+	}
+
+	encoder := xml.NewEncoder(w)
+	encoder.Indent("", "  ")
+	if err := encoder.Encode(report); err != nil {
+		return err
+	}
+
+	_, err = io.WriteString(w, "\n")
+	return err
+}
+
+// className - имя класса теста: полный путь без имени метода
+func className(result adapter.TestResult) string {
+	if idx := strings.LastIndex(result.FullName, "."); idx > 0 {
+		return result.FullName[:idx]
+	}
+	if result.FullName != "" {
+		return result.FullName
+	}
+
+	return labelValue(result, "suite")
+}
+
+// systemOut - параметры теста, которые CI показывает рядом с результатом
+func systemOut(result adapter.TestResult) string {
+	if len(result.Parameters) == 0 {
+		return ""
+	}
+
+	lines := make([]string, 0, len(result.Parameters))
+	for _, parameter := range result.Parameters {
+		lines = append(lines, fmt.Sprintf("%s = %s", parameter.Name, parameter.Value))
+	}
+
+	return strings.Join(lines, "\n")
+}
+
+// seconds - переводит миллисекунды в секунды в формате JUnit
+func seconds(milliseconds int64) string {
+	return fmt.Sprintf("%.3f", float64(milliseconds)/1000)
+}
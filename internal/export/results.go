@@ -0,0 +1,33 @@
+package export
+
+import "github.com/vkr-mtuci/allure-service/internal/adapter"
+
+// Results - последовательность результатов тестов для выгрузки. Передает результаты в yield,
+// пока тот возвращает true, и возвращает ошибку получения результатов, если она прервала обход.
+type Results func(yield func(adapter.TestResult) bool) error
+
+// SliceResults - последовательность из уже полученного списка результатов
+func SliceResults(results []adapter.TestResult) Results {
+	return func(yield func(adapter.TestResult) bool) error {
+		for _, result := range results {
+			if !yield(result) {
+				break
+			}
+		}
+		return nil
+	}
+}
+
+// each - вызывает fn для каждого результата; ошибка fn останавливает обход и возвращается первой
+func (r Results) each(fn func(adapter.TestResult) error) error {
+	var fnErr error
+	err := r(func(result adapter.TestResult) bool {
+		fnErr = fn(result)
+		return fnErr == nil
+	})
+	if fnErr != nil {
+		return fnErr
+	}
+
+	return err
+}
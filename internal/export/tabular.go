@@ -0,0 +1,160 @@
+package export
+
+import (
+	"encoding/csv"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+
+	"github.com/xuri/excelize/v2"
+
+	"github.com/vkr-mtuci/allure-service/internal/adapter"
+)
+
+// Форматы табличной выгрузки результатов
+const (
+	FormatCSV  = "csv"
+	FormatXLSX = "xlsx"
+)
+
+// xlsxSheet - имя листа с результатами в XLSX-файле
+const xlsxSheet = "Результаты"
+
+// utf8BOM - метка порядка байт, чтобы Excel корректно открывал кириллицу в CSV
+const utf8BOM = "\ufeff"
+
+// tableHeader - заголовки колонок табличной выгрузки
+var tableHeader = []string{"Название", "Полный путь", "Статус", "Длительность, мс", "Владелец", "Теги", "Ошибка"}
+
+// ContentType - возвращает MIME-тип файла выгрузки
+func ContentType(format string) string {
+	switch format {
+	case FormatCSV:
+		return "text/csv; charset=utf-8"
+	case FormatXLSX:
+		return "application/vnd.openxmlformats-officedocument.spreadsheetml.sheet"
+	default:
+		return "application/octet-stream"
+	}
+}
+
+// SupportedFormat - поддерживается ли формат табличной выгрузки
+func SupportedFormat(format string) bool {
+	return format == FormatCSV || format == FormatXLSX
+}
+
+// WriteTable - записывает результаты тестов в указанном формате
+func WriteTable(w io.Writer, format string, results Results) error {
+	switch format {
+	case FormatCSV:
+		return WriteCSV(w, results)
+	case FormatXLSX:
+		return WriteXLSX(w, results)
+	default:
+		return fmt.Errorf("неподдерживаемый формат выгрузки: %s", format)
+	}
+}
+
+// WriteCSV - записывает результаты тестов в CSV; строки уходят в w по мере получения результатов
+func WriteCSV(w io.Writer, results Results) error {
+	if _, err := io.WriteString(w, utf8BOM); err != nil {
+		return err
+	}
+
+	writer := csv.NewWriter(w)
+	if err := writer.Write(tableHeader); err != nil {
+		return err
+	}
+	err := results.each(func(result adapter.TestResult) error {
+		return writer.Write(tableRow(result))
+	})
+	if err != nil {
+		return err
+	}
+
+	writer.Flush()
+	return writer.Error()
+}
+
+// WriteXLSX - записывает результаты тестов в XLSX. Строки пишутся на диск по мере получения
+// результатов, а в w файл попадает целиком после последней строки: XLSX - это zip-архив,
+// который нельзя отдавать по частям.
+func WriteXLSX(w io.Writer, results Results) error {
+	file := excelize.NewFile()
+	defer file.Close()
+
+	if err := file.SetSheetName(file.GetSheetName(0), xlsxSheet); err != nil {
+		return err
+	}
+
+	// Потоковая запись сбрасывает строки во временный файл и не держит их в памяти
+	stream, err := file.NewStreamWriter(xlsxSheet)
+	if err != nil {
+		return err
+	}
+
+	if err := stream.SetRow("A1", toCells(tableHeader)); err != nil {
+		return err
+	}
+	rowNumber := 1
+	err = results.each(func(result adapter.TestResult) error {
+		row := toCells(tableRow(result))
+		row[3] = result.Duration // Длительность оставляем числом, чтобы по ней можно было считать
+
+		rowNumber++
+		cell, err := excelize.CoordinatesToCellName(1, rowNumber)
+		if err != nil {
+			return err
+		}
+		return stream.SetRow(cell, row)
+	})
+	if err != nil {
+		return err
+	}
+
+	if err := stream.Flush(); err != nil {
+		return err
+	}
+
+	return file.Write(w)
+}
+
+// tableRow - формирует строку таблицы из результата теста
+func tableRow(result adapter.TestResult) []string {
+	tags := make([]string, 0, len(result.Tags))
+	for _, tag := range result.Tags {
+		tags = append(tags, tag.Name)
+	}
+
+	return []string{
+		result.Name,
+		result.FullName,
+		result.Status,
+		strconv.FormatInt(result.Duration, 10),
+		labelValue(result, "owner"),
+		strings.Join(tags, ", "),
+		result.Message,
+	}
+}
+
+// labelValue - возвращает значение первой метки с указанным именем
+func labelValue(result adapter.TestResult, name string) string {
+	for _, label := range result.Labels {
+		if label.Name == name {
+			return label.Value
+		}
+	}
+
+	return ""
+}
+
+// toCells - приводит строку таблицы к значениям ячеек XLSX
+func toCells(values []string) []interface{} {
+	cells := make([]interface{}, len(values))
+	for i, value := range values {
+		cells[i] = value
+	}
+
+	return cells
+}
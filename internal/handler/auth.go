@@ -0,0 +1,67 @@
+package handler
+
+import (
+	"fmt"
+	"net/http"
+	"slices"
+	"strings"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/rs/zerolog/log"
+
+	"github.com/vkr-mtuci/allure-service/internal/auth"
+	"github.com/vkr-mtuci/allure-service/internal/reqctx"
+)
+
+// APIKeyHeader - заголовок со статическим API-ключом вызывающего
+const APIKeyHeader = "X-API-Key"
+
+// Authenticate - проверяет API-ключ из заголовка X-API-Key или токен из Authorization: Bearer
+// и добавляет вызывающего в контекст запроса. Пути publicPaths доступны без аутентификации.
+// Если аутентификация отключена в конфигурации, все запросы пропускаются.
+func Authenticate(authenticator *auth.Authenticator, publicPaths ...string) fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		if !authenticator.Enabled() || slices.Contains(publicPaths, c.Path()) || c.Method() == fiber.MethodOptions {
+			return c.Next()
+		}
+
+		bearer := c.Get(fiber.HeaderAuthorization)
+		if scheme, token, ok := strings.Cut(bearer, " "); ok && strings.EqualFold(scheme, "Bearer") {
+			bearer = strings.TrimSpace(token)
+		} else {
+			bearer = ""
+		}
+
+		caller, err := authenticator.Authenticate(c.UserContext(), c.Get(APIKeyHeader), bearer)
+		if err != nil {
+			log.Warn().Err(err).Msgf("🔒 Запрос %s %s отклонен: нет действующих учетных данных", c.Method(), c.Path())
+			c.Set(fiber.HeaderWWWAuthenticate, `Bearer realm="allure-service"`)
+			return c.Status(http.StatusUnauthorized).JSON(fiber.Map{
+				"error": "Требуется аутентификация: передайте API-ключ в заголовке X-API-Key или токен в Authorization: Bearer",
+			})
+		}
+
+		ctx := auth.WithCaller(c.UserContext(), caller)
+		c.SetUserContext(reqctx.WithUser(ctx, caller.Name))
+		return c.Next()
+	}
+}
+
+// Require - пропускает запрос, только если вызывающему разрешена операция
+func Require(operation string) fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		caller := auth.CallerFrom(c.UserContext())
+		if caller != nil && !caller.Can(operation) {
+			return forbidden(c, fmt.Errorf("%w: %s не разрешена операция %s", auth.ErrForbidden, caller.Name, operation))
+		}
+		return c.Next()
+	}
+}
+
+// forbidden - ответ 403 на запрос к проекту или операции, не разрешенным вызывающему
+func forbidden(c *fiber.Ctx, err error) error {
+	log.Warn().Err(err).Msgf("🚫 Запрос %s %s запрещен", c.Method(), c.Path())
+	return c.Status(http.StatusForbidden).JSON(fiber.Map{
+		"error": err.Error(),
+	})
+}
@@ -0,0 +1,60 @@
+//go:build linux || darwin || freebsd
+
+package handler
+
+import (
+	"context"
+	"errors"
+	"net"
+	"syscall"
+	"time"
+)
+
+// connCheckInterval - как часто проверяется, что клиент не закрыл соединение
+const connCheckInterval = 200 * time.Millisecond
+
+// watchConnection - отменяет контекст запроса, когда клиент оборвал соединение.
+// fasthttp не сообщает об обрыве соединения, поэтому сокет периодически проверяется чтением
+// без извлечения данных; проверка завершается вместе с контекстом.
+func watchConnection(ctx context.Context, cancel context.CancelFunc, conn net.Conn) {
+	sysConn, ok := conn.(syscall.Conn)
+	if !ok {
+		// TLS и тестовые соединения без дескриптора не отслеживаются
+		return
+	}
+	rawConn, err := sysConn.SyscallConn()
+	if err != nil {
+		return
+	}
+
+	go func() {
+		ticker := time.NewTicker(connCheckInterval)
+		defer ticker.Stop()
+
+		buf := make([]byte, 1)
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+				if connClosed(rawConn, buf) {
+					cancel()
+					return
+				}
+			}
+		}
+	}()
+}
+
+// connClosed - оборвал ли клиент соединение. Конец потока обрывом не считается: клиент мог закрыть
+// только свою сторону записи после отправки запроса и все еще ждет ответа.
+func connClosed(rawConn syscall.RawConn, buf []byte) bool {
+	closed := false
+	err := rawConn.Control(func(fd uintptr) {
+		_, _, err := syscall.Recvfrom(int(fd), buf, syscall.MSG_PEEK|syscall.MSG_DONTWAIT)
+		closed = errors.Is(err, syscall.ECONNRESET) || errors.Is(err, syscall.EPIPE) || errors.Is(err, syscall.ETIMEDOUT)
+	})
+
+	// Дескриптор уже закрыт - запрос завершен
+	return err != nil || closed
+}
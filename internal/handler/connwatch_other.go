@@ -0,0 +1,12 @@
+//go:build !(linux || darwin || freebsd)
+
+package handler
+
+import (
+	"context"
+	"net"
+)
+
+// watchConnection - на этой платформе обрыв соединения не отслеживается:
+// контекст запроса отменяется только после отправки ответа
+func watchConnection(context.Context, context.CancelFunc, net.Conn) {}
@@ -1,16 +1,61 @@
 package handler
 
 import (
+	"errors"
+	"fmt"
+	"io"
+	"mime"
 	"net/http"
+	"regexp"
 	"strconv"
 	"strings"
 	"time"
 
 	"github.com/gofiber/fiber/v2"
 	"github.com/rs/zerolog/log"
+	"github.com/vkr-mtuci/allure-service/internal/adapter"
+	"github.com/vkr-mtuci/allure-service/internal/archive"
+	"github.com/vkr-mtuci/allure-service/internal/auth"
+	"github.com/vkr-mtuci/allure-service/internal/export"
 	"github.com/vkr-mtuci/allure-service/internal/service"
 )
 
+const (
+	// defaultLaunchPageSize - размер страницы списка запусков по умолчанию
+	defaultLaunchPageSize = 20
+	// maxLaunchPageSize - максимальный размер страницы списка запусков
+	maxLaunchPageSize = 100
+	// defaultLaunchSort - сортировка списка запусков по умолчанию
+	defaultLaunchSort = "createdDate,desc"
+	// defaultResultPageSize - размер страницы результатов тестов по умолчанию
+	defaultResultPageSize = 50
+	// maxResultPageSize - максимальный размер страницы результатов тестов
+	maxResultPageSize = 500
+)
+
+// testResultStatuses - допустимые значения фильтра по статусу результата теста
+var testResultStatuses = map[string]bool{
+	adapter.StatusPassed:  true,
+	adapter.StatusFailed:  true,
+	adapter.StatusBroken:  true,
+	adapter.StatusSkipped: true,
+	adapter.StatusUnknown: true,
+}
+
+// launchSortFields - поля, по которым разрешена сортировка запусков
+var launchSortFields = map[string]bool{
+	"id":               true,
+	"name":             true,
+	"createdDate":      true,
+	"lastModifiedDate": true,
+}
+
+// projectIDKey - ключ проекта запроса в c.Locals
+const projectIDKey = "projectId"
+
+// localePattern - формат локали PDF-отчета: код языка и необязательный код региона
+var localePattern = regexp.MustCompile(`^[a-z]{2}([-_][A-Z]{2})?$`)
+
 // AllureHandler - обработчик запросов к Allure
 type AllureHandler struct {
 	service service.AllureServiceInterface
@@ -21,6 +66,42 @@ func NewAllureHandler(service service.AllureServiceInterface) *AllureHandler {
 	return &AllureHandler{service: service}
 }
 
+// GetProjects - возвращает проекты Allure, доступные через сервис
+func (h *AllureHandler) GetProjects(c *fiber.Ctx) error {
+	projects, err := h.service.GetProjects(c.UserContext())
+	if err != nil {
+		log.Error().Err(err).Msg("❌ Ошибка при получении списка проектов")
+		return serverError(c, err, fiber.Map{
+			"error": err.Error(),
+		})
+	}
+
+	return c.JSON(projects)
+}
+
+// ProjectScope - проверяет проект из пути /projects/:projectId и передает его следующим обработчикам.
+// Маршруты вне /projects работают с проектом по умолчанию.
+func (h *AllureHandler) ProjectScope(c *fiber.Ctx) error {
+	projectID, ok := parseID(c, "projectId")
+	if !ok {
+		return c.Status(http.StatusBadRequest).JSON(fiber.Map{
+			"error": "Необходимо передать корректный ID проекта",
+		})
+	}
+
+	if err := h.service.CheckProject(c.UserContext(), projectID); err != nil {
+		if errors.Is(err, auth.ErrForbidden) {
+			return forbidden(c, err)
+		}
+		return c.Status(http.StatusForbidden).JSON(fiber.Map{
+			"error": "Проект недоступен через сервис",
+		})
+	}
+
+	c.Locals(projectIDKey, projectID)
+	return c.Next()
+}
+
 // GetNextLaunch - обрабатывает запрос поиска следующего запуска после указанной даты
 func (h *AllureHandler) GetNextLaunch(c *fiber.Ctx) error {
 	dateParam := c.Query("after")
@@ -31,21 +112,17 @@ func (h *AllureHandler) GetNextLaunch(c *fiber.Ctx) error {
 		})
 	}
 
-	// 🛠 Заменяем пробел на `+`, если браузер или cURL его заменили
-	correctedDate := strings.ReplaceAll(dateParam, " ", "+")
-
-	afterDate, err := time.Parse(time.RFC3339, correctedDate)
+	afterDate, err := parseDate(dateParam)
 	if err != nil {
-		log.Error().Err(err).Msgf("❌ Ошибка парсинга даты: %s", correctedDate)
 		return c.Status(http.StatusBadRequest).JSON(fiber.Map{
 			"error": "Некорректный формат даты, используйте RFC3339 (например, 2025-01-30T22:00:38.625+03:00)",
 		})
 	}
 
-	nextLaunch, err := h.service.GetNextLaunch(afterDate)
+	nextLaunch, err := h.service.GetNextLaunch(c.UserContext(), projectID(c), afterDate)
 	if err != nil {
 		log.Error().Err(err).Msg("❌ Ошибка при поиске следующего запуска")
-		return c.Status(http.StatusInternalServerError).JSON(fiber.Map{
+		return serverError(c, err, fiber.Map{
 			"error": err.Error(),
 		})
 	}
@@ -53,13 +130,224 @@ func (h *AllureHandler) GetNextLaunch(c *fiber.Ctx) error {
 	return c.JSON(nextLaunch)
 }
 
+// GetLaunches - возвращает страницу запусков с фильтрацией по дате, имени и тегу
+func (h *AllureHandler) GetLaunches(c *fiber.Ctx) error {
+	query := adapter.LaunchQuery{
+		ProjectID: projectID(c),
+		Name:      c.Query("name"),
+		Tag:       c.Query("tag"),
+		Sort:      c.Query("sort", defaultLaunchSort),
+	}
+
+	dateParams := []struct {
+		name   string
+		target *time.Time
+	}{{"from", &query.From}, {"to", &query.To}}
+
+	for _, param := range dateParams {
+		value := c.Query(param.name)
+		if value == "" {
+			continue
+		}
+
+		date, err := parseDate(value)
+		if err != nil {
+			return c.Status(http.StatusBadRequest).JSON(fiber.Map{
+				"error": fmt.Sprintf("Некорректный формат параметра '%s', используйте RFC3339 (например, 2025-01-30T22:00:38.625+03:00)", param.name),
+			})
+		}
+		*param.target = date
+	}
+
+	if !query.From.IsZero() && !query.To.IsZero() && !query.From.Before(query.To) {
+		log.Warn().Msg("⚠️ Параметр 'from' не раньше 'to'")
+		return c.Status(http.StatusBadRequest).JSON(fiber.Map{
+			"error": "Параметр 'from' должен быть раньше 'to'",
+		})
+	}
+
+	page, size, err := parsePaging(c, defaultLaunchPageSize, maxLaunchPageSize)
+	if err != nil {
+		return c.Status(http.StatusBadRequest).JSON(fiber.Map{
+			"error": err.Error(),
+		})
+	}
+	query.Page, query.Size = page, size
+
+	if !validLaunchSort(query.Sort) {
+		log.Warn().Msgf("⚠️ Некорректный параметр 'sort': %s", query.Sort)
+		return c.Status(http.StatusBadRequest).JSON(fiber.Map{
+			"error": "Параметр 'sort' должен иметь вид '<поле>,asc|desc', поля: id, name, createdDate, lastModifiedDate",
+		})
+	}
+
+	launchPage, err := h.service.GetLaunches(c.UserContext(), query)
+	if err != nil {
+		log.Error().Err(err).Msg("❌ Ошибка при получении списка запусков")
+		return serverError(c, err, fiber.Map{
+			"error": err.Error(),
+		})
+	}
+
+	return c.JSON(launchPage)
+}
+
+// GetLaunch - возвращает запуск со статистикой и подробностями
+func (h *AllureHandler) GetLaunch(c *fiber.Ctx) error {
+	launchID, ok := parseID(c, "id")
+	if !ok {
+		return c.Status(http.StatusBadRequest).JSON(fiber.Map{
+			"error": "Необходимо передать корректный ID запуска",
+		})
+	}
+
+	launch, err := h.service.GetLaunch(c.UserContext(), projectID(c), launchID)
+	if err != nil {
+		log.Error().Err(err).Msgf("❌ Ошибка при получении запуска %d", launchID)
+		if errors.Is(err, adapter.ErrNotFound) {
+			return c.Status(http.StatusNotFound).JSON(fiber.Map{
+				"error": "Запуск не найден",
+			})
+		}
+		return serverError(c, err, fiber.Map{
+			"error": err.Error(),
+		})
+	}
+
+	return c.JSON(launch)
+}
+
+// GetLaunchResults - возвращает страницу результатов тестов запуска с фильтром по статусам
+func (h *AllureHandler) GetLaunchResults(c *fiber.Ctx) error {
+	launchID, ok := parseID(c, "id")
+	if !ok {
+		return c.Status(http.StatusBadRequest).JSON(fiber.Map{
+			"error": "Необходимо передать корректный ID запуска",
+		})
+	}
+
+	query := adapter.TestResultQuery{LaunchID: launchID}
+	if statusParam := c.Query("status"); statusParam != "" {
+		for _, status := range strings.Split(statusParam, ",") {
+			status = strings.ToLower(strings.TrimSpace(status))
+			if !testResultStatuses[status] {
+				log.Warn().Msgf("⚠️ Некорректный статус: %s", status)
+				return c.Status(http.StatusBadRequest).JSON(fiber.Map{
+					"error": "Параметр 'status' может содержать: passed, failed, broken, skipped, unknown",
+				})
+			}
+			query.Statuses = append(query.Statuses, status)
+		}
+	}
+
+	page, size, err := parsePaging(c, defaultResultPageSize, maxResultPageSize)
+	if err != nil {
+		return c.Status(http.StatusBadRequest).JSON(fiber.Map{
+			"error": err.Error(),
+		})
+	}
+	query.Page, query.Size = page, size
+
+	resultPage, err := h.service.GetTestResults(c.UserContext(), projectID(c), query)
+	if err != nil {
+		log.Error().Err(err).Msgf("❌ Ошибка при получении результатов запуска %d", launchID)
+		if errors.Is(err, adapter.ErrNotFound) {
+			return c.Status(http.StatusNotFound).JSON(fiber.Map{
+				"error": "Запуск не найден",
+			})
+		}
+		return serverError(c, err, fiber.Map{
+			"error": err.Error(),
+		})
+	}
+
+	return c.JSON(resultPage)
+}
+
+// GetTestResult - возвращает результат теста с шагами, вложениями и параметрами
+func (h *AllureHandler) GetTestResult(c *fiber.Ctx) error {
+	resultID, ok := parseID(c, "id")
+	if !ok {
+		return c.Status(http.StatusBadRequest).JSON(fiber.Map{
+			"error": "Необходимо передать корректный ID результата теста",
+		})
+	}
+
+	result, err := h.service.GetTestResult(c.UserContext(), projectID(c), resultID)
+	if err != nil {
+		log.Error().Err(err).Msgf("❌ Ошибка при получении результата теста %d", resultID)
+		if errors.Is(err, adapter.ErrNotFound) {
+			return c.Status(http.StatusNotFound).JSON(fiber.Map{
+				"error": "Результат теста не найден",
+			})
+		}
+		return serverError(c, err, fiber.Map{
+			"error": err.Error(),
+		})
+	}
+
+	return c.JSON(result)
+}
+
+// DownloadAttachment - проксирует вложение результата теста с поддержкой Range-запросов
+func (h *AllureHandler) DownloadAttachment(c *fiber.Ctx) error {
+	resultID, ok := parseID(c, "id")
+	if !ok {
+		return c.Status(http.StatusBadRequest).JSON(fiber.Map{
+			"error": "Необходимо передать корректный ID результата теста",
+		})
+	}
+
+	attachmentID, ok := parseID(c, "attachmentId")
+	if !ok {
+		return c.Status(http.StatusBadRequest).JSON(fiber.Map{
+			"error": "Необходимо передать корректный ID вложения",
+		})
+	}
+
+	content, err := h.service.DownloadAttachment(c.UserContext(), projectID(c), resultID, attachmentID, c.Get(fiber.HeaderRange))
+	if err != nil {
+		log.Error().Err(err).Msgf("❌ Ошибка скачивания вложения %d", attachmentID)
+		switch {
+		case errors.Is(err, adapter.ErrNotFound):
+			return c.Status(http.StatusNotFound).JSON(fiber.Map{
+				"error": "Вложение не найдено",
+			})
+		case errors.Is(err, adapter.ErrRangeNotSatisfiable):
+			return c.Status(http.StatusRequestedRangeNotSatisfiable).JSON(fiber.Map{
+				"error": "Некорректный диапазон в заголовке Range",
+			})
+		}
+		return serverError(c, err, fiber.Map{
+			"error": "Ошибка скачивания вложения",
+		})
+	}
+
+	// Вложение отдается с адреса сервиса, поэтому HTML или SVG из него не должен открываться
+	// в браузере как страница сервиса: файл всегда скачивается, а тип не угадывается по содержимому
+	c.Set(fiber.HeaderContentType, content.ContentType)
+	c.Set(fiber.HeaderContentDisposition, mime.FormatMediaType("attachment", map[string]string{"filename": content.FileName}))
+	c.Set(fiber.HeaderXContentTypeOptions, "nosniff")
+	if content.AcceptRanges != "" {
+		c.Set(fiber.HeaderAcceptRanges, content.AcceptRanges)
+	}
+	if content.ContentRange != "" {
+		c.Set(fiber.HeaderContentRange, content.ContentRange)
+	}
+
+	// Поток закрывается fasthttp после отправки тела
+	return sendStream(c.Status(content.StatusCode), content.Body, int(content.ContentLength))
+}
+
 // GeneratePDFReport - инициирует создание PDF-отчета
 func (h *AllureHandler) GeneratePDFReport(c *fiber.Ctx) error {
 	var request struct {
-		LaunchID        int64  `json:"launchId"`
-		Name            string `json:"name"`
-		WithPageNumbers bool   `json:"withPageNumbers"`
+		LaunchID int64  `json:"launchId"`
+		Name     string `json:"name"`
+		adapter.PDFExportOptions
 	}
+	// Незаданные в запросе параметры экспорта берутся по умолчанию
+	request.PDFExportOptions = adapter.DefaultPDFExportOptions()
 
 	// Распарсим JSON из тела запроса
 	if err := c.BodyParser(&request); err != nil {
@@ -84,11 +372,34 @@ func (h *AllureHandler) GeneratePDFReport(c *fiber.Ctx) error {
 		})
 	}
 
+	for i, status := range request.Statuses {
+		request.Statuses[i] = strings.ToLower(status)
+		if !testResultStatuses[request.Statuses[i]] {
+			log.Warn().Msgf("⚠️ Некорректный статус в фильтре отчета: %s", status)
+			return c.Status(http.StatusBadRequest).JSON(fiber.Map{
+				"error": "Поле 'statuses' может содержать: passed, failed, broken, skipped, unknown",
+			})
+		}
+	}
+
+	if request.Locale != "" && !localePattern.MatchString(request.Locale) {
+		log.Warn().Msgf("⚠️ Некорректная локаль отчета: %s", request.Locale)
+		return c.Status(http.StatusBadRequest).JSON(fiber.Map{
+			"error": "Поле 'locale' должно быть кодом языка, например 'ru' или 'en-US'",
+		})
+	}
+
 	// Вызываем сервис для генерации PDF
-	report, err := h.service.GeneratePDFReport(request.LaunchID, request.Name)
+	report, err := h.service.GeneratePDFReport(c.UserContext(), projectID(c), request.LaunchID, request.Name, request.PDFExportOptions)
 	if err != nil {
 		log.Error().Err(err).Msg("❌ Ошибка генерации PDF-отчета")
 
+		if errors.Is(err, adapter.ErrNotFound) {
+			return c.Status(http.StatusNotFound).JSON(fiber.Map{
+				"error": "Запуск не найден",
+			})
+		}
+
 		// Если ошибка связана с валидацией данных, возвращаем 400
 		if strings.Contains(err.Error(), "invalid input") {
 			return c.Status(http.StatusBadRequest).JSON(fiber.Map{
@@ -97,7 +408,7 @@ func (h *AllureHandler) GeneratePDFReport(c *fiber.Ctx) error {
 		}
 
 		// В остальных случаях - 500
-		return c.Status(http.StatusInternalServerError).JSON(fiber.Map{
+		return serverError(c, err, fiber.Map{
 			"error": "Ошибка сервера при генерации отчета",
 		})
 	}
@@ -112,6 +423,105 @@ func (h *AllureHandler) GeneratePDFReport(c *fiber.Ctx) error {
 	})
 }
 
+// ExportCSV - выгружает результаты тестов запуска в CSV
+func (h *AllureHandler) ExportCSV(c *fiber.Ctx) error {
+	return h.exportLaunchResults(c, export.FormatCSV)
+}
+
+// ExportXLSX - выгружает результаты тестов запуска в XLSX
+func (h *AllureHandler) ExportXLSX(c *fiber.Ctx) error {
+	return h.exportLaunchResults(c, export.FormatXLSX)
+}
+
+// exportLaunchResults - формирует табличную выгрузку и передает ее на фронт
+func (h *AllureHandler) exportLaunchResults(c *fiber.Ctx, format string) error {
+	launchID, ok := parseID(c, "id")
+	if !ok {
+		return c.Status(http.StatusBadRequest).JSON(fiber.Map{
+			"error": "Необходимо передать корректный ID запуска",
+		})
+	}
+
+	results, err := h.service.ExportLaunchResults(c.UserContext(), projectID(c), launchID, format)
+	if err != nil {
+		log.Error().Err(err).Msgf("❌ Ошибка выгрузки результатов запуска %d в %s", launchID, format)
+		if errors.Is(err, adapter.ErrNotFound) {
+			return c.Status(http.StatusNotFound).JSON(fiber.Map{
+				"error": "Запуск не найден",
+			})
+		}
+		return serverError(c, err, fiber.Map{
+			"error": "Ошибка формирования выгрузки результатов",
+		})
+	}
+
+	return sendExport(c, results)
+}
+
+// sendExport - передает выгрузку потоком, не собирая файл в памяти. Ошибка посреди записи
+// обрывает соединение без завершающего блока chunked-ответа, чтобы клиент не принял обрезанный файл за целый.
+func sendExport(c *fiber.Ctx, results *service.ResultsExport) error {
+	c.Set(fiber.HeaderContentDisposition, mime.FormatMediaType("attachment", map[string]string{"filename": results.FileName}))
+	c.Set(fiber.HeaderContentType, results.ContentType)
+
+	reader, writer := io.Pipe()
+	go func() {
+		writer.CloseWithError(results.Write(writer))
+	}()
+
+	// fasthttp закрывает поток после отправки или при обрыве соединения, и запись прекращается
+	return sendStream(c, reader, -1)
+}
+
+// GetLaunchJUnit - выгружает результаты тестов запуска в JUnit XML
+func (h *AllureHandler) GetLaunchJUnit(c *fiber.Ctx) error {
+	launchID, ok := parseID(c, "id")
+	if !ok {
+		return c.Status(http.StatusBadRequest).JSON(fiber.Map{
+			"error": "Необходимо передать корректный ID запуска",
+		})
+	}
+
+	results, err := h.service.ExportLaunchJUnit(c.UserContext(), projectID(c), launchID)
+	if err != nil {
+		log.Error().Err(err).Msgf("❌ Ошибка JUnit-выгрузки запуска %d", launchID)
+		if errors.Is(err, adapter.ErrNotFound) {
+			return c.Status(http.StatusNotFound).JSON(fiber.Map{
+				"error": "Запуск не найден",
+			})
+		}
+		return serverError(c, err, fiber.Map{
+			"error": "Ошибка формирования JUnit-выгрузки",
+		})
+	}
+
+	return sendExport(c, results)
+}
+
+// GetExportStatus - возвращает состояние задачи экспорта PDF-отчета
+func (h *AllureHandler) GetExportStatus(c *fiber.Ctx) error {
+	if _, ok := parseID(c, "id"); !ok {
+		return c.Status(http.StatusBadRequest).JSON(fiber.Map{
+			"error": "Необходимо передать корректный ID отчета",
+		})
+	}
+
+	job, err := h.service.GetExportStatus(c.UserContext(), projectID(c), c.Params("id"))
+	if err != nil {
+		log.Error().Err(err).Msg("❌ Ошибка получения статуса экспорта")
+		if errors.Is(err, adapter.ErrNotFound) {
+			return c.Status(http.StatusNotFound).JSON(fiber.Map{
+				"error": "Отчет не найден",
+			})
+		}
+		return serverError(c, err, fiber.Map{
+			"error": "Ошибка получения статуса отчета",
+		})
+	}
+
+	return c.JSON(job)
+}
+
 // GetPDFDownloadLink - получает ссылку на скачивание PDF-отчета
 func (h *AllureHandler) GetPDFDownloadLink(c *fiber.Ctx) error {
 	reportID := c.Params("id")
@@ -129,25 +539,216 @@ func (h *AllureHandler) GetPDFDownloadLink(c *fiber.Ctx) error {
 
 // DownloadPDFReport - скачивает PDF-отчет и передает его на фронт
 func (h *AllureHandler) DownloadPDFReport(c *fiber.Ctx) error {
-	reportID := c.Params("id")
-	if reportID == "" {
-		log.Warn().Msg("⚠️ Не указан reportID")
+	// ID отчета становится частью пути в Allure и ключом кэша и архива, поэтому допускается только число
+	reportID, ok := parseID(c, "id")
+	if !ok {
 		return c.Status(http.StatusBadRequest).JSON(fiber.Map{
-			"error": "Необходимо передать ID отчета",
+			"error": "Необходимо передать корректный ID отчета",
 		})
 	}
 
 	// Запрашиваем скачивание PDF
-	fileData, fileName, err := h.service.DownloadPDFReport(reportID)
+	download, err := h.service.DownloadPDFReport(c.UserContext(), projectID(c), strconv.FormatInt(reportID, 10))
 	if err != nil {
 		log.Error().Err(err).Msg("❌ Ошибка скачивания PDF")
-		return c.Status(http.StatusInternalServerError).JSON(fiber.Map{
+		switch {
+		case errors.Is(err, service.ErrReportNotReady):
+			return c.Status(http.StatusConflict).JSON(fiber.Map{
+				"error": "PDF-отчет еще формируется, повторите запрос позже",
+			})
+		case errors.Is(err, service.ErrReportFailed):
+			return c.Status(http.StatusUnprocessableEntity).JSON(fiber.Map{
+				"error": "Allure не смог сформировать PDF-отчет",
+			})
+		case errors.Is(err, adapter.ErrNotFound):
+			return c.Status(http.StatusNotFound).JSON(fiber.Map{
+				"error": "Отчет не найден",
+			})
+		}
+		return serverError(c, err, fiber.Map{
 			"error": "Ошибка скачивания PDF-отчета",
 		})
 	}
 
-	// Возвращаем PDF-файл как поток
-	c.Set("Content-Disposition", "attachment; filename="+fileName)
-	c.Set("Content-Type", "application/pdf")
-	return c.Send(fileData)
+	// Клиент проверяет свою копию по ETag при каждом запросе. ETag есть только у отчета из кэша:
+	// первое скачивание из Allure отдается без него, и условный запрос работает со второго скачивания.
+	if download.ETag != "" {
+		c.Set(fiber.HeaderETag, download.ETag)
+		c.Set(fiber.HeaderCacheControl, "private, no-cache")
+		if etagMatches(c.Get(fiber.HeaderIfNoneMatch), download.ETag) {
+			download.Body.Close()
+			return c.SendStatus(http.StatusNotModified)
+		}
+	}
+
+	// Возвращаем PDF-файл как поток; поток закрывается fasthttp после отправки тела
+	c.Set(fiber.HeaderContentDisposition, mime.FormatMediaType("attachment", map[string]string{"filename": download.FileName}))
+	c.Set(fiber.HeaderContentType, download.ContentType)
+	return sendStream(c, download.Body, int(download.Size))
+}
+
+// ListArchivedReports - возвращает архивные отчеты, при указании 'launchId' - только отчеты запуска
+func (h *AllureHandler) ListArchivedReports(c *fiber.Ctx) error {
+	var launchID int64
+	if value := c.Query("launchId"); value != "" {
+		parsed, err := strconv.ParseInt(value, 10, 64)
+		if err != nil || parsed <= 0 {
+			log.Warn().Msgf("⚠️ Некорректный параметр 'launchId': %s", value)
+			return c.Status(http.StatusBadRequest).JSON(fiber.Map{
+				"error": "Параметр 'launchId' должен быть положительным целым числом",
+			})
+		}
+		launchID = parsed
+	}
+
+	reports, err := h.service.ListArchivedReports(c.UserContext(), launchID)
+	if err != nil {
+		log.Error().Err(err).Msg("❌ Ошибка получения списка архивных отчетов")
+		switch {
+		case errors.Is(err, service.ErrArchiveDisabled):
+			return c.Status(http.StatusNotFound).JSON(fiber.Map{
+				"error": "Архив отчетов не настроен",
+			})
+		}
+		return serverError(c, err, fiber.Map{
+			"error": "Ошибка получения списка архивных отчетов",
+		})
+	}
+
+	return c.JSON(reports)
+}
+
+// DownloadArchivedReport - отдает архивный отчет запуска потоком
+func (h *AllureHandler) DownloadArchivedReport(c *fiber.Ctx) error {
+	// Отчеты, запуск которых неизвестен, хранятся под запуском 0
+	launchID, err := strconv.ParseInt(c.Params("launchId"), 10, 64)
+	if err != nil || launchID < 0 {
+		log.Warn().Msgf("⚠️ Некорректный ID запуска архивного отчета: %s", c.Params("launchId"))
+		return c.Status(http.StatusBadRequest).JSON(fiber.Map{
+			"error": "Необходимо передать корректный ID запуска",
+		})
+	}
+
+	download, err := h.service.OpenArchivedReport(c.UserContext(), launchID, c.Params("reportId"))
+	if err != nil {
+		log.Error().Err(err).Msg("❌ Ошибка скачивания архивного отчета")
+		switch {
+		case errors.Is(err, service.ErrArchiveDisabled):
+			return c.Status(http.StatusNotFound).JSON(fiber.Map{
+				"error": "Архив отчетов не настроен",
+			})
+		case errors.Is(err, archive.ErrInvalidReport):
+			return c.Status(http.StatusBadRequest).JSON(fiber.Map{
+				"error": "Необходимо передать корректный ID отчета",
+			})
+		case errors.Is(err, archive.ErrNotFound), errors.Is(err, adapter.ErrNotFound):
+			return c.Status(http.StatusNotFound).JSON(fiber.Map{
+				"error": "Отчет не найден в архиве",
+			})
+		}
+		return serverError(c, err, fiber.Map{
+			"error": "Ошибка скачивания архивного отчета",
+		})
+	}
+
+	// Поток закрывается fasthttp после отправки тела
+	c.Set(fiber.HeaderContentDisposition, mime.FormatMediaType("attachment", map[string]string{"filename": download.FileName}))
+	c.Set(fiber.HeaderContentType, download.ContentType)
+	return sendStream(c, download.Body, int(download.Size))
+}
+
+// serverError - ответ на ошибку обращения к Allure: 401, если Allure не принял API-токен вызывающего,
+// 403, если проект не разрешен вызывающему в сервисе или в Allure, 503, если автомат защиты
+// не пропускает запросы к Allure, иначе 500 с телом body
+func serverError(c *fiber.Ctx, err error, body fiber.Map) error {
+	if errors.Is(err, adapter.ErrUserTokenRejected) {
+		return c.Status(http.StatusUnauthorized).JSON(fiber.Map{
+			"error": "Allure не принял переданный API-токен",
+		})
+	}
+	if errors.Is(err, auth.ErrForbidden) || errors.Is(err, adapter.ErrForbidden) {
+		return forbidden(c, err)
+	}
+	if errors.Is(err, adapter.ErrCircuitOpen) {
+		return c.Status(http.StatusServiceUnavailable).JSON(fiber.Map{
+			"error": "Allure временно недоступен, повторите запрос позже",
+		})
+	}
+	return c.Status(http.StatusInternalServerError).JSON(body)
+}
+
+// projectID - проект запроса, 0 - проект по умолчанию
+func projectID(c *fiber.Ctx) int64 {
+	projectID, _ := c.Locals(projectIDKey).(int64)
+	return projectID
+}
+
+// parseDate - разбирает дату в формате RFC3339 из query-параметра
+func parseDate(value string) (time.Time, error) {
+	// 🛠 Заменяем пробел на `+`, если браузер или cURL его заменили
+	correctedDate := strings.ReplaceAll(value, " ", "+")
+
+	date, err := time.Parse(time.RFC3339, correctedDate)
+	if err != nil {
+		log.Error().Err(err).Msgf("❌ Ошибка парсинга даты: %s", correctedDate)
+		return time.Time{}, err
+	}
+
+	return date, nil
+}
+
+// parseID - разбирает положительный числовой ID из параметра пути
+func parseID(c *fiber.Ctx, param string) (int64, bool) {
+	id, err := strconv.ParseInt(c.Params(param), 10, 64)
+	if err != nil || id <= 0 {
+		log.Warn().Msgf("⚠️ Некорректный ID в параметре '%s': %s", param, c.Params(param))
+		return 0, false
+	}
+
+	return id, true
+}
+
+// parsePaging - разбирает параметры 'page' и 'size' с проверкой границ
+func parsePaging(c *fiber.Ctx, defaultSize, maxSize int) (int, int, error) {
+	page, err := strconv.Atoi(c.Query("page", "0"))
+	if err != nil || page < 0 {
+		log.Warn().Msgf("⚠️ Некорректный параметр 'page': %s", c.Query("page"))
+		return 0, 0, errors.New("Параметр 'page' должен быть неотрицательным целым числом")
+	}
+
+	size, err := strconv.Atoi(c.Query("size", strconv.Itoa(defaultSize)))
+	if err != nil || size < 1 || size > maxSize {
+		log.Warn().Msgf("⚠️ Некорректный параметр 'size': %s", c.Query("size"))
+		return 0, 0, fmt.Errorf("Параметр 'size' должен быть целым числом от 1 до %d", maxSize)
+	}
+
+	return page, size, nil
+}
+
+// etagMatches - проверяет, совпадает ли ETag с одним из значений заголовка If-None-Match
+func etagMatches(ifNoneMatch, etag string) bool {
+	if ifNoneMatch == "" || etag == "" {
+		return false
+	}
+
+	for _, candidate := range strings.Split(ifNoneMatch, ",") {
+		candidate = strings.TrimSpace(candidate)
+		// If-None-Match использует слабое сравнение
+		if candidate == "*" || strings.TrimPrefix(candidate, "W/") == strings.TrimPrefix(etag, "W/") {
+			return true
+		}
+	}
+
+	return false
+}
+
+// validLaunchSort - проверяет параметр сортировки вида "<поле>,asc|desc"
+func validLaunchSort(sort string) bool {
+	field, direction, found := strings.Cut(sort, ",")
+	if !found || !launchSortFields[field] {
+		return false
+	}
+
+	direction = strings.ToLower(direction)
+	return direction == "asc" || direction == "desc"
 }
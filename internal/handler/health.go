@@ -0,0 +1,37 @@
+package handler
+
+import (
+	"github.com/gofiber/fiber/v2"
+
+	"github.com/vkr-mtuci/allure-service/internal/adapter"
+)
+
+// CircuitReporter - источник состояния автомата защиты экземпляра Allure
+type CircuitReporter interface {
+	Circuit() adapter.CircuitState
+}
+
+// InstanceCircuit - экземпляр Allure, состояние которого показывает /health
+type InstanceCircuit struct {
+	Name   string
+	Client CircuitReporter
+}
+
+// Health - состояние сервиса и автоматов защиты экземпляров Allure.
+// Недоступный Allure не делает сервис нездоровым: ответ остается 200 со статусом degraded,
+// чтобы перезапуск или вывод сервиса из балансировки не усугублял сбой.
+func Health(instances []InstanceCircuit) fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		status := "ok"
+		states := make([]fiber.Map, 0, len(instances))
+		for _, instance := range instances {
+			circuit := instance.Client.Circuit()
+			if circuit.State != adapter.CircuitClosed {
+				status = "degraded"
+			}
+			states = append(states, fiber.Map{"name": instance.Name, "circuit": circuit})
+		}
+
+		return c.JSON(fiber.Map{"status": status, "instances": states})
+	}
+}
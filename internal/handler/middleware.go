@@ -0,0 +1,143 @@
+package handler
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"regexp"
+	"slices"
+	"strings"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/gofiber/fiber/v2/middleware/cors"
+	"github.com/gofiber/fiber/v2/utils"
+
+	"github.com/vkr-mtuci/allure-service/config"
+	"github.com/vkr-mtuci/allure-service/internal/reqctx"
+)
+
+// requestIDPattern - допустимый ID запроса от клиента; иначе сервис назначает свой
+var requestIDPattern = regexp.MustCompile(`^[A-Za-z0-9._-]{1,128}$`)
+
+// cancelRequestKey - ключ Locals с функцией отмены контекста запроса
+const cancelRequestKey = "cancelRequest"
+
+// RequestContext - готовит контекст запроса для сервиса: добавляет в него ID запроса
+// из заголовка X-Request-ID (или новый) и возвращает этот ID в ответе.
+// Обработчики передают в сервис c.UserContext(), поэтому значения контекста доходят до клиента Allure.
+// Контекст отменяется, когда ответ отправлен или клиент закрыл соединение, и вместе с ним
+// прерываются запросы к Allure.
+func RequestContext(c *fiber.Ctx) error {
+	// Контекст переживает запрос, а строки Fiber ссылаются на переиспользуемый буфер, поэтому ID копируется
+	requestID := utils.CopyString(c.Get(reqctx.RequestIDHeader))
+	if !requestIDPattern.MatchString(requestID) {
+		requestID = utils.UUIDv4()
+	}
+
+	c.Set(reqctx.RequestIDHeader, requestID)
+	ctx, cancel := context.WithCancel(reqctx.WithRequestID(c.UserContext(), requestID))
+	c.SetUserContext(ctx)
+	c.Locals(cancelRequestKey, cancel)
+	watchConnection(ctx, cancel, c.Context().Conn())
+
+	err := c.Next()
+
+	// Поток ответа fasthttp отправляет после выхода из обработчиков, поэтому его контекст отменяется при закрытии потока
+	if _, streaming := c.Response().BodyStream().(*requestStream); !streaming {
+		cancel()
+	}
+	return err
+}
+
+// sendStream - отдает поток в ответ. Контекст запроса живет, пока fasthttp не закончит отправку
+// и не закроет поток: без этого отмена контекста оборвала бы чтение из Allure.
+func sendStream(c *fiber.Ctx, body io.Reader, size int) error {
+	if cancel, ok := c.Locals(cancelRequestKey).(context.CancelFunc); ok {
+		body = &requestStream{Reader: body, cancel: cancel}
+	}
+	return c.SendStream(body, size)
+}
+
+// requestStream - поток ответа, отменяющий контекст запроса при закрытии
+type requestStream struct {
+	io.Reader
+	cancel context.CancelFunc
+}
+
+// Close - закрывает исходный поток и отменяет контекст запроса
+func (r *requestStream) Close() error {
+	defer r.cancel()
+	if closer, ok := r.Reader.(io.Closer); ok {
+		return closer.Close()
+	}
+	return nil
+}
+
+// AllureToken - передает в контекст запроса API-токен Allure вызывающего из заголовка settings.Header,
+// чтобы сервис обращался к Allure от его имени. В режиме required запросы без токена, кроме путей
+// publicPaths, отклоняются со статусом 401.
+func AllureToken(settings config.TokenPassthrough, publicPaths ...string) fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		// Токен живет в кэше клиента Allure дольше запроса, поэтому копируется из буфера Fiber
+		token := utils.CopyString(c.Get(settings.Header))
+		if token == "" {
+			if settings.Mode == config.TokenPassthroughRequired && !slices.Contains(publicPaths, c.Path()) && c.Method() != fiber.MethodOptions {
+				return c.Status(http.StatusUnauthorized).JSON(fiber.Map{
+					"error": "Необходимо передать API-токен Allure в заголовке " + settings.Header,
+				})
+			}
+			return c.Next()
+		}
+
+		c.SetUserContext(reqctx.WithAllureToken(c.UserContext(), token))
+		return c.Next()
+	}
+}
+
+// downloadPathPattern - маршруты скачивания файлов с учетом префиксов /instances/:name и /projects/:projectId:
+// вложения, PDF-отчеты, архивные отчеты и JUnit XML
+var downloadPathPattern = regexp.MustCompile(`/(results/[^/]+/attachments/[^/]+|export/pdf/download/[^/]+|archive/[^/]+/[^/]+|launches/[^/]+/junit\.xml)/?$`)
+
+// CORS - применяет политику download к маршрутам скачивания файлов и политику api к остальным,
+// в том числе к предварительным запросам OPTIONS
+func CORS(api, download config.CORS) fiber.Handler {
+	apiCORS := cors.New(corsConfig(api))
+	downloadCORS := cors.New(corsConfig(download))
+	return func(c *fiber.Ctx) error {
+		if downloadPathPattern.MatchString(c.Path()) {
+			return downloadCORS(c)
+		}
+		return apiCORS(c)
+	}
+}
+
+// corsConfig - настройки middleware CORS для политики из конфигурации
+func corsConfig(policy config.CORS) cors.Config {
+	return cors.Config{
+		AllowOrigins:     strings.Join(policy.AllowOrigins, ","),
+		AllowMethods:     strings.Join(policy.AllowMethods, ","),
+		AllowHeaders:     strings.Join(policy.AllowHeaders, ", "),
+		ExposeHeaders:    strings.Join(policy.ExposeHeaders, ", "),
+		AllowCredentials: policy.AllowCredentials,
+	}
+}
+
+// SecurityHeaders - добавляет во все ответы заголовки безопасности; пустые значения не отправляются
+func SecurityHeaders(settings config.SecurityHeaders) fiber.Handler {
+	hsts := settings.HSTS()
+	return func(c *fiber.Ctx) error {
+		if hsts != "" {
+			c.Set(fiber.HeaderStrictTransportSecurity, hsts)
+		}
+		if settings.ContentTypeNosniff {
+			c.Set(fiber.HeaderXContentTypeOptions, "nosniff")
+		}
+		if settings.FrameOptions != "" {
+			c.Set(fiber.HeaderXFrameOptions, settings.FrameOptions)
+		}
+		if settings.ReferrerPolicy != "" {
+			c.Set(fiber.HeaderReferrerPolicy, settings.ReferrerPolicy)
+		}
+		return c.Next()
+	}
+}
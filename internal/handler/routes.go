@@ -0,0 +1,62 @@
+package handler
+
+import (
+	"github.com/gofiber/fiber/v2"
+	"github.com/vkr-mtuci/allure-service/config"
+	"github.com/vkr-mtuci/allure-service/internal/auth"
+)
+
+// RegisterInstanceRoutes - регистрирует маршруты экземпляра Allure под префиксом /instances/<name>.
+// Маршруты без префикса работают с экземпляром по умолчанию.
+func RegisterInstanceRoutes(app fiber.Router, name string, isDefault bool, allureHandler *AllureHandler) {
+	scope := instanceScope(auth.Instance{Name: name, Default: isDefault})
+	if isDefault {
+		registerRoutes(app, scope, allureHandler)
+	}
+	registerRoutes(app.Group("/instances/"+name), scope, allureHandler)
+}
+
+// instanceScope - передает экземпляр Allure в контекст запроса до проверки прав:
+// права на проекты выдаются отдельно для каждого экземпляра
+func instanceScope(instance auth.Instance) fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		c.SetUserContext(auth.WithInstance(c.UserContext(), instance))
+		return c.Next()
+	}
+}
+
+// Проверки операций, разрешенных вызывающему
+var (
+	canRead     = Require(config.OperationRead)
+	canExport   = Require(config.OperationExport)
+	canDownload = Require(config.OperationDownload)
+)
+
+// registerRoutes - регистрирует маршруты одного экземпляра Allure; scope предшествует проверкам прав
+func registerRoutes(router fiber.Router, scope fiber.Handler, allureHandler *AllureHandler) {
+	router.Get("/projects", scope, canRead, allureHandler.GetProjects)
+
+	// Маршруты без префикса работают с проектом по умолчанию
+	registerProjectRoutes(router, scope, allureHandler)
+	registerProjectRoutes(router.Group("/projects/:projectId", scope, allureHandler.ProjectScope), scope, allureHandler)
+
+	router.Get("/archive", scope, canDownload, allureHandler.ListArchivedReports)
+	router.Get("/archive/:launchId/:reportId", scope, canDownload, allureHandler.DownloadArchivedReport)
+}
+
+// registerProjectRoutes - регистрирует маршруты, относящиеся к одному проекту Allure
+func registerProjectRoutes(router fiber.Router, scope fiber.Handler, allureHandler *AllureHandler) {
+	router.Get("/next-launch", scope, canRead, allureHandler.GetNextLaunch)
+	router.Get("/launches", scope, canRead, allureHandler.GetLaunches)
+	router.Get("/launches/:id", scope, canRead, allureHandler.GetLaunch)
+	router.Get("/launches/:id/results", scope, canRead, allureHandler.GetLaunchResults)
+	router.Get("/launches/:id/junit.xml", scope, canExport, allureHandler.GetLaunchJUnit)
+	router.Get("/results/:id", scope, canRead, allureHandler.GetTestResult)
+	router.Get("/results/:id/attachments/:attachmentId", scope, canDownload, allureHandler.DownloadAttachment)
+	router.Post("/export/pdf/:id", scope, canExport, allureHandler.GeneratePDFReport)
+	router.Get("/export/:id/status", scope, canRead, allureHandler.GetExportStatus)
+	router.Post("/export/csv/:id", scope, canExport, allureHandler.ExportCSV)
+	router.Post("/export/xlsx/:id", scope, canExport, allureHandler.ExportXLSX)
+	router.Get("/export/download/:id", scope, canRead, allureHandler.GetPDFDownloadLink)
+	router.Get("/export/pdf/download/:id", scope, canDownload, allureHandler.DownloadPDFReport)
+}
@@ -0,0 +1,135 @@
+package logging
+
+import (
+	"fmt"
+	"io"
+	"net/http"
+	"regexp"
+	"strings"
+	"sync/atomic"
+	"unicode/utf8"
+
+	"github.com/rs/zerolog"
+	"github.com/vkr-mtuci/allure-service/config"
+)
+
+// mask - замена скрытого значения
+const mask = "******"
+
+// defaultMaxBodyBytes - размер тела в логе, пока параметры логирования не заданы
+const defaultMaxBodyBytes = 2048
+
+// defaultSensitiveFields - поля, значения которых скрываются всегда
+var defaultSensitiveFields = []string{
+	"access_token", "refresh_token", "id_token", "token", "apiToken", "api_token",
+	"password", "secret", "client_secret", "accessKey", "secretKey",
+}
+
+// sensitiveHeaders - заголовки HTTP, значения которых скрываются
+var sensitiveHeaders = []string{"Authorization", "Cookie", "Set-Cookie", "X-Api-Key"}
+
+// bearerPattern - токен в заголовке Authorization
+var bearerPattern = regexp.MustCompile(`(?i)(bearer\s+)[A-Za-z0-9\-._~+/]+=*`)
+
+// redactor - правила скрытия секретов; заменяется целиком при изменении настроек
+type redactor struct {
+	secrets      []string
+	jsonFields   *regexp.Regexp // "поле": "значение", в том числе внутри экранированной строки
+	formFields   *regexp.Regexp // поле=значение в теле формы и строке запроса
+	maxBodyBytes int
+}
+
+var current atomic.Pointer[redactor]
+
+func init() {
+	current.Store(newRedactor(nil, nil, defaultMaxBodyBytes))
+}
+
+// Configure - применяет параметры логирования: уровень, размер тел в логе, скрываемые поля
+// и секреты конфигурации, которые скрываются везде, где встретятся
+func Configure(settings config.Logging, secrets []string) {
+	if level, err := zerolog.ParseLevel(settings.Level); err == nil {
+		zerolog.SetGlobalLevel(level)
+	}
+	current.Store(newRedactor(settings.SensitiveFields, secrets, settings.MaxBodyBytes))
+}
+
+// newRedactor - правила для стандартных и дополнительных полей и секретов
+func newRedactor(fields, secrets []string, maxBodyBytes int) *redactor {
+	names := make([]string, 0, len(defaultSensitiveFields)+len(fields))
+	for _, field := range append(append([]string{}, defaultSensitiveFields...), fields...) {
+		names = append(names, regexp.QuoteMeta(field))
+	}
+	alternatives := strings.Join(names, "|")
+
+	r := &redactor{
+		jsonFields:   regexp.MustCompile(`(?i)(\\?"(?:` + alternatives + `)\\?"\s*:\s*\\?")(?:[^"\\]|\\[^"])*`),
+		formFields:   regexp.MustCompile(`(?i)\b((?:` + alternatives + `)=)[^&\s"\\]+`),
+		maxBodyBytes: maxBodyBytes,
+	}
+	// Короткие значения совпали бы с обычным текстом
+	for _, secret := range secrets {
+		if len(secret) >= 4 {
+			r.secrets = append(r.secrets, secret)
+		}
+	}
+	return r
+}
+
+// Redact - скрывает в тексте bearer-токены, значения чувствительных полей и секреты конфигурации
+func Redact(text string) string {
+	r := current.Load()
+	for _, secret := range r.secrets {
+		text = strings.ReplaceAll(text, secret, mask)
+	}
+	text = bearerPattern.ReplaceAllString(text, "${1}"+mask)
+	text = r.jsonFields.ReplaceAllString(text, "${1}"+mask)
+	return r.formFields.ReplaceAllString(text, "${1}"+mask)
+}
+
+// RedactHeader - скрывает значения заголовков с учетными данными
+func RedactHeader(header http.Header) {
+	for _, name := range sensitiveHeaders {
+		if header.Get(name) != "" {
+			header.Set(name, mask)
+		}
+	}
+}
+
+// Body - тело HTTP-сообщения для лога уровня debug: секреты скрыты, длина ограничена MaxBodyBytes
+func Body(body []byte) string {
+	limit := MaxBodyBytes()
+	text := Redact(string(body))
+	if len(text) <= limit {
+		return text
+	}
+
+	// Обрезаем по границе символа, чтобы не разорвать UTF-8
+	cut := limit
+	for cut > 0 && !utf8.RuneStart(text[cut]) {
+		cut--
+	}
+	return fmt.Sprintf("%s… (обрезано, всего %d байт)", text[:cut], len(body))
+}
+
+// MaxBodyBytes - сколько байт тела HTTP-сообщения попадает в лог
+func MaxBodyBytes() int {
+	return current.Load().maxBodyBytes
+}
+
+// Writer - оборачивает вывод логов: каждая запись проходит через Redact.
+// zerolog пишет событие одним вызовом Write, поэтому секрет не разрывается между вызовами.
+func Writer(out io.Writer) io.Writer {
+	return redactingWriter{out: out}
+}
+
+type redactingWriter struct {
+	out io.Writer
+}
+
+func (w redactingWriter) Write(p []byte) (int, error) {
+	if _, err := io.WriteString(w.out, Redact(string(p))); err != nil {
+		return 0, err
+	}
+	return len(p), nil
+}
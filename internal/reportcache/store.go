@@ -0,0 +1,345 @@
+package reportcache
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"hash"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/rs/zerolog/log"
+)
+
+// indexFile - имя файла индекса кэша в каталоге хранилища
+const indexFile = "index.json"
+
+// blobDir - подкаталог с содержимым отчетов, адресуемым по контрольной сумме
+const blobDir = "blobs"
+
+// ErrTooLarge - отчет больше допустимого размера кэша
+var ErrTooLarge = errors.New("отчет превышает максимальный размер кэша")
+
+// Entry - отчет, сохраненный в кэше
+type Entry struct {
+	ReportID   string    `json:"reportId"`
+	Checksum   string    `json:"checksum"` // SHA-256 содержимого в hex
+	Size       int64     `json:"size"`
+	FileName   string    `json:"fileName"`
+	StoredAt   time.Time `json:"storedAt"`
+	LastAccess time.Time `json:"lastAccess"`
+}
+
+// ETag - сильный ETag содержимого отчета
+func (e Entry) ETag() string {
+	return ETag(e.Checksum)
+}
+
+// ETag - формирует ETag по контрольной сумме содержимого
+func ETag(checksum string) string {
+	return `"` + checksum + `"`
+}
+
+// Store - файловый кэш отчетов с вытеснением давно не использованных (LRU).
+// Содержимое хранится по контрольной сумме, поэтому одинаковые отчеты занимают место один раз.
+type Store struct {
+	dir     string
+	maxSize int64
+	mu      sync.Mutex
+	entries map[string]*Entry // по ID отчета
+	blobs   map[string]int64  // размер содержимого по контрольной сумме
+	size    int64             // суммарный размер содержимого на диске
+}
+
+// New - открывает кэш в каталоге dir, восстанавливая индекс с диска
+func New(dir string, maxSize int64) (*Store, error) {
+	if maxSize <= 0 {
+		return nil, fmt.Errorf("некорректный размер кэша отчетов: %d", maxSize)
+	}
+	if err := os.MkdirAll(filepath.Join(dir, blobDir), 0o755); err != nil {
+		return nil, fmt.Errorf("ошибка создания каталога кэша отчетов: %w", err)
+	}
+
+	s := &Store{
+		dir:     dir,
+		maxSize: maxSize,
+		entries: make(map[string]*Entry),
+		blobs:   make(map[string]int64),
+	}
+
+	if err := s.load(); err != nil {
+		return nil, err
+	}
+
+	log.Info().Msgf("🗄️ Кэш отчетов: %s (%d отчетов, %d из %d байт)", dir, len(s.entries), s.size, maxSize)
+	return s, nil
+}
+
+// Get - возвращает запись кэша и отмечает обращение к ней
+func (s *Store) Get(reportID string) (*Entry, bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	entry, ok := s.entries[reportID]
+	if !ok {
+		return nil, false
+	}
+	entry.LastAccess = time.Now()
+
+	copied := *entry
+	return &copied, true
+}
+
+// Open - открывает содержимое отчета из кэша
+func (s *Store) Open(entry *Entry) (*os.File, error) {
+	return os.Open(s.blobPath(entry.Checksum))
+}
+
+// MaxSize - максимальный суммарный размер кэша в байтах
+func (s *Store) MaxSize() int64 {
+	return s.maxSize
+}
+
+// Put - сохраняет отчет в кэш, при необходимости вытесняя давно не использованные
+func (s *Store) Put(reportID, fileName string, r io.Reader) (*Entry, error) {
+	upload, err := s.NewUpload(reportID, fileName)
+	if err != nil {
+		return nil, err
+	}
+	if _, err := io.Copy(upload, r); err != nil {
+		upload.Abort()
+		return nil, err
+	}
+
+	return upload.Commit()
+}
+
+// Upload - отчет, который записывается во временный файл кэша по мере чтения из источника.
+// Отчет попадает в кэш только после Commit; Abort отбрасывает записанное.
+type Upload struct {
+	store    *Store
+	reportID string
+	fileName string
+	file     *os.File
+	hash     hash.Hash
+	size     int64
+	err      error // Первая ошибка записи; после нее данные не пишутся
+}
+
+// NewUpload - начинает запись отчета в кэш
+func (s *Store) NewUpload(reportID, fileName string) (*Upload, error) {
+	file, err := os.CreateTemp(s.dir, "upload-*")
+	if err != nil {
+		return nil, err
+	}
+
+	return &Upload{store: s, reportID: reportID, fileName: fileName, file: file, hash: sha256.New()}, nil
+}
+
+// Write - дописывает часть отчета. Ошибки записи не возвращаются, чтобы копирование в кэш
+// не прерывало основной поток; их возвращает Commit.
+func (u *Upload) Write(p []byte) (int, error) {
+	if u.err != nil {
+		return len(p), nil
+	}
+
+	u.size += int64(len(p))
+	if u.size > u.store.maxSize {
+		u.err = ErrTooLarge
+		return len(p), nil
+	}
+
+	if _, err := u.file.Write(p); err != nil {
+		u.err = err
+		return len(p), nil
+	}
+	u.hash.Write(p)
+	return len(p), nil
+}
+
+// Size - сколько байт отчета записано
+func (u *Upload) Size() int64 {
+	return u.size
+}
+
+// Abort - отбрасывает недописанный отчет
+func (u *Upload) Abort() {
+	u.file.Close()
+	os.Remove(u.file.Name())
+}
+
+// Commit - сохраняет записанный отчет в кэш, при необходимости вытесняя давно не использованные
+func (u *Upload) Commit() (*Entry, error) {
+	s := u.store
+	defer os.Remove(u.file.Name()) // После переименования удалять уже нечего
+
+	err := u.err
+	if closeErr := u.file.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	checksum := hex.EncodeToString(u.hash.Sum(nil))
+	now := time.Now()
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if _, ok := s.blobs[checksum]; !ok {
+		if err := os.Rename(u.file.Name(), s.blobPath(checksum)); err != nil {
+			return nil, err
+		}
+		s.blobs[checksum] = u.size
+		s.size += u.size
+	}
+
+	if previous, ok := s.entries[u.reportID]; ok && previous.Checksum != checksum {
+		delete(s.entries, u.reportID)
+		s.removeBlobIfUnusedLocked(previous.Checksum)
+	}
+
+	entry := &Entry{
+		ReportID:   u.reportID,
+		Checksum:   checksum,
+		Size:       u.size,
+		FileName:   u.fileName,
+		StoredAt:   now,
+		LastAccess: now,
+	}
+	s.entries[u.reportID] = entry
+	s.evictLocked(u.reportID)
+
+	if err := s.saveLocked(); err != nil {
+		log.Warn().Err(err).Msg("⚠️ Ошибка сохранения индекса кэша отчетов")
+	}
+
+	copied := *entry
+	return &copied, nil
+}
+
+// evictLocked - вытесняет давно не использованные отчеты, пока кэш не уложится в лимит.
+// Только что сохраненный отчет keep не вытесняется; вызывается под мьютексом.
+func (s *Store) evictLocked(keep string) {
+	if s.size <= s.maxSize {
+		return
+	}
+
+	candidates := make([]*Entry, 0, len(s.entries))
+	for reportID, entry := range s.entries {
+		if reportID != keep {
+			candidates = append(candidates, entry)
+		}
+	}
+	sort.Slice(candidates, func(i, j int) bool {
+		return candidates[i].LastAccess.Before(candidates[j].LastAccess)
+	})
+
+	for _, entry := range candidates {
+		if s.size <= s.maxSize {
+			return
+		}
+		delete(s.entries, entry.ReportID)
+		s.removeBlobIfUnusedLocked(entry.Checksum)
+		log.Info().Msgf("🧹 Отчет %s вытеснен из кэша", entry.ReportID)
+	}
+}
+
+// removeBlobIfUnusedLocked - удаляет содержимое, на которое больше не ссылается ни один отчет
+func (s *Store) removeBlobIfUnusedLocked(checksum string) {
+	for _, entry := range s.entries {
+		if entry.Checksum == checksum {
+			return
+		}
+	}
+
+	if err := os.Remove(s.blobPath(checksum)); err != nil && !os.IsNotExist(err) {
+		log.Warn().Err(err).Msgf("⚠️ Ошибка удаления файла кэша %s", checksum)
+	}
+	s.size -= s.blobs[checksum]
+	delete(s.blobs, checksum)
+}
+
+// load - восстанавливает индекс кэша; записи без файла на диске отбрасываются
+func (s *Store) load() error {
+	data, err := os.ReadFile(filepath.Join(s.dir, indexFile))
+	if os.IsNotExist(err) {
+		s.removeOrphans()
+		return nil
+	}
+	if err != nil {
+		return fmt.Errorf("ошибка чтения индекса кэша отчетов: %w", err)
+	}
+
+	var entries []*Entry
+	if err := json.Unmarshal(data, &entries); err != nil {
+		// Поврежденный индекс не должен мешать запуску: кэш просто начнется заново
+		log.Warn().Err(err).Msg("⚠️ Индекс кэша отчетов поврежден, кэш будет пересобран")
+		entries = nil
+	}
+
+	for _, entry := range entries {
+		info, err := os.Stat(s.blobPath(entry.Checksum))
+		if err != nil || info.Size() != entry.Size {
+			continue
+		}
+		s.entries[entry.ReportID] = entry
+		if _, ok := s.blobs[entry.Checksum]; !ok {
+			s.blobs[entry.Checksum] = entry.Size
+			s.size += entry.Size
+		}
+	}
+
+	s.evictLocked("")
+	s.removeOrphans()
+	return nil
+}
+
+// removeOrphans - удаляет файлы, оставшиеся после аварийного завершения и не попавшие в индекс
+func (s *Store) removeOrphans() {
+	uploads, _ := filepath.Glob(filepath.Join(s.dir, "upload-*"))
+	for _, path := range uploads {
+		os.Remove(path)
+	}
+
+	files, err := os.ReadDir(filepath.Join(s.dir, blobDir))
+	if err != nil {
+		return
+	}
+	for _, file := range files {
+		if _, ok := s.blobs[file.Name()]; !ok {
+			os.Remove(s.blobPath(file.Name()))
+		}
+	}
+}
+
+// saveLocked - атомарно записывает индекс кэша на диск; вызывается под мьютексом
+func (s *Store) saveLocked() error {
+	entries := make([]*Entry, 0, len(s.entries))
+	for _, entry := range s.entries {
+		entries = append(entries, entry)
+	}
+
+	data, err := json.Marshal(entries)
+	if err != nil {
+		return err
+	}
+
+	path := filepath.Join(s.dir, indexFile)
+	if err := os.WriteFile(path+".tmp", data, 0o644); err != nil {
+		return err
+	}
+	return os.Rename(path+".tmp", path)
+}
+
+// blobPath - путь к содержимому отчета по контрольной сумме
+func (s *Store) blobPath(checksum string) string {
+	return filepath.Join(s.dir, blobDir, checksum)
+}
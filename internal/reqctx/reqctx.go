@@ -0,0 +1,49 @@
+package reqctx
+
+import "context"
+
+// contextKey - тип ключей значений запроса, чтобы они не пересекались с чужими ключами
+type contextKey int
+
+const (
+	requestIDKey contextKey = iota
+	userKey
+	allureTokenKey
+)
+
+// RequestIDHeader - заголовок с ID запроса, который передается и в Allure
+const RequestIDHeader = "X-Request-ID"
+
+// WithRequestID - контекст с ID входящего запроса
+func WithRequestID(ctx context.Context, requestID string) context.Context {
+	return context.WithValue(ctx, requestIDKey, requestID)
+}
+
+// RequestID - ID запроса из контекста, пустая строка, если его нет
+func RequestID(ctx context.Context) string {
+	requestID, _ := ctx.Value(requestIDKey).(string)
+	return requestID
+}
+
+// WithUser - контекст с пользователем, от имени которого выполняется запрос
+func WithUser(ctx context.Context, user string) context.Context {
+	return context.WithValue(ctx, userKey, user)
+}
+
+// User - пользователь из контекста, пустая строка для анонимного запроса
+func User(ctx context.Context) string {
+	user, _ := ctx.Value(userKey).(string)
+	return user
+}
+
+// WithAllureToken - контекст с API-токеном Allure вызывающего: запросы к Allure
+// выполняются от его имени, а не от имени сервиса
+func WithAllureToken(ctx context.Context, token string) context.Context {
+	return context.WithValue(ctx, allureTokenKey, token)
+}
+
+// AllureToken - API-токен Allure вызывающего из контекста, пустая строка - запрос от имени сервиса
+func AllureToken(ctx context.Context) string {
+	token, _ := ctx.Value(allureTokenKey).(string)
+	return token
+}
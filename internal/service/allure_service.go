@@ -3,99 +3,711 @@ package service
 import (
 	"context"
 	"fmt"
-	"math"
+	"io"
+	"strconv"
+	"sync/atomic"
 	"time"
 
 	"github.com/rs/zerolog/log"
 	"github.com/vkr-mtuci/allure-service/internal/adapter"
+	"github.com/vkr-mtuci/allure-service/internal/archive"
+	"github.com/vkr-mtuci/allure-service/internal/export"
+	"github.com/vkr-mtuci/allure-service/internal/reportcache"
+	"github.com/vkr-mtuci/allure-service/internal/reqctx"
 )
 
-// Интерфейс сервиса
+// Интерфейс сервиса. Параметр projectID == 0 означает проект по умолчанию.
 type AllureServiceInterface interface {
-	GetNextLaunch(afterDate time.Time) (*adapter.Launch, error)
-	GeneratePDFReport(launchID int64, launchName string) (*adapter.PDFReport, error)
+	GetProjects(ctx context.Context) ([]adapter.Project, error)
+	CheckProject(ctx context.Context, projectID int64) error
+	GetNextLaunch(ctx context.Context, projectID int64, afterDate time.Time) (*adapter.Launch, error)
+	GetLaunches(ctx context.Context, query adapter.LaunchQuery) (*adapter.LaunchPage, error)
+	GetLaunch(ctx context.Context, projectID, launchID int64) (*adapter.Launch, error)
+	GetTestResults(ctx context.Context, projectID int64, query adapter.TestResultQuery) (*adapter.TestResultPage, error)
+	GetTestResult(ctx context.Context, projectID, resultID int64) (*adapter.TestResult, error)
+	DownloadAttachment(ctx context.Context, projectID, resultID, attachmentID int64, rangeHeader string) (*adapter.FileContent, error)
+	ExportLaunchResults(ctx context.Context, projectID, launchID int64, format string) (*ResultsExport, error)
+	ExportLaunchJUnit(ctx context.Context, projectID, launchID int64) (*ResultsExport, error)
+	GeneratePDFReport(ctx context.Context, projectID, launchID int64, launchName string, opts adapter.PDFExportOptions) (*adapter.PDFReport, error)
+	GetExportStatus(ctx context.Context, projectID int64, reportID string) (*ExportJob, error)
 	GetPDFDownloadLink(reportID string) string
-	DownloadPDFReport(reportID string) ([]byte, string, error)
+	DownloadPDFReport(ctx context.Context, projectID int64, reportID string) (*PDFDownload, error)
+	ListArchivedReports(ctx context.Context, launchID int64) ([]archive.Report, error)
+	OpenArchivedReport(ctx context.Context, launchID int64, reportID string) (*PDFDownload, error)
 }
 
 // AllureService - реализация сервиса
 type AllureService struct {
-	client adapter.AllureClientInterface
+	client   adapter.AllureClientInterface
+	settings atomic.Pointer[serviceSettings]
+	exports  *exportTracker
+	reports  *reportcache.Store    // nil, если кэш отчетов отключен
+	archive  archive.ReportArchive // nil, если архив отчетов отключен
+}
+
+// PDFDownload - поток PDF-отчета с метаданными. Вызывающий обязан закрыть Body.
+type PDFDownload struct {
+	Body        io.ReadCloser
+	Size        int64 // -1, если размер неизвестен
+	ContentType string
+	FileName    string
+	ETag        string // Сильный ETag по SHA-256 содержимого; известен только для отчета из кэша
+	Cached      bool   // Отчет отдан из локального кэша
+}
+
+// ResultsExport - выгрузка результатов тестов запуска. Write пишет файл по мере получения
+// результатов из Allure и освобождает контекст выгрузки; вызывающий обязан вызвать Write один раз.
+type ResultsExport struct {
+	FileName    string
+	ContentType string
+	Write       func(w io.Writer) error
+}
+
+// Timeouts - ограничения времени операций сервиса с Allure
+type Timeouts struct {
+	List     time.Duration // Получение проектов, запусков, результатов тестов и статусов отчетов
+	Export   time.Duration // Генерация PDF-отчетов и выгрузок результатов
+	Download time.Duration // Скачивание вложений и PDF-отчетов вместе с чтением потока
+}
+
+// DefaultTimeouts - таймауты по умолчанию
+var DefaultTimeouts = Timeouts{
+	List:     10 * time.Second,
+	Export:   30 * time.Second,
+	Download: 5 * time.Minute,
+}
+
+// serviceSettings - настройки сервиса, которые можно заменить без перезапуска
+type serviceSettings struct {
+	timeouts Timeouts
+	projects projectAccess
+}
+
+// Option - дополнительная настройка сервиса
+type Option func(*AllureService)
+
+// WithTimeouts - задает таймауты операций с Allure
+func WithTimeouts(timeouts Timeouts) Option {
+	return func(s *AllureService) {
+		s.updateSettings(func(settings *serviceSettings) {
+			settings.timeouts = timeouts
+		})
+	}
+}
+
+// WithExportPolling - задает настройки опроса статуса экспорта PDF-отчетов
+func WithExportPolling(polling ExportPolling) Option {
+	return func(s *AllureService) {
+		s.exports.polling = polling
+	}
+}
+
+// WithReportCache - включает локальный кэш скачанных PDF-отчетов
+func WithReportCache(store *reportcache.Store) Option {
+	return func(s *AllureService) {
+		s.reports = store
+	}
+}
+
+// WithReportArchive - включает архивирование всех скачанных PDF-отчетов
+func WithReportArchive(reportArchive archive.ReportArchive) Option {
+	return func(s *AllureService) {
+		s.archive = reportArchive
+	}
 }
 
 // NewAllureService - создание сервиса
-func NewAllureService(client adapter.AllureClientInterface) *AllureService {
-	return &AllureService{client: client}
+func NewAllureService(client adapter.AllureClientInterface, opts ...Option) *AllureService {
+	s := &AllureService{
+		client:  client,
+		exports: newExportTracker(client, DefaultExportPolling),
+	}
+	s.settings.Store(&serviceSettings{timeouts: DefaultTimeouts})
+	s.exports.statusTimeout = func() time.Duration { return s.timeouts().List }
+	for _, opt := range opts {
+		opt(s)
+	}
+
+	return s
+}
+
+// Reload - атомарно заменяет таймауты и разрешенные проекты без перезапуска.
+// Начатые запросы завершаются со старыми настройками.
+func (s *AllureService) Reload(timeouts Timeouts, defaultProjectID int64, allowedProjects []int64) {
+	s.settings.Store(&serviceSettings{
+		timeouts: timeouts,
+		projects: newProjectAccess(defaultProjectID, allowedProjects),
+	})
+	log.Info().Msgf("🔁 Настройки сервиса обновлены (проект по умолчанию %d, разрешено проектов: %d)", defaultProjectID, len(allowedProjects))
+}
+
+// updateSettings - заменяет настройки измененной копией
+func (s *AllureService) updateSettings(update func(*serviceSettings)) {
+	settings := *s.settings.Load()
+	update(&settings)
+	s.settings.Store(&settings)
+}
+
+// timeouts - действующие таймауты операций
+func (s *AllureService) timeouts() Timeouts {
+	return s.settings.Load().timeouts
 }
 
 // GetNextLaunch - поиск ближайшего запуска после переданной даты
-func (s *AllureService) GetNextLaunch(afterDate time.Time) (*adapter.Launch, error) {
-	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+func (s *AllureService) GetNextLaunch(ctx context.Context, projectID int64, afterDate time.Time) (*adapter.Launch, error) {
+	projectID, err := s.resolveProject(ctx, projectID)
+	if err != nil {
+		return nil, err
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, s.timeouts().List)
 	defer cancel()
 
-	launches, err := s.client.GetLaunches(ctx)
+	// Allure сам отбирает запуски после даты и сортирует их по возрастанию,
+	// поэтому ближайший запуск приходит первым
+	launchPage, err := s.client.SearchLaunches(ctx, adapter.LaunchQuery{
+		ProjectID: projectID,
+		From:      afterDate,
+		Sort:      "createdDate,asc",
+		Size:      1,
+	})
 	if err != nil {
 		log.Error().Err(err).Msg("❌ Ошибка получения запусков")
 		return nil, err
 	}
 
-	if len(launches) == 0 {
-		log.Warn().Msg("⚠️ Нет запусков для поиска")
-		return nil, fmt.Errorf("нет запусков для анализа")
+	if len(launchPage.Content) == 0 {
+		log.Warn().Msg("⚠️ Не найден запуск после указанной даты")
+		return nil, fmt.Errorf("не найден запуск после указанной даты")
+	}
+
+	nextLaunch := launchPage.Content[0]
+	log.Info().Msgf("✅ Найден ближайший запуск: %s (ID: %d)", nextLaunch.Name, nextLaunch.ID)
+	return &nextLaunch, nil
+}
+
+// GetLaunches - получает страницу запусков по фильтрам запроса
+func (s *AllureService) GetLaunches(ctx context.Context, query adapter.LaunchQuery) (*adapter.LaunchPage, error) {
+	projectID, err := s.resolveProject(ctx, query.ProjectID)
+	if err != nil {
+		return nil, err
+	}
+	query.ProjectID = projectID
+
+	ctx, cancel := context.WithTimeout(ctx, s.timeouts().List)
+	defer cancel()
+
+	launchPage, err := s.client.SearchLaunches(ctx, query)
+	if err != nil {
+		log.Error().Err(err).Msg("❌ Ошибка получения списка запусков")
+		return nil, err
+	}
+
+	log.Info().Msgf("✅ Получено запусков: %d (страница %d из %d)", len(launchPage.Content), launchPage.Number+1, launchPage.TotalPages)
+	return launchPage, nil
+}
+
+// GetLaunch - получает запуск со статистикой, длительностью, окружением и связанной CI-джобой
+func (s *AllureService) GetLaunch(ctx context.Context, projectID, launchID int64) (*adapter.Launch, error) {
+	projectID, err := s.resolveProject(ctx, projectID)
+	if err != nil {
+		return nil, err
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, s.timeouts().List)
+	defer cancel()
+
+	launch, err := s.launchInProject(ctx, projectID, launchID)
+	if err != nil {
+		log.Error().Err(err).Msgf("❌ Ошибка получения запуска %d", launchID)
+		return nil, err
+	}
+
+	if launch.Statistic, err = s.client.GetLaunchStatistic(ctx, launchID); err != nil {
+		log.Error().Err(err).Msgf("❌ Ошибка получения статистики запуска %d", launchID)
+		return nil, err
+	}
+
+	if launch.Duration, err = s.client.GetLaunchDuration(ctx, launchID); err != nil {
+		log.Error().Err(err).Msgf("❌ Ошибка получения длительности запуска %d", launchID)
+		return nil, err
+	}
+
+	if launch.Environment, err = s.client.GetLaunchEnvironment(ctx, launchID); err != nil {
+		log.Error().Err(err).Msgf("❌ Ошибка получения окружения запуска %d", launchID)
+		return nil, err
+	}
+
+	if launch.JobRun, err = s.client.GetLaunchJobRun(ctx, launchID); err != nil {
+		log.Error().Err(err).Msgf("❌ Ошибка получения CI-джобы запуска %d", launchID)
+		return nil, err
 	}
 
-	afterTimestamp := afterDate.UnixMilli()
-	var closestLaunch *adapter.Launch
-	var minDiff int64 = math.MaxInt64
+	log.Info().Msgf("✅ Получен запуск: %s (ID: %d)", launch.Name, launch.ID)
+	return launch, nil
+}
 
-	for _, launch := range launches {
-		diff := launch.CreatedDate - afterTimestamp
-		if diff >= 0 && diff < minDiff {
-			closestLaunch = &launch
-			minDiff = diff
+// GetTestResults - получает страницу результатов тестов запуска
+func (s *AllureService) GetTestResults(ctx context.Context, projectID int64, query adapter.TestResultQuery) (*adapter.TestResultPage, error) {
+	projectID, err := s.resolveProject(ctx, projectID)
+	if err != nil {
+		return nil, err
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, s.timeouts().List)
+	defer cancel()
+
+	if projectID != 0 {
+		if _, err := s.launchInProject(ctx, projectID, query.LaunchID); err != nil {
+			log.Error().Err(err).Msgf("❌ Ошибка проверки запуска %d", query.LaunchID)
+			return nil, err
 		}
 	}
 
-	if closestLaunch == nil {
-		log.Warn().Msg("⚠️ Не найден запуск после указанной даты")
-		return nil, fmt.Errorf("не найден запуск после указанной даты")
+	resultPage, err := s.client.SearchTestResults(ctx, query)
+	if err != nil {
+		log.Error().Err(err).Msgf("❌ Ошибка получения результатов запуска %d", query.LaunchID)
+		return nil, err
 	}
 
-	log.Info().Msgf("✅ Найден ближайший запуск: %s (ID: %d)", closestLaunch.Name, closestLaunch.ID)
-	return closestLaunch, nil
+	log.Info().Msgf("✅ Получено результатов запуска %d: %d", query.LaunchID, len(resultPage.Content))
+	return resultPage, nil
+}
+
+// GetTestResult - получает результат теста вместе с шагами и вложениями
+func (s *AllureService) GetTestResult(ctx context.Context, projectID, resultID int64) (*adapter.TestResult, error) {
+	projectID, err := s.resolveProject(ctx, projectID)
+	if err != nil {
+		return nil, err
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, s.timeouts().List)
+	defer cancel()
+
+	result, err := s.resultInProject(ctx, projectID, resultID)
+	if err != nil {
+		log.Error().Err(err).Msgf("❌ Ошибка получения результата теста %d", resultID)
+		return nil, err
+	}
+
+	execution, err := s.client.GetTestResultExecution(ctx, resultID)
+	if err != nil {
+		log.Error().Err(err).Msgf("❌ Ошибка получения шагов результата теста %d", resultID)
+		return nil, err
+	}
+	result.Steps = execution.Steps
+	result.Attachments = execution.Attachments
+	if len(execution.Parameters) > 0 {
+		result.Parameters = execution.Parameters
+	}
+
+	log.Info().Msgf("✅ Получен результат теста: %s (ID: %d)", result.Name, result.ID)
+	return result, nil
+}
+
+// DownloadAttachment - открывает поток вложения результата теста.
+// Вложение отдается, только если оно принадлежит указанному результату.
+func (s *AllureService) DownloadAttachment(ctx context.Context, projectID, resultID, attachmentID int64, rangeHeader string) (*adapter.FileContent, error) {
+	projectID, err := s.resolveProject(ctx, projectID)
+	if err != nil {
+		return nil, err
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, s.timeouts().Download)
+
+	if projectID != 0 {
+		if _, err := s.resultInProject(ctx, projectID, resultID); err != nil {
+			cancel()
+			log.Error().Err(err).Msgf("❌ Ошибка проверки результата теста %d", resultID)
+			return nil, err
+		}
+	}
+
+	execution, err := s.client.GetTestResultExecution(ctx, resultID)
+	if err != nil {
+		cancel()
+		log.Error().Err(err).Msgf("❌ Ошибка получения вложений результата теста %d", resultID)
+		return nil, err
+	}
+
+	attachment := findAttachment(execution, attachmentID)
+	if attachment == nil {
+		cancel()
+		log.Warn().Msgf("⚠️ Вложение %d не найдено в результате теста %d", attachmentID, resultID)
+		return nil, fmt.Errorf("%w: вложение %d результата теста %d", adapter.ErrNotFound, attachmentID, resultID)
+	}
+
+	content, err := s.client.DownloadAttachment(ctx, attachmentID, rangeHeader)
+	if err != nil {
+		cancel()
+		log.Error().Err(err).Msgf("❌ Ошибка скачивания вложения %d", attachmentID)
+		return nil, err
+	}
+
+	// Контекст живет, пока вызывающий читает поток
+	content.Body = &cancelOnClose{ReadCloser: content.Body, cancel: cancel}
+	content.FileName = attachment.Name
+	if content.ContentType == "" {
+		content.ContentType = attachment.ContentType
+	}
+	if content.ContentType == "" {
+		content.ContentType = "application/octet-stream"
+	}
+
+	log.Info().Msgf("✅ Вложение открыто: %s (ID: %d)", attachment.Name, attachmentID)
+	return content, nil
+}
+
+// ExportLaunchResults - выгружает результаты тестов запуска в CSV или XLSX.
+// Запуск проверяется сразу, а результаты запрашиваются у Allure постранично во время записи.
+func (s *AllureService) ExportLaunchResults(ctx context.Context, projectID, launchID int64, format string) (*ResultsExport, error) {
+	if !export.SupportedFormat(format) {
+		return nil, fmt.Errorf("неподдерживаемый формат выгрузки: %s", format)
+	}
+
+	projectID, err := s.resolveProject(ctx, projectID)
+	if err != nil {
+		return nil, err
+	}
+
+	// Таймаут выгрузки действует до конца записи, поэтому контекст освобождает Write
+	ctx, cancel := context.WithTimeout(ctx, s.timeouts().Export)
+
+	if projectID != 0 {
+		if _, err := s.launchInProject(ctx, projectID, launchID); err != nil {
+			cancel()
+			log.Error().Err(err).Msgf("❌ Ошибка проверки запуска %d для выгрузки", launchID)
+			return nil, err
+		}
+	}
+
+	fileName := fmt.Sprintf("allure-launch-%d.%s", launchID, format)
+	return &ResultsExport{
+		FileName:    fileName,
+		ContentType: export.ContentType(format),
+		Write: func(w io.Writer) error {
+			defer cancel()
+
+			results, count := s.launchResults(ctx, launchID)
+			if err := export.WriteTable(w, format, results); err != nil {
+				log.Error().Err(err).Msgf("❌ Ошибка формирования %s-выгрузки запуска %d", format, launchID)
+				return err
+			}
+
+			log.Info().Msgf("✅ Выгрузка сформирована: %s (результатов: %d)", fileName, *count)
+			return nil
+		},
+	}, nil
+}
+
+// ExportLaunchJUnit - выгружает результаты тестов запуска в JUnit XML для CI-систем.
+// Запуск проверяется сразу, а результаты запрашиваются у Allure постранично во время записи.
+func (s *AllureService) ExportLaunchJUnit(ctx context.Context, projectID, launchID int64) (*ResultsExport, error) {
+	projectID, err := s.resolveProject(ctx, projectID)
+	if err != nil {
+		return nil, err
+	}
+
+	// Таймаут выгрузки действует до конца записи, поэтому контекст освобождает Write
+	ctx, cancel := context.WithTimeout(ctx, s.timeouts().Export)
+
+	launch, err := s.launchInProject(ctx, projectID, launchID)
+	if err != nil {
+		cancel()
+		log.Error().Err(err).Msgf("❌ Ошибка получения запуска %d для JUnit-выгрузки", launchID)
+		return nil, err
+	}
+
+	fileName := fmt.Sprintf("allure-launch-%d.junit.xml", launchID)
+	return &ResultsExport{
+		FileName:    fileName,
+		ContentType: export.JUnitContentType,
+		Write: func(w io.Writer) error {
+			defer cancel()
+
+			results, count := s.launchResults(ctx, launchID)
+			if err := export.WriteJUnit(w, launch.Name, results); err != nil {
+				log.Error().Err(err).Msgf("❌ Ошибка формирования JUnit-выгрузки запуска %d", launchID)
+				return err
+			}
+
+			log.Info().Msgf("✅ JUnit-выгрузка сформирована: %s (результатов: %d)", fileName, *count)
+			return nil
+		},
+	}, nil
+}
+
+// resultInProject - получает результат теста и проверяет, что он принадлежит проекту
+func (s *AllureService) resultInProject(ctx context.Context, projectID, resultID int64) (*adapter.TestResult, error) {
+	result, err := s.client.GetTestResult(ctx, resultID)
+	if err != nil {
+		return nil, err
+	}
+	if err := checkProject(projectID, int64(result.ProjectID), fmt.Sprintf("результат теста %d", resultID)); err != nil {
+		return nil, err
+	}
+
+	return result, nil
+}
+
+// launchResults - результаты тестов запуска, которые запрашиваются у Allure по мере обхода;
+// count считает переданные результаты для журнала
+func (s *AllureService) launchResults(ctx context.Context, launchID int64) (export.Results, *int) {
+	count := new(int)
+	results := func(yield func(adapter.TestResult) bool) error {
+		return s.client.IterateTestResults(ctx, adapter.TestResultQuery{LaunchID: launchID}, func(result adapter.TestResult) bool {
+			*count++
+			return yield(result)
+		})
+	}
+
+	return results, count
+}
+
+// findAttachment - ищет вложение по ID во всем дереве шагов
+func findAttachment(step *adapter.Step, attachmentID int64) *adapter.Attachment {
+	for i := range step.Attachments {
+		if step.Attachments[i].ID == attachmentID {
+			return &step.Attachments[i]
+		}
+	}
+	for i := range step.Steps {
+		if attachment := findAttachment(&step.Steps[i], attachmentID); attachment != nil {
+			return attachment
+		}
+	}
+
+	return nil
+}
+
+// cancelOnClose - поток, отменяющий контекст запроса при закрытии
+type cancelOnClose struct {
+	io.ReadCloser
+	cancel context.CancelFunc
+}
+
+// Close - закрывает поток и освобождает контекст
+func (c *cancelOnClose) Close() error {
+	defer c.cancel()
+	return c.ReadCloser.Close()
+}
+
+// cachingReader - поток отчета, копирующий прочитанное в кэш.
+// Кэш получает отчет, только если поток дочитан до конца и размер совпал с заявленным Allure.
+type cachingReader struct {
+	io.ReadCloser
+	upload *reportcache.Upload
+	size   int64
+	commit func()
+	done   bool
+}
+
+// Read - читает отчет и дописывает прочитанное в кэш
+func (c *cachingReader) Read(p []byte) (int, error) {
+	n, err := c.ReadCloser.Read(p)
+	if n > 0 && !c.done {
+		c.upload.Write(p[:n])
+	}
+	if err == io.EOF && !c.done {
+		c.done = true
+		if c.upload.Size() == c.size {
+			c.commit()
+		} else {
+			c.upload.Abort()
+		}
+	}
+	return n, err
+}
+
+// Close - закрывает поток; недочитанный отчет в кэш не попадает
+func (c *cachingReader) Close() error {
+	if !c.done {
+		c.done = true
+		c.upload.Abort()
+	}
+	return c.ReadCloser.Close()
 }
 
 // GeneratePDFReport - инициирует создание PDF-отчета
-func (s *AllureService) GeneratePDFReport(launchID int64, launchName string) (*adapter.PDFReport, error) {
-	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+func (s *AllureService) GeneratePDFReport(ctx context.Context, projectID, launchID int64, launchName string, opts adapter.PDFExportOptions) (*adapter.PDFReport, error) {
+	projectID, err := s.resolveProject(ctx, projectID)
+	if err != nil {
+		return nil, err
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, s.timeouts().Export)
 	defer cancel()
 
-	report, err := s.client.GeneratePDFReport(ctx, launchID, launchName)
+	if projectID != 0 {
+		if _, err := s.launchInProject(ctx, projectID, launchID); err != nil {
+			log.Error().Err(err).Msgf("❌ Ошибка проверки запуска %d для PDF-отчета", launchID)
+			return nil, err
+		}
+	}
+
+	report, err := s.client.GeneratePDFReport(ctx, launchID, launchName, opts)
 	if err != nil {
 		log.Error().Err(err).Msg("❌ Ошибка генерации PDF-отчета")
 		return nil, err
 	}
 
-	log.Info().Msgf("✅ PDF-отчет сгенерирован: %s (ID: %d)", report.Name, report.ID)
+	// Отчет формируется асинхронно, поэтому отслеживаем его готовность в фоне
+	job := s.exports.track(strconv.FormatInt(report.ID, 10), report, launchID, projectID)
+
+	log.Info().Msgf("✅ PDF-отчет запрошен: %s (ID: %d, статус: %s)", report.Name, report.ID, job.Status)
 	return report, nil
 }
 
+// GetExportStatus - возвращает состояние задачи экспорта PDF-отчета
+func (s *AllureService) GetExportStatus(ctx context.Context, projectID int64, reportID string) (*ExportJob, error) {
+	projectID, err := s.resolveProject(ctx, projectID)
+	if err != nil {
+		return nil, err
+	}
+
+	// Отчет запрошен не через этот экземпляр сервиса - узнаем статус у Allure.
+	// Вызывающий со своим API-токеном спрашивает Allure всегда: Allure сам решает, доступен ли ему отчет.
+	job, ok := s.exports.get(reportID)
+	if !ok || reqctx.AllureToken(ctx) != "" {
+		ctx, cancel := context.WithTimeout(ctx, s.timeouts().List)
+		defer cancel()
+
+		report, err := s.client.GetPDFReport(ctx, reportID)
+		if err != nil {
+			log.Error().Err(err).Msgf("❌ Ошибка получения статуса PDF-отчета %s", reportID)
+			return nil, err
+		}
+
+		job = s.exports.track(reportID, report, 0, int64(report.ProjectID))
+	}
+
+	if err := checkProject(projectID, job.ProjectID, "отчет "+reportID); err != nil {
+		log.Warn().Err(err).Msgf("⚠️ PDF-отчет %s не относится к проекту %d", reportID, projectID)
+		return nil, err
+	}
+
+	return &job, nil
+}
+
 // GetPDFDownloadLink - формирует ссылку для скачивания PDF-отчета
 func (s *AllureService) GetPDFDownloadLink(reportID string) string {
 	return s.client.GetPDFDownloadLink(reportID)
 }
 
 // DownloadPDFReport - скачивает PDF-отчет и отдает его фронтенду
-func (s *AllureService) DownloadPDFReport(reportID string) ([]byte, string, error) {
-	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
-	defer cancel()
+func (s *AllureService) DownloadPDFReport(ctx context.Context, projectID int64, reportID string) (*PDFDownload, error) {
+	// Отчет должен принадлежать проекту, даже если он уже лежит в кэше
+	job, err := s.GetExportStatus(ctx, projectID, reportID)
+	if err != nil {
+		log.Warn().Err(err).Msgf("⚠️ PDF-отчет %s нельзя скачать", reportID)
+		return nil, err
+	}
+
+	// Готовые отчеты в Allure не меняются, поэтому копию из кэша можно отдавать без проверок
+	if download, ok := s.openCachedReport(reportID); ok {
+		log.Info().Msgf("🗄️ PDF-отчет %s отдан из кэша", reportID)
+		return download, nil
+	}
 
-	fileData, fileName, err := s.client.DownloadPDFReport(ctx, reportID)
+	if err := s.waitExportReady(ctx, job); err != nil {
+		log.Warn().Err(err).Msgf("⚠️ PDF-отчет %s нельзя скачать", reportID)
+		return nil, err
+	}
+
+	parent := ctx
+	if s.archive != nil {
+		// Для архива отчет дочитывается и после ухода клиента
+		parent = context.WithoutCancel(ctx)
+	}
+	downloadCtx, cancel := context.WithTimeout(parent, s.timeouts().Download)
+	content, err := s.client.DownloadPDFReport(downloadCtx, reportID)
 	if err != nil {
+		cancel()
 		log.Error().Err(err).Msg("❌ Ошибка скачивания PDF-отчета")
-		return nil, "", err
+		return nil, err
+	}
+
+	// Контекст живет, пока поток читается. Кэш и архив получают копии потока независимо друг от друга.
+	var body io.ReadCloser = &cancelOnClose{ReadCloser: content.Body, cancel: cancel}
+	if s.reports != nil && content.ContentLength >= 0 && content.ContentLength <= s.reports.MaxSize() {
+		body = s.cacheStream(reportID, content.FileName, content.ContentLength, body)
+	}
+	if s.archive != nil {
+		body = s.archiveStream(reportID, content.FileName, content.ContentLength, body)
+	}
+
+	// ETag не задается: контрольная сумма станет известна только после отдачи всего потока
+	log.Info().Msgf("✅ PDF-отчет открыт: %s", content.FileName)
+	return &PDFDownload{
+		Body:        body,
+		Size:        content.ContentLength,
+		ContentType: content.ContentType,
+		FileName:    content.FileName,
+	}, nil
+}
+
+// cacheStream - копирует поток отчета в кэш по мере его чтения. Если кэш недоступен,
+// отчет отдается без него: повторно запрашивать отчет у Allure нельзя, поток уже открыт.
+func (s *AllureService) cacheStream(reportID, fileName string, size int64, body io.ReadCloser) io.ReadCloser {
+	upload, err := s.reports.NewUpload(reportID, fileName)
+	if err != nil {
+		log.Warn().Err(err).Msgf("⚠️ PDF-отчет %s не будет сохранен в кэш", reportID)
+		return body
+	}
+
+	return &cachingReader{ReadCloser: body, upload: upload, size: size, commit: func() {
+		if _, err := upload.Commit(); err != nil {
+			log.Warn().Err(err).Msgf("⚠️ PDF-отчет %s не сохранен в кэш", reportID)
+			return
+		}
+		log.Info().Msgf("🗄️ PDF-отчет %s сохранен в кэш", reportID)
+	}}
+}
+
+// openCachedReport - открывает отчет из кэша, если он там есть
+func (s *AllureService) openCachedReport(reportID string) (*PDFDownload, bool) {
+	if s.reports == nil {
+		return nil, false
+	}
+
+	entry, ok := s.reports.Get(reportID)
+	if !ok {
+		return nil, false
+	}
+
+	file, err := s.reports.Open(entry)
+	if err != nil {
+		log.Warn().Err(err).Msgf("⚠️ Ошибка чтения PDF-отчета %s из кэша", reportID)
+		return nil, false
 	}
 
-	log.Info().Msgf("✅ PDF-отчет скачан: %s", fileName)
-	return fileData, fileName, nil
+	return &PDFDownload{
+		Body:        file,
+		Size:        entry.Size,
+		ContentType: "application/pdf",
+		FileName:    entry.FileName,
+		ETag:        entry.ETag(),
+		Cached:      true,
+	}, true
+}
+
+// waitExportReady - ждет готовности отчета не дольше DownloadWait и не дольше жизни запроса
+func (s *AllureService) waitExportReady(ctx context.Context, job *ExportJob) error {
+	if job.Status == ExportStatusPending {
+		ctx, cancel := context.WithTimeout(ctx, s.exports.polling.DownloadWait)
+		defer cancel()
+
+		if waited, ok := s.exports.wait(ctx, job.ReportID); ok {
+			job = &waited
+		}
+	}
+
+	switch job.Status {
+	case ExportStatusReady:
+		return nil
+	case ExportStatusFailed:
+		return fmt.Errorf("%w: %s", ErrReportFailed, job.Error)
+	default:
+		return fmt.Errorf("%w: статус %s", ErrReportNotReady, job.AllureStatus)
+	}
 }
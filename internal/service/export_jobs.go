@@ -0,0 +1,228 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/rs/zerolog/log"
+	"github.com/vkr-mtuci/allure-service/internal/adapter"
+)
+
+// Состояния задачи экспорта PDF-отчета
+const (
+	ExportStatusPending = "pending"
+	ExportStatusReady   = "ready"
+	ExportStatusFailed  = "failed"
+)
+
+var (
+	// ErrReportNotReady - отчет еще формируется в Allure
+	ErrReportNotReady = errors.New("PDF-отчет еще не готов")
+	// ErrReportFailed - Allure не смог сформировать отчет
+	ErrReportFailed = errors.New("ошибка формирования PDF-отчета в Allure")
+)
+
+// finishedJobRetention - сколько хранится информация о завершенных задачах
+const finishedJobRetention = time.Hour
+
+// ExportPolling - настройки фонового опроса статуса экспорта
+type ExportPolling struct {
+	Interval     time.Duration // Период опроса Allure
+	Timeout      time.Duration // Максимальное время ожидания готовности отчета
+	DownloadWait time.Duration // Сколько скачивание ждет неготовый отчет
+}
+
+// DefaultExportPolling - настройки опроса по умолчанию
+var DefaultExportPolling = ExportPolling{
+	Interval:     2 * time.Second,
+	Timeout:      10 * time.Minute,
+	DownloadWait: 20 * time.Second,
+}
+
+// ExportJob - состояние задачи экспорта PDF-отчета
+type ExportJob struct {
+	ReportID     string    `json:"reportId"`
+	LaunchID     int64     `json:"launchId,omitempty"`
+	ProjectID    int64     `json:"projectId,omitempty"`
+	Name         string    `json:"name,omitempty"`
+	Status       string    `json:"status"`
+	AllureStatus string    `json:"allureStatus,omitempty"`
+	Error        string    `json:"error,omitempty"`
+	CreatedAt    time.Time `json:"createdAt"`
+	UpdatedAt    time.Time `json:"updatedAt"`
+}
+
+// trackedJob - задача экспорта и сигнал о ее завершении
+type trackedJob struct {
+	job  ExportJob
+	done chan struct{}
+}
+
+// exportTracker - отслеживает задачи экспорта, опрашивая Allure в фоне
+type exportTracker struct {
+	client        adapter.AllureClientInterface
+	polling       ExportPolling
+	statusTimeout func() time.Duration // Действующий таймаут одного запроса статуса к Allure
+	mu            sync.Mutex
+	jobs          map[string]*trackedJob
+}
+
+// newExportTracker - создание трекера задач экспорта
+func newExportTracker(client adapter.AllureClientInterface, polling ExportPolling) *exportTracker {
+	return &exportTracker{
+		client:        client,
+		polling:       polling,
+		statusTimeout: func() time.Duration { return DefaultTimeouts.List },
+		jobs:          make(map[string]*trackedJob),
+	}
+}
+
+// track - начинает отслеживать отчет; если он еще не готов, запускается фоновый опрос
+func (t *exportTracker) track(reportID string, report *adapter.PDFReport, launchID, projectID int64) ExportJob {
+	now := time.Now()
+
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.cleanupLocked(now)
+
+	if tracked, ok := t.jobs[reportID]; ok {
+		return tracked.job
+	}
+
+	tracked := &trackedJob{
+		job: ExportJob{
+			ReportID:     reportID,
+			LaunchID:     launchID,
+			ProjectID:    projectID,
+			Name:         report.Name,
+			Status:       exportStatus(report.Status),
+			AllureStatus: report.Status,
+			CreatedAt:    now,
+			UpdatedAt:    now,
+		},
+		done: make(chan struct{}),
+	}
+	t.jobs[reportID] = tracked
+
+	if tracked.job.Status == ExportStatusPending {
+		go t.poll(tracked)
+	} else {
+		close(tracked.done)
+	}
+
+	return tracked.job
+}
+
+// get - возвращает состояние отслеживаемой задачи
+func (t *exportTracker) get(reportID string) (ExportJob, bool) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	tracked, ok := t.jobs[reportID]
+	if !ok {
+		return ExportJob{}, false
+	}
+	return tracked.job, true
+}
+
+// wait - ждет завершения задачи, но не дольше, чем живет ctx
+func (t *exportTracker) wait(ctx context.Context, reportID string) (ExportJob, bool) {
+	t.mu.Lock()
+	tracked, ok := t.jobs[reportID]
+	t.mu.Unlock()
+	if !ok {
+		return ExportJob{}, false
+	}
+
+	select {
+	case <-tracked.done:
+	case <-ctx.Done():
+	}
+
+	return t.get(reportID)
+}
+
+// poll - опрашивает Allure, пока отчет не будет готов, не упадет или не истечет таймаут
+func (t *exportTracker) poll(tracked *trackedJob) {
+	reportID := tracked.job.ReportID
+	deadline := time.Now().Add(t.polling.Timeout)
+	ticker := time.NewTicker(t.polling.Interval)
+	defer ticker.Stop()
+
+	log.Info().Msgf("⏳ Ожидание готовности PDF-отчета %s", reportID)
+
+	for range ticker.C {
+		if time.Now().After(deadline) {
+			t.mu.Lock()
+			allureStatus := tracked.job.AllureStatus
+			t.mu.Unlock()
+			t.finish(tracked, ExportStatusFailed, allureStatus, "превышено время ожидания готовности отчета")
+			return
+		}
+
+		ctx, cancel := context.WithTimeout(context.Background(), t.statusTimeout())
+		report, err := t.client.GetPDFReport(ctx, reportID)
+		cancel()
+
+		if err != nil {
+			// Ошибки опроса считаем временными и пробуем снова до истечения таймаута
+			log.Warn().Err(err).Msgf("⚠️ Ошибка опроса статуса PDF-отчета %s", reportID)
+			continue
+		}
+
+		if status := exportStatus(report.Status); status != ExportStatusPending {
+			message := ""
+			if status == ExportStatusFailed {
+				message = "Allure вернул статус " + report.Status
+			}
+			t.finish(tracked, status, report.Status, message)
+			return
+		}
+
+		t.mu.Lock()
+		tracked.job.AllureStatus = report.Status
+		tracked.job.UpdatedAt = time.Now()
+		t.mu.Unlock()
+	}
+}
+
+// finish - фиксирует итоговое состояние задачи и будит ожидающих
+func (t *exportTracker) finish(tracked *trackedJob, status, allureStatus, message string) {
+	t.mu.Lock()
+	tracked.job.Status = status
+	tracked.job.AllureStatus = allureStatus
+	tracked.job.Error = message
+	tracked.job.UpdatedAt = time.Now()
+	t.mu.Unlock()
+	close(tracked.done)
+
+	if status == ExportStatusReady {
+		log.Info().Msgf("✅ PDF-отчет %s готов", tracked.job.ReportID)
+	} else {
+		log.Warn().Msgf("⚠️ PDF-отчет %s не сформирован: %s", tracked.job.ReportID, message)
+	}
+}
+
+// cleanupLocked - удаляет давно завершенные задачи; вызывается под мьютексом
+func (t *exportTracker) cleanupLocked(now time.Time) {
+	for reportID, tracked := range t.jobs {
+		if tracked.job.Status != ExportStatusPending && now.Sub(tracked.job.UpdatedAt) > finishedJobRetention {
+			delete(t.jobs, reportID)
+		}
+	}
+}
+
+// exportStatus - приводит статус экспорта Allure к состоянию задачи
+func exportStatus(allureStatus string) string {
+	switch strings.ToLower(allureStatus) {
+	case "ready", "done", "generated", "completed", "success":
+		return ExportStatusReady
+	case "failed", "error", "cancelled", "canceled":
+		return ExportStatusFailed
+	default:
+		return ExportStatusPending
+	}
+}
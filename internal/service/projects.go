@@ -0,0 +1,120 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"github.com/rs/zerolog/log"
+	"github.com/vkr-mtuci/allure-service/internal/adapter"
+	"github.com/vkr-mtuci/allure-service/internal/auth"
+)
+
+// ErrProjectNotAllowed - проект не входит в список разрешенных в конфигурации
+var ErrProjectNotAllowed = errors.New("проект недоступен через сервис")
+
+// projectAccess - проект по умолчанию и список разрешенных проектов
+type projectAccess struct {
+	defaultID int64          // 0 - проект по умолчанию задан только в клиенте Allure
+	allowed   map[int64]bool // nil - сервис создан без WithProjects и не ограничивает проекты
+}
+
+// WithProjects - задает проект по умолчанию и список разрешенных проектов
+func WithProjects(defaultID int64, allowed []int64) Option {
+	return func(s *AllureService) {
+		s.updateSettings(func(settings *serviceSettings) {
+			settings.projects = newProjectAccess(defaultID, allowed)
+		})
+	}
+}
+
+// newProjectAccess - доступ к проектам; проект по умолчанию разрешен всегда
+func newProjectAccess(defaultID int64, allowed []int64) projectAccess {
+	projects := projectAccess{
+		defaultID: defaultID,
+		allowed:   make(map[int64]bool, len(allowed)+1),
+	}
+	for _, projectID := range allowed {
+		projects.allowed[projectID] = true
+	}
+	if defaultID != 0 {
+		projects.allowed[defaultID] = true
+	}
+
+	return projects
+}
+
+// GetProjects - возвращает проекты Allure, разрешенные в конфигурации сервиса и вызывающему
+func (s *AllureService) GetProjects(ctx context.Context) ([]adapter.Project, error) {
+	ctx, cancel := context.WithTimeout(ctx, s.timeouts().List)
+	defer cancel()
+
+	projects, err := s.client.GetProjects(ctx)
+	if err != nil {
+		log.Error().Err(err).Msg("❌ Ошибка получения списка проектов")
+		return nil, err
+	}
+
+	access := s.settings.Load().projects
+	accessible := make([]adapter.Project, 0, len(projects))
+	for _, project := range projects {
+		if access.isAllowed(project.ID) && auth.CheckProject(ctx, project.ID) == nil {
+			accessible = append(accessible, project)
+		}
+	}
+
+	log.Info().Msgf("✅ Получено доступных проектов: %d из %d", len(accessible), len(projects))
+	return accessible, nil
+}
+
+// CheckProject - проверяет, что проект разрешен в конфигурации сервиса и вызывающему
+func (s *AllureService) CheckProject(ctx context.Context, projectID int64) error {
+	_, err := s.resolveProject(ctx, projectID)
+	return err
+}
+
+// resolveProject - подставляет проект по умолчанию вместо 0 и проверяет, что проект разрешен
+// в конфигурации сервиса и вызывающему из контекста
+func (s *AllureService) resolveProject(ctx context.Context, projectID int64) (int64, error) {
+	access := s.settings.Load().projects
+	if projectID == 0 {
+		projectID = access.defaultID
+	} else if !access.isAllowed(projectID) {
+		log.Warn().Msgf("⚠️ Запрос к неразрешенному проекту %d", projectID)
+		return 0, fmt.Errorf("%w: %d", ErrProjectNotAllowed, projectID)
+	}
+
+	if err := auth.CheckProject(ctx, projectID); err != nil {
+		log.Warn().Err(err).Msgf("⚠️ Запрос к проекту %d без прав", projectID)
+		return 0, err
+	}
+	return projectID, nil
+}
+
+// isAllowed - проверяет проект по списку разрешенных. Из конфигурации список приходит всегда
+// и содержит проект по умолчанию, пустой список не разрешает ни одного проекта.
+func (p projectAccess) isAllowed(projectID int64) bool {
+	return p.allowed == nil || p.allowed[projectID]
+}
+
+// checkProject - объект другого проекта считается ненайденным, чтобы не раскрывать его существование.
+// При projectID == 0 проект не проверяется.
+func checkProject(projectID, ownerProjectID int64, object string) error {
+	if projectID != 0 && ownerProjectID != projectID {
+		return fmt.Errorf("%w: %s в проекте %d", adapter.ErrNotFound, object, projectID)
+	}
+	return nil
+}
+
+// launchInProject - получает запуск и проверяет, что он принадлежит проекту
+func (s *AllureService) launchInProject(ctx context.Context, projectID, launchID int64) (*adapter.Launch, error) {
+	launch, err := s.client.GetLaunch(ctx, launchID)
+	if err != nil {
+		return nil, err
+	}
+	if err := checkProject(projectID, int64(launch.ProjectID), fmt.Sprintf("запуск %d", launchID)); err != nil {
+		return nil, err
+	}
+
+	return launch, nil
+}
@@ -0,0 +1,226 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+
+	"github.com/rs/zerolog/log"
+	"github.com/vkr-mtuci/allure-service/internal/adapter"
+	"github.com/vkr-mtuci/allure-service/internal/archive"
+	"github.com/vkr-mtuci/allure-service/internal/auth"
+	"github.com/vkr-mtuci/allure-service/internal/reqctx"
+)
+
+// ErrArchiveDisabled - архив отчетов не настроен
+var ErrArchiveDisabled = errors.New("архив отчетов не настроен")
+
+// ListArchivedReports - возвращает архивные отчеты запуска, при launchID == 0 - все
+func (s *AllureService) ListArchivedReports(ctx context.Context, launchID int64) ([]archive.Report, error) {
+	if s.archive == nil {
+		return nil, ErrArchiveDisabled
+	}
+	// Архив общий для всех проектов экземпляра
+	if err := auth.CheckAllProjects(ctx); err != nil {
+		return nil, err
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, s.timeouts().List)
+	defer cancel()
+
+	reports, err := s.archive.List(ctx, launchID)
+	if err != nil {
+		log.Error().Err(err).Msgf("❌ Ошибка получения архивных отчетов запуска %d", launchID)
+		return nil, err
+	}
+	if reports, err = s.visibleReports(ctx, reports); err != nil {
+		log.Error().Err(err).Msgf("❌ Ошибка проверки доступа к архивным отчетам запуска %d", launchID)
+		return nil, err
+	}
+
+	log.Info().Msgf("✅ Получено архивных отчетов запуска %d: %d", launchID, len(reports))
+	return reports, nil
+}
+
+// OpenArchivedReport - открывает архивный отчет запуска потоком
+func (s *AllureService) OpenArchivedReport(ctx context.Context, launchID int64, reportID string) (*PDFDownload, error) {
+	if s.archive == nil {
+		return nil, ErrArchiveDisabled
+	}
+	// Архив общий для всех проектов экземпляра
+	if err := auth.CheckAllProjects(ctx); err != nil {
+		return nil, err
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, s.timeouts().Download)
+	if err := s.checkArchivedLaunch(ctx, launchID); err != nil {
+		cancel()
+		log.Warn().Err(err).Msgf("⚠️ Архивный отчет %s запуска %d недоступен вызывающему", reportID, launchID)
+		return nil, err
+	}
+	report, body, err := s.archive.Open(ctx, launchID, reportID)
+	if err != nil {
+		cancel()
+		log.Error().Err(err).Msgf("❌ Ошибка открытия архивного отчета %s запуска %d", reportID, launchID)
+		return nil, err
+	}
+
+	log.Info().Msgf("🗃️ Архивный отчет открыт: %s", report.FileName)
+	return &PDFDownload{
+		// Контекст живет, пока вызывающий читает поток
+		Body:        &cancelOnClose{ReadCloser: body, cancel: cancel},
+		Size:        report.Size,
+		ContentType: "application/pdf",
+		FileName:    report.FileName,
+	}, nil
+}
+
+// checkArchivedLaunch - проверяет, что вызывающему со своим API-токеном Allure доступен запуск
+// архивного отчета: архив хранит копии, и права в Allure на них не распространяются.
+// Отчеты с неизвестным запуском (0) таким вызывающим недоступны.
+func (s *AllureService) checkArchivedLaunch(ctx context.Context, launchID int64) error {
+	if reqctx.AllureToken(ctx) == "" {
+		return nil
+	}
+	if launchID == 0 {
+		return fmt.Errorf("%w: запуск архивного отчета неизвестен", auth.ErrForbidden)
+	}
+
+	_, err := s.launchInProject(ctx, 0, launchID)
+	return err
+}
+
+// visibleReports - оставляет архивные отчеты, запуски которых доступны вызывающему в Allure
+func (s *AllureService) visibleReports(ctx context.Context, reports []archive.Report) ([]archive.Report, error) {
+	if reqctx.AllureToken(ctx) == "" {
+		return reports, nil
+	}
+
+	allowed := make(map[int64]bool)
+	visible := make([]archive.Report, 0, len(reports))
+	for _, report := range reports {
+		ok, checked := allowed[report.LaunchID]
+		if !checked {
+			err := s.checkArchivedLaunch(ctx, report.LaunchID)
+			switch {
+			case err == nil:
+				ok = true
+			case errors.Is(err, auth.ErrForbidden), errors.Is(err, adapter.ErrForbidden), errors.Is(err, adapter.ErrNotFound):
+				ok = false
+			default:
+				return nil, err
+			}
+			allowed[report.LaunchID] = ok
+		}
+		if ok {
+			visible = append(visible, report)
+		}
+	}
+	return visible, nil
+}
+
+// archiveStream - копирует поток отчета во временный файл по мере его чтения вызывающим.
+// В архив файл загружается в фоне после отдачи отчета, поэтому скорость архива не влияет на скачивание;
+// недочитанный вызывающим остаток отчета дочитывается там же.
+func (s *AllureService) archiveStream(reportID, fileName string, size int64, body io.ReadCloser) io.ReadCloser {
+	file, err := os.CreateTemp("", "allure-archive-*")
+	if err != nil {
+		log.Warn().Err(err).Msgf("⚠️ PDF-отчет %s не будет сохранен в архив", reportID)
+		return body
+	}
+
+	return &archivingReader{ReadCloser: body, file: file, size: size, upload: func(file *os.File) {
+		s.putArchive(reportID, fileName, file)
+	}}
+}
+
+// putArchive - сохраняет отчет в архив под запуском, для которого он был запрошен.
+// Загрузка идет в фоне, поэтому ограничена таймаутом скачивания, а не жизнью запроса.
+func (s *AllureService) putArchive(reportID, fileName string, r io.Reader) {
+	ctx, cancel := context.WithTimeout(context.Background(), s.timeouts().Download)
+	defer cancel()
+
+	// Для отчетов, запрошенных не через этот экземпляр сервиса, запуск неизвестен (0)
+	var launchID int64
+	if job, ok := s.exports.get(reportID); ok {
+		launchID = job.LaunchID
+	}
+
+	report, err := s.archive.Put(ctx, archive.Report{LaunchID: launchID, ReportID: reportID, FileName: fileName}, r)
+	if err != nil {
+		log.Warn().Err(err).Msgf("⚠️ PDF-отчет %s не сохранен в архив", reportID)
+		return
+	}
+
+	log.Info().Msgf("🗃️ PDF-отчет %s сохранен в архив (запуск %d, %d байт)", reportID, report.LaunchID, report.Size)
+}
+
+// archivingReader - поток отчета, копирующий прочитанное во временный файл для архива
+type archivingReader struct {
+	io.ReadCloser
+	file   *os.File // nil после ошибки записи
+	upload func(file *os.File)
+	size   int64 // -1, если размер неизвестен
+	read   int64
+}
+
+// Read - читает отчет и дописывает прочитанное во временный файл
+func (a *archivingReader) Read(p []byte) (int, error) {
+	n, err := a.ReadCloser.Read(p)
+	if n > 0 {
+		a.read += int64(n)
+		if a.file != nil {
+			if _, werr := a.file.Write(p[:n]); werr != nil {
+				// Архив не получит отчет - отдачу отчета это не прерывает
+				log.Warn().Err(werr).Msg("⚠️ Ошибка записи PDF-отчета во временный файл архива")
+				removeTemp(a.file)
+				a.file = nil
+			}
+		}
+	}
+	return n, err
+}
+
+// Close - завершает отдачу отчета; архивирование продолжается в фоне
+func (a *archivingReader) Close() error {
+	if a.file == nil {
+		return a.ReadCloser.Close()
+	}
+	go a.finish()
+	return nil
+}
+
+// finish - дочитывает отчет, закрывает поток и загружает отчет в архив
+func (a *archivingReader) finish() {
+	_, err := io.Copy(io.Discard, a)
+	a.ReadCloser.Close()
+
+	file := a.file
+	if file == nil {
+		return
+	}
+	defer removeTemp(file)
+
+	switch {
+	case err != nil:
+		log.Warn().Err(err).Msg("⚠️ PDF-отчет не дочитан из Allure и не сохранен в архив")
+		return
+	case a.size >= 0 && a.read != a.size:
+		log.Warn().Msgf("⚠️ PDF-отчет не сохранен в архив: получено %d байт из %d", a.read, a.size)
+		return
+	}
+
+	if _, err := file.Seek(0, io.SeekStart); err != nil {
+		log.Warn().Err(err).Msg("⚠️ Ошибка чтения временного файла архива")
+		return
+	}
+	a.upload(file)
+}
+
+// removeTemp - закрывает и удаляет временный файл
+func removeTemp(file *os.File) {
+	file.Close()
+	os.Remove(file.Name())
+}
@@ -1,13 +1,18 @@
 package test
 
 import (
+	"bytes"
 	"context"
 	"encoding/json"
 	"errors"
 	"fmt"
+	"io"
 	"net/http"
 	"net/http/httptest"
+	"strconv"
 	"strings"
+	"sync"
+	"sync/atomic"
 	"testing"
 	"time"
 
@@ -15,6 +20,7 @@ import (
 	"github.com/stretchr/testify/mock"
 	"github.com/vkr-mtuci/allure-service/config"
 	"github.com/vkr-mtuci/allure-service/internal/adapter"
+	"github.com/vkr-mtuci/allure-service/internal/reqctx"
 )
 
 // ✅ **Тест с моком**
@@ -161,7 +167,7 @@ func TestGetLaunches_RealClient(t *testing.T) {
 	client := adapter.NewAllureClient(cfg)
 
 	// Запрашиваем запуски через реальный `AllureClient`
-	launches, err := client.GetLaunches(context.Background())
+	launches, err := client.GetLaunches(context.Background(), adapter.LaunchQuery{})
 
 	// Проверяем, что нет ошибок
 	assert.NoError(t, err)
@@ -170,6 +176,341 @@ func TestGetLaunches_RealClient(t *testing.T) {
 	assert.Equal(t, "Launch 1", launches[0].Name)
 }
 
+// newLaunchPagesServer - фейковый Allure API, отдающий запуски постранично
+func newLaunchPagesServer(t *testing.T, pages [][]adapter.Launch, requested *[]string) *httptest.Server {
+	return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method == http.MethodPost && r.URL.Path == "/api/uaa/oauth/token" {
+			w.Header().Set("Content-Type", "application/json")
+			_, _ = w.Write([]byte(`{"access_token": "mocked_token", "expires_in": 3600}`))
+			return
+		}
+
+		if r.Method == http.MethodGet && r.URL.Path == "/api/launch" {
+			page := r.URL.Query().Get("page")
+			*requested = append(*requested, page)
+			assert.Equal(t, "createdDate,DESC", r.URL.Query().Get("sort"))
+
+			number, _ := strconv.Atoi(page)
+			w.Header().Set("Content-Type", "application/json")
+			json.NewEncoder(w).Encode(adapter.LaunchPage{
+				Content:    pages[number],
+				TotalPages: len(pages),
+				Number:     number,
+				Last:       number == len(pages)-1,
+			})
+			return
+		}
+
+		w.WriteHeader(http.StatusNotFound)
+	}))
+}
+
+// ✅ **Тест: обход всех страниц запусков**
+func TestGetLaunches_AllPages(t *testing.T) {
+	now := time.Now()
+	pages := [][]adapter.Launch{
+		{{ID: 5, CreatedDate: now.UnixMilli()}, {ID: 4, CreatedDate: now.Add(-1 * time.Hour).UnixMilli()}},
+		{{ID: 3, CreatedDate: now.Add(-2 * time.Hour).UnixMilli()}, {ID: 2, CreatedDate: now.Add(-3 * time.Hour).UnixMilli()}},
+		{{ID: 1, CreatedDate: now.Add(-4 * time.Hour).UnixMilli()}},
+	}
+	var requested []string
+	mockServer := newLaunchPagesServer(t, pages, &requested)
+	defer mockServer.Close()
+
+	client := adapter.NewAllureClient(&config.Config{
+		AllureBaseURL:   mockServer.URL,
+		AllureAPIURL:    "/api/",
+		AllureUserToken: "fake-token",
+	})
+
+	launches, err := client.GetLaunches(context.Background(), adapter.LaunchQuery{})
+
+	assert.NoError(t, err)
+	assert.Len(t, launches, 5)
+	assert.Equal(t, []string{"0", "1", "2"}, requested)
+}
+
+// ✅ **Тест: фильтрация и сортировка запусков передаются в Allure**
+func TestSearchLaunches_ServerSideFilter(t *testing.T) {
+	from := time.Date(2025, 1, 30, 22, 0, 0, 0, time.UTC)
+	to := from.Add(24 * time.Hour)
+
+	mockServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method == http.MethodPost && r.URL.Path == "/api/uaa/oauth/token" {
+			w.Header().Set("Content-Type", "application/json")
+			_, _ = w.Write([]byte(`{"access_token": "mocked_token", "expires_in": 3600}`))
+			return
+		}
+
+		if r.Method == http.MethodGet && r.URL.Path == "/api/launch/__search" {
+			query := r.URL.Query()
+			assert.Equal(t, "1661", query.Get("projectId"))
+			assert.Equal(t, fmt.Sprintf("createdDate >= %d and createdDate < %d", from.UnixMilli(), to.UnixMilli()), query.Get("rql"))
+			assert.Equal(t, "createdDate,asc", query.Get("sort"))
+			assert.Equal(t, "2", query.Get("page"))
+			assert.Equal(t, "1", query.Get("size"))
+
+			w.Header().Set("Content-Type", "application/json")
+			json.NewEncoder(w).Encode(adapter.LaunchPage{
+				Content:    []adapter.Launch{{ID: 7, CreatedDate: from.Add(time.Hour).UnixMilli()}},
+				TotalPages: 3,
+				Number:     2,
+				Last:       true,
+			})
+			return
+		}
+
+		w.WriteHeader(http.StatusNotFound)
+	}))
+	defer mockServer.Close()
+
+	client := adapter.NewAllureClient(&config.Config{
+		AllureBaseURL:   mockServer.URL,
+		AllureAPIURL:    "/api/",
+		AllureUserToken: "fake-token",
+		AllureProjectID: "1661",
+	})
+
+	page, err := client.SearchLaunches(context.Background(), adapter.LaunchQuery{
+		From: from,
+		To:   to,
+		Sort: "createdDate,asc",
+		Page: 2,
+		Size: 1,
+	})
+
+	assert.NoError(t, err)
+	assert.Len(t, page.Content, 1)
+	assert.Equal(t, int64(7), page.Content[0].ID)
+	assert.True(t, page.Last)
+}
+
+// ✅ **Тест: фильтры по имени и тегу экранируются в RQL**
+func TestSearchLaunches_NameAndTagFilter(t *testing.T) {
+	var rql string
+	mockServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method == http.MethodPost && r.URL.Path == "/api/uaa/oauth/token" {
+			w.Header().Set("Content-Type", "application/json")
+			_, _ = w.Write([]byte(`{"access_token": "mocked_token", "expires_in": 3600}`))
+			return
+		}
+
+		if r.Method == http.MethodGet && r.URL.Path == "/api/launch/__search" {
+			rql = r.URL.Query().Get("rql")
+			w.Header().Set("Content-Type", "application/json")
+			_, _ = w.Write([]byte(`{"content": [], "last": true}`))
+			return
+		}
+
+		w.WriteHeader(http.StatusNotFound)
+	}))
+	defer mockServer.Close()
+
+	client := adapter.NewAllureClient(&config.Config{
+		AllureBaseURL:   mockServer.URL,
+		AllureAPIURL:    "/api/",
+		AllureUserToken: "fake-token",
+	})
+
+	_, err := client.SearchLaunches(context.Background(), adapter.LaunchQuery{Name: `smoke "main"`, Tag: "regress"})
+
+	assert.NoError(t, err)
+	assert.Equal(t, `name ~= "smoke \"main\"" and tag = "regress"`, rql)
+}
+
+// ✅ **Тест: потоковый обход прерывается по желанию вызывающего**
+func TestIterateLaunches_StopByCallback(t *testing.T) {
+	now := time.Now()
+	pages := [][]adapter.Launch{
+		{{ID: 3, CreatedDate: now.UnixMilli()}},
+		{{ID: 2, CreatedDate: now.Add(-1 * time.Hour).UnixMilli()}},
+		{{ID: 1, CreatedDate: now.Add(-2 * time.Hour).UnixMilli()}},
+	}
+	var requested []string
+	mockServer := newLaunchPagesServer(t, pages, &requested)
+	defer mockServer.Close()
+
+	client := adapter.NewAllureClient(&config.Config{
+		AllureBaseURL:   mockServer.URL,
+		AllureAPIURL:    "/api/",
+		AllureUserToken: "fake-token",
+	})
+
+	var seen []int64
+	err := client.IterateLaunches(context.Background(), adapter.LaunchQuery{}, func(launch adapter.Launch) bool {
+		seen = append(seen, launch.ID)
+		return launch.ID != 2
+	})
+
+	assert.NoError(t, err)
+	assert.Equal(t, []int64{3, 2}, seen)
+	assert.Equal(t, []string{"0", "1"}, requested)
+}
+
+// ✅ **Тест: подробности запуска из API запусков и статистики**
+func TestGetLaunchDetails_RealClient(t *testing.T) {
+	mockServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method == http.MethodPost && r.URL.Path == "/api/uaa/oauth/token" {
+			w.Header().Set("Content-Type", "application/json")
+			_, _ = w.Write([]byte(`{"access_token": "mocked_token", "expires_in": 3600}`))
+			return
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		switch r.URL.Path {
+		case "/api/launch/42":
+			_, _ = w.Write([]byte(`{"id": 42, "name": "Nightly", "projectId": 1661, "closed": true, "tags": [{"id": 1, "name": "regress"}]}`))
+		case "/api/launch/42/statistic":
+			_, _ = w.Write([]byte(`[{"status": "passed", "count": 10}, {"status": "failed", "count": 2}, {"status": "broken", "count": 1}, {"status": "skipped", "count": 3}, {"status": "unknown", "count": 1}]`))
+		case "/api/launch/42/duration":
+			_, _ = w.Write([]byte(`{"duration": 90000}`))
+		case "/api/launch/42/env":
+			_, _ = w.Write([]byte(`[{"name": "browser", "value": "chrome"}]`))
+		default:
+			w.WriteHeader(http.StatusNotFound)
+		}
+	}))
+	defer mockServer.Close()
+
+	client := adapter.NewAllureClient(&config.Config{
+		AllureBaseURL:   mockServer.URL,
+		AllureAPIURL:    "/api/",
+		AllureUserToken: "fake-token",
+	})
+	ctx := context.Background()
+
+	launch, err := client.GetLaunch(ctx, 42)
+	assert.NoError(t, err)
+	assert.Equal(t, "Nightly", launch.Name)
+	assert.True(t, launch.Closed)
+	assert.Equal(t, []adapter.Tag{{ID: 1, Name: "regress"}}, launch.Tags)
+
+	statistic, err := client.GetLaunchStatistic(ctx, 42)
+	assert.NoError(t, err)
+	assert.Equal(t, &adapter.LaunchStatistic{Passed: 10, Failed: 2, Broken: 1, Skipped: 3, Unknown: 1, Total: 17}, statistic)
+
+	duration, err := client.GetLaunchDuration(ctx, 42)
+	assert.NoError(t, err)
+	assert.Equal(t, int64(90000), duration)
+
+	environment, err := client.GetLaunchEnvironment(ctx, 42)
+	assert.NoError(t, err)
+	assert.Equal(t, []adapter.EnvVarValue{{Name: "browser", Value: "chrome"}}, environment)
+
+	// У запуска нет связанной CI-джобы - это не ошибка
+	jobRun, err := client.GetLaunchJobRun(ctx, 42)
+	assert.NoError(t, err)
+	assert.Nil(t, jobRun)
+
+	// Несуществующий запуск
+	_, err = client.GetLaunch(ctx, 43)
+	assert.ErrorIs(t, err, adapter.ErrNotFound)
+}
+
+// ✅ **Тест: результаты тестов запуска и дерево шагов**
+func TestTestResults_RealClient(t *testing.T) {
+	mockServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method == http.MethodPost && r.URL.Path == "/api/uaa/oauth/token" {
+			w.Header().Set("Content-Type", "application/json")
+			_, _ = w.Write([]byte(`{"access_token": "mocked_token", "expires_in": 3600}`))
+			return
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		switch r.URL.Path {
+		case "/api/testresult/__search":
+			assert.Equal(t, "42", r.URL.Query().Get("launchId"))
+			assert.Equal(t, `status in ["failed", "broken"]`, r.URL.Query().Get("rql"))
+			assert.Equal(t, "10", r.URL.Query().Get("size"))
+			_, _ = w.Write([]byte(`{"content": [{"id": 7, "name": "login", "status": "failed", "launchId": 42}], "totalElements": 1, "totalPages": 1, "last": true}`))
+		case "/api/testresult/7":
+			_, _ = w.Write([]byte(`{"id": 7, "name": "login", "status": "failed", "message": "boom", "trace": "at login()"}`))
+		case "/api/testresult/7/execution":
+			_, _ = w.Write([]byte(`{"steps": [{"name": "open page", "status": "passed", "attachments": [{"id": 3, "name": "screen.png", "contentType": "image/png", "contentLength": 1024}]}]}`))
+		default:
+			w.WriteHeader(http.StatusNotFound)
+		}
+	}))
+	defer mockServer.Close()
+
+	client := adapter.NewAllureClient(&config.Config{
+		AllureBaseURL:   mockServer.URL,
+		AllureAPIURL:    "/api/",
+		AllureUserToken: "fake-token",
+	})
+	ctx := context.Background()
+
+	page, err := client.SearchTestResults(ctx, adapter.TestResultQuery{LaunchID: 42, Statuses: []string{"failed", "broken"}, Size: 10})
+	assert.NoError(t, err)
+	assert.Len(t, page.Content, 1)
+	assert.Equal(t, "failed", page.Content[0].Status)
+
+	result, err := client.GetTestResult(ctx, 7)
+	assert.NoError(t, err)
+	assert.Equal(t, "boom", result.Message)
+	assert.Equal(t, "at login()", result.Trace)
+
+	execution, err := client.GetTestResultExecution(ctx, 7)
+	assert.NoError(t, err)
+	assert.Len(t, execution.Steps, 1)
+	assert.Equal(t, "screen.png", execution.Steps[0].Attachments[0].Name)
+
+	_, err = client.GetTestResult(ctx, 8)
+	assert.ErrorIs(t, err, adapter.ErrNotFound)
+}
+
+// ✅ **Тест: потоковое скачивание вложения с поддержкой Range**
+func TestDownloadAttachment_RealClient(t *testing.T) {
+	video := []byte("0123456789abcdef")
+	mockServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method == http.MethodPost && r.URL.Path == "/api/uaa/oauth/token" {
+			w.Header().Set("Content-Type", "application/json")
+			_, _ = w.Write([]byte(`{"access_token": "mocked_token", "expires_in": 3600}`))
+			return
+		}
+
+		if r.URL.Path == "/api/testresult/attachment/3/content" {
+			assert.Equal(t, "Bearer mocked_token", r.Header.Get("Authorization"))
+			w.Header().Set("Content-Type", "video/mp4")
+			http.ServeContent(w, r, "video.mp4", time.Time{}, bytes.NewReader(video))
+			return
+		}
+
+		w.WriteHeader(http.StatusNotFound)
+	}))
+	defer mockServer.Close()
+
+	client := adapter.NewAllureClient(&config.Config{
+		AllureBaseURL:   mockServer.URL,
+		AllureAPIURL:    "/api/",
+		AllureUserToken: "fake-token",
+	})
+	ctx := context.Background()
+
+	content, err := client.DownloadAttachment(ctx, 3, "bytes=4-7")
+	assert.NoError(t, err)
+	data, _ := io.ReadAll(content.Body)
+	content.Body.Close()
+	assert.Equal(t, http.StatusPartialContent, content.StatusCode)
+	assert.Equal(t, "video/mp4", content.ContentType)
+	assert.Equal(t, "bytes 4-7/16", content.ContentRange)
+	assert.Equal(t, int64(4), content.ContentLength)
+	assert.Equal(t, "4567", string(data))
+
+	content, err = client.DownloadAttachment(ctx, 3, "")
+	assert.NoError(t, err)
+	data, _ = io.ReadAll(content.Body)
+	content.Body.Close()
+	assert.Equal(t, http.StatusOK, content.StatusCode)
+	assert.Equal(t, video, data)
+
+	_, err = client.DownloadAttachment(ctx, 3, "bytes=100-200")
+	assert.ErrorIs(t, err, adapter.ErrRangeNotSatisfiable)
+
+	_, err = client.DownloadAttachment(ctx, 4, "")
+	assert.ErrorIs(t, err, adapter.ErrNotFound)
+}
+
 func TestGeneratePDFReport_RealClient(t *testing.T) {
 	// Фейковый HTTP-сервер, который эмулирует Allure API
 	mockServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
@@ -208,7 +549,7 @@ func TestGeneratePDFReport_RealClient(t *testing.T) {
 	client := adapter.NewAllureClient(cfg)
 
 	// Запрашиваем генерацию PDF через реальный `AllureClient`
-	report, err := client.GeneratePDFReport(context.Background(), 123, "Test Run")
+	report, err := client.GeneratePDFReport(context.Background(), 123, "Test Run", adapter.DefaultPDFExportOptions())
 
 	// Проверяем, что нет ошибок
 	assert.NoError(t, err)
@@ -217,6 +558,50 @@ func TestGeneratePDFReport_RealClient(t *testing.T) {
 	assert.Equal(t, "Test Run", report.Name)
 }
 
+// ✅ **Тест: параметры экспорта передаются в тело запроса Allure**
+func TestGeneratePDFReport_ExportOptions(t *testing.T) {
+	var requestBody map[string]interface{}
+	mockServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method == http.MethodPost && r.URL.Path == "/api/uaa/oauth/token" {
+			w.Header().Set("Content-Type", "application/json")
+			_, _ = w.Write([]byte(`{"access_token": "mocked_token", "expires_in": 3600}`))
+			return
+		}
+
+		if r.Method == http.MethodPost && r.URL.Path == "/api/export/launch/pdf" {
+			_ = json.NewDecoder(r.Body).Decode(&requestBody)
+			w.Header().Set("Content-Type", "application/json")
+			_, _ = w.Write([]byte(`{"id": 456, "status": "IN_PROGRESS"}`))
+			return
+		}
+
+		w.WriteHeader(http.StatusNotFound)
+	}))
+	defer mockServer.Close()
+
+	client := adapter.NewAllureClient(&config.Config{
+		AllureBaseURL:   mockServer.URL,
+		AllureAPIURL:    "/api/",
+		AllureUserToken: "fake-token",
+	})
+
+	opts := adapter.DefaultPDFExportOptions()
+	opts.WithPageNumbers = false
+	opts.Sections.Steps = false
+	opts.Statuses = []string{"failed"}
+	opts.Locale = "en"
+
+	_, err := client.GeneratePDFReport(context.Background(), 123, "Nightly", opts)
+
+	assert.NoError(t, err)
+	assert.Equal(t, false, requestBody["withPageNumbers"])
+	assert.Equal(t, true, requestBody["withTitlePage"])
+	assert.Equal(t, true, requestBody["withOverview"])
+	assert.Equal(t, false, requestBody["withSteps"])
+	assert.Equal(t, []interface{}{"failed"}, requestBody["statuses"])
+	assert.Equal(t, "en", requestBody["locale"])
+}
+
 func TestGetPDFDownloadLink_RealClient(t *testing.T) {
 	// Фейковый HTTP-сервер, который эмулирует Allure API
 	mockServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
@@ -280,9 +665,595 @@ func TestDownloadPDFReport_RealClient(t *testing.T) {
 	}
 	client := adapter.NewAllureClient(cfg)
 
-	data, filename, err := client.DownloadPDFReport(context.Background(), "456")
+	content, err := client.DownloadPDFReport(context.Background(), "456")
 
 	assert.NoError(t, err)
-	assert.Equal(t, "allure-report-456.pdf", filename) // ✅ Исправлено имя файла
+	defer content.Body.Close()
+	assert.Equal(t, "allure-report-456.pdf", content.FileName) // ✅ Исправлено имя файла
+	data, _ := io.ReadAll(content.Body)
 	assert.Equal(t, "PDF content", string(data))
 }
+
+// ✅ Тест: поток PDF-отчета с размером и именем файла из Content-Disposition
+func TestDownloadPDFReport_StreamMetadata(t *testing.T) {
+	pdf := strings.Repeat("%PDF-1.4 ", 1024)
+	mockServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/api/uaa/oauth/token":
+			_, _ = w.Write([]byte(`{"access_token": "mocked_token", "expires_in": 3600}`))
+		case "/api/export/download/456":
+			w.Header().Set("Content-Type", "application/pdf")
+			w.Header().Set("Content-Disposition", `attachment; filename*=UTF-8''%D0%BE%D1%82%D1%87%D0%B5%D1%82.pdf`)
+			w.Header().Set("Content-Length", strconv.Itoa(len(pdf)))
+			_, _ = w.Write([]byte(pdf))
+		case "/api/export/download/457":
+			w.Header().Set("Content-Disposition", `attachment; filename="../../etc/passwd"`)
+			_, _ = w.Write([]byte("PDF"))
+		default:
+			w.WriteHeader(http.StatusNotFound)
+		}
+	}))
+	defer mockServer.Close()
+
+	client := adapter.NewAllureClient(&config.Config{AllureBaseURL: mockServer.URL, AllureAPIURL: "/api/"})
+
+	content, err := client.DownloadPDFReport(context.Background(), "456")
+	assert.NoError(t, err)
+	defer content.Body.Close()
+	assert.Equal(t, "отчет.pdf", content.FileName)
+	assert.Equal(t, "application/pdf", content.ContentType)
+	assert.Equal(t, int64(len(pdf)), content.ContentLength)
+	data, _ := io.ReadAll(content.Body)
+	assert.Equal(t, pdf, string(data))
+
+	content, err = client.DownloadPDFReport(context.Background(), "457")
+	assert.NoError(t, err)
+	content.Body.Close()
+	assert.Equal(t, "passwd", content.FileName)
+
+	_, err = client.DownloadPDFReport(context.Background(), "458")
+	assert.ErrorIs(t, err, adapter.ErrNotFound)
+}
+
+// ✅ **Тест: проекты собираются со всех страниц, а запуски ищутся в переданном проекте**
+func TestGetProjects_AllPages(t *testing.T) {
+	var launchProject string
+	mockServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method == http.MethodPost && r.URL.Path == "/api/uaa/oauth/token" {
+			w.Header().Set("Content-Type", "application/json")
+			_, _ = w.Write([]byte(`{"access_token": "mocked_token", "expires_in": 3600}`))
+			return
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		switch r.URL.Path {
+		case "/api/project":
+			number, _ := strconv.Atoi(r.URL.Query().Get("page"))
+			json.NewEncoder(w).Encode(adapter.ProjectPage{
+				Content:    []adapter.Project{{ID: int64(number + 1), Name: fmt.Sprintf("Project %d", number+1)}},
+				TotalPages: 2,
+				Number:     number,
+				Last:       number == 1,
+			})
+		case "/api/launch":
+			launchProject = r.URL.Query().Get("projectId")
+			_, _ = w.Write([]byte(`{"content": [], "last": true}`))
+		default:
+			w.WriteHeader(http.StatusNotFound)
+		}
+	}))
+	defer mockServer.Close()
+
+	client := adapter.NewAllureClient(&config.Config{
+		AllureBaseURL:   mockServer.URL,
+		AllureAPIURL:    "/api/",
+		AllureUserToken: "fake-token",
+		AllureProjectID: "1661",
+	})
+
+	projects, err := client.GetProjects(context.Background())
+	assert.NoError(t, err)
+	assert.Equal(t, []adapter.Project{{ID: 1, Name: "Project 1"}, {ID: 2, Name: "Project 2"}}, projects)
+
+	_, err = client.SearchLaunches(context.Background(), adapter.LaunchQuery{ProjectID: 42})
+	assert.NoError(t, err)
+	assert.Equal(t, "42", launchProject)
+
+	_, err = client.SearchLaunches(context.Background(), adapter.LaunchQuery{})
+	assert.NoError(t, err)
+	assert.Equal(t, "1661", launchProject)
+}
+
+// ✅ **Тест: срок жизни токена и запас обновления берутся из конфигурации**
+func TestAuthenticate_TokenLifetime(t *testing.T) {
+	var tokenRequests atomic.Int32
+	mockServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		tokenRequests.Add(1)
+		// Allure не сообщает срок жизни токена
+		_, _ = w.Write([]byte(`{"access_token": "mocked_token"}`))
+	}))
+	defer mockServer.Close()
+
+	client := adapter.NewAllureClient(&config.Config{
+		AllureBaseURL:      mockServer.URL,
+		TokenExpiry:        time.Hour,
+		TokenRefreshMargin: 5 * time.Minute,
+	})
+	assert.NoError(t, client.Authenticate(context.Background()))
+	assert.NoError(t, client.Authenticate(context.Background()))
+	assert.Equal(t, int32(1), tokenRequests.Load())
+
+	// Запас больше срока жизни - каждое обращение обновляет токен в фоне, не дожидаясь обновления
+	client = adapter.NewAllureClient(&config.Config{
+		AllureBaseURL:      mockServer.URL,
+		TokenExpiry:        time.Hour,
+		TokenRefreshMargin: 2 * time.Hour,
+	})
+	assert.NoError(t, client.Authenticate(context.Background()))
+	assert.NoError(t, client.Authenticate(context.Background()))
+	assert.Eventually(t, func() bool { return tokenRequests.Load() == 3 }, time.Second, 10*time.Millisecond)
+}
+
+// ✅ **Тест: одновременные запросы ждут одного обновления токена**
+func TestTokenRefresh_SingleFlight(t *testing.T) {
+	var tokenRequests atomic.Int32
+	release := make(chan struct{})
+	mockServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/api/uaa/oauth/token" {
+			tokenRequests.Add(1)
+			<-release
+			_, _ = w.Write([]byte(`{"access_token": "mocked_token", "expires_in": 3600}`))
+			return
+		}
+		_, _ = w.Write([]byte(`{"id": 1, "name": "Run"}`))
+	}))
+	defer mockServer.Close()
+
+	client := adapter.NewAllureClient(&config.Config{AllureBaseURL: mockServer.URL, AllureAPIURL: "/api/"})
+
+	var wg sync.WaitGroup
+	errs := make(chan error, 10)
+	for i := 0; i < 10; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			_, err := client.GetLaunch(context.Background(), 1)
+			errs <- err
+		}()
+	}
+
+	// Запрос, который не дождался токена, не мешает остальным
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+	defer cancel()
+	_, err := client.GetLaunch(ctx, 1)
+	assert.ErrorIs(t, err, context.DeadlineExceeded)
+
+	close(release)
+	wg.Wait()
+	close(errs)
+	for err := range errs {
+		assert.NoError(t, err)
+	}
+	assert.Equal(t, int32(1), tokenRequests.Load())
+}
+
+// ✅ **Тест: токен, отклоненный Allure, обновляется один раз, и запрос повторяется**
+func TestTokenRefresh_Unauthorized(t *testing.T) {
+	var tokenRequests atomic.Int32
+	mockServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/api/uaa/oauth/token" {
+			_, _ = fmt.Fprintf(w, `{"access_token": "access-%d", "expires_in": 3600}`, tokenRequests.Add(1))
+			return
+		}
+		// Allure отозвал первый токен раньше срока
+		if r.Header.Get("Authorization") != "Bearer access-2" {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		_, _ = w.Write([]byte(`{"id": 1, "name": "Run"}`))
+	}))
+	defer mockServer.Close()
+
+	client := adapter.NewAllureClient(&config.Config{AllureBaseURL: mockServer.URL, AllureAPIURL: "/api/"})
+
+	launch, err := client.GetLaunch(context.Background(), 1)
+	assert.NoError(t, err)
+	assert.Equal(t, "Run", launch.Name)
+	assert.Equal(t, int32(2), tokenRequests.Load())
+
+	// С новым токеном остальные запросы идут без обновления
+	_, err = client.GeneratePDFReport(context.Background(), 1, "Run", adapter.PDFExportOptions{})
+	assert.NoError(t, err)
+	_, err = client.DownloadAttachment(context.Background(), 5, "")
+	assert.NoError(t, err)
+	assert.Equal(t, int32(2), tokenRequests.Load())
+}
+
+// ✅ **Тест: токен обновляется в фоне до истечения без входящих запросов**
+func TestTokenRefresh_Background(t *testing.T) {
+	var tokenRequests atomic.Int32
+	mockServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		tokenRequests.Add(1)
+		_, _ = w.Write([]byte(`{"access_token": "mocked_token", "expires_in": 1}`))
+	}))
+	defer mockServer.Close()
+
+	client := adapter.NewAllureClient(&config.Config{AllureBaseURL: mockServer.URL, TokenRefreshMargin: 900 * time.Millisecond})
+	assert.NoError(t, client.Authenticate(context.Background()))
+	assert.Eventually(t, func() bool { return tokenRequests.Load() >= 2 }, time.Second, 10*time.Millisecond)
+}
+
+// ✅ **Тест: смена учетных данных отменяет плановое обновление токена прежнего снимка**
+func TestTokenRefresh_BackgroundStoppedOnReload(t *testing.T) {
+	var mu sync.Mutex
+	exchanges := map[string]int{}
+	mockServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		exchanges[r.FormValue("token")]++
+		mu.Unlock()
+		_, _ = w.Write([]byte(`{"access_token": "mocked_token", "expires_in": 1}`))
+	}))
+	defer mockServer.Close()
+
+	cfg := &config.Config{AllureBaseURL: mockServer.URL, AllureUserToken: "old", TokenRefreshMargin: 800 * time.Millisecond}
+	client := adapter.NewAllureClient(cfg)
+	assert.NoError(t, client.Authenticate(context.Background()))
+
+	rotated := *cfg
+	rotated.AllureUserToken = "new"
+	client.Reload(&rotated)
+
+	// Обновление старого токена было запланировано через 200 мс; новый токен без запросов не нужен
+	time.Sleep(400 * time.Millisecond)
+	mu.Lock()
+	defer mu.Unlock()
+	assert.Equal(t, map[string]int{"old": 1}, exchanges)
+}
+
+// ✅ **Тест: скачивание ограничено таймаутом скачивания, а не таймаутом запроса**
+func TestDownloadPDFReport_DownloadTimeout(t *testing.T) {
+	mockServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/api/uaa/oauth/token":
+			_, _ = w.Write([]byte(`{"access_token": "mocked_token", "expires_in": 3600}`))
+		case "/api/export/download/456":
+			w.Header().Set("Content-Type", "application/pdf")
+			_, _ = w.Write([]byte("%PDF-1.4 "))
+			w.(http.Flusher).Flush()
+			// Большой отчет отдается дольше одного обычного запроса
+			time.Sleep(150 * time.Millisecond)
+			_, _ = w.Write([]byte("EOF"))
+		default:
+			w.WriteHeader(http.StatusNotFound)
+		}
+	}))
+	defer mockServer.Close()
+
+	client := adapter.NewAllureClient(&config.Config{
+		AllureBaseURL: mockServer.URL,
+		AllureAPIURL:  "/api/",
+		Timeouts:      config.Timeouts{Request: 50 * time.Millisecond, Download: 5 * time.Second},
+	})
+
+	content, err := client.DownloadPDFReport(context.Background(), "456")
+	assert.NoError(t, err)
+	defer content.Body.Close()
+	data, err := io.ReadAll(content.Body)
+	assert.NoError(t, err)
+	assert.Equal(t, "%PDF-1.4 EOF", string(data))
+}
+
+// ✅ **Тест: новый API-токен применяется без перезапуска, начатое скачивание не прерывается**
+func TestReload_SwapsCredentials(t *testing.T) {
+	var mu sync.Mutex
+	var authorizations []string
+	release := make(chan struct{})
+	mockServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/api/uaa/oauth/token":
+			_, _ = fmt.Fprintf(w, `{"access_token": "access-%s", "expires_in": 3600}`, r.FormValue("token"))
+		case "/api/export/123":
+			mu.Lock()
+			authorizations = append(authorizations, r.Header.Get("Authorization"))
+			mu.Unlock()
+			_, _ = w.Write([]byte(`{"id": 123, "status": "READY"}`))
+		case "/api/export/download/123":
+			_, _ = w.Write([]byte("%PDF-1.4 "))
+			w.(http.Flusher).Flush()
+			<-release
+			_, _ = w.Write([]byte("EOF"))
+		default:
+			w.WriteHeader(http.StatusNotFound)
+		}
+	}))
+	defer mockServer.Close()
+
+	cfg := &config.Config{AllureBaseURL: mockServer.URL, AllureAPIURL: "/api/", AllureUserToken: "old", TokenExpiry: time.Hour}
+	client := adapter.NewAllureClient(cfg)
+
+	_, err := client.GetPDFReport(context.Background(), "123")
+	assert.NoError(t, err)
+
+	content, err := client.DownloadPDFReport(context.Background(), "123")
+	assert.NoError(t, err)
+	defer content.Body.Close()
+
+	rotated := *cfg
+	rotated.AllureUserToken = "new"
+	client.Reload(&rotated)
+
+	_, err = client.GetPDFReport(context.Background(), "123")
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"Bearer access-old", "Bearer access-new"}, authorizations)
+
+	close(release)
+	data, err := io.ReadAll(content.Body)
+	assert.NoError(t, err)
+	assert.Equal(t, "%PDF-1.4 EOF", string(data))
+}
+
+// ✅ **Тест: ID запроса передается в Allure**
+func TestRequestID_ForwardedToAllure(t *testing.T) {
+	var requestIDs []string
+	mockServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requestIDs = append(requestIDs, r.Header.Get("X-Request-ID"))
+		switch r.URL.Path {
+		case "/api/uaa/oauth/token":
+			_, _ = w.Write([]byte(`{"access_token": "mocked_token", "expires_in": 3600}`))
+		default:
+			_, _ = w.Write([]byte(`{"content": [], "last": true}`))
+		}
+	}))
+	defer mockServer.Close()
+
+	client := adapter.NewAllureClient(&config.Config{AllureBaseURL: mockServer.URL, AllureAPIURL: "/api/", AllureProjectID: "1661"})
+
+	_, err := client.SearchLaunches(reqctx.WithRequestID(context.Background(), "req-42"), adapter.LaunchQuery{})
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"req-42", "req-42"}, requestIDs)
+}
+
+// ✅ **Тест: временные ошибки Allure повторяются, неидемпотентный запрос - только после 429**
+func TestRetry_TransientErrors(t *testing.T) {
+	var mu sync.Mutex
+	calls := map[string]int{}
+	mockServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		calls[r.URL.Path]++
+		call := calls[r.URL.Path]
+		mu.Unlock()
+
+		switch r.URL.Path {
+		case "/api/uaa/oauth/token":
+			_, _ = w.Write([]byte(`{"access_token": "mocked_token", "expires_in": 3600}`))
+		case "/api/launch/1":
+			// Две временные ошибки, затем ответ
+			if call <= 2 {
+				w.WriteHeader(http.StatusBadGateway)
+				return
+			}
+			_, _ = w.Write([]byte(`{"id": 1, "name": "Run"}`))
+		case "/api/export/launch/pdf":
+			// Первый раз Allure просит подождать, второй - падает
+			if call == 1 {
+				w.Header().Set("Retry-After", "0")
+				w.WriteHeader(http.StatusTooManyRequests)
+				return
+			}
+			w.WriteHeader(http.StatusBadGateway)
+		case "/api/export/download/7":
+			w.WriteHeader(http.StatusServiceUnavailable)
+		default:
+			w.WriteHeader(http.StatusNotFound)
+		}
+	}))
+	defer mockServer.Close()
+
+	client := adapter.NewAllureClient(&config.Config{
+		AllureBaseURL: mockServer.URL,
+		AllureAPIURL:  "/api/",
+		Retry:         config.RetryPolicy{MaxAttempts: 3, InitialBackoff: time.Millisecond, MaxBackoff: 10 * time.Millisecond},
+	})
+
+	launch, err := client.GetLaunch(context.Background(), 1)
+	assert.NoError(t, err)
+	assert.Equal(t, "Run", launch.Name)
+	assert.Equal(t, 3, calls["/api/launch/1"])
+
+	// Генерация после 5xx не повторяется: Allure мог уже начать ее
+	_, err = client.GeneratePDFReport(context.Background(), 1, "Run", adapter.PDFExportOptions{})
+	assert.Error(t, err)
+	assert.Equal(t, 2, calls["/api/export/launch/pdf"])
+
+	// Попытки ограничены политикой повторов
+	_, err = client.DownloadPDFReport(context.Background(), "7")
+	assert.Error(t, err)
+	assert.Equal(t, 3, calls["/api/export/download/7"])
+}
+
+// ✅ **Тест: Retry-After дольше предельной паузы не ждется, отмена контекста прерывает паузу**
+func TestRetry_RetryAfterAndCancel(t *testing.T) {
+	var mu sync.Mutex
+	var launchCalls int
+	retryAfter := "60"
+	mockServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/api/uaa/oauth/token" {
+			_, _ = w.Write([]byte(`{"access_token": "mocked_token", "expires_in": 3600}`))
+			return
+		}
+		mu.Lock()
+		launchCalls++
+		w.Header().Set("Retry-After", retryAfter)
+		mu.Unlock()
+		w.WriteHeader(http.StatusTooManyRequests)
+	}))
+	defer mockServer.Close()
+
+	client := adapter.NewAllureClient(&config.Config{
+		AllureBaseURL: mockServer.URL,
+		AllureAPIURL:  "/api/",
+		Retry:         config.RetryPolicy{MaxAttempts: 3, InitialBackoff: time.Millisecond, MaxBackoff: time.Second},
+	})
+
+	_, err := client.GetLaunch(context.Background(), 1)
+	assert.Error(t, err)
+	assert.Equal(t, 1, launchCalls)
+
+	// Пауза в пределах политики прерывается отменой запроса
+	mu.Lock()
+	retryAfter = "1"
+	mu.Unlock()
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	started := time.Now()
+	_, err = client.GetLaunch(ctx, 1)
+	assert.ErrorIs(t, err, context.DeadlineExceeded)
+	assert.Less(t, time.Since(started), 500*time.Millisecond)
+	assert.Equal(t, 2, launchCalls)
+}
+
+// ✅ **Тест: автомат защиты размыкается при недоступном Allure и замыкается после восстановления**
+func TestCircuitBreaker_OpensAndRecovers(t *testing.T) {
+	var mu sync.Mutex
+	var launchCalls int
+	healthy := false
+	mockServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/api/uaa/oauth/token" {
+			_, _ = w.Write([]byte(`{"access_token": "mocked_token", "expires_in": 3600}`))
+			return
+		}
+		mu.Lock()
+		defer mu.Unlock()
+		launchCalls++
+		if !healthy {
+			w.WriteHeader(http.StatusServiceUnavailable)
+			return
+		}
+		_, _ = w.Write([]byte(`{"id": 1, "name": "Run"}`))
+	}))
+	defer mockServer.Close()
+
+	client := adapter.NewAllureClient(&config.Config{
+		AllureBaseURL:  mockServer.URL,
+		AllureAPIURL:   "/api/",
+		CircuitBreaker: config.CircuitBreaker{FailureThreshold: 2, OpenTimeout: 100 * time.Millisecond},
+	})
+	assert.Equal(t, adapter.CircuitClosed, client.Circuit().State)
+
+	for i := 0; i < 2; i++ {
+		_, err := client.GetLaunch(context.Background(), 1)
+		assert.Error(t, err)
+	}
+	state := client.Circuit()
+	assert.Equal(t, adapter.CircuitOpen, state.State)
+	assert.Equal(t, 2, state.Failures)
+	assert.NotNil(t, state.RetryAt)
+
+	// Разомкнутый автомат не пропускает запросы в Allure
+	_, err := client.GetLaunch(context.Background(), 1)
+	assert.ErrorIs(t, err, adapter.ErrCircuitOpen)
+	assert.Equal(t, 2, launchCalls)
+
+	// После паузы пробный запрос к восстановленному Allure замыкает автомат
+	mu.Lock()
+	healthy = true
+	mu.Unlock()
+	time.Sleep(150 * time.Millisecond)
+	_, err = client.GetLaunch(context.Background(), 1)
+	assert.NoError(t, err)
+	assert.Equal(t, adapter.CircuitClosed, client.Circuit().State)
+	assert.Equal(t, 0, client.Circuit().Failures)
+}
+
+// ✅ **Тест: нулевой порог отключает автомат защиты**
+func TestCircuitBreaker_Disabled(t *testing.T) {
+	var launchCalls atomic.Int32
+	mockServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/api/uaa/oauth/token" {
+			_, _ = w.Write([]byte(`{"access_token": "mocked_token", "expires_in": 3600}`))
+			return
+		}
+		launchCalls.Add(1)
+		w.WriteHeader(http.StatusServiceUnavailable)
+	}))
+	defer mockServer.Close()
+
+	client := adapter.NewAllureClient(&config.Config{
+		AllureBaseURL:  mockServer.URL,
+		AllureAPIURL:   "/api/",
+		CircuitBreaker: config.CircuitBreaker{FailureThreshold: 0, OpenTimeout: time.Minute},
+	})
+
+	for i := 0; i < 5; i++ {
+		_, err := client.GetLaunch(context.Background(), 1)
+		assert.Error(t, err)
+		assert.NotErrorIs(t, err, adapter.ErrCircuitOpen)
+	}
+	assert.Equal(t, int32(5), launchCalls.Load())
+	assert.Equal(t, adapter.CircuitClosed, client.Circuit().State)
+}
+
+// ✅ **Тест: запросы вызывающего идут с его токеном доступа, токены кэшируются по вызывающим**
+func TestTokenPassthrough_PerCaller(t *testing.T) {
+	var mu sync.Mutex
+	exchanges := map[string]int{}
+	var authorizations []string
+	mockServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/api/uaa/oauth/token" {
+			apiToken := r.FormValue("token")
+			if apiToken == "revoked" {
+				w.WriteHeader(http.StatusUnauthorized)
+				return
+			}
+			mu.Lock()
+			exchanges[apiToken]++
+			mu.Unlock()
+			_, _ = fmt.Fprintf(w, `{"access_token": "access-%s", "expires_in": 3600}`, apiToken)
+			return
+		}
+
+		mu.Lock()
+		authorizations = append(authorizations, r.Header.Get("Authorization"))
+		mu.Unlock()
+		// У bob нет прав на запуск в Allure
+		if r.Header.Get("Authorization") == "Bearer access-bob" {
+			w.WriteHeader(http.StatusForbidden)
+			return
+		}
+		_, _ = w.Write([]byte(`{"id": 1, "name": "Run"}`))
+	}))
+	defer mockServer.Close()
+
+	client := adapter.NewAllureClient(&config.Config{
+		AllureBaseURL:    mockServer.URL,
+		AllureAPIURL:     "/api/",
+		AllureUserToken:  "service",
+		TokenPassthrough: config.TokenPassthrough{Mode: config.TokenPassthroughOptional, CacheSize: 10},
+	})
+	alice := reqctx.WithAllureToken(context.Background(), "alice")
+	bob := reqctx.WithAllureToken(context.Background(), "bob")
+
+	for i := 0; i < 3; i++ {
+		_, err := client.GetLaunch(alice, 1)
+		assert.NoError(t, err)
+	}
+	_, err := client.GetLaunch(context.Background(), 1)
+	assert.NoError(t, err)
+
+	// Права проверяет Allure: bob получает 403 от Allure, а не ответ от имени сервиса
+	_, err = client.GetLaunch(bob, 1)
+	assert.ErrorIs(t, err, adapter.ErrForbidden)
+
+	// Отозванный API-токен вызывающего
+	_, err = client.GetLaunch(reqctx.WithAllureToken(context.Background(), "revoked"), 1)
+	assert.ErrorIs(t, err, adapter.ErrUserTokenRejected)
+
+	mu.Lock()
+	defer mu.Unlock()
+	assert.Equal(t, map[string]int{"alice": 1, "service": 1, "bob": 1}, exchanges)
+	assert.Equal(t, []string{
+		"Bearer access-alice", "Bearer access-alice", "Bearer access-alice",
+		"Bearer access-service",
+		"Bearer access-bob",
+	}, authorizations)
+}
@@ -0,0 +1,234 @@
+package test
+
+import (
+	"context"
+	"encoding/xml"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"sort"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/vkr-mtuci/allure-service/internal/archive"
+)
+
+// fakeS3 - минимальная замена MinIO: хранит объекты одного бакета в памяти
+// и поддерживает PUT, GET, DELETE и ListObjectsV2 с постраничной выдачей
+type fakeS3 struct {
+	bucket   string
+	pageSize int
+	mu       sync.Mutex
+	objects  map[string][]byte
+	modified map[string]time.Time
+}
+
+// newFakeS3 - запускает замену S3 на httptest-сервере
+func newFakeS3(t *testing.T, bucket string) (*fakeS3, *httptest.Server) {
+	fake := &fakeS3{
+		bucket:   bucket,
+		pageSize: 2,
+		objects:  make(map[string][]byte),
+		modified: make(map[string]time.Time),
+	}
+	server := httptest.NewServer(fake)
+	t.Cleanup(server.Close)
+	return fake, server
+}
+
+func (f *fakeS3) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	auth := r.Header.Get("Authorization")
+	if !strings.HasPrefix(auth, "AWS4-HMAC-SHA256 Credential=minio/") || !strings.Contains(auth, "Signature=") ||
+		r.Header.Get("X-Amz-Date") == "" || r.Header.Get("X-Amz-Content-Sha256") == "" {
+		w.WriteHeader(http.StatusForbidden)
+		return
+	}
+
+	bucket, key, _ := strings.Cut(strings.TrimPrefix(r.URL.Path, "/"), "/")
+	if bucket != f.bucket {
+		w.WriteHeader(http.StatusNotFound)
+		return
+	}
+
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	switch {
+	case r.Method == http.MethodGet && key == "":
+		f.list(w, r)
+	case r.Method == http.MethodPut:
+		data, _ := io.ReadAll(r.Body)
+		if int64(len(data)) != r.ContentLength {
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+		f.objects[key] = data
+		f.modified[key] = time.Now().UTC()
+	case r.Method == http.MethodGet:
+		data, ok := f.objects[key]
+		if !ok {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+		w.Write(data)
+	case r.Method == http.MethodDelete:
+		delete(f.objects, key)
+		w.WriteHeader(http.StatusNoContent)
+	default:
+		w.WriteHeader(http.StatusMethodNotAllowed)
+	}
+}
+
+// list - ответ ListObjectsV2; токен продолжения - последний отданный ключ
+func (f *fakeS3) list(w http.ResponseWriter, r *http.Request) {
+	prefix := r.URL.Query().Get("prefix")
+	after := r.URL.Query().Get("continuation-token")
+
+	var keys []string
+	for key := range f.objects {
+		if strings.HasPrefix(key, prefix) && key > after {
+			keys = append(keys, key)
+		}
+	}
+	sort.Strings(keys)
+
+	type content struct {
+		Key          string
+		Size         int
+		LastModified string
+	}
+	result := struct {
+		XMLName               xml.Name `xml:"ListBucketResult"`
+		Contents              []content
+		IsTruncated           bool
+		NextContinuationToken string `xml:",omitempty"`
+	}{}
+	if len(keys) > f.pageSize {
+		keys = keys[:f.pageSize]
+		result.IsTruncated = true
+		result.NextContinuationToken = keys[len(keys)-1]
+	}
+	for _, key := range keys {
+		result.Contents = append(result.Contents, content{
+			Key:          key,
+			Size:         len(f.objects[key]),
+			LastModified: f.modified[key].Format(time.RFC3339),
+		})
+	}
+
+	w.Header().Set("Content-Type", "application/xml")
+	xml.NewEncoder(w).Encode(result)
+}
+
+// testReportArchive - общий сценарий для всех реализаций архива
+func testReportArchive(t *testing.T, reportArchive archive.ReportArchive) {
+	ctx := context.Background()
+
+	stored, err := reportArchive.Put(ctx, archive.Report{LaunchID: 123, ReportID: "456", FileName: "allure-report-456.pdf"}, strings.NewReader("PDF 456"))
+	assert.NoError(t, err)
+	assert.Equal(t, int64(7), stored.Size)
+	assert.Equal(t, "allure-report-456.pdf", stored.FileName)
+
+	for _, reportID := range []string{"457", "458"} {
+		_, err = reportArchive.Put(ctx, archive.Report{LaunchID: 123, ReportID: reportID, FileName: reportID + ".pdf"}, strings.NewReader("PDF "+reportID))
+		assert.NoError(t, err)
+	}
+	_, err = reportArchive.Put(ctx, archive.Report{LaunchID: 124, ReportID: "500", FileName: "500.pdf"}, strings.NewReader("PDF 500"))
+	assert.NoError(t, err)
+
+	// Повторное сохранение под другим именем заменяет отчет
+	_, err = reportArchive.Put(ctx, archive.Report{LaunchID: 123, ReportID: "458", FileName: "renamed.pdf"}, strings.NewReader("PDF 458 v2"))
+	assert.NoError(t, err)
+
+	reports, err := reportArchive.List(ctx, 123)
+	assert.NoError(t, err)
+	var reportIDs []string
+	for _, report := range reports {
+		assert.Equal(t, int64(123), report.LaunchID)
+		reportIDs = append(reportIDs, report.ReportID)
+	}
+	assert.ElementsMatch(t, []string{"456", "457", "458"}, reportIDs)
+
+	all, err := reportArchive.List(ctx, 0)
+	assert.NoError(t, err)
+	assert.Len(t, all, 4)
+
+	report, body, err := reportArchive.Open(ctx, 123, "458")
+	if assert.NoError(t, err) {
+		data, _ := io.ReadAll(body)
+		body.Close()
+		assert.Equal(t, "PDF 458 v2", string(data))
+		assert.Equal(t, "renamed.pdf", report.FileName)
+	}
+
+	_, _, err = reportArchive.Open(ctx, 124, "456")
+	assert.ErrorIs(t, err, archive.ErrNotFound)
+
+	_, _, err = reportArchive.Open(ctx, 123, "../456")
+	assert.ErrorIs(t, err, archive.ErrInvalidReport)
+
+	// Прерванный поток не попадает в архив
+	_, err = reportArchive.Put(ctx, archive.Report{LaunchID: 125, ReportID: "600", FileName: "600.pdf"}, io.MultiReader(strings.NewReader("PDF"), failingReader{}))
+	assert.Error(t, err)
+	reports, err = reportArchive.List(ctx, 125)
+	assert.NoError(t, err)
+	assert.Empty(t, reports)
+}
+
+// failingReader - поток, обрывающийся с ошибкой
+type failingReader struct{}
+
+func (failingReader) Read([]byte) (int, error) {
+	return 0, errors.New("соединение разорвано")
+}
+
+// ✅ **Тест: Архив в локальном каталоге**
+func TestReportArchive_Local(t *testing.T) {
+	reportArchive, err := archive.NewLocal(t.TempDir())
+	assert.NoError(t, err)
+
+	testReportArchive(t, reportArchive)
+}
+
+// ✅ **Тест: Архив в S3-совместимом хранилище**
+func TestReportArchive_S3(t *testing.T) {
+	fake, server := newFakeS3(t, "reports")
+
+	reportArchive, err := archive.NewS3(archive.S3Config{
+		Endpoint:  server.URL,
+		Bucket:    "reports",
+		Prefix:    "allure",
+		AccessKey: "minio",
+		SecretKey: "minio-secret",
+	})
+	assert.NoError(t, err)
+
+	testReportArchive(t, reportArchive)
+
+	// Ключи лежат под префиксом архива
+	fake.mu.Lock()
+	_, ok := fake.objects["allure/123/456/allure-report-456.pdf"]
+	fake.mu.Unlock()
+	assert.True(t, ok)
+}
+
+// ❌ **Тест: Неверные ключи доступа к S3**
+func TestReportArchive_S3Forbidden(t *testing.T) {
+	_, server := newFakeS3(t, "reports")
+
+	reportArchive, err := archive.NewS3(archive.S3Config{
+		Endpoint:  server.URL,
+		Bucket:    "reports",
+		AccessKey: "other",
+		SecretKey: "secret",
+	})
+	assert.NoError(t, err)
+
+	_, err = reportArchive.List(context.Background(), 123)
+	assert.ErrorContains(t, err, "статус 403")
+}
@@ -0,0 +1,381 @@
+package test
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/rsa"
+	"encoding/base64"
+	"encoding/json"
+	"io"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+	"github.com/vkr-mtuci/allure-service/config"
+	"github.com/vkr-mtuci/allure-service/internal/adapter"
+	"github.com/vkr-mtuci/allure-service/internal/auth"
+	"github.com/vkr-mtuci/allure-service/internal/handler"
+	"github.com/vkr-mtuci/allure-service/internal/service"
+)
+
+// newAuthApp - приложение с аутентификацией и маршрутами проектов, как в cmd/main.go
+func newAuthApp(t *testing.T, cfg config.Auth, mockClient *MockAllureClient) *fiber.App {
+	authenticator, err := auth.NewAuthenticator(cfg)
+	require.NoError(t, err)
+
+	h := handler.NewAllureHandler(service.NewAllureService(mockClient, service.WithProjects(1661, []int64{42})))
+	app := fiber.New()
+	app.Use(handler.Authenticate(authenticator, "/health"))
+	app.Get("/health", func(c *fiber.Ctx) error { return c.SendString("ok") })
+	app.Get("/projects", handler.Require(config.OperationRead), h.GetProjects)
+	app.Get("/launches", handler.Require(config.OperationRead), h.GetLaunches)
+	app.Get("/projects/:projectId/launches", h.ProjectScope, handler.Require(config.OperationRead), h.GetLaunches)
+	app.Post("/export/csv/:id", handler.Require(config.OperationExport), h.ExportCSV)
+	return app
+}
+
+// authRequest - выполняет запрос с заголовком учетных данных и возвращает статус и тело ответа
+func authRequest(t *testing.T, app *fiber.App, method, target, header, value string) (int, string) {
+	req := httptest.NewRequest(method, target, nil)
+	if header != "" {
+		req.Header.Set(header, value)
+	}
+	resp, err := app.Test(req)
+	require.NoError(t, err)
+	body, _ := io.ReadAll(resp.Body)
+	return resp.StatusCode, string(body)
+}
+
+// ✅ **Тест: API-ключи ограничивают проекты и операции, ошибки - в формате {"error": ...}**
+func TestAuthenticate_APIKeys(t *testing.T) {
+	mockClient := new(MockAllureClient)
+	mockClient.On("GetProjects", mock.Anything).Return([]adapter.Project{{ID: 1661}, {ID: 42}}, nil)
+	mockClient.On("SearchLaunches", mock.Anything, mock.Anything).Return(&adapter.LaunchPage{}, nil)
+
+	app := newAuthApp(t, config.Auth{APIKeys: []config.APIKey{
+		{Name: "ci", Key: "ci-key-0123456789", Projects: []config.ProjectRef{config.AllowAll}, Operations: []string{config.AllowAll}},
+		{Name: "viewer", Key: "viewer-key-0123456789", Projects: []config.ProjectRef{"42"}, Operations: []string{config.OperationRead}},
+	}}, mockClient)
+
+	// Без учетных данных - 401, проверка работоспособности открыта
+	status, body := authRequest(t, app, http.MethodGet, "/projects", "", "")
+	assert.Equal(t, http.StatusUnauthorized, status)
+	assert.Contains(t, body, `"error":`)
+	status, _ = authRequest(t, app, http.MethodGet, "/projects", handler.APIKeyHeader, "wrong-key-0123456789")
+	assert.Equal(t, http.StatusUnauthorized, status)
+	status, _ = authRequest(t, app, http.MethodGet, "/health", "", "")
+	assert.Equal(t, http.StatusOK, status)
+
+	// Ключ со всеми правами, в том числе в Authorization: Bearer
+	status, body = authRequest(t, app, http.MethodGet, "/projects", handler.APIKeyHeader, "ci-key-0123456789")
+	assert.Equal(t, http.StatusOK, status)
+	assert.Contains(t, body, `"id":1661`)
+	status, _ = authRequest(t, app, http.MethodGet, "/projects/42/launches", "Authorization", "Bearer ci-key-0123456789")
+	assert.Equal(t, http.StatusOK, status)
+
+	// Ключ только на чтение проекта 42
+	status, body = authRequest(t, app, http.MethodGet, "/projects", handler.APIKeyHeader, "viewer-key-0123456789")
+	assert.Equal(t, http.StatusOK, status)
+	assert.NotContains(t, body, `"id":1661`)
+	status, _ = authRequest(t, app, http.MethodGet, "/projects/42/launches", handler.APIKeyHeader, "viewer-key-0123456789")
+	assert.Equal(t, http.StatusOK, status)
+
+	// Проект по умолчанию ключу не разрешен
+	status, body = authRequest(t, app, http.MethodGet, "/launches", handler.APIKeyHeader, "viewer-key-0123456789")
+	assert.Equal(t, http.StatusForbidden, status)
+	assert.Contains(t, body, `"error":"доступ запрещен`)
+
+	// Экспорт ключу не разрешен
+	status, body = authRequest(t, app, http.MethodPost, "/export/csv/5", handler.APIKeyHeader, "viewer-key-0123456789")
+	assert.Equal(t, http.StatusForbidden, status)
+	assert.Contains(t, body, "export")
+}
+
+// ❌ **Тест: API-ключ с пустыми списками прав не получает доступа ни к чему**
+func TestAuthenticate_APIKeyWithoutRights(t *testing.T) {
+	mockClient := new(MockAllureClient)
+	mockClient.On("GetProjects", mock.Anything).Return([]adapter.Project{{ID: 1661}}, nil)
+	mockClient.On("SearchLaunches", mock.Anything, mock.Anything).Return(&adapter.LaunchPage{}, nil)
+
+	app := newAuthApp(t, config.Auth{APIKeys: []config.APIKey{
+		{Name: "empty", Key: "empty-key-0123456789"},
+		{Name: "no-projects", Key: "no-projects-key-0123456789", Projects: []config.ProjectRef{}, Operations: []string{config.AllowAll}},
+		{Name: "no-operations", Key: "no-operations-key-0123456789", Projects: []config.ProjectRef{config.AllowAll}, Operations: []string{}},
+	}}, mockClient)
+
+	for _, key := range []string{"empty-key-0123456789", "no-projects-key-0123456789", "no-operations-key-0123456789"} {
+		status, _ := authRequest(t, app, http.MethodGet, "/launches", handler.APIKeyHeader, key)
+		assert.Equal(t, http.StatusForbidden, status, key)
+		status, _ = authRequest(t, app, http.MethodGet, "/projects/1661/launches", handler.APIKeyHeader, key)
+		assert.Equal(t, http.StatusForbidden, status, key)
+	}
+
+	// Без проектов список проектов пуст
+	status, body := authRequest(t, app, http.MethodGet, "/projects", handler.APIKeyHeader, "no-projects-key-0123456789")
+	assert.Equal(t, http.StatusOK, status)
+	assert.NotContains(t, body, `"id":1661`)
+	mockClient.AssertNotCalled(t, "SearchLaunches", mock.Anything, mock.Anything)
+}
+
+// ✅ **Тест: без учетных данных аутентификация не включается сама, открытый API - только явно**
+func TestAuthenticate_Disabled(t *testing.T) {
+	_, err := auth.NewAuthenticator(config.Auth{})
+	assert.Error(t, err)
+
+	mockClient := new(MockAllureClient)
+	mockClient.On("GetProjects", mock.Anything).Return([]adapter.Project{{ID: 1661}}, nil)
+	app := newAuthApp(t, config.Auth{Disabled: true}, mockClient)
+
+	status, body := authRequest(t, app, http.MethodGet, "/projects", "", "")
+	assert.Equal(t, http.StatusOK, status)
+	assert.Contains(t, body, `"id":1661`)
+}
+
+// ✅ **Тест: права на проекты выдаются для экземпляра Allure, ID без экземпляра - проект экземпляра по умолчанию**
+func TestAuthenticate_InstanceScopedProjects(t *testing.T) {
+	authenticator, err := auth.NewAuthenticator(config.Auth{APIKeys: []config.APIKey{
+		{Name: "prod-42", Key: "prod-42-key-0123456789", Projects: []config.ProjectRef{"prod:42"}, Operations: []string{config.AllowAll}},
+		{Name: "plain-42", Key: "plain-42-key-0123456789", Projects: []config.ProjectRef{"42"}, Operations: []string{config.AllowAll}},
+		{Name: "staging-all", Key: "staging-all-key-0123456789", Projects: []config.ProjectRef{"staging:*"}, Operations: []string{config.AllowAll}},
+	}})
+	require.NoError(t, err)
+
+	app := fiber.New()
+	app.Use(handler.Authenticate(authenticator))
+	for i, name := range []string{"prod", "staging"} {
+		mockClient := new(MockAllureClient)
+		mockClient.On("SearchLaunches", mock.Anything, mock.Anything).Return(&adapter.LaunchPage{}, nil)
+		allureService := service.NewAllureService(mockClient, service.WithProjects(42, []int64{42, 7}))
+		handler.RegisterInstanceRoutes(app, name, i == 0, handler.NewAllureHandler(allureService))
+	}
+
+	tests := []struct {
+		key    string
+		target string
+		status int
+	}{
+		{"prod-42-key-0123456789", "/instances/prod/projects/42/launches", http.StatusOK},
+		{"prod-42-key-0123456789", "/projects/42/launches", http.StatusOK},
+		{"prod-42-key-0123456789", "/instances/staging/projects/42/launches", http.StatusForbidden},
+		{"plain-42-key-0123456789", "/launches", http.StatusOK},
+		{"plain-42-key-0123456789", "/instances/prod/projects/42/launches", http.StatusOK},
+		{"plain-42-key-0123456789", "/instances/staging/projects/42/launches", http.StatusForbidden},
+		{"plain-42-key-0123456789", "/instances/staging/launches", http.StatusForbidden},
+		{"staging-all-key-0123456789", "/instances/staging/projects/7/launches", http.StatusOK},
+		{"staging-all-key-0123456789", "/instances/staging/launches", http.StatusOK},
+		{"staging-all-key-0123456789", "/projects/42/launches", http.StatusForbidden},
+	}
+	for _, tt := range tests {
+		status, _ := authRequest(t, app, http.MethodGet, tt.target, handler.APIKeyHeader, tt.key)
+		assert.Equal(t, tt.status, status, "%s %s", tt.key, tt.target)
+	}
+}
+
+// jwtKey - RSA-ключ подписи токенов и его JWKS
+type jwtKey struct {
+	kid     string
+	private *rsa.PrivateKey
+}
+
+func newJWTKey(t *testing.T, kid string) jwtKey {
+	private, err := rsa.GenerateKey(rand.Reader, 2048)
+	require.NoError(t, err)
+	return jwtKey{kid: kid, private: private}
+}
+
+// jwks - JWKS с открытыми ключами keys
+func jwks(keys ...jwtKey) []byte {
+	set := map[string][]map[string]string{"keys": {}}
+	for _, key := range keys {
+		set["keys"] = append(set["keys"], map[string]string{
+			"kty": "RSA",
+			"kid": key.kid,
+			"use": "sig",
+			"n":   base64.RawURLEncoding.EncodeToString(key.private.N.Bytes()),
+			"e":   base64.RawURLEncoding.EncodeToString(big.NewInt(int64(key.private.E)).Bytes()),
+		})
+	}
+	data, _ := json.Marshal(set)
+	return data
+}
+
+// sign - подписывает claims ключом key
+func (key jwtKey) sign(t *testing.T, claims jwt.MapClaims) string {
+	token := jwt.NewWithClaims(jwt.SigningMethodRS256, claims)
+	token.Header["kid"] = key.kid
+	signed, err := token.SignedString(key.private)
+	require.NoError(t, err)
+	return signed
+}
+
+// ✅ **Тест: JWT проверяется по JWKS из файла, права берутся из claims**
+func TestAuthenticate_JWTFile(t *testing.T) {
+	key := newJWTKey(t, "key-1")
+	path := filepath.Join(t.TempDir(), "jwks.json")
+	require.NoError(t, os.WriteFile(path, jwks(key), 0o600))
+
+	mockClient := new(MockAllureClient)
+	mockClient.On("SearchLaunches", mock.Anything, mock.Anything).Return(&adapter.LaunchPage{}, nil)
+
+	app := newAuthApp(t, config.Auth{JWT: config.JWTAuth{
+		JWKSFile:        path,
+		JWKSRefresh:     time.Minute,
+		Issuer:          "https://sso.example.com",
+		Audience:        "allure-service",
+		ProjectsClaim:   "allure_projects",
+		OperationsClaim: "allure_operations",
+	}}, mockClient)
+
+	claims := func(overrides jwt.MapClaims) jwt.MapClaims {
+		result := jwt.MapClaims{
+			"sub":               "alice",
+			"iss":               "https://sso.example.com",
+			"aud":               "allure-service",
+			"exp":               time.Now().Add(time.Hour).Unix(),
+			"allure_projects":   []interface{}{42},
+			"allure_operations": "read download",
+		}
+		for name, value := range overrides {
+			result[name] = value
+		}
+		return result
+	}
+	bearer := func(token string) string { return "Bearer " + token }
+
+	status, _ := authRequest(t, app, http.MethodGet, "/projects/42/launches", "Authorization", bearer(key.sign(t, claims(nil))))
+	assert.Equal(t, http.StatusOK, status)
+
+	// Проект и операция, не указанные в claims
+	status, _ = authRequest(t, app, http.MethodGet, "/launches", "Authorization", bearer(key.sign(t, claims(nil))))
+	assert.Equal(t, http.StatusForbidden, status)
+	status, _ = authRequest(t, app, http.MethodPost, "/export/csv/5", "Authorization", bearer(key.sign(t, claims(nil))))
+	assert.Equal(t, http.StatusForbidden, status)
+
+	// Проект другого экземпляра Allure с тем же ID
+	otherInstance := key.sign(t, claims(jwt.MapClaims{"allure_projects": []interface{}{"staging:42"}}))
+	status, _ = authRequest(t, app, http.MethodGet, "/projects/42/launches", "Authorization", bearer(otherInstance))
+	assert.Equal(t, http.StatusForbidden, status)
+
+	// Истекший токен, чужой издатель, чужой ключ и токен без claim прав
+	rejected := map[string]string{
+		"истекший":        key.sign(t, claims(jwt.MapClaims{"exp": time.Now().Add(-time.Hour).Unix()})),
+		"чужой iss":       key.sign(t, claims(jwt.MapClaims{"iss": "https://evil.example.com"})),
+		"чужой aud":       key.sign(t, claims(jwt.MapClaims{"aud": "other-service"})),
+		"чужой ключ":      newJWTKey(t, "key-1").sign(t, claims(nil)),
+		"без проектов":    key.sign(t, claims(jwt.MapClaims{"allure_projects": nil})),
+		"неизвестный kid": newJWTKey(t, "key-2").sign(t, claims(nil)),
+	}
+	for name, token := range rejected {
+		status, body := authRequest(t, app, http.MethodGet, "/projects/42/launches", "Authorization", bearer(token))
+		assert.Equal(t, http.StatusUnauthorized, status, name)
+		assert.Contains(t, body, `"error":`, name)
+	}
+}
+
+// ✅ **Тест: ключи JWKS по URL перезагружаются при появлении нового kid**
+func TestAuthenticate_JWKSURL(t *testing.T) {
+	oldKey, newKey := newJWTKey(t, "old"), newJWTKey(t, "new")
+	var published atomic.Value
+	published.Store(jwks(oldKey))
+	var fetches int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(&fetches, 1)
+		w.Write(published.Load().([]byte))
+	}))
+	defer server.Close()
+
+	authenticator, err := auth.NewAuthenticator(config.Auth{JWT: config.JWTAuth{
+		JWKSURL:         server.URL,
+		JWKSRefresh:     time.Hour,
+		ProjectsClaim:   "allure_projects",
+		OperationsClaim: "allure_operations",
+	}})
+	require.NoError(t, err)
+	assert.Equal(t, int32(1), atomic.LoadInt32(&fetches))
+
+	claims := jwt.MapClaims{"sub": "ci", "exp": time.Now().Add(time.Hour).Unix(), "allure_projects": "*", "allure_operations": "*"}
+
+	caller, err := authenticator.Authenticate(context.Background(), "", oldKey.sign(t, claims))
+	require.NoError(t, err)
+	assert.Equal(t, "ci", caller.Name)
+	assert.True(t, caller.AllProjects(auth.Instance{Name: "staging"}))
+	assert.True(t, caller.Can(config.OperationExport))
+	assert.Equal(t, int32(1), atomic.LoadInt32(&fetches), "ключи берутся из кэша")
+
+	// Ключи заменили: новый kid загружается повторным запросом
+	published.Store(jwks(newKey))
+	_, err = authenticator.Authenticate(context.Background(), "", newKey.sign(t, claims))
+	assert.NoError(t, err)
+	assert.Equal(t, int32(2), atomic.LoadInt32(&fetches))
+
+	// Неизвестный kid не перезагружает ключи на каждый запрос
+	_, err = authenticator.Authenticate(context.Background(), "", newJWTKey(t, "other").sign(t, claims))
+	assert.ErrorIs(t, err, auth.ErrUnauthenticated)
+	assert.Equal(t, int32(2), atomic.LoadInt32(&fetches))
+}
+
+// ✅ **Тест: медленная загрузка JWKS не задерживает токены с уже загруженными ключами**
+func TestAuthenticate_JWKSSlowReload(t *testing.T) {
+	key := newJWTKey(t, "known")
+	release := make(chan struct{})
+	var fetches int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		// Первая загрузка - при создании, следующие ждут release
+		if atomic.AddInt32(&fetches, 1) > 1 {
+			<-release
+		}
+		w.Write(jwks(key))
+	}))
+	defer server.Close()
+	defer close(release)
+
+	authenticator, err := auth.NewAuthenticator(config.Auth{JWT: config.JWTAuth{
+		JWKSURL:         server.URL,
+		JWKSRefresh:     time.Hour,
+		ProjectsClaim:   "allure_projects",
+		OperationsClaim: "allure_operations",
+	}})
+	require.NoError(t, err)
+
+	claims := jwt.MapClaims{"sub": "ci", "exp": time.Now().Add(time.Hour).Unix(), "allure_projects": "*", "allure_operations": "*"}
+
+	// Неизвестный kid запускает перезагрузку, которая повисает на сервере
+	unknown := newJWTKey(t, "unknown").sign(t, claims)
+	var wg sync.WaitGroup
+	for i := 0; i < 3; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+			defer cancel()
+			authenticator.Authenticate(ctx, "", unknown)
+		}()
+	}
+	require.Eventually(t, func() bool { return atomic.LoadInt32(&fetches) == 2 }, time.Second, 10*time.Millisecond)
+
+	done := make(chan error, 1)
+	go func() {
+		_, err := authenticator.Authenticate(context.Background(), "", key.sign(t, claims))
+		done <- err
+	}()
+	select {
+	case err := <-done:
+		assert.NoError(t, err)
+	case <-time.After(500 * time.Millisecond):
+		t.Fatal("проверка токена ждет загрузки JWKS")
+	}
+
+	wg.Wait()
+	assert.Equal(t, int32(2), atomic.LoadInt32(&fetches), "неизвестный kid перезагружает ключи один раз")
+}
@@ -1,8 +1,13 @@
 package test
 
 import (
+	"bytes"
+	"context"
 	"os"
+	"path/filepath"
+	"syscall"
 	"testing"
+	"time"
 
 	"github.com/stretchr/testify/assert"
 	"github.com/vkr-mtuci/allure-service/config"
@@ -15,8 +20,10 @@ func TestLoadConfig(t *testing.T) {
 	os.Setenv("ALLURE_API_URL", "/api")
 	os.Setenv("ALLURE_API_TOKEN", "test-token")
 	os.Setenv("ALLURE_PROJECT_ID", "1661")
+	t.Setenv("AUTH_DISABLED", "true")
 
-	cfg := config.LoadConfig()
+	cfg, err := config.LoadConfig("")
+	assert.NoError(t, err)
 
 	assert.Equal(t, "8080", cfg.ServerPort)
 	assert.Equal(t, "https://allure.example.com", cfg.AllureBaseURL)
@@ -24,3 +31,449 @@ func TestLoadConfig(t *testing.T) {
 	assert.Equal(t, "test-token", cfg.AllureUserToken)
 	assert.Equal(t, "1661", cfg.AllureProjectID)
 }
+
+// Config Test: список разрешенных проектов
+func TestLoadConfig_Projects(t *testing.T) {
+	t.Setenv("ALLURE_BASE_URL", "https://allure.example.com")
+	t.Setenv("ALLURE_API_URL", "/api")
+	t.Setenv("ALLURE_API_TOKEN", "test-token")
+	t.Setenv("ALLURE_PROJECT_ID", "")
+	t.Setenv("ALLURE_PROJECT_IDS", "42, 1661")
+	t.Setenv("AUTH_DISABLED", "true")
+
+	cfg, err := config.LoadConfig("")
+	assert.NoError(t, err)
+
+	assert.Equal(t, "42", cfg.AllureProjectID)
+	assert.Equal(t, []int64{42, 1661}, cfg.AllureProjects)
+}
+
+// Config Test: нулевой порог отключает автомат защиты, отрицательный - ошибка
+func TestLoadConfig_CircuitBreakerThreshold(t *testing.T) {
+	t.Setenv("ALLURE_BASE_URL", "https://allure.example.com")
+	t.Setenv("ALLURE_API_URL", "/api")
+	t.Setenv("ALLURE_API_TOKEN", "test-token")
+	t.Setenv("ALLURE_PROJECT_ID", "1661")
+	t.Setenv("ALLURE_CIRCUIT_FAILURE_THRESHOLD", "0")
+	t.Setenv("AUTH_DISABLED", "true")
+
+	cfg, err := config.LoadConfig("")
+	assert.NoError(t, err)
+	assert.Equal(t, 0, cfg.CircuitBreaker.FailureThreshold)
+
+	t.Setenv("ALLURE_CIRCUIT_FAILURE_THRESHOLD", "-1")
+	_, err = config.LoadConfig("")
+	assert.ErrorContains(t, err, "allure.circuitBreaker.failureThreshold")
+}
+
+// Config Test: несколько экземпляров Allure
+func TestLoadConfig_Instances(t *testing.T) {
+	t.Setenv("ALLURE_INSTANCES", "prod, staging-2")
+	t.Setenv("ALLURE_PROD_BASE_URL", "https://allure.example.com")
+	t.Setenv("ALLURE_PROD_API_URL", "/api/rs/")
+	t.Setenv("ALLURE_PROD_API_TOKEN", "prod-token")
+	t.Setenv("ALLURE_PROD_PROJECT_ID", "1661")
+	t.Setenv("ALLURE_STAGING_2_BASE_URL", "https://allure-staging.example.com")
+	t.Setenv("ALLURE_STAGING_2_API_URL", "/api/rs/")
+	t.Setenv("ALLURE_STAGING_2_API_TOKEN", "staging-token")
+	t.Setenv("ALLURE_STAGING_2_PROJECT_IDS", "7,8")
+	t.Setenv("AUTH_DISABLED", "true")
+
+	cfg, err := config.LoadConfig("")
+	assert.NoError(t, err)
+
+	assert.Len(t, cfg.AllureInstances, 2)
+	assert.Equal(t, "prod", cfg.AllureInstances[0].Name)
+	assert.Equal(t, "https://allure.example.com", cfg.AllureBaseURL)
+	assert.Equal(t, "prod-token", cfg.AllureUserToken)
+
+	staging := cfg.ForInstance(cfg.AllureInstances[1])
+	assert.Equal(t, "https://allure-staging.example.com", staging.AllureBaseURL)
+	assert.Equal(t, "staging-token", staging.AllureUserToken)
+	assert.Equal(t, "7", staging.AllureProjectID)
+	assert.Equal(t, []int64{7, 8}, staging.AllureProjects)
+	assert.Equal(t, "https://allure.example.com", cfg.AllureBaseURL)
+}
+
+// Config Test: файл YAML под переменными окружения
+func TestLoadConfig_YAMLFile(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "config.yaml")
+	os.WriteFile(path, []byte(`
+server:
+  port: 9090
+allure:
+  instances:
+    - name: prod
+      baseUrl: https://allure.example.com
+      apiUrl: /api/rs/
+      apiToken: file-token
+      projectIds: [42, 1661]
+  timeouts:
+    export: 2m
+  retry:
+    maxAttempts: 5
+  circuitBreaker:
+    openTimeout: 1m
+  tokenPassthrough:
+    mode: required
+reportCache:
+  maxMB: 16
+auth:
+  disabled: true
+`), 0o600)
+	t.Setenv("SERVER_PORT", "")
+	t.Setenv("ALLURE_TOKEN_CACHE_SIZE", "50")
+	t.Setenv("ALLURE_PROD_API_TOKEN", "env-token")
+	t.Setenv("ALLURE_CIRCUIT_FAILURE_THRESHOLD", "10")
+
+	cfg, err := config.LoadConfig(path)
+	assert.NoError(t, err)
+
+	assert.Equal(t, "9090", cfg.ServerPort)
+	assert.Equal(t, "prod", cfg.AllureInstances[0].Name)
+	assert.Equal(t, "env-token", cfg.AllureUserToken)
+	assert.Equal(t, "42", cfg.AllureProjectID)
+	assert.Equal(t, []int64{42, 1661}, cfg.AllureProjects)
+	assert.Equal(t, 2*time.Minute, cfg.Timeouts.Export)
+	assert.Equal(t, 10*time.Second, cfg.Timeouts.List)
+	assert.Equal(t, 5, cfg.Retry.MaxAttempts)
+	assert.Equal(t, config.CircuitBreaker{FailureThreshold: 10, OpenTimeout: time.Minute}, cfg.CircuitBreaker)
+	assert.Equal(t, config.TokenPassthrough{Mode: config.TokenPassthroughRequired, Header: "X-Allure-Token", CacheSize: 50}, cfg.TokenPassthrough)
+	assert.Equal(t, int64(16<<20), cfg.ReportCacheMaxSize)
+	assert.True(t, cfg.Auth.Disabled)
+	assert.Equal(t, 5*time.Minute, cfg.TokenRefreshMargin)
+}
+
+// Config Test: файл TOML
+func TestLoadConfig_TOMLFile(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "config.toml")
+	os.WriteFile(path, []byte(`
+[allure]
+baseUrl = "https://allure.example.com"
+apiUrl = "/api/rs/"
+apiToken = "file-token"
+projectId = 1661
+tokenRefreshMargin = "2m"
+
+[archive]
+backend = "local"
+dir = "/var/lib/allure-service/archive"
+
+[[auth.apiKeys]]
+name = "ci"
+projects = [1661, "default:42", "default:*"]
+operations = ["*"]
+`), 0o600)
+	t.Setenv("AUTH_API_KEYS", "")
+	t.Setenv("AUTH_API_KEY_CI", "ci-key-0123456789")
+	t.Setenv("SERVER_PORT", "")
+	t.Setenv("ALLURE_BASE_URL", "")
+	t.Setenv("ALLURE_API_URL", "")
+	t.Setenv("ALLURE_API_TOKEN", "")
+	t.Setenv("ALLURE_PROJECT_ID", "")
+
+	cfg, err := config.LoadConfig(path)
+	assert.NoError(t, err)
+
+	assert.Equal(t, "8080", cfg.ServerPort)
+	assert.Equal(t, "file-token", cfg.AllureUserToken)
+	assert.Equal(t, "1661", cfg.AllureProjectID)
+	assert.Equal(t, 2*time.Minute, cfg.TokenRefreshMargin)
+	assert.Equal(t, config.ArchiveBackendLocal, cfg.ArchiveBackend)
+	assert.Equal(t, []config.APIKey{
+		{Name: "ci", Key: "ci-key-0123456789", Projects: []config.ProjectRef{"1661", "default:42", "default:*"}, Operations: []string{config.AllowAll}},
+	}, cfg.Auth.APIKeys)
+}
+
+// Config Test: ошибки возвращаются по полям
+func TestLoadConfig_FieldErrors(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "config.yaml")
+	os.WriteFile(path, []byte(`
+allure:
+  apiUrl: /api/rs/
+archive:
+  backend: s3
+logging:
+  level: verbose
+`), 0o600)
+	t.Setenv("ALLURE_BASE_URL", "")
+	t.Setenv("ALLURE_API_URL", "")
+	t.Setenv("ALLURE_API_TOKEN", "")
+	t.Setenv("ALLURE_PROJECT_ID", "")
+	t.Setenv("ALLURE_TIMEOUT_EXPORT", "полминуты")
+
+	_, err := config.LoadConfig(path)
+
+	var validationErr config.ValidationError
+	if assert.ErrorAs(t, err, &validationErr) {
+		var fields []string
+		for _, fieldErr := range validationErr {
+			fields = append(fields, fieldErr.Field)
+		}
+		assert.ElementsMatch(t, []string{
+			"allure.timeouts.export",
+			"archive.s3.endpoint",
+			"archive.s3.bucket",
+			"allure.baseUrl",
+			"allure.apiToken",
+			"allure.projectId",
+			"logging.level",
+			"auth",
+		}, fields)
+	}
+	assert.ErrorContains(t, err, "ALLURE_BASE_URL")
+}
+
+// Config Test: API-ключи из файла и переменных окружения, настройки JWT
+func TestLoadConfig_Auth(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "config.yaml")
+	os.WriteFile(path, []byte(`
+allure:
+  baseUrl: https://allure.example.com
+  apiUrl: /api/rs/
+  apiToken: file-token
+  projectId: 1661
+auth:
+  apiKeys:
+    - name: ci
+      projects: [1661]
+      operations: [read, export]
+  jwt:
+    jwksUrl: https://sso.example.com/jwks.json
+    audience: allure-service
+`), 0o600)
+	t.Setenv("AUTH_API_KEYS", "ci, grafana")
+	t.Setenv("AUTH_API_KEY_CI", "ci-key-0123456789")
+	t.Setenv("AUTH_API_KEY_GRAFANA", "grafana-key-0123456789")
+	t.Setenv("AUTH_JWT_PROJECTS_CLAIM", "projects")
+
+	cfg, err := config.LoadConfig(path)
+	if !assert.NoError(t, err) {
+		return
+	}
+
+	assert.Equal(t, []config.APIKey{
+		{Name: "ci", Key: "ci-key-0123456789", Projects: []config.ProjectRef{"1661"}, Operations: []string{"read", "export"}},
+		{Name: "grafana", Key: "grafana-key-0123456789"},
+	}, cfg.Auth.APIKeys)
+	assert.Equal(t, "https://sso.example.com/jwks.json", cfg.Auth.JWT.JWKSURL)
+	assert.Equal(t, "allure-service", cfg.Auth.JWT.Audience)
+	assert.Equal(t, "projects", cfg.Auth.JWT.ProjectsClaim)
+	assert.Equal(t, "allure_operations", cfg.Auth.JWT.OperationsClaim)
+	assert.Equal(t, 10*time.Minute, cfg.Auth.JWT.JWKSRefresh)
+	assert.Contains(t, cfg.Secrets(), "grafana-key-0123456789")
+
+	var out bytes.Buffer
+	assert.NoError(t, cfg.WriteRedacted(&out))
+	assert.NotContains(t, out.String(), "ci-key-0123456789")
+
+	// Короткий ключ и неизвестная операция
+	t.Setenv("AUTH_API_KEY_CI", "short")
+	t.Setenv("AUTH_API_KEYS", "")
+	os.WriteFile(path, []byte(`
+allure:
+  baseUrl: https://allure.example.com
+  apiUrl: /api/rs/
+  apiToken: file-token
+  projectId: 1661
+auth:
+  apiKeys:
+    - name: ci
+      projects: ["*", 0, all, "default:7", "staging:7"]
+      operations: [delete, "*"]
+`), 0o600)
+
+	_, err = config.LoadConfig(path)
+	var validationErr config.ValidationError
+	if assert.ErrorAs(t, err, &validationErr) {
+		var fields []string
+		for _, fieldErr := range validationErr {
+			fields = append(fields, fieldErr.Field)
+		}
+		assert.ElementsMatch(t, []string{
+			"auth.apiKeys[0].key",
+			"auth.apiKeys[0].projects",
+			"auth.apiKeys[0].projects",
+			"auth.apiKeys[0].projects",
+			"auth.apiKeys[0].operations",
+		}, fields)
+	}
+	assert.ErrorContains(t, err, "AUTH_API_KEY_CI")
+}
+
+// Config Test: без учетных данных аутентификацию нужно отключить явно, вместе с ними - нельзя
+func TestLoadConfig_AuthDisabled(t *testing.T) {
+	t.Setenv("ALLURE_BASE_URL", "https://allure.example.com")
+	t.Setenv("ALLURE_API_URL", "/api")
+	t.Setenv("ALLURE_API_TOKEN", "test-token")
+	t.Setenv("ALLURE_PROJECT_ID", "1661")
+	t.Setenv("AUTH_API_KEYS", "")
+	t.Setenv("AUTH_DISABLED", "")
+
+	_, err := config.LoadConfig("")
+	assert.ErrorContains(t, err, "AUTH_DISABLED=true")
+
+	t.Setenv("AUTH_DISABLED", "true")
+	cfg, err := config.LoadConfig("")
+	assert.NoError(t, err)
+	assert.True(t, cfg.Auth.Disabled)
+
+	t.Setenv("AUTH_API_KEYS", "ci")
+	t.Setenv("AUTH_API_KEY_CI", "ci-key-0123456789")
+	_, err = config.LoadConfig("")
+	assert.ErrorContains(t, err, "auth.disabled")
+}
+
+// Config Test: политики CORS и заголовки безопасности из файла и переменных окружения
+func TestLoadConfig_HTTP(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "config.yaml")
+	os.WriteFile(path, []byte(`
+allure:
+  baseUrl: https://allure.example.com
+  apiUrl: /api/rs/
+  apiToken: file-token
+  projectId: 1661
+  tokenPassthrough:
+    mode: optional
+http:
+  cors:
+    allowOrigins: [https://app.example.com]
+    allowCredentials: true
+  downloadCors:
+    allowOrigins: ["https://*.example.com"]
+  securityHeaders:
+    hstsMaxAge: 8760h
+    frameOptions: ""
+`), 0o600)
+	t.Setenv("CORS_ALLOW_METHODS", "GET, POST")
+	t.Setenv("AUTH_DISABLED", "true")
+	t.Setenv("SECURITY_HSTS_INCLUDE_SUBDOMAINS", "true")
+
+	cfg, err := config.LoadConfig(path)
+	if !assert.NoError(t, err) {
+		return
+	}
+
+	assert.Equal(t, []string{"https://app.example.com"}, cfg.HTTP.CORS.AllowOrigins)
+	assert.Equal(t, []string{"GET", "POST"}, cfg.HTTP.CORS.AllowMethods)
+	assert.True(t, cfg.HTTP.CORS.AllowCredentials)
+	assert.Contains(t, cfg.HTTP.CORS.AllowHeaders, "X-API-Key")
+	assert.Contains(t, cfg.HTTP.CORS.AllowHeaders, "X-Allure-Token")
+	assert.Contains(t, cfg.HTTP.DownloadCORS.AllowHeaders, "X-Allure-Token")
+	assert.Contains(t, cfg.HTTP.DownloadCORS.ExposeHeaders, "Content-Disposition")
+	assert.Equal(t, "max-age=31536000; includeSubDomains", cfg.HTTP.Security.HSTS())
+	assert.Empty(t, cfg.HTTP.Security.FrameOptions)
+	assert.True(t, cfg.HTTP.Security.ContentTypeNosniff)
+
+	// "*" с учетными данными, источник с путем, неизвестный метод
+	t.Setenv("CORS_ALLOW_ORIGINS", "*")
+	t.Setenv("DOWNLOAD_CORS_ALLOW_ORIGINS", "https://app.example.com/reports")
+	t.Setenv("DOWNLOAD_CORS_ALLOW_METHODS", "GET,FETCH")
+	t.Setenv("SECURITY_FRAME_OPTIONS", "ALLOW")
+
+	_, err = config.LoadConfig(path)
+	var validationErr config.ValidationError
+	if assert.ErrorAs(t, err, &validationErr) {
+		var fields []string
+		for _, fieldErr := range validationErr {
+			fields = append(fields, fieldErr.Field)
+		}
+		assert.ElementsMatch(t, []string{
+			"http.cors.allowOrigins",
+			"http.downloadCors.allowOrigins",
+			"http.downloadCors.allowMethods",
+			"http.securityHeaders.frameOptions",
+		}, fields)
+	}
+	assert.ErrorContains(t, err, "CORS_ALLOW_CREDENTIALS")
+}
+
+// Config Test: неизвестный параметр в файле
+func TestLoadConfig_UnknownField(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "config.yaml")
+	os.WriteFile(path, []byte("allure:\n  timeout: 10s\n"), 0o600)
+
+	_, err := config.LoadConfig(path)
+	assert.ErrorContains(t, err, "timeout")
+}
+
+// Config Test: вывод конфигурации без секретов
+func TestConfig_WriteRedacted(t *testing.T) {
+	cfg := config.Defaults()
+	cfg.AllureInstances = []config.AllureInstance{{
+		Name:      config.DefaultInstanceName,
+		BaseURL:   "https://allure.example.com",
+		APIURL:    "/api/rs/",
+		UserToken: "secret-token",
+		ProjectID: "1661",
+		Projects:  []int64{1661},
+	}}
+	cfg.ArchiveBackend = config.ArchiveBackendS3
+	cfg.ArchiveS3.Endpoint = "http://minio:9000"
+	cfg.ArchiveS3.SecretKey = "minio-secret"
+
+	var out bytes.Buffer
+	assert.NoError(t, cfg.WriteRedacted(&out))
+
+	assert.NotContains(t, out.String(), "secret-token")
+	assert.NotContains(t, out.String(), "minio-secret")
+	assert.Contains(t, out.String(), "apiToken: '******'")
+	assert.Contains(t, out.String(), "export: 30s")
+	assert.Contains(t, out.String(), "baseUrl: https://allure.example.com")
+}
+
+// Config Test: перезагрузка по изменению файла и по SIGHUP
+func TestWatch_FileChangeAndSIGHUP(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "config.yaml")
+	os.WriteFile(path, []byte("server:\n  port: 8080\n"), 0o600)
+
+	reloads := make(chan struct{}, 10)
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go config.Watch(ctx, path, 10*time.Millisecond, func() { reloads <- struct{}{} })
+	time.Sleep(50 * time.Millisecond)
+
+	os.WriteFile(path, []byte("server:\n  port: 19090\n"), 0o600)
+	select {
+	case <-reloads:
+	case <-time.After(time.Second):
+		t.Fatal("изменение файла не вызвало перезагрузку")
+	}
+
+	syscall.Kill(os.Getpid(), syscall.SIGHUP)
+	select {
+	case <-reloads:
+	case <-time.After(time.Second):
+		t.Fatal("SIGHUP не вызвал перезагрузку")
+	}
+}
+
+// Config Test: значения из .env перечитываются при повторной загрузке
+func TestLoadConfig_DotenvReload(t *testing.T) {
+	dir := t.TempDir()
+	wd, _ := os.Getwd()
+	os.Chdir(dir)
+	defer os.Chdir(wd)
+
+	t.Setenv("ALLURE_INSTANCES", "dotenv")
+	t.Setenv("AUTH_DISABLED", "true")
+	t.Cleanup(func() {
+		for _, key := range []string{"ALLURE_DOTENV_BASE_URL", "ALLURE_DOTENV_API_URL", "ALLURE_DOTENV_API_TOKEN", "ALLURE_DOTENV_PROJECT_ID"} {
+			os.Unsetenv(key)
+		}
+	})
+	writeDotenv := func(token string) {
+		os.WriteFile(filepath.Join(dir, ".env"), []byte("ALLURE_DOTENV_BASE_URL=https://allure.example.com\n"+
+			"ALLURE_DOTENV_API_URL=/api/\nALLURE_DOTENV_PROJECT_ID=1661\nALLURE_DOTENV_API_TOKEN="+token+"\n"), 0o600)
+	}
+
+	writeDotenv("old-token")
+	cfg, err := config.LoadConfig("")
+	assert.NoError(t, err)
+	assert.Equal(t, "old-token", cfg.AllureUserToken)
+
+	writeDotenv("new-token")
+	cfg, err = config.LoadConfig("")
+	assert.NoError(t, err)
+	assert.Equal(t, "new-token", cfg.AllureUserToken)
+}
@@ -0,0 +1,170 @@
+package test
+
+import (
+	"bytes"
+	"encoding/csv"
+	"encoding/xml"
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/xuri/excelize/v2"
+
+	"github.com/vkr-mtuci/allure-service/internal/adapter"
+	"github.com/vkr-mtuci/allure-service/internal/export"
+)
+
+// exportResults - результаты тестов для проверки выгрузок
+var exportResults = []adapter.TestResult{
+	{
+		Name:     "login",
+		FullName: "auth.LoginTest.login",
+		Status:   "passed",
+		Duration: 1500,
+		Tags:     []adapter.Tag{{Name: "smoke"}, {Name: "auth"}},
+		Labels:   []adapter.Label{{Name: "owner", Value: "qa.lead"}, {Name: "suite", Value: "auth"}},
+	},
+	{
+		Name:     "checkout, \"guest\"",
+		FullName: "shop.CheckoutTest.guest",
+		Status:   "failed",
+		Duration: 3200,
+		Message:  "expected 200, got 500",
+	},
+}
+
+// ✅ **Тест: CSV-выгрузка с заголовком и экранированием**
+func TestWriteCSV(t *testing.T) {
+	var buf bytes.Buffer
+	assert.NoError(t, export.WriteCSV(&buf, export.SliceResults(exportResults)))
+
+	assert.True(t, strings.HasPrefix(buf.String(), "\ufeff"))
+
+	records, err := csv.NewReader(strings.NewReader(strings.TrimPrefix(buf.String(), "\ufeff"))).ReadAll()
+	assert.NoError(t, err)
+	assert.Len(t, records, 3)
+	assert.Equal(t, []string{"Название", "Полный путь", "Статус", "Длительность, мс", "Владелец", "Теги", "Ошибка"}, records[0])
+	assert.Equal(t, []string{"login", "auth.LoginTest.login", "passed", "1500", "qa.lead", "smoke, auth", ""}, records[1])
+	assert.Equal(t, "checkout, \"guest\"", records[2][0])
+	assert.Equal(t, "expected 200, got 500", records[2][6])
+}
+
+// ❌ **Тест: ошибка получения результатов посреди обхода прерывает CSV-выгрузку**
+func TestWriteCSV_ResultsError(t *testing.T) {
+	results := func(yield func(adapter.TestResult) bool) error {
+		yield(exportResults[0])
+		return errors.New("ошибка API")
+	}
+
+	var buf bytes.Buffer
+	assert.EqualError(t, export.WriteCSV(&buf, results), "ошибка API")
+}
+
+// ✅ **Тест: XLSX-выгрузка читается обратно**
+func TestWriteXLSX(t *testing.T) {
+	var buf bytes.Buffer
+	assert.NoError(t, export.WriteXLSX(&buf, export.SliceResults(exportResults)))
+
+	file, err := excelize.OpenReader(&buf)
+	assert.NoError(t, err)
+	defer file.Close()
+
+	rows, err := file.GetRows("Результаты")
+	assert.NoError(t, err)
+	assert.Len(t, rows, 3)
+	assert.Equal(t, "Полный путь", rows[0][1])
+	assert.Equal(t, "qa.lead", rows[1][4])
+	assert.Equal(t, "3200", rows[2][3])
+	assert.Equal(t, "failed", rows[2][2])
+}
+
+// ❌ **Тест: неизвестный формат выгрузки**
+func TestWriteTable_UnknownFormat(t *testing.T) {
+	var buf bytes.Buffer
+	assert.Error(t, export.WriteTable(&buf, "ods", export.SliceResults(exportResults)))
+}
+
+// ✅ **Тест: JUnit-выгрузка группирует тесты по suite и отмечает падения**
+func TestWriteJUnit(t *testing.T) {
+	results := append([]adapter.TestResult{
+		{
+			Name:       "logout",
+			FullName:   "auth.LoginTest.logout",
+			Status:     "broken",
+			Duration:   250,
+			Message:    "NullPointerException",
+			Trace:      "at auth.LoginTest.logout(LoginTest.java:42)",
+			Labels:     []adapter.Label{{Name: "suite", Value: "auth"}},
+			Parameters: []adapter.Parameter{{Name: "browser", Value: "chrome"}},
+		},
+		{Name: "refund", Status: "skipped", Labels: []adapter.Label{{Name: "suite", Value: "shop"}}},
+	}, exportResults...)
+
+	var buf bytes.Buffer
+	assert.NoError(t, export.WriteJUnit(&buf, "nightly", export.SliceResults(results)))
+	assert.True(t, strings.HasPrefix(buf.String(), xml.Header))
+
+	var report struct {
+		Name     string `xml:"name,attr"`
+		Tests    int    `xml:"tests,attr"`
+		Failures int    `xml:"failures,attr"`
+		Errors   int    `xml:"errors,attr"`
+		Skipped  int    `xml:"skipped,attr"`
+		Suites   []struct {
+			Name      string `xml:"name,attr"`
+			Tests     int    `xml:"tests,attr"`
+			Time      string `xml:"time,attr"`
+			TestCases []struct {
+				Name      string `xml:"name,attr"`
+				ClassName string `xml:"classname,attr"`
+				Failure   *struct {
+					Message string `xml:"message,attr"`
+				} `xml:"failure"`
+				Error *struct {
+					Trace string `xml:",chardata"`
+				} `xml:"error"`
+				Skipped   *struct{} `xml:"skipped"`
+				SystemOut string    `xml:"system-out"`
+			} `xml:"testcase"`
+		} `xml:"testsuite"`
+	}
+	assert.NoError(t, xml.Unmarshal(buf.Bytes(), &report))
+
+	assert.Equal(t, "nightly", report.Name)
+	assert.Equal(t, 4, report.Tests)
+	assert.Equal(t, 1, report.Failures)
+	assert.Equal(t, 1, report.Errors)
+	assert.Equal(t, 1, report.Skipped)
+
+	// Наборы отсортированы по имени, результаты без suite попадают в "default"
+	assert.Len(t, report.Suites, 3)
+	assert.Equal(t, "auth", report.Suites[0].Name)
+	assert.Equal(t, 2, report.Suites[0].Tests)
+	assert.Equal(t, "1.750", report.Suites[0].Time)
+	assert.Equal(t, "default", report.Suites[1].Name)
+	assert.Equal(t, "shop", report.Suites[2].Name)
+
+	logout := report.Suites[0].TestCases[0]
+	assert.Equal(t, "auth.LoginTest", logout.ClassName)
+	assert.NotNil(t, logout.Error)
+	assert.Contains(t, logout.Error.Trace, "LoginTest.java:42")
+	assert.Equal(t, "browser = chrome", logout.SystemOut)
+
+	checkout := report.Suites[1].TestCases[0]
+	assert.NotNil(t, checkout.Failure)
+	assert.Equal(t, "expected 200, got 500", checkout.Failure.Message)
+	assert.NotNil(t, report.Suites[2].TestCases[0].Skipped)
+}
+
+// ❌ **Тест: при ошибке получения результатов JUnit XML не записывается**
+func TestWriteJUnit_ResultsError(t *testing.T) {
+	results := func(yield func(adapter.TestResult) bool) error {
+		yield(exportResults[0])
+		return errors.New("ошибка API")
+	}
+
+	var buf bytes.Buffer
+	assert.EqualError(t, export.WriteJUnit(&buf, "nightly", results), "ошибка API")
+	assert.Zero(t, buf.Len())
+}
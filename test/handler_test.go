@@ -1,8 +1,12 @@
 package test
 
 import (
+	"bufio"
+	"context"
 	"errors"
+	"fmt"
 	"io"
+	"net"
 	"net/http"
 	"net/http/httptest"
 	"strings"
@@ -12,8 +16,12 @@ import (
 	"github.com/gofiber/fiber/v2"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/mock"
+	"github.com/vkr-mtuci/allure-service/config"
 	"github.com/vkr-mtuci/allure-service/internal/adapter"
+	"github.com/vkr-mtuci/allure-service/internal/archive"
 	"github.com/vkr-mtuci/allure-service/internal/handler"
+	"github.com/vkr-mtuci/allure-service/internal/reqctx"
+	"github.com/vkr-mtuci/allure-service/internal/service"
 )
 
 // ✅ Тест для `GetNextLaunch`
@@ -29,7 +37,7 @@ func TestGetNextLaunchHandler(t *testing.T) {
 		Name:        "Test Run",
 		CreatedDate: time.Now().UnixMilli(),
 	}
-	mockService.On("GetNextLaunch", mock.Anything).Return(mockLaunch, nil)
+	mockService.On("GetNextLaunch", mock.Anything, int64(0), mock.Anything).Return(mockLaunch, nil)
 
 	// 🏃‍♂️ Выполняем тестовый запрос
 	req := httptest.NewRequest(http.MethodGet, "/next-launch?after=2024-02-01T12:00:00Z", nil)
@@ -48,7 +56,7 @@ func TestGetNextLaunchHandler_NotFound(t *testing.T) {
 	app.Get("/next-launch", h.GetNextLaunch)
 
 	// 🛠 Мокируем ошибку "не найден запуск"
-	mockService.On("GetNextLaunch", mock.Anything).Return(nil, errors.New("не найден запуск"))
+	mockService.On("GetNextLaunch", mock.Anything, int64(0), mock.Anything).Return(nil, errors.New("не найден запуск"))
 
 	// 🏃‍♂️ Выполняем тестовый запрос
 	req := httptest.NewRequest(http.MethodGet, "/next-launch?after=2024-02-01T12:00:00Z", nil)
@@ -66,7 +74,7 @@ func TestGeneratePDFReportHandler_InvalidInput(t *testing.T) {
 	app.Post("/export/pdf/:id", handler.GeneratePDFReport)
 
 	// Ожидаем вызов `GeneratePDFReport` с `launchId=123` и `name="Test"`
-	mockService.On("GeneratePDFReport", int64(123), "Test").Return(nil, errors.New("invalid input"))
+	mockService.On("GeneratePDFReport", mock.Anything, int64(0), int64(123), "Test", adapter.DefaultPDFExportOptions()).Return(nil, errors.New("invalid input"))
 
 	// Тест с несоответствующим ID в пути и теле
 	reqBody := `{"launchId": 123, "name": "Test"}`
@@ -89,9 +97,8 @@ func TestDownloadPDFReport_StatusHandling(t *testing.T) {
 	app.Get("/export/pdf/download/:id", handler.DownloadPDFReport)
 
 	// Тест с несуществующим отчетом
-	mockService.On("DownloadPDFReport", "999").Return(
+	mockService.On("DownloadPDFReport", mock.Anything, int64(0), "999").Return(
 		nil,
-		"",
 		errors.New("report not found"),
 	)
 
@@ -135,3 +142,902 @@ func TestGetPDFDownloadLink_Handler(t *testing.T) {
 	expected := `{"download_link":"http://mocked.url/download/456"}`
 	assert.JSONEq(t, expected, string(body))
 }
+
+// ✅ Тест для `GetLaunches` с фильтрами и пагинацией
+func TestGetLaunchesHandler(t *testing.T) {
+	mockService := new(MockAllureService)
+	app := fiber.New()
+	h := handler.NewAllureHandler(mockService)
+	app.Get("/launches", h.GetLaunches)
+
+	expectedQuery := adapter.LaunchQuery{
+		From: time.Date(2025, 1, 30, 0, 0, 0, 0, time.FixedZone("", 3*60*60)),
+		To:   time.Date(2025, 2, 1, 0, 0, 0, 0, time.UTC),
+		Name: "nightly",
+		Tag:  "regress",
+		Sort: "name,asc",
+		Page: 2,
+		Size: 50,
+	}
+	mockService.On("GetLaunches", mock.Anything, mock.MatchedBy(func(query adapter.LaunchQuery) bool {
+		return query.From.Equal(expectedQuery.From) && query.To.Equal(expectedQuery.To) &&
+			query.Name == expectedQuery.Name && query.Tag == expectedQuery.Tag &&
+			query.Sort == expectedQuery.Sort && query.Page == expectedQuery.Page && query.Size == expectedQuery.Size
+	})).Return(&adapter.LaunchPage{
+		Content:       []adapter.Launch{{ID: 1, Name: "nightly #1"}},
+		TotalElements: 101,
+		TotalPages:    3,
+		Number:        2,
+		Size:          50,
+		Last:          true,
+	}, nil)
+
+	req := httptest.NewRequest(http.MethodGet,
+		"/launches?from=2025-01-30T00:00:00%2B03:00&to=2025-02-01T00:00:00Z&name=nightly&tag=regress&page=2&size=50&sort=name,asc", nil)
+	resp, err := app.Test(req)
+	assert.NoError(t, err)
+	assert.Equal(t, http.StatusOK, resp.StatusCode)
+
+	body, _ := io.ReadAll(resp.Body)
+	assert.Contains(t, string(body), `"totalElements":101`)
+	assert.Contains(t, string(body), `"name":"nightly #1"`)
+	mockService.AssertExpectations(t)
+}
+
+// ✅ Тест для `GetLaunches` со значениями по умолчанию
+func TestGetLaunchesHandler_Defaults(t *testing.T) {
+	mockService := new(MockAllureService)
+	app := fiber.New()
+	h := handler.NewAllureHandler(mockService)
+	app.Get("/launches", h.GetLaunches)
+
+	mockService.On("GetLaunches", mock.Anything, adapter.LaunchQuery{Sort: "createdDate,desc", Size: 20}).
+		Return(&adapter.LaunchPage{}, nil)
+
+	req := httptest.NewRequest(http.MethodGet, "/launches", nil)
+	resp, err := app.Test(req)
+	assert.NoError(t, err)
+	assert.Equal(t, http.StatusOK, resp.StatusCode)
+	mockService.AssertExpectations(t)
+}
+
+// ❌ Тест для `GetLaunches` с некорректными параметрами
+func TestGetLaunchesHandler_InvalidParams(t *testing.T) {
+	mockService := new(MockAllureService)
+	app := fiber.New()
+	h := handler.NewAllureHandler(mockService)
+	app.Get("/launches", h.GetLaunches)
+
+	for _, query := range []string{
+		"from=2024-02-01",
+		"to=yesterday",
+		"from=2025-02-01T00:00:00Z&to=2025-01-01T00:00:00Z",
+		"page=-1",
+		"page=abc",
+		"size=0",
+		"size=1000",
+		"sort=createdDate",
+		"sort=password,asc",
+		"sort=name,sideways",
+	} {
+		req := httptest.NewRequest(http.MethodGet, "/launches?"+query, nil)
+		resp, err := app.Test(req)
+		assert.NoError(t, err)
+		assert.Equal(t, http.StatusBadRequest, resp.StatusCode, query)
+	}
+
+	mockService.AssertNotCalled(t, "GetLaunches", mock.Anything, mock.Anything)
+}
+
+// ✅ Тест для `GetLaunch`
+func TestGetLaunchHandler(t *testing.T) {
+	mockService := new(MockAllureService)
+	app := fiber.New()
+	h := handler.NewAllureHandler(mockService)
+	app.Get("/launches/:id", h.GetLaunch)
+
+	mockService.On("GetLaunch", mock.Anything, int64(0), int64(42)).Return(&adapter.Launch{
+		ID:        42,
+		Name:      "Nightly",
+		Statistic: &adapter.LaunchStatistic{Passed: 3, Total: 3},
+	}, nil)
+	mockService.On("GetLaunch", mock.Anything, int64(0), int64(43)).Return(nil, fmt.Errorf("%w: launch/43", adapter.ErrNotFound))
+
+	resp, err := app.Test(httptest.NewRequest(http.MethodGet, "/launches/42", nil))
+	assert.NoError(t, err)
+	assert.Equal(t, http.StatusOK, resp.StatusCode)
+	body, _ := io.ReadAll(resp.Body)
+	assert.Contains(t, string(body), `"statistic":{"passed":3`)
+
+	resp, err = app.Test(httptest.NewRequest(http.MethodGet, "/launches/43", nil))
+	assert.NoError(t, err)
+	assert.Equal(t, http.StatusNotFound, resp.StatusCode)
+
+	resp, err = app.Test(httptest.NewRequest(http.MethodGet, "/launches/abc", nil))
+	assert.NoError(t, err)
+	assert.Equal(t, http.StatusBadRequest, resp.StatusCode)
+}
+
+// ✅ Тест для `GetLaunchResults` с фильтром по статусам
+func TestGetLaunchResultsHandler(t *testing.T) {
+	mockService := new(MockAllureService)
+	app := fiber.New()
+	h := handler.NewAllureHandler(mockService)
+	app.Get("/launches/:id/results", h.GetLaunchResults)
+
+	mockService.On("GetTestResults", mock.Anything, int64(0), adapter.TestResultQuery{
+		LaunchID: 42,
+		Statuses: []string{"failed", "broken"},
+		Page:     1,
+		Size:     10,
+	}).Return(&adapter.TestResultPage{Content: []adapter.TestResult{{ID: 7, Status: "failed"}}}, nil)
+
+	resp, err := app.Test(httptest.NewRequest(http.MethodGet, "/launches/42/results?status=failed,Broken&page=1&size=10", nil))
+	assert.NoError(t, err)
+	assert.Equal(t, http.StatusOK, resp.StatusCode)
+
+	resp, err = app.Test(httptest.NewRequest(http.MethodGet, "/launches/42/results?status=green", nil))
+	assert.NoError(t, err)
+	assert.Equal(t, http.StatusBadRequest, resp.StatusCode)
+
+	resp, err = app.Test(httptest.NewRequest(http.MethodGet, "/launches/42/results?size=100000", nil))
+	assert.NoError(t, err)
+	assert.Equal(t, http.StatusBadRequest, resp.StatusCode)
+
+	mockService.AssertExpectations(t)
+}
+
+// ✅ Тест для `GetTestResult`
+func TestGetTestResultHandler(t *testing.T) {
+	mockService := new(MockAllureService)
+	app := fiber.New()
+	h := handler.NewAllureHandler(mockService)
+	app.Get("/results/:id", h.GetTestResult)
+
+	mockService.On("GetTestResult", mock.Anything, int64(0), int64(7)).Return(&adapter.TestResult{
+		ID:      7,
+		Message: "boom",
+		Steps:   []adapter.Step{{Name: "open page"}},
+	}, nil)
+	mockService.On("GetTestResult", mock.Anything, int64(0), int64(8)).Return(nil, fmt.Errorf("%w: testresult/8", adapter.ErrNotFound))
+
+	resp, err := app.Test(httptest.NewRequest(http.MethodGet, "/results/7", nil))
+	assert.NoError(t, err)
+	assert.Equal(t, http.StatusOK, resp.StatusCode)
+	body, _ := io.ReadAll(resp.Body)
+	assert.Contains(t, string(body), `"steps":[{"name":"open page"`)
+
+	resp, err = app.Test(httptest.NewRequest(http.MethodGet, "/results/8", nil))
+	assert.NoError(t, err)
+	assert.Equal(t, http.StatusNotFound, resp.StatusCode)
+}
+
+// ✅ Тест для `DownloadAttachment` с частичной загрузкой
+func TestDownloadAttachmentHandler(t *testing.T) {
+	mockService := new(MockAllureService)
+	app := fiber.New()
+	h := handler.NewAllureHandler(mockService)
+	app.Get("/results/:id/attachments/:attachmentId", h.DownloadAttachment)
+
+	mockService.On("DownloadAttachment", mock.Anything, int64(0), int64(7), int64(3), "bytes=0-3").Return(&adapter.FileContent{
+		Body:          io.NopCloser(strings.NewReader("0123")),
+		StatusCode:    http.StatusPartialContent,
+		ContentType:   "video/mp4",
+		ContentLength: 4,
+		ContentRange:  "bytes 0-3/16",
+		AcceptRanges:  "bytes",
+		FileName:      "video.mp4",
+	}, nil)
+	mockService.On("DownloadAttachment", mock.Anything, int64(0), int64(7), int64(5), "").Return(&adapter.FileContent{
+		Body:          io.NopCloser(strings.NewReader("<script>alert(1)</script>")),
+		StatusCode:    http.StatusOK,
+		ContentType:   "text/html",
+		ContentLength: 25,
+		FileName:      "report.html",
+	}, nil)
+	mockService.On("DownloadAttachment", mock.Anything, int64(0), int64(7), int64(3), "bytes=100-").Return(nil, adapter.ErrRangeNotSatisfiable)
+	mockService.On("DownloadAttachment", mock.Anything, int64(0), int64(7), int64(4), "").Return(nil, adapter.ErrNotFound)
+
+	req := httptest.NewRequest(http.MethodGet, "/results/7/attachments/3", nil)
+	req.Header.Set("Range", "bytes=0-3")
+	resp, err := app.Test(req)
+	assert.NoError(t, err)
+	assert.Equal(t, http.StatusPartialContent, resp.StatusCode)
+	assert.Equal(t, "video/mp4", resp.Header.Get("Content-Type"))
+	assert.Equal(t, "bytes 0-3/16", resp.Header.Get("Content-Range"))
+	assert.Equal(t, `attachment; filename=video.mp4`, resp.Header.Get("Content-Disposition"))
+	assert.Equal(t, "bytes", resp.Header.Get("Accept-Ranges"))
+	body, _ := io.ReadAll(resp.Body)
+	assert.Equal(t, "0123", string(body))
+
+	// HTML-вложение скачивается, а не открывается страницей сервиса; Allure не сообщил о Range
+	resp, err = app.Test(httptest.NewRequest(http.MethodGet, "/results/7/attachments/5", nil))
+	assert.NoError(t, err)
+	assert.Equal(t, http.StatusOK, resp.StatusCode)
+	assert.Equal(t, `attachment; filename=report.html`, resp.Header.Get("Content-Disposition"))
+	assert.Equal(t, "nosniff", resp.Header.Get("X-Content-Type-Options"))
+	assert.Empty(t, resp.Header.Get("Accept-Ranges"))
+
+	req = httptest.NewRequest(http.MethodGet, "/results/7/attachments/3", nil)
+	req.Header.Set("Range", "bytes=100-")
+	resp, err = app.Test(req)
+	assert.NoError(t, err)
+	assert.Equal(t, http.StatusRequestedRangeNotSatisfiable, resp.StatusCode)
+
+	resp, err = app.Test(httptest.NewRequest(http.MethodGet, "/results/7/attachments/4", nil))
+	assert.NoError(t, err)
+	assert.Equal(t, http.StatusNotFound, resp.StatusCode)
+}
+
+// ✅ Тест для `GetExportStatus`
+func TestGetExportStatusHandler(t *testing.T) {
+	mockService := new(MockAllureService)
+	app := fiber.New()
+	h := handler.NewAllureHandler(mockService)
+	app.Get("/export/:id/status", h.GetExportStatus)
+
+	mockService.On("GetExportStatus", mock.Anything, int64(0), "456").Return(&service.ExportJob{ReportID: "456", Status: service.ExportStatusPending}, nil)
+	mockService.On("GetExportStatus", mock.Anything, int64(0), "457").Return(nil, adapter.ErrNotFound)
+
+	resp, err := app.Test(httptest.NewRequest(http.MethodGet, "/export/456/status", nil))
+	assert.NoError(t, err)
+	assert.Equal(t, http.StatusOK, resp.StatusCode)
+	body, _ := io.ReadAll(resp.Body)
+	assert.Contains(t, string(body), `"status":"pending"`)
+
+	resp, err = app.Test(httptest.NewRequest(http.MethodGet, "/export/457/status", nil))
+	assert.NoError(t, err)
+	assert.Equal(t, http.StatusNotFound, resp.StatusCode)
+}
+
+// ❌ Тест для `DownloadPDFReport`, когда отчет не готов или не сформирован
+func TestDownloadPDFReport_NotReadyHandler(t *testing.T) {
+	mockService := new(MockAllureService)
+	app := fiber.New()
+	h := handler.NewAllureHandler(mockService)
+	app.Get("/export/pdf/download/:id", h.DownloadPDFReport)
+
+	mockService.On("DownloadPDFReport", mock.Anything, int64(0), "456").Return(nil, service.ErrReportNotReady)
+	mockService.On("DownloadPDFReport", mock.Anything, int64(0), "789").Return(nil, service.ErrReportFailed)
+
+	resp, _ := app.Test(httptest.NewRequest(http.MethodGet, "/export/pdf/download/456", nil))
+	assert.Equal(t, http.StatusConflict, resp.StatusCode)
+
+	resp, _ = app.Test(httptest.NewRequest(http.MethodGet, "/export/pdf/download/789", nil))
+	assert.Equal(t, http.StatusUnprocessableEntity, resp.StatusCode)
+
+	// Нечисловой ID не доходит до сервиса
+	for _, reportID := range []string{"abc", "..%2F..%2Fsecret", "-1", "0"} {
+		resp, _ = app.Test(httptest.NewRequest(http.MethodGet, "/export/pdf/download/"+reportID, nil))
+		assert.Equal(t, http.StatusBadRequest, resp.StatusCode, reportID)
+	}
+	mockService.AssertNumberOfCalls(t, "DownloadPDFReport", 2)
+}
+
+// ✅ Тест для `GeneratePDFReport`: параметры экспорта из тела запроса доходят до сервиса
+func TestGeneratePDFReportHandler_ExportOptions(t *testing.T) {
+	app := fiber.New()
+	mockService := new(MockAllureService)
+	h := handler.NewAllureHandler(mockService)
+	app.Post("/export/pdf/:id", h.GeneratePDFReport)
+
+	expectedOptions := adapter.DefaultPDFExportOptions()
+	expectedOptions.WithPageNumbers = false
+	expectedOptions.Sections.Attachments = false
+	expectedOptions.Statuses = []string{"failed", "broken"}
+	expectedOptions.Locale = "en"
+
+	mockService.On("GeneratePDFReport", mock.Anything, int64(0), int64(123), "Nightly", expectedOptions).Return(&adapter.PDFReport{ID: 456}, nil)
+	mockService.On("GetPDFDownloadLink", "456").Return("http://mocked.url/download/456")
+
+	reqBody := `{"launchId": 123, "name": "Nightly", "withPageNumbers": false, "sections": {"attachments": false}, "statuses": ["failed", "BROKEN"], "locale": "en"}`
+	req := httptest.NewRequest(http.MethodPost, "/export/pdf/123", strings.NewReader(reqBody))
+	req.Header.Set("Content-Type", "application/json")
+
+	resp, err := app.Test(req)
+	assert.NoError(t, err)
+	assert.Equal(t, http.StatusOK, resp.StatusCode)
+	mockService.AssertExpectations(t)
+}
+
+// ❌ Тест для `GeneratePDFReport` с некорректными параметрами экспорта
+func TestGeneratePDFReportHandler_InvalidExportOptions(t *testing.T) {
+	app := fiber.New()
+	mockService := new(MockAllureService)
+	h := handler.NewAllureHandler(mockService)
+	app.Post("/export/pdf/:id", h.GeneratePDFReport)
+
+	for _, reqBody := range []string{
+		`{"launchId": 123, "name": "Nightly", "statuses": ["green"]}`,
+		`{"launchId": 123, "name": "Nightly", "locale": "russian"}`,
+	} {
+		req := httptest.NewRequest(http.MethodPost, "/export/pdf/123", strings.NewReader(reqBody))
+		req.Header.Set("Content-Type", "application/json")
+
+		resp, err := app.Test(req)
+		assert.NoError(t, err)
+		assert.Equal(t, http.StatusBadRequest, resp.StatusCode, reqBody)
+	}
+
+	mockService.AssertNotCalled(t, "GeneratePDFReport", mock.Anything, mock.Anything, mock.Anything, mock.Anything)
+}
+
+// ✅ Тест для табличных выгрузок результатов
+func TestExportLaunchResultsHandler(t *testing.T) {
+	mockService := new(MockAllureService)
+	app := fiber.New()
+	h := handler.NewAllureHandler(mockService)
+	app.Post("/export/csv/:id", h.ExportCSV)
+	app.Post("/export/xlsx/:id", h.ExportXLSX)
+
+	mockService.On("ExportLaunchResults", mock.Anything, int64(0), int64(42), "csv").Return(&service.ResultsExport{
+		FileName:    "allure-launch-42.csv",
+		ContentType: "text/csv; charset=utf-8",
+		Write: func(w io.Writer) error {
+			_, err := io.WriteString(w, "name\nlogin\n")
+			return err
+		},
+	}, nil)
+	mockService.On("ExportLaunchResults", mock.Anything, int64(0), int64(42), "xlsx").Return(nil, errors.New("ошибка API"))
+	mockService.On("ExportLaunchResults", mock.Anything, int64(0), int64(43), "csv").Return(&service.ResultsExport{
+		FileName:    "результаты 43.csv",
+		ContentType: "text/csv; charset=utf-8",
+		Write: func(w io.Writer) error {
+			_, _ = io.WriteString(w, "name\n")
+			return errors.New("ошибка API")
+		},
+	}, nil)
+
+	resp, err := app.Test(httptest.NewRequest(http.MethodPost, "/export/csv/42", nil))
+	assert.NoError(t, err)
+	assert.Equal(t, http.StatusOK, resp.StatusCode)
+	assert.Equal(t, "text/csv; charset=utf-8", resp.Header.Get("Content-Type"))
+	assert.Equal(t, "attachment; filename=allure-launch-42.csv", resp.Header.Get("Content-Disposition"))
+	body, err := io.ReadAll(resp.Body)
+	assert.NoError(t, err)
+	assert.Equal(t, "name\nlogin\n", string(body))
+
+	// Ошибка посреди записи обрывает ответ, а не выдает обрезанный файл за целый
+	resp, err = app.Test(httptest.NewRequest(http.MethodPost, "/export/csv/43", nil))
+	if err == nil {
+		assert.Equal(t, "attachment; filename*=utf-8''%D1%80%D0%B5%D0%B7%D1%83%D0%BB%D1%8C%D1%82%D0%B0%D1%82%D1%8B%2043.csv", resp.Header.Get("Content-Disposition"))
+		_, err = io.ReadAll(resp.Body)
+	}
+	assert.Error(t, err)
+
+	resp, err = app.Test(httptest.NewRequest(http.MethodPost, "/export/xlsx/42", nil))
+	assert.NoError(t, err)
+	assert.Equal(t, http.StatusInternalServerError, resp.StatusCode)
+
+	resp, err = app.Test(httptest.NewRequest(http.MethodPost, "/export/xlsx/abc", nil))
+	assert.NoError(t, err)
+	assert.Equal(t, http.StatusBadRequest, resp.StatusCode)
+}
+
+// ✅ Тест для JUnit-выгрузки результатов запуска
+func TestGetLaunchJUnitHandler(t *testing.T) {
+	mockService := new(MockAllureService)
+	app := fiber.New()
+	h := handler.NewAllureHandler(mockService)
+	app.Get("/launches/:id/junit.xml", h.GetLaunchJUnit)
+
+	mockService.On("ExportLaunchJUnit", mock.Anything, int64(0), int64(42)).Return(&service.ResultsExport{
+		FileName:    "allure-launch-42.junit.xml",
+		ContentType: "application/xml; charset=utf-8",
+		Write: func(w io.Writer) error {
+			_, err := io.WriteString(w, "<testsuites></testsuites>")
+			return err
+		},
+	}, nil)
+	mockService.On("ExportLaunchJUnit", mock.Anything, int64(0), int64(404)).Return(nil, adapter.ErrNotFound)
+
+	resp, err := app.Test(httptest.NewRequest(http.MethodGet, "/launches/42/junit.xml", nil))
+	assert.NoError(t, err)
+	assert.Equal(t, http.StatusOK, resp.StatusCode)
+	assert.Equal(t, "application/xml; charset=utf-8", resp.Header.Get("Content-Type"))
+	assert.Equal(t, "attachment; filename=allure-launch-42.junit.xml", resp.Header.Get("Content-Disposition"))
+	body, err := io.ReadAll(resp.Body)
+	assert.NoError(t, err)
+	assert.Equal(t, "<testsuites></testsuites>", string(body))
+
+	resp, err = app.Test(httptest.NewRequest(http.MethodGet, "/launches/404/junit.xml", nil))
+	assert.NoError(t, err)
+	assert.Equal(t, http.StatusNotFound, resp.StatusCode)
+
+	resp, err = app.Test(httptest.NewRequest(http.MethodGet, "/launches/abc/junit.xml", nil))
+	assert.NoError(t, err)
+	assert.Equal(t, http.StatusBadRequest, resp.StatusCode)
+}
+
+// ✅ Тест для `DownloadPDFReport`: ETag и условный запрос If-None-Match
+func TestDownloadPDFReport_ETagHandler(t *testing.T) {
+	mockService := new(MockAllureService)
+	app := fiber.New()
+	h := handler.NewAllureHandler(mockService)
+	app.Get("/export/pdf/download/:id", h.DownloadPDFReport)
+
+	// Каждый запрос получает новый поток
+	mockService.On("DownloadPDFReport", mock.Anything, int64(0), "456").Return(func(string) *service.PDFDownload {
+		return &service.PDFDownload{
+			Body:        io.NopCloser(strings.NewReader("PDF content")),
+			Size:        11,
+			ContentType: "application/pdf",
+			FileName:    "allure-report-456.pdf",
+			ETag:        `"abc123"`,
+		}
+	}, nil)
+
+	resp, err := app.Test(httptest.NewRequest(http.MethodGet, "/export/pdf/download/456", nil))
+	assert.NoError(t, err)
+	assert.Equal(t, http.StatusOK, resp.StatusCode)
+	assert.Equal(t, `"abc123"`, resp.Header.Get("ETag"))
+	body, _ := io.ReadAll(resp.Body)
+	assert.Equal(t, "PDF content", string(body))
+
+	for _, ifNoneMatch := range []string{`"abc123"`, `W/"abc123"`, `"other", "abc123"`, "*"} {
+		req := httptest.NewRequest(http.MethodGet, "/export/pdf/download/456", nil)
+		req.Header.Set("If-None-Match", ifNoneMatch)
+		resp, err = app.Test(req)
+		assert.NoError(t, err)
+		assert.Equal(t, http.StatusNotModified, resp.StatusCode, ifNoneMatch)
+		body, _ = io.ReadAll(resp.Body)
+		assert.Empty(t, body)
+	}
+
+	req := httptest.NewRequest(http.MethodGet, "/export/pdf/download/456", nil)
+	req.Header.Set("If-None-Match", `"stale"`)
+	resp, err = app.Test(req)
+	assert.NoError(t, err)
+	assert.Equal(t, http.StatusOK, resp.StatusCode)
+}
+
+// ✅ Тест для `/archive`: список и скачивание архивных отчетов
+func TestArchivedReportsHandler(t *testing.T) {
+	mockService := new(MockAllureService)
+	app := fiber.New()
+	h := handler.NewAllureHandler(mockService)
+	app.Get("/archive", h.ListArchivedReports)
+	app.Get("/archive/:launchId/:reportId", h.DownloadArchivedReport)
+
+	mockService.On("ListArchivedReports", mock.Anything, int64(123)).Return([]archive.Report{{LaunchID: 123, ReportID: "456", FileName: "456.pdf", Size: 3}}, nil)
+	mockService.On("OpenArchivedReport", mock.Anything, int64(123), "456").Return(&service.PDFDownload{
+		Body:        io.NopCloser(strings.NewReader("PDF")),
+		Size:        3,
+		ContentType: "application/pdf",
+		FileName:    "456.pdf",
+	}, nil)
+	mockService.On("OpenArchivedReport", mock.Anything, int64(123), "457").Return(nil, fmt.Errorf("%w: 457", archive.ErrNotFound))
+
+	resp, err := app.Test(httptest.NewRequest(http.MethodGet, "/archive?launchId=123", nil))
+	assert.NoError(t, err)
+	assert.Equal(t, http.StatusOK, resp.StatusCode)
+	body, _ := io.ReadAll(resp.Body)
+	assert.Contains(t, string(body), `"reportId":"456"`)
+
+	resp, err = app.Test(httptest.NewRequest(http.MethodGet, "/archive?launchId=abc", nil))
+	assert.NoError(t, err)
+	assert.Equal(t, http.StatusBadRequest, resp.StatusCode)
+
+	resp, err = app.Test(httptest.NewRequest(http.MethodGet, "/archive/123/456", nil))
+	assert.NoError(t, err)
+	assert.Equal(t, http.StatusOK, resp.StatusCode)
+	assert.Contains(t, resp.Header.Get("Content-Disposition"), "456.pdf")
+	body, _ = io.ReadAll(resp.Body)
+	assert.Equal(t, "PDF", string(body))
+
+	resp, err = app.Test(httptest.NewRequest(http.MethodGet, "/archive/123/457", nil))
+	assert.NoError(t, err)
+	assert.Equal(t, http.StatusNotFound, resp.StatusCode)
+}
+
+// ❌ **Тест: вызывающий со своим API-токеном не видит архивные отчеты запусков, закрытых ему в Allure**
+func TestArchivedReportsHandler_TokenPassthrough(t *testing.T) {
+	reportArchive, err := archive.NewLocal(t.TempDir())
+	assert.NoError(t, err)
+	for _, report := range []archive.Report{
+		{LaunchID: 123, ReportID: "456", FileName: "456.pdf"},
+		{LaunchID: 124, ReportID: "457", FileName: "457.pdf"},
+		{LaunchID: 0, ReportID: "458", FileName: "458.pdf"},
+	} {
+		_, err := reportArchive.Put(context.Background(), report, strings.NewReader("PDF"))
+		assert.NoError(t, err)
+	}
+
+	mockClient := new(MockAllureClient)
+	h := handler.NewAllureHandler(service.NewAllureService(mockClient, service.WithReportArchive(reportArchive)))
+	app := fiber.New()
+	app.Use(handler.AllureToken(config.TokenPassthrough{Mode: config.TokenPassthroughOptional, Header: "X-Allure-Token"}))
+	app.Get("/archive", h.ListArchivedReports)
+	app.Get("/archive/:launchId/:reportId", h.DownloadArchivedReport)
+
+	aliceCtx := mock.MatchedBy(func(ctx context.Context) bool { return reqctx.AllureToken(ctx) == "alice-token" })
+	mockClient.On("GetLaunch", aliceCtx, int64(123)).Return(&adapter.Launch{ID: 123, ProjectID: 1}, nil)
+	mockClient.On("GetLaunch", aliceCtx, int64(124)).Return(nil, fmt.Errorf("запуск 124: %w", adapter.ErrForbidden))
+
+	request := func(target, token string) (int, string) {
+		req := httptest.NewRequest(http.MethodGet, target, nil)
+		if token != "" {
+			req.Header.Set("X-Allure-Token", token)
+		}
+		resp, err := app.Test(req)
+		assert.NoError(t, err)
+		body, _ := io.ReadAll(resp.Body)
+		return resp.StatusCode, string(body)
+	}
+
+	// В списке только отчеты запусков, доступных вызывающему
+	status, body := request("/archive", "alice-token")
+	assert.Equal(t, http.StatusOK, status)
+	assert.Contains(t, body, `"reportId":"456"`)
+	assert.NotContains(t, body, `"reportId":"457"`)
+	assert.NotContains(t, body, `"reportId":"458"`)
+
+	status, body = request("/archive/123/456", "alice-token")
+	assert.Equal(t, http.StatusOK, status)
+	assert.Equal(t, "PDF", body)
+
+	status, _ = request("/archive/124/457", "alice-token")
+	assert.Equal(t, http.StatusForbidden, status)
+	status, _ = request("/archive/0/458", "alice-token")
+	assert.Equal(t, http.StatusForbidden, status)
+
+	// Без API-токена архив доступен от имени сервиса
+	status, body = request("/archive/124/457", "")
+	assert.Equal(t, http.StatusOK, status)
+	assert.Equal(t, "PDF", body)
+	mockClient.AssertNumberOfCalls(t, "GetLaunch", 4)
+}
+
+// ✅ Тест для маршрутов `/projects/:projectId`: проект проверяется и передается в сервис
+func TestProjectScopeHandler(t *testing.T) {
+	mockService := new(MockAllureService)
+	app := fiber.New()
+	h := handler.NewAllureHandler(mockService)
+	app.Get("/projects", h.GetProjects)
+	app.Get("/projects/:projectId/launches/:id", h.ProjectScope, h.GetLaunch)
+
+	mockService.On("GetProjects", mock.Anything).Return([]adapter.Project{{ID: 42, Name: "Mobile"}}, nil)
+	mockService.On("CheckProject", mock.Anything, int64(42)).Return(nil)
+	mockService.On("CheckProject", mock.Anything, int64(7)).Return(service.ErrProjectNotAllowed)
+	mockService.On("GetLaunch", mock.Anything, int64(42), int64(5)).Return(&adapter.Launch{ID: 5, ProjectID: 42}, nil)
+
+	resp, err := app.Test(httptest.NewRequest(http.MethodGet, "/projects", nil))
+	assert.NoError(t, err)
+	assert.Equal(t, http.StatusOK, resp.StatusCode)
+	body, _ := io.ReadAll(resp.Body)
+	assert.Contains(t, string(body), `"name":"Mobile"`)
+
+	resp, err = app.Test(httptest.NewRequest(http.MethodGet, "/projects/42/launches/5", nil))
+	assert.NoError(t, err)
+	assert.Equal(t, http.StatusOK, resp.StatusCode)
+
+	resp, err = app.Test(httptest.NewRequest(http.MethodGet, "/projects/7/launches/5", nil))
+	assert.NoError(t, err)
+	assert.Equal(t, http.StatusForbidden, resp.StatusCode)
+
+	resp, err = app.Test(httptest.NewRequest(http.MethodGet, "/projects/abc/launches/5", nil))
+	assert.NoError(t, err)
+	assert.Equal(t, http.StatusBadRequest, resp.StatusCode)
+}
+
+// ✅ **Тест: ID запроса попадает в контекст сервиса и в ответ**
+func TestRequestContextHandler(t *testing.T) {
+	mockService := new(MockAllureService)
+	app := fiber.New()
+	h := handler.NewAllureHandler(mockService)
+	app.Use(handler.RequestContext)
+	app.Get("/projects", h.GetProjects)
+
+	var requestIDs []string
+	mockService.On("GetProjects", mock.Anything).Run(func(args mock.Arguments) {
+		requestIDs = append(requestIDs, reqctx.RequestID(args.Get(0).(context.Context)))
+	}).Return([]adapter.Project{}, nil)
+
+	req := httptest.NewRequest(http.MethodGet, "/projects", nil)
+	req.Header.Set("X-Request-ID", "req-42")
+	resp, err := app.Test(req)
+	assert.NoError(t, err)
+	assert.Equal(t, "req-42", resp.Header.Get("X-Request-ID"))
+
+	// Недопустимый ID заменяется новым
+	req = httptest.NewRequest(http.MethodGet, "/projects", nil)
+	req.Header.Set("X-Request-ID", "bad id\t")
+	resp, err = app.Test(req)
+	assert.NoError(t, err)
+	generated := resp.Header.Get("X-Request-ID")
+	assert.NotEmpty(t, generated)
+	assert.NotEqual(t, "bad id\t", generated)
+
+	assert.Equal(t, []string{"req-42", generated}, requestIDs)
+}
+
+// ✅ **Тест: обрыв соединения клиентом отменяет запрос к Allure**
+func TestRequestContext_ClientDisconnect(t *testing.T) {
+	mockClient := new(MockAllureClient)
+	started := make(chan struct{})
+	cancelled := make(chan error, 1)
+	mockClient.On("SearchLaunches", mock.Anything, mock.Anything).Run(func(args mock.Arguments) {
+		ctx := args.Get(0).(context.Context)
+		close(started)
+		select {
+		case <-ctx.Done():
+			cancelled <- ctx.Err()
+		case <-time.After(5 * time.Second):
+			cancelled <- nil
+		}
+	}).Return(nil, context.Canceled)
+
+	app := fiber.New(fiber.Config{DisableStartupMessage: true})
+	app.Use(handler.RequestContext)
+	app.Get("/launches", handler.NewAllureHandler(service.NewAllureService(mockClient)).GetLaunches)
+
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	assert.NoError(t, err)
+	go app.Listener(listener)
+	defer app.Shutdown()
+
+	conn, err := net.Dial("tcp", listener.Addr().String())
+	assert.NoError(t, err)
+	_, err = io.WriteString(conn, "GET /launches HTTP/1.1\r\nHost: localhost\r\n\r\n")
+	assert.NoError(t, err)
+
+	select {
+	case <-started:
+	case <-time.After(5 * time.Second):
+		t.Fatal("запрос не дошел до клиента Allure")
+	}
+	// Клиент обрывает соединение сбросом
+	assert.NoError(t, conn.(*net.TCPConn).SetLinger(0))
+	conn.Close()
+
+	select {
+	case err := <-cancelled:
+		assert.ErrorIs(t, err, context.Canceled)
+	case <-time.After(5 * time.Second):
+		t.Fatal("запрос к Allure не отменен")
+	}
+}
+
+// ✅ **Тест: клиент, закрывший только свою сторону записи, получает ответ**
+func TestRequestContext_ClientHalfClose(t *testing.T) {
+	mockClient := new(MockAllureClient)
+	ctxErr := make(chan error, 1)
+	mockClient.On("SearchLaunches", mock.Anything, mock.Anything).Run(func(args mock.Arguments) {
+		// Ждем несколько проверок соединения
+		time.Sleep(600 * time.Millisecond)
+		ctxErr <- args.Get(0).(context.Context).Err()
+	}).Return(&adapter.LaunchPage{}, nil)
+
+	app := fiber.New(fiber.Config{DisableStartupMessage: true})
+	app.Use(handler.RequestContext)
+	app.Get("/launches", handler.NewAllureHandler(service.NewAllureService(mockClient)).GetLaunches)
+
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	assert.NoError(t, err)
+	go app.Listener(listener)
+	defer app.Shutdown()
+
+	conn, err := net.Dial("tcp", listener.Addr().String())
+	assert.NoError(t, err)
+	defer conn.Close()
+	_, err = io.WriteString(conn, "GET /launches HTTP/1.1\r\nHost: localhost\r\n\r\n")
+	assert.NoError(t, err)
+	assert.NoError(t, conn.(*net.TCPConn).CloseWrite())
+
+	assert.NoError(t, conn.SetReadDeadline(time.Now().Add(5*time.Second)))
+	resp, err := http.ReadResponse(bufio.NewReader(conn), nil)
+	assert.NoError(t, err)
+	if resp != nil {
+		resp.Body.Close()
+		assert.Equal(t, http.StatusOK, resp.StatusCode)
+	}
+	assert.NoError(t, <-ctxErr)
+}
+
+// ctxReader - поток, который читается, только пока жив контекст запроса
+type ctxReader struct {
+	ctx  context.Context
+	data io.Reader
+}
+
+func (r *ctxReader) Read(p []byte) (int, error) {
+	if err := r.ctx.Err(); err != nil {
+		return 0, err
+	}
+	return r.data.Read(p)
+}
+
+// ✅ **Тест: контекст запроса живет, пока отправляется поток ответа, и отменяется после отправки**
+func TestRequestContext_StreamedResponse(t *testing.T) {
+	mockService := new(MockAllureService)
+	app := fiber.New()
+	app.Use(handler.RequestContext)
+	app.Get("/results/:id/attachments/:attachmentId", handler.NewAllureHandler(mockService).DownloadAttachment)
+
+	body := &ctxReader{data: strings.NewReader("attachment content")}
+	mockService.On("DownloadAttachment", mock.Anything, int64(0), int64(7), int64(3), "").Run(func(args mock.Arguments) {
+		body.ctx = args.Get(0).(context.Context)
+	}).Return(&adapter.FileContent{
+		Body:          io.NopCloser(body),
+		StatusCode:    http.StatusOK,
+		ContentType:   "text/plain",
+		ContentLength: -1,
+		FileName:      "log.txt",
+	}, nil)
+
+	resp, err := app.Test(httptest.NewRequest(http.MethodGet, "/results/7/attachments/3", nil))
+	assert.NoError(t, err)
+	data, err := io.ReadAll(resp.Body)
+	assert.NoError(t, err)
+	assert.Equal(t, "attachment content", string(data))
+
+	assert.Eventually(t, func() bool {
+		return body.ctx.Err() != nil
+	}, time.Second, 10*time.Millisecond)
+}
+
+// ✅ **Тест: разомкнутый автомат защиты превращается в 503, а /health показывает его состояние**
+func TestCircuitOpenHandler(t *testing.T) {
+	mockService := new(MockAllureService)
+	app := fiber.New()
+	h := handler.NewAllureHandler(mockService)
+	app.Get("/launches/:id", h.GetLaunch)
+
+	mockService.On("GetLaunch", mock.Anything, int64(0), int64(5)).Return(nil, fmt.Errorf("запуск 5: %w", adapter.ErrCircuitOpen))
+
+	resp, err := app.Test(httptest.NewRequest(http.MethodGet, "/launches/5", nil))
+	assert.NoError(t, err)
+	assert.Equal(t, http.StatusServiceUnavailable, resp.StatusCode)
+
+	// Состояние автомата - у настоящего клиента, Allure заменен недоступным сервером
+	allure := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusBadGateway)
+	}))
+	defer allure.Close()
+	client := adapter.NewAllureClient(&config.Config{
+		AllureBaseURL:  allure.URL,
+		CircuitBreaker: config.CircuitBreaker{FailureThreshold: 1, OpenTimeout: time.Minute},
+	})
+	app.Get("/health", handler.Health([]handler.InstanceCircuit{{Name: "default", Client: client}}))
+
+	health := func() string {
+		resp, err := app.Test(httptest.NewRequest(http.MethodGet, "/health", nil))
+		assert.NoError(t, err)
+		assert.Equal(t, http.StatusOK, resp.StatusCode)
+		body, _ := io.ReadAll(resp.Body)
+		return string(body)
+	}
+	assert.Contains(t, health(), `"status":"ok"`)
+
+	assert.Error(t, client.Authenticate(context.Background()))
+	body := health()
+	assert.Contains(t, body, `"status":"degraded"`)
+	assert.Contains(t, body, `"state":"open"`)
+}
+
+// ✅ **Тест: API-токен Allure вызывающего передается в сервис, в режиме required он обязателен**
+func TestAllureTokenHandler(t *testing.T) {
+	mockService := new(MockAllureService)
+	app := fiber.New()
+	h := handler.NewAllureHandler(mockService)
+	app.Use(handler.AllureToken(config.TokenPassthrough{Mode: config.TokenPassthroughRequired, Header: "X-Allure-Token"}, "/health"))
+	app.Get("/health", func(c *fiber.Ctx) error { return c.SendString("ok") })
+	app.Get("/launches/:id", h.GetLaunch)
+
+	tokenIs := func(token string) interface{} {
+		return mock.MatchedBy(func(ctx context.Context) bool { return reqctx.AllureToken(ctx) == token })
+	}
+	mockService.On("GetLaunch", tokenIs("alice-token"), int64(0), int64(5)).Return(&adapter.Launch{ID: 5}, nil)
+	mockService.On("GetLaunch", tokenIs("bob-token"), int64(0), int64(5)).Return(nil, fmt.Errorf("запуск 5: %w", adapter.ErrForbidden))
+	mockService.On("GetLaunch", tokenIs("revoked"), int64(0), int64(5)).Return(nil, fmt.Errorf("токен: %w", adapter.ErrUserTokenRejected))
+
+	status := func(token string) int {
+		req := httptest.NewRequest(http.MethodGet, "/launches/5", nil)
+		if token != "" {
+			req.Header.Set("X-Allure-Token", token)
+		}
+		resp, err := app.Test(req)
+		assert.NoError(t, err)
+		return resp.StatusCode
+	}
+	assert.Equal(t, http.StatusOK, status("alice-token"))
+	assert.Equal(t, http.StatusForbidden, status("bob-token"))
+	assert.Equal(t, http.StatusUnauthorized, status("revoked"))
+	assert.Equal(t, http.StatusUnauthorized, status(""))
+	mockService.AssertNumberOfCalls(t, "GetLaunch", 3)
+
+	resp, err := app.Test(httptest.NewRequest(http.MethodGet, "/health", nil))
+	assert.NoError(t, err)
+	assert.Equal(t, http.StatusOK, resp.StatusCode)
+}
+
+// ✅ **Тест: у скачивания файлов своя политика CORS, заголовки безопасности есть во всех ответах**
+func TestCORSAndSecurityHeaders(t *testing.T) {
+	app := fiber.New()
+	app.Use(handler.SecurityHeaders(config.SecurityHeaders{HSTSMaxAge: time.Hour, ContentTypeNosniff: true, FrameOptions: "DENY"}))
+	app.Use(handler.CORS(
+		config.CORS{
+			AllowOrigins:     []string{"https://app.example.com"},
+			AllowMethods:     []string{"GET", "POST"},
+			AllowHeaders:     []string{"Content-Type", "X-API-Key"},
+			AllowCredentials: true,
+		},
+		config.CORS{
+			AllowOrigins:  []string{"*"},
+			AllowMethods:  []string{"GET"},
+			ExposeHeaders: []string{"Content-Disposition", "Content-Range"},
+		},
+	))
+	ok := func(c *fiber.Ctx) error { return c.SendString("ok") }
+	app.Get("/launches", ok)
+	app.Get("/instances/prod/projects/42/export/pdf/download/:id", ok)
+	app.Get("/results/:id/attachments/:attachmentId", ok)
+
+	request := func(method, target, origin string) *http.Response {
+		req := httptest.NewRequest(method, target, nil)
+		req.Header.Set(fiber.HeaderOrigin, origin)
+		if method == http.MethodOptions {
+			req.Header.Set(fiber.HeaderAccessControlRequestMethod, http.MethodGet)
+		}
+		resp, err := app.Test(req)
+		assert.NoError(t, err)
+		return resp
+	}
+
+	// API: только разрешенный источник, с учетными данными
+	resp := request(http.MethodOptions, "/launches", "https://app.example.com")
+	assert.Equal(t, "https://app.example.com", resp.Header.Get(fiber.HeaderAccessControlAllowOrigin))
+	assert.Equal(t, "true", resp.Header.Get(fiber.HeaderAccessControlAllowCredentials))
+	assert.Contains(t, resp.Header.Get(fiber.HeaderAccessControlAllowHeaders), "X-API-Key")
+	resp = request(http.MethodGet, "/launches", "https://evil.example.com")
+	assert.Empty(t, resp.Header.Get(fiber.HeaderAccessControlAllowOrigin))
+
+	// Скачивание: любой источник, заголовки файла доступны скрипту
+	for _, target := range []string{"/instances/prod/projects/42/export/pdf/download/7", "/results/3/attachments/9"} {
+		resp = request(http.MethodGet, target, "https://evil.example.com")
+		assert.Equal(t, "*", resp.Header.Get(fiber.HeaderAccessControlAllowOrigin), target)
+		assert.Contains(t, resp.Header.Get(fiber.HeaderAccessControlExposeHeaders), "Content-Disposition", target)
+	}
+
+	assert.Equal(t, "max-age=3600", resp.Header.Get(fiber.HeaderStrictTransportSecurity))
+	assert.Equal(t, "nosniff", resp.Header.Get(fiber.HeaderXContentTypeOptions))
+	assert.Equal(t, "DENY", resp.Header.Get(fiber.HeaderXFrameOptions))
+	assert.Empty(t, resp.Header.Get(fiber.HeaderReferrerPolicy))
+}
+
+// ✅ Тест маршрутизации по экземплярам Allure: префикс /instances/:name и маршруты экземпляра по умолчанию
+func TestInstanceRoutes(t *testing.T) {
+	mainClient := new(MockAllureClient)
+	stagingClient := new(MockAllureClient)
+	mainClient.On("SearchLaunches", mock.Anything, mock.Anything).
+		Return(&adapter.LaunchPage{Content: []adapter.Launch{{ID: 1, Name: "main nightly"}}, TotalPages: 1}, nil)
+	stagingClient.On("SearchLaunches", mock.Anything, mock.Anything).
+		Return(&adapter.LaunchPage{Content: []adapter.Launch{{ID: 2, Name: "staging nightly"}}, TotalPages: 1}, nil)
+
+	app := fiber.New()
+	handler.RegisterInstanceRoutes(app, "main", true, handler.NewAllureHandler(service.NewAllureService(mainClient)))
+	handler.RegisterInstanceRoutes(app, "staging", false, handler.NewAllureHandler(service.NewAllureService(stagingClient)))
+
+	for path, expected := range map[string]string{
+		"/launches":                              "main nightly",
+		"/instances/main/launches":               "main nightly",
+		"/instances/staging/launches":            "staging nightly",
+		"/instances/staging/projects/7/launches": "staging nightly",
+	} {
+		resp, err := app.Test(httptest.NewRequest(http.MethodGet, path, nil))
+		assert.NoError(t, err)
+		assert.Equal(t, http.StatusOK, resp.StatusCode, path)
+		body, _ := io.ReadAll(resp.Body)
+		assert.Contains(t, string(body), expected, path)
+	}
+
+	resp, err := app.Test(httptest.NewRequest(http.MethodGet, "/instances/unknown/launches", nil))
+	assert.NoError(t, err)
+	assert.Equal(t, http.StatusNotFound, resp.StatusCode)
+
+	mainClient.AssertNumberOfCalls(t, "SearchLaunches", 2)
+	stagingClient.AssertNumberOfCalls(t, "SearchLaunches", 2)
+	stagingClient.AssertCalled(t, "SearchLaunches", mock.Anything, mock.MatchedBy(func(query adapter.LaunchQuery) bool {
+		return query.ProjectID == 7
+	}))
+}
@@ -3,6 +3,7 @@ package test
 import (
 	"context"
 	"errors"
+	"io"
 	"net/http"
 	"net/http/httptest"
 	"strings"
@@ -25,7 +26,7 @@ func TestIntegrationGetNextLaunch(t *testing.T) {
 	app := fiber.New()
 	app.Get("/next-launch", handler.GetNextLaunch)
 
-	// 📌 **Добавляем мок `GetLaunches()`**
+	// 📌 **Добавляем мок `SearchLaunches()`**
 	mockLaunches := []adapter.Launch{
 		{
 			ID:          123,
@@ -33,7 +34,7 @@ func TestIntegrationGetNextLaunch(t *testing.T) {
 			CreatedDate: time.Now().UnixMilli(),
 		},
 	}
-	mockClient.On("GetLaunches", mock.Anything).Return(mockLaunches, nil)
+	mockClient.On("SearchLaunches", mock.Anything, mock.Anything).Return(&adapter.LaunchPage{Content: mockLaunches}, nil)
 
 	// 🏃‍♂️ Выполняем тестовый запрос
 	req := httptest.NewRequest(http.MethodGet, "/next-launch?after=2024-02-01T12:00:00Z", nil)
@@ -52,8 +53,8 @@ func TestIntegrationGetNextLaunch_NotFound(t *testing.T) {
 	app := fiber.New()
 	app.Get("/next-launch", handler.GetNextLaunch)
 
-	// 📌 **Мокаем `GetLaunches()` без данных**
-	mockClient.On("GetLaunches", mock.Anything).Return([]adapter.Launch{}, nil)
+	// 📌 **Мокаем `SearchLaunches()` без данных**
+	mockClient.On("SearchLaunches", mock.Anything, mock.Anything).Return(&adapter.LaunchPage{Content: []adapter.Launch{}}, nil)
 
 	// 🏃‍♂️ Выполняем тест
 	req := httptest.NewRequest(http.MethodGet, "/next-launch?after=2024-02-01T12:00:00Z", nil)
@@ -64,7 +65,7 @@ func TestIntegrationGetNextLaunch_NotFound(t *testing.T) {
 	mockClient.AssertExpectations(t)
 }
 
-// ✅ **Тест ошибки `GetLaunches()`**
+// ✅ **Тест ошибки `SearchLaunches()`**
 func TestIntegrationGetNextLaunch_Error(t *testing.T) {
 	mockClient := new(MockAllureClient)
 	service := service.NewAllureService(mockClient)
@@ -72,8 +73,8 @@ func TestIntegrationGetNextLaunch_Error(t *testing.T) {
 	app := fiber.New()
 	app.Get("/next-launch", handler.GetNextLaunch)
 
-	// 📌 **Мокаем ошибку в `GetLaunches()` (возвращаем пустой массив вместо nil!)**
-	mockClient.On("GetLaunches", mock.Anything).Return([]adapter.Launch{}, errors.New("ошибка API"))
+	// 📌 **Мокаем ошибку в `SearchLaunches()`**
+	mockClient.On("SearchLaunches", mock.Anything, mock.Anything).Return(&adapter.LaunchPage{Content: []adapter.Launch{}}, errors.New("ошибка API"))
 
 	// 🏃‍♂️ Выполняем тест
 	req := httptest.NewRequest(http.MethodGet, "/next-launch?after=2024-02-01T12:00:00Z", nil)
@@ -88,7 +89,7 @@ func TestMockGeneratePDFReport(t *testing.T) {
 	mockClient := new(MockAllureClient)
 
 	// 🔹 Мокаем `GeneratePDFReport`
-	mockClient.On("GeneratePDFReport", mock.Anything, int64(123), "Test Run").
+	mockClient.On("GeneratePDFReport", mock.Anything, int64(123), "Test Run", mock.Anything).
 		Return(&adapter.PDFReport{
 			ID:          456,
 			Name:        "Test Report",
@@ -98,7 +99,7 @@ func TestMockGeneratePDFReport(t *testing.T) {
 		}, nil)
 
 	// 🏃‍♂️ Вызываем `GeneratePDFReport`
-	report, err := mockClient.GeneratePDFReport(context.TODO(), 123, "Test Run")
+	report, err := mockClient.GeneratePDFReport(context.TODO(), 123, "Test Run", adapter.DefaultPDFExportOptions())
 
 	// ✅ Проверяем результат
 	assert.NoError(t, err)
@@ -135,15 +136,15 @@ func TestMockDownloadPDFReport(t *testing.T) {
 
 	// 🔹 Мокаем `DownloadPDFReport`
 	mockClient.On("DownloadPDFReport", mock.Anything, "456").
-		Return(pdfData, fileName, nil)
+		Return(newPDFContent(string(pdfData), fileName), nil)
 
 	// 🏃‍♂️ Вызываем `DownloadPDFReport`
-	data, name, err := mockClient.DownloadPDFReport(context.TODO(), "456")
+	content, err := mockClient.DownloadPDFReport(context.TODO(), "456")
 
 	// ✅ Проверяем результат
 	assert.NoError(t, err)
-	assert.NotNil(t, data)
-	assert.Equal(t, fileName, name)
+	assert.NotNil(t, content.Body)
+	assert.Equal(t, fileName, content.FileName)
 
 	// ✅ Проверяем, что мок был вызван
 	mockClient.AssertExpectations(t)
@@ -153,21 +154,29 @@ func TestFullPDFFlow(t *testing.T) {
 	// Инициализация приложения
 	app := fiber.New()
 	mockClient := new(MockAllureClient)
-	service := service.NewAllureService(mockClient)
+	service := service.NewAllureService(mockClient, service.WithExportPolling(service.ExportPolling{
+		Interval:     10 * time.Millisecond,
+		Timeout:      time.Second,
+		DownloadWait: 500 * time.Millisecond,
+	}))
 	handler := handler.NewAllureHandler(service)
 
 	app.Post("/export/pdf/:id", handler.GeneratePDFReport)
+	app.Get("/export/:id/status", handler.GetExportStatus)
 	app.Get("/export/pdf/download/:id", handler.DownloadPDFReport)
 
-	// Мокаем успешный поток
-	mockClient.On("GeneratePDFReport", mock.Anything, int64(123), "Test Run").
-		Return(&adapter.PDFReport{ID: 456}, nil)
+	// Мокаем успешный поток: отчет формируется асинхронно
+	mockClient.On("GeneratePDFReport", mock.Anything, int64(123), "Test Run", mock.Anything).
+		Return(&adapter.PDFReport{ID: 456, Status: "IN_PROGRESS"}, nil)
+
+	mockClient.On("GetPDFReport", mock.Anything, "456").
+		Return(&adapter.PDFReport{ID: 456, Status: "DONE"}, nil)
 
 	mockClient.On("GetPDFDownloadLink", "456").
 		Return("http://mocked.url/download/456")
 
 	mockClient.On("DownloadPDFReport", mock.Anything, "456").
-		Return([]byte("PDF content"), "report.pdf", nil)
+		Return(newPDFContent("PDF content", "report.pdf"), nil)
 
 	// Шаг 1: Генерация отчета
 	reqGen := httptest.NewRequest("POST", "/export/pdf/123", strings.NewReader(
@@ -177,9 +186,17 @@ func TestFullPDFFlow(t *testing.T) {
 	respGen, _ := app.Test(reqGen)
 	assert.Equal(t, http.StatusOK, respGen.StatusCode)
 
-	// Шаг 2: Скачивание отчета
+	// Шаг 2: Проверка статуса
+	reqStatus := httptest.NewRequest("GET", "/export/456/status", nil)
+	respStatus, _ := app.Test(reqStatus)
+	assert.Equal(t, http.StatusOK, respStatus.StatusCode)
+
+	// Шаг 3: Скачивание отчета
 	reqDown := httptest.NewRequest("GET", "/export/pdf/download/456", nil)
 	respDown, _ := app.Test(reqDown)
 	assert.Equal(t, http.StatusOK, respDown.StatusCode)
 	assert.Equal(t, "application/pdf", respDown.Header.Get("Content-Type"))
+	assert.Equal(t, "11", respDown.Header.Get("Content-Length"))
+	body, _ := io.ReadAll(respDown.Body)
+	assert.Equal(t, "PDF content", string(body))
 }
@@ -2,11 +2,16 @@ package test
 
 import (
 	"context"
+	"io"
+	"net/http"
+	"strings"
 	"time"
 
 	"github.com/stretchr/testify/mock"
 
 	"github.com/vkr-mtuci/allure-service/internal/adapter"
+	"github.com/vkr-mtuci/allure-service/internal/archive"
+	"github.com/vkr-mtuci/allure-service/internal/service"
 )
 
 // MockAllureClient - мок-клиент Allure API
@@ -19,16 +24,133 @@ func (m *MockAllureClient) Authenticate(ctx context.Context) error {
 	return args.Error(0)
 }
 
-func (m *MockAllureClient) GetLaunches(ctx context.Context) ([]adapter.Launch, error) {
+func (m *MockAllureClient) GetProjects(ctx context.Context) ([]adapter.Project, error) {
 	args := m.Called(ctx)
+	if projects, ok := args.Get(0).([]adapter.Project); ok {
+		return projects, args.Error(1)
+	}
+	return nil, args.Error(1)
+}
+
+func (m *MockAllureClient) GetLaunches(ctx context.Context, query adapter.LaunchQuery) ([]adapter.Launch, error) {
+	args := m.Called(ctx, query)
 	if launches, ok := args.Get(0).([]adapter.Launch); ok {
 		return launches, args.Error(1)
 	}
 	return nil, args.Error(1)
 }
 
-func (m *MockAllureClient) GeneratePDFReport(ctx context.Context, launchID int64, launchName string) (*adapter.PDFReport, error) {
-	args := m.Called(ctx, launchID, launchName)
+func (m *MockAllureClient) IterateLaunches(ctx context.Context, query adapter.LaunchQuery, fn func(adapter.Launch) bool) error {
+	args := m.Called(ctx, query, fn)
+	if launches, ok := args.Get(0).([]adapter.Launch); ok {
+		for _, launch := range launches {
+			if !fn(launch) {
+				break
+			}
+		}
+	}
+	return args.Error(1)
+}
+
+func (m *MockAllureClient) SearchLaunches(ctx context.Context, query adapter.LaunchQuery) (*adapter.LaunchPage, error) {
+	args := m.Called(ctx, query)
+	if page, ok := args.Get(0).(*adapter.LaunchPage); ok {
+		return page, args.Error(1)
+	}
+	return nil, args.Error(1)
+}
+
+func (m *MockAllureClient) GetLaunch(ctx context.Context, launchID int64) (*adapter.Launch, error) {
+	args := m.Called(ctx, launchID)
+	if launch, ok := args.Get(0).(*adapter.Launch); ok {
+		return launch, args.Error(1)
+	}
+	return nil, args.Error(1)
+}
+
+func (m *MockAllureClient) GetLaunchStatistic(ctx context.Context, launchID int64) (*adapter.LaunchStatistic, error) {
+	args := m.Called(ctx, launchID)
+	if statistic, ok := args.Get(0).(*adapter.LaunchStatistic); ok {
+		return statistic, args.Error(1)
+	}
+	return nil, args.Error(1)
+}
+
+func (m *MockAllureClient) GetLaunchDuration(ctx context.Context, launchID int64) (int64, error) {
+	args := m.Called(ctx, launchID)
+	return args.Get(0).(int64), args.Error(1)
+}
+
+func (m *MockAllureClient) GetLaunchEnvironment(ctx context.Context, launchID int64) ([]adapter.EnvVarValue, error) {
+	args := m.Called(ctx, launchID)
+	if environment, ok := args.Get(0).([]adapter.EnvVarValue); ok {
+		return environment, args.Error(1)
+	}
+	return nil, args.Error(1)
+}
+
+func (m *MockAllureClient) GetLaunchJobRun(ctx context.Context, launchID int64) (*adapter.JobRun, error) {
+	args := m.Called(ctx, launchID)
+	if jobRun, ok := args.Get(0).(*adapter.JobRun); ok {
+		return jobRun, args.Error(1)
+	}
+	return nil, args.Error(1)
+}
+
+func (m *MockAllureClient) SearchTestResults(ctx context.Context, query adapter.TestResultQuery) (*adapter.TestResultPage, error) {
+	args := m.Called(ctx, query)
+	if page, ok := args.Get(0).(*adapter.TestResultPage); ok {
+		return page, args.Error(1)
+	}
+	return nil, args.Error(1)
+}
+
+func (m *MockAllureClient) IterateTestResults(ctx context.Context, query adapter.TestResultQuery, fn func(adapter.TestResult) bool) error {
+	args := m.Called(ctx, query, fn)
+	if results, ok := args.Get(0).([]adapter.TestResult); ok {
+		for _, result := range results {
+			if !fn(result) {
+				break
+			}
+		}
+	}
+	return args.Error(1)
+}
+
+func (m *MockAllureClient) GetTestResult(ctx context.Context, resultID int64) (*adapter.TestResult, error) {
+	args := m.Called(ctx, resultID)
+	if result, ok := args.Get(0).(*adapter.TestResult); ok {
+		return result, args.Error(1)
+	}
+	return nil, args.Error(1)
+}
+
+func (m *MockAllureClient) GetTestResultExecution(ctx context.Context, resultID int64) (*adapter.Step, error) {
+	args := m.Called(ctx, resultID)
+	if execution, ok := args.Get(0).(*adapter.Step); ok {
+		return execution, args.Error(1)
+	}
+	return nil, args.Error(1)
+}
+
+func (m *MockAllureClient) DownloadAttachment(ctx context.Context, attachmentID int64, rangeHeader string) (*adapter.FileContent, error) {
+	args := m.Called(ctx, attachmentID, rangeHeader)
+	if content, ok := args.Get(0).(*adapter.FileContent); ok {
+		return content, args.Error(1)
+	}
+	return nil, args.Error(1)
+}
+
+func (m *MockAllureClient) GeneratePDFReport(ctx context.Context, launchID int64, launchName string, opts adapter.PDFExportOptions) (*adapter.PDFReport, error) {
+	args := m.Called(ctx, launchID, launchName, opts)
+	if report, ok := args.Get(0).(*adapter.PDFReport); ok {
+		return report, args.Error(1)
+	}
+	return nil, args.Error(1)
+}
+
+func (m *MockAllureClient) GetPDFReport(ctx context.Context, reportID string) (*adapter.PDFReport, error) {
+	args := m.Called(ctx, reportID)
 	if report, ok := args.Get(0).(*adapter.PDFReport); ok {
 		return report, args.Error(1)
 	}
@@ -40,12 +162,23 @@ func (m *MockAllureClient) GetPDFDownloadLink(reportID string) string {
 	return args.String(0)
 }
 
-func (m *MockAllureClient) DownloadPDFReport(ctx context.Context, reportID string) ([]byte, string, error) {
+func (m *MockAllureClient) DownloadPDFReport(ctx context.Context, reportID string) (*adapter.FileContent, error) {
 	args := m.Called(ctx, reportID)
-	if data, ok := args.Get(0).([]byte); ok {
-		return data, args.String(1), args.Error(2)
+	if content, ok := args.Get(0).(*adapter.FileContent); ok {
+		return content, args.Error(1)
+	}
+	return nil, args.Error(1)
+}
+
+// newPDFContent - поток PDF-отчета для моков скачивания; читается один раз
+func newPDFContent(data, fileName string) *adapter.FileContent {
+	return &adapter.FileContent{
+		Body:          io.NopCloser(strings.NewReader(data)),
+		StatusCode:    http.StatusOK,
+		ContentType:   "application/pdf",
+		ContentLength: int64(len(data)),
+		FileName:      fileName,
 	}
-	return nil, "", args.Error(2)
 }
 
 // MockAllureService - мок-сервис для AllureService
@@ -53,24 +186,111 @@ type MockAllureService struct {
 	mock.Mock
 }
 
+// GetProjects - мок-метод получения доступных проектов
+func (m *MockAllureService) GetProjects(ctx context.Context) ([]adapter.Project, error) {
+	args := m.Called(ctx)
+	if projects, ok := args.Get(0).([]adapter.Project); ok {
+		return projects, args.Error(1)
+	}
+	return nil, args.Error(1)
+}
+
+// CheckProject - мок-метод проверки проекта
+func (m *MockAllureService) CheckProject(ctx context.Context, projectID int64) error {
+	args := m.Called(ctx, projectID)
+	return args.Error(0)
+}
+
 // GetNextLaunch - мок-метод поиска ближайшего запуска
-func (m *MockAllureService) GetNextLaunch(afterDate time.Time) (*adapter.Launch, error) {
-	args := m.Called(afterDate)
+func (m *MockAllureService) GetNextLaunch(ctx context.Context, projectID int64, afterDate time.Time) (*adapter.Launch, error) {
+	args := m.Called(ctx, projectID, afterDate)
 	if launch, ok := args.Get(0).(*adapter.Launch); ok {
 		return launch, args.Error(1)
 	}
 	return nil, args.Error(1)
 }
 
+// GetLaunches - мок-метод получения страницы запусков
+func (m *MockAllureService) GetLaunches(ctx context.Context, query adapter.LaunchQuery) (*adapter.LaunchPage, error) {
+	args := m.Called(ctx, query)
+	if page, ok := args.Get(0).(*adapter.LaunchPage); ok {
+		return page, args.Error(1)
+	}
+	return nil, args.Error(1)
+}
+
+// GetLaunch - мок-метод получения запуска с подробностями
+func (m *MockAllureService) GetLaunch(ctx context.Context, projectID, launchID int64) (*adapter.Launch, error) {
+	args := m.Called(ctx, projectID, launchID)
+	if launch, ok := args.Get(0).(*adapter.Launch); ok {
+		return launch, args.Error(1)
+	}
+	return nil, args.Error(1)
+}
+
+// GetTestResults - мок-метод получения результатов тестов запуска
+func (m *MockAllureService) GetTestResults(ctx context.Context, projectID int64, query adapter.TestResultQuery) (*adapter.TestResultPage, error) {
+	args := m.Called(ctx, projectID, query)
+	if page, ok := args.Get(0).(*adapter.TestResultPage); ok {
+		return page, args.Error(1)
+	}
+	return nil, args.Error(1)
+}
+
+// GetTestResult - мок-метод получения результата теста
+func (m *MockAllureService) GetTestResult(ctx context.Context, projectID, resultID int64) (*adapter.TestResult, error) {
+	args := m.Called(ctx, projectID, resultID)
+	if result, ok := args.Get(0).(*adapter.TestResult); ok {
+		return result, args.Error(1)
+	}
+	return nil, args.Error(1)
+}
+
+// DownloadAttachment - мок-метод скачивания вложения
+func (m *MockAllureService) DownloadAttachment(ctx context.Context, projectID, resultID, attachmentID int64, rangeHeader string) (*adapter.FileContent, error) {
+	args := m.Called(ctx, projectID, resultID, attachmentID, rangeHeader)
+	if content, ok := args.Get(0).(*adapter.FileContent); ok {
+		return content, args.Error(1)
+	}
+	return nil, args.Error(1)
+}
+
 // GeneratePDFReport - мок-метод генерации PDF
-func (m *MockAllureService) GeneratePDFReport(launchID int64, launchName string) (*adapter.PDFReport, error) {
-	args := m.Called(launchID, launchName)
+func (m *MockAllureService) GeneratePDFReport(ctx context.Context, projectID, launchID int64, launchName string, opts adapter.PDFExportOptions) (*adapter.PDFReport, error) {
+	args := m.Called(ctx, projectID, launchID, launchName, opts)
 	if report, ok := args.Get(0).(*adapter.PDFReport); ok {
 		return report, args.Error(1)
 	}
 	return nil, args.Error(1)
 }
 
+// ExportLaunchResults - мок-метод табличной выгрузки результатов
+func (m *MockAllureService) ExportLaunchResults(ctx context.Context, projectID, launchID int64, format string) (*service.ResultsExport, error) {
+	args := m.Called(ctx, projectID, launchID, format)
+	if results, ok := args.Get(0).(*service.ResultsExport); ok {
+		return results, args.Error(1)
+	}
+	return nil, args.Error(1)
+}
+
+// ExportLaunchJUnit - мок-метод JUnit-выгрузки результатов
+func (m *MockAllureService) ExportLaunchJUnit(ctx context.Context, projectID, launchID int64) (*service.ResultsExport, error) {
+	args := m.Called(ctx, projectID, launchID)
+	if results, ok := args.Get(0).(*service.ResultsExport); ok {
+		return results, args.Error(1)
+	}
+	return nil, args.Error(1)
+}
+
+// GetExportStatus - мок-метод получения статуса экспорта
+func (m *MockAllureService) GetExportStatus(ctx context.Context, projectID int64, reportID string) (*service.ExportJob, error) {
+	args := m.Called(ctx, projectID, reportID)
+	if job, ok := args.Get(0).(*service.ExportJob); ok {
+		return job, args.Error(1)
+	}
+	return nil, args.Error(1)
+}
+
 // GetPDFDownloadLink - мок-метод получения ссылки PDF
 func (m *MockAllureService) GetPDFDownloadLink(reportID string) string {
 	args := m.Called(reportID)
@@ -78,10 +298,31 @@ func (m *MockAllureService) GetPDFDownloadLink(reportID string) string {
 }
 
 // DownloadPDFReport - мок-метод скачивания PDF
-func (m *MockAllureService) DownloadPDFReport(reportID string) ([]byte, string, error) {
-	args := m.Called(reportID)
-	if fileData, ok := args.Get(0).([]byte); ok {
-		return fileData, args.String(1), args.Error(2)
+func (m *MockAllureService) DownloadPDFReport(ctx context.Context, projectID int64, reportID string) (*service.PDFDownload, error) {
+	args := m.Called(ctx, projectID, reportID)
+	if download, ok := args.Get(0).(func(string) *service.PDFDownload); ok {
+		return download(reportID), args.Error(1)
+	}
+	if download, ok := args.Get(0).(*service.PDFDownload); ok {
+		return download, args.Error(1)
+	}
+	return nil, args.Error(1)
+}
+
+// ListArchivedReports - мок-метод получения списка архивных отчетов
+func (m *MockAllureService) ListArchivedReports(ctx context.Context, launchID int64) ([]archive.Report, error) {
+	args := m.Called(ctx, launchID)
+	if reports, ok := args.Get(0).([]archive.Report); ok {
+		return reports, args.Error(1)
 	}
-	return nil, "", args.Error(2)
+	return nil, args.Error(1)
+}
+
+// OpenArchivedReport - мок-метод скачивания архивного отчета
+func (m *MockAllureService) OpenArchivedReport(ctx context.Context, launchID int64, reportID string) (*service.PDFDownload, error) {
+	args := m.Called(ctx, launchID, reportID)
+	if download, ok := args.Get(0).(*service.PDFDownload); ok {
+		return download, args.Error(1)
+	}
+	return nil, args.Error(1)
 }
@@ -0,0 +1,133 @@
+package test
+
+import (
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/vkr-mtuci/allure-service/internal/reportcache"
+)
+
+// ✅ **Тест: Отчет сохраняется и читается из кэша с ETag по содержимому**
+func TestReportCache_PutGet(t *testing.T) {
+	store, err := reportcache.New(t.TempDir(), 1024)
+	assert.NoError(t, err)
+
+	entry, err := store.Put("456", "allure-report-456.pdf", strings.NewReader("PDF content"))
+	assert.NoError(t, err)
+	assert.Equal(t, int64(11), entry.Size)
+	assert.Len(t, entry.Checksum, 64)
+	assert.Equal(t, `"`+entry.Checksum+`"`, entry.ETag())
+
+	data, cached := readCachedReport(t, store, "456")
+	assert.Equal(t, "PDF content", string(data))
+	assert.Equal(t, "allure-report-456.pdf", cached.FileName)
+
+	_, ok := store.Get("457")
+	assert.False(t, ok)
+}
+
+// ✅ **Тест: Давно не использованные отчеты вытесняются при превышении лимита**
+func TestReportCache_LRUEviction(t *testing.T) {
+	store, err := reportcache.New(t.TempDir(), 25)
+	assert.NoError(t, err)
+
+	_, err = store.Put("1", "1.pdf", strings.NewReader("0123456789"))
+	assert.NoError(t, err)
+	time.Sleep(time.Millisecond)
+	_, err = store.Put("2", "2.pdf", strings.NewReader("abcdefghij"))
+	assert.NoError(t, err)
+	time.Sleep(time.Millisecond)
+
+	// Обращение к первому отчету делает второй самым старым
+	_, ok := store.Get("1")
+	assert.True(t, ok)
+	time.Sleep(time.Millisecond)
+
+	_, err = store.Put("3", "3.pdf", strings.NewReader("ABCDEFGHIJ"))
+	assert.NoError(t, err)
+
+	_, ok = store.Get("2")
+	assert.False(t, ok)
+	_, ok = store.Get("1")
+	assert.True(t, ok)
+	_, ok = store.Get("3")
+	assert.True(t, ok)
+}
+
+// ✅ **Тест: Одинаковое содержимое хранится один раз**
+func TestReportCache_ContentAddressed(t *testing.T) {
+	dir := t.TempDir()
+	store, err := reportcache.New(dir, 1024)
+	assert.NoError(t, err)
+
+	first, err := store.Put("1", "1.pdf", strings.NewReader("same"))
+	assert.NoError(t, err)
+	second, err := store.Put("2", "2.pdf", strings.NewReader("same"))
+	assert.NoError(t, err)
+	assert.Equal(t, first.Checksum, second.Checksum)
+
+	blobs, err := os.ReadDir(filepath.Join(dir, "blobs"))
+	assert.NoError(t, err)
+	assert.Len(t, blobs, 1)
+}
+
+// ❌ **Тест: Отчет больше лимита не кэшируется**
+func TestReportCache_TooLarge(t *testing.T) {
+	store, err := reportcache.New(t.TempDir(), 4)
+	assert.NoError(t, err)
+
+	_, err = store.Put("1", "1.pdf", strings.NewReader("too large"))
+	assert.ErrorIs(t, err, reportcache.ErrTooLarge)
+
+	_, ok := store.Get("1")
+	assert.False(t, ok)
+}
+
+// ✅ **Тест: Кэш восстанавливается после перезапуска**
+func TestReportCache_Reload(t *testing.T) {
+	dir := t.TempDir()
+	store, err := reportcache.New(dir, 1024)
+	assert.NoError(t, err)
+
+	entry, err := store.Put("456", "allure-report-456.pdf", strings.NewReader("PDF content"))
+	assert.NoError(t, err)
+
+	// Файл, не попавший в индекс, удаляется при открытии
+	assert.NoError(t, os.WriteFile(filepath.Join(dir, "blobs", "orphan"), []byte("x"), 0o644))
+
+	reopened, err := reportcache.New(dir, 1024)
+	assert.NoError(t, err)
+
+	data, cached := readCachedReport(t, reopened, "456")
+	assert.Equal(t, "PDF content", string(data))
+	assert.Equal(t, entry.ETag(), cached.ETag())
+
+	_, err = os.Stat(filepath.Join(dir, "blobs", "orphan"))
+	assert.True(t, os.IsNotExist(err))
+}
+
+// readCachedReport - читает отчет из кэша целиком
+func readCachedReport(t *testing.T, store *reportcache.Store, reportID string) ([]byte, *reportcache.Entry) {
+	t.Helper()
+
+	entry, ok := store.Get(reportID)
+	if !assert.True(t, ok) {
+		return nil, nil
+	}
+
+	file, err := store.Open(entry)
+	if !assert.NoError(t, err) {
+		return nil, entry
+	}
+	defer file.Close()
+
+	data, err := io.ReadAll(file)
+	assert.NoError(t, err)
+	return data, entry
+}
@@ -1,13 +1,23 @@
 package test
 
 import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
 	"errors"
+	"io"
+	"os"
+	"strings"
 	"testing"
 	"time"
 
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/mock"
 	"github.com/vkr-mtuci/allure-service/internal/adapter"
+	"github.com/vkr-mtuci/allure-service/internal/archive"
+	"github.com/vkr-mtuci/allure-service/internal/reportcache"
+	"github.com/vkr-mtuci/allure-service/internal/reqctx"
 	"github.com/vkr-mtuci/allure-service/internal/service"
 )
 
@@ -16,28 +26,45 @@ func TestGetNextLaunch_Success(t *testing.T) {
 	mockClient := new(MockAllureClient)
 	service := service.NewAllureService(mockClient)
 
+	// Allure возвращает только ближайший запуск после переданной даты
 	now := time.Now().UnixMilli()
 	mockLaunches := []adapter.Launch{
-		{ID: 101, Name: "Older Run", CreatedDate: now - 50000},
-		{ID: 102, Name: "Latest Run", CreatedDate: now + 1000}, // Ближайший запуск после переданной даты
+		{ID: 102, Name: "Latest Run", CreatedDate: now + 1000},
 	}
 
-	mockClient.On("GetLaunches", mock.Anything).Return(mockLaunches, nil)
+	mockClient.On("SearchLaunches", mock.Anything, mock.Anything).Return(&adapter.LaunchPage{Content: mockLaunches}, nil)
 
-	launch, err := service.GetNextLaunch(time.Now()) // Передаем текущее время, а не -2 часа
+	launch, err := service.GetNextLaunch(context.Background(), 0, time.Now()) // Передаем текущее время, а не -2 часа
 	assert.NoError(t, err)
 	assert.NotNil(t, launch)
 	assert.Equal(t, int64(102), launch.ID) // Теперь этот запуск действительно ближайший
 }
 
+// ✅ **Тест: Фильтр и сортировка передаются в адаптер**
+func TestGetNextLaunch_ServerSideQuery(t *testing.T) {
+	mockClient := new(MockAllureClient)
+	service := service.NewAllureService(mockClient)
+
+	afterDate := time.Date(2025, 1, 30, 22, 0, 0, 0, time.UTC)
+	expectedQuery := adapter.LaunchQuery{From: afterDate, Sort: "createdDate,asc", Size: 1}
+	mockLaunches := []adapter.Launch{{ID: 201, Name: "Next Run", CreatedDate: afterDate.Add(time.Minute).UnixMilli()}}
+
+	mockClient.On("SearchLaunches", mock.Anything, expectedQuery).Return(&adapter.LaunchPage{Content: mockLaunches}, nil)
+
+	launch, err := service.GetNextLaunch(context.Background(), 0, afterDate)
+	assert.NoError(t, err)
+	assert.Equal(t, int64(201), launch.ID)
+	mockClient.AssertExpectations(t)
+}
+
 // ❌ **Тест: Нет подходящих запусков**
 func TestGetNextLaunch_NoLaunches(t *testing.T) {
 	mockClient := new(MockAllureClient)
 	service := service.NewAllureService(mockClient)
 
-	mockClient.On("GetLaunches", mock.Anything).Return([]adapter.Launch{}, nil)
+	mockClient.On("SearchLaunches", mock.Anything, mock.Anything).Return(&adapter.LaunchPage{Content: []adapter.Launch{}}, nil)
 
-	launch, err := service.GetNextLaunch(time.Now().Add(-1 * time.Hour))
+	launch, err := service.GetNextLaunch(context.Background(), 0, time.Now().Add(-1*time.Hour))
 	assert.Error(t, err)
 	assert.Nil(t, launch)
 }
@@ -47,14 +74,233 @@ func TestGetNextLaunch_APIError(t *testing.T) {
 	mockClient := new(MockAllureClient)
 	service := service.NewAllureService(mockClient)
 
-	// ✅ Возвращаем **пустой** слайс `[]adapter.Launch{}` вместо `nil`
-	mockClient.On("GetLaunches", mock.Anything).Return([]adapter.Launch{}, errors.New("ошибка API"))
+	mockClient.On("SearchLaunches", mock.Anything, mock.Anything).Return(&adapter.LaunchPage{Content: []adapter.Launch{}}, errors.New("ошибка API"))
 
-	launch, err := service.GetNextLaunch(time.Now().Add(-1 * time.Hour))
+	launch, err := service.GetNextLaunch(context.Background(), 0, time.Now().Add(-1*time.Hour))
 	assert.Error(t, err)
 	assert.Nil(t, launch)
 }
 
+// ✅ **Тест: Получение страницы запусков**
+func TestGetLaunches_Page(t *testing.T) {
+	mockClient := new(MockAllureClient)
+	service := service.NewAllureService(mockClient)
+
+	query := adapter.LaunchQuery{Name: "nightly", Page: 1, Size: 10}
+	mockClient.On("SearchLaunches", mock.Anything, query).Return(&adapter.LaunchPage{
+		Content:    []adapter.Launch{{ID: 11, Name: "nightly #11"}},
+		TotalPages: 2,
+		Number:     1,
+	}, nil)
+
+	page, err := service.GetLaunches(context.Background(), query)
+	assert.NoError(t, err)
+	assert.Len(t, page.Content, 1)
+	assert.Equal(t, 2, page.TotalPages)
+}
+
+// ❌ **Тест: Ошибка получения страницы запусков**
+func TestGetLaunches_Error(t *testing.T) {
+	mockClient := new(MockAllureClient)
+	service := service.NewAllureService(mockClient)
+
+	mockClient.On("SearchLaunches", mock.Anything, mock.Anything).Return(nil, errors.New("ошибка API"))
+
+	page, err := service.GetLaunches(context.Background(), adapter.LaunchQuery{})
+	assert.Error(t, err)
+	assert.Nil(t, page)
+}
+
+// ✅ **Тест: Запуск собирается из всех API Allure**
+func TestGetLaunch_Details(t *testing.T) {
+	mockClient := new(MockAllureClient)
+	service := service.NewAllureService(mockClient)
+
+	statistic := &adapter.LaunchStatistic{Passed: 5, Failed: 1, Total: 6}
+	environment := []adapter.EnvVarValue{{Name: "stand", Value: "staging"}}
+	jobRun := &adapter.JobRun{ID: 7, Name: "nightly", URL: "https://ci.example.com/7"}
+
+	mockClient.On("GetLaunch", mock.Anything, int64(42)).Return(&adapter.Launch{ID: 42, Name: "Nightly", Closed: true}, nil)
+	mockClient.On("GetLaunchStatistic", mock.Anything, int64(42)).Return(statistic, nil)
+	mockClient.On("GetLaunchDuration", mock.Anything, int64(42)).Return(int64(60000), nil)
+	mockClient.On("GetLaunchEnvironment", mock.Anything, int64(42)).Return(environment, nil)
+	mockClient.On("GetLaunchJobRun", mock.Anything, int64(42)).Return(jobRun, nil)
+
+	launch, err := service.GetLaunch(context.Background(), 0, 42)
+	assert.NoError(t, err)
+	assert.Equal(t, statistic, launch.Statistic)
+	assert.Equal(t, int64(60000), launch.Duration)
+	assert.Equal(t, environment, launch.Environment)
+	assert.Equal(t, jobRun, launch.JobRun)
+	assert.True(t, launch.Closed)
+	mockClient.AssertExpectations(t)
+}
+
+// ❌ **Тест: Ошибка получения статистики запуска**
+func TestGetLaunch_StatisticError(t *testing.T) {
+	mockClient := new(MockAllureClient)
+	service := service.NewAllureService(mockClient)
+
+	mockClient.On("GetLaunch", mock.Anything, int64(42)).Return(&adapter.Launch{ID: 42}, nil)
+	mockClient.On("GetLaunchStatistic", mock.Anything, int64(42)).Return(nil, errors.New("ошибка API"))
+
+	launch, err := service.GetLaunch(context.Background(), 0, 42)
+	assert.Error(t, err)
+	assert.Nil(t, launch)
+}
+
+// ✅ **Тест: Результат теста дополняется шагами и вложениями**
+func TestGetTestResult_WithExecution(t *testing.T) {
+	mockClient := new(MockAllureClient)
+	service := service.NewAllureService(mockClient)
+
+	steps := []adapter.Step{{Name: "open page", Status: "passed"}}
+	attachments := []adapter.Attachment{{ID: 3, Name: "log.txt", ContentType: "text/plain"}}
+
+	mockClient.On("GetTestResult", mock.Anything, int64(7)).Return(&adapter.TestResult{ID: 7, Name: "login", Status: "failed"}, nil)
+	mockClient.On("GetTestResultExecution", mock.Anything, int64(7)).Return(&adapter.Step{Steps: steps, Attachments: attachments}, nil)
+
+	result, err := service.GetTestResult(context.Background(), 0, 7)
+	assert.NoError(t, err)
+	assert.Equal(t, steps, result.Steps)
+	assert.Equal(t, attachments, result.Attachments)
+	mockClient.AssertExpectations(t)
+}
+
+// ❌ **Тест: Ошибка получения результатов запуска**
+func TestGetTestResults_Error(t *testing.T) {
+	mockClient := new(MockAllureClient)
+	service := service.NewAllureService(mockClient)
+
+	mockClient.On("SearchTestResults", mock.Anything, mock.Anything).Return(nil, errors.New("ошибка API"))
+
+	page, err := service.GetTestResults(context.Background(), 0, adapter.TestResultQuery{LaunchID: 42})
+	assert.Error(t, err)
+	assert.Nil(t, page)
+}
+
+// ✅ **Тест: Вложение ищется во вложенных шагах результата**
+func TestDownloadAttachment_NestedStep(t *testing.T) {
+	mockClient := new(MockAllureClient)
+	service := service.NewAllureService(mockClient)
+
+	execution := &adapter.Step{Steps: []adapter.Step{{
+		Name:  "open page",
+		Steps: []adapter.Step{{Name: "screenshot", Attachments: []adapter.Attachment{{ID: 3, Name: "screen.png", ContentType: "image/png"}}}},
+	}}}
+	mockClient.On("GetTestResultExecution", mock.Anything, int64(7)).Return(execution, nil)
+	mockClient.On("DownloadAttachment", mock.Anything, int64(3), "").Return(&adapter.FileContent{
+		Body:          io.NopCloser(strings.NewReader("PNG")),
+		StatusCode:    200,
+		ContentLength: 3,
+	}, nil)
+
+	content, err := service.DownloadAttachment(context.Background(), 0, 7, 3, "")
+	assert.NoError(t, err)
+	assert.Equal(t, "screen.png", content.FileName)
+	assert.Equal(t, "image/png", content.ContentType) // Тип берется из метаданных вложения
+	assert.NoError(t, content.Body.Close())
+	mockClient.AssertExpectations(t)
+}
+
+// ❌ **Тест: Вложение другого результата не отдается**
+func TestDownloadAttachment_ForeignAttachment(t *testing.T) {
+	mockClient := new(MockAllureClient)
+	service := service.NewAllureService(mockClient)
+
+	mockClient.On("GetTestResultExecution", mock.Anything, int64(7)).Return(&adapter.Step{
+		Attachments: []adapter.Attachment{{ID: 3, Name: "log.txt"}},
+	}, nil)
+
+	content, err := service.DownloadAttachment(context.Background(), 0, 7, 99, "")
+	assert.ErrorIs(t, err, adapter.ErrNotFound)
+	assert.Nil(t, content)
+	mockClient.AssertNotCalled(t, "DownloadAttachment", mock.Anything, mock.Anything, mock.Anything)
+}
+
+// ✅ **Тест: Выгрузка результатов собирает все страницы**
+func TestExportLaunchResults_CSV(t *testing.T) {
+	mockClient := new(MockAllureClient)
+	service := service.NewAllureService(mockClient)
+
+	mockClient.On("IterateTestResults", mock.Anything, adapter.TestResultQuery{LaunchID: 42}, mock.Anything).Return([]adapter.TestResult{
+		{Name: "login", Status: "passed"},
+		{Name: "logout", Status: "failed"},
+	}, nil)
+
+	results, err := service.ExportLaunchResults(context.Background(), 0, 42, "csv")
+	assert.NoError(t, err)
+	assert.Equal(t, "allure-launch-42.csv", results.FileName)
+	assert.Equal(t, "text/csv; charset=utf-8", results.ContentType)
+
+	var buf bytes.Buffer
+	assert.NoError(t, results.Write(&buf))
+	assert.Contains(t, buf.String(), "login,,passed")
+	assert.Contains(t, buf.String(), "logout,,failed")
+}
+
+// ❌ **Тест: Ошибка получения результатов для выгрузки**
+func TestExportLaunchResults_Error(t *testing.T) {
+	mockClient := new(MockAllureClient)
+	service := service.NewAllureService(mockClient)
+
+	mockClient.On("IterateTestResults", mock.Anything, mock.Anything, mock.Anything).Return(nil, errors.New("ошибка API"))
+
+	results, err := service.ExportLaunchResults(context.Background(), 0, 42, "xlsx")
+	assert.NoError(t, err)
+
+	var buf bytes.Buffer
+	assert.Error(t, results.Write(&buf))
+	assert.Zero(t, buf.Len())
+}
+
+// ❌ **Тест: Выгрузка запуска чужого проекта отклоняется до записи файла**
+func TestExportLaunchResults_ForeignLaunch(t *testing.T) {
+	mockClient := new(MockAllureClient)
+	service := service.NewAllureService(mockClient)
+
+	mockClient.On("GetLaunch", mock.Anything, int64(42)).Return(&adapter.Launch{ID: 42, ProjectID: 2}, nil)
+
+	results, err := service.ExportLaunchResults(context.Background(), 1, 42, "csv")
+	assert.ErrorIs(t, err, adapter.ErrNotFound)
+	assert.Nil(t, results)
+	mockClient.AssertNotCalled(t, "IterateTestResults", mock.Anything, mock.Anything, mock.Anything)
+}
+
+// ✅ **Тест: JUnit-выгрузка берет имя запуска и все результаты**
+func TestExportLaunchJUnit(t *testing.T) {
+	mockClient := new(MockAllureClient)
+	service := service.NewAllureService(mockClient)
+
+	mockClient.On("GetLaunch", mock.Anything, int64(42)).Return(&adapter.Launch{ID: 42, Name: "nightly"}, nil)
+	mockClient.On("IterateTestResults", mock.Anything, adapter.TestResultQuery{LaunchID: 42}, mock.Anything).Return([]adapter.TestResult{
+		{Name: "login", Status: "passed"},
+		{Name: "logout", Status: "failed", Message: "timeout"},
+	}, nil)
+
+	results, err := service.ExportLaunchJUnit(context.Background(), 0, 42)
+	assert.NoError(t, err)
+	assert.Equal(t, "allure-launch-42.junit.xml", results.FileName)
+
+	var buf bytes.Buffer
+	assert.NoError(t, results.Write(&buf))
+	assert.Contains(t, buf.String(), `<testsuites name="nightly" tests="2" failures="1"`)
+	assert.Contains(t, buf.String(), `<failure message="timeout" type="failed">`)
+}
+
+// ❌ **Тест: JUnit-выгрузка несуществующего запуска**
+func TestExportLaunchJUnit_NotFound(t *testing.T) {
+	mockClient := new(MockAllureClient)
+	service := service.NewAllureService(mockClient)
+
+	mockClient.On("GetLaunch", mock.Anything, int64(42)).Return(nil, adapter.ErrNotFound)
+
+	results, err := service.ExportLaunchJUnit(context.Background(), 0, 42)
+	assert.ErrorIs(t, err, adapter.ErrNotFound)
+	assert.Nil(t, results)
+	mockClient.AssertNotCalled(t, "IterateTestResults", mock.Anything, mock.Anything, mock.Anything)
+}
+
 // ✅ **Тест: Успешная генерация PDF-отчёта**
 func TestGeneratePDFReport_Success(t *testing.T) {
 	mockClient := new(MockAllureClient)
@@ -64,12 +310,13 @@ func TestGeneratePDFReport_Success(t *testing.T) {
 		ID:          999,
 		Name:        "Test Report",
 		ProjectID:   1661,
+		Status:      "READY",
 		CreatedDate: time.Now().UnixMilli(),
 	}
 
-	mockClient.On("GeneratePDFReport", mock.Anything, int64(123), "Test Run").Return(mockReport, nil)
+	mockClient.On("GeneratePDFReport", mock.Anything, int64(123), "Test Run", mock.Anything).Return(mockReport, nil)
 
-	report, err := service.GeneratePDFReport(123, "Test Run")
+	report, err := service.GeneratePDFReport(context.Background(), 0, 123, "Test Run", adapter.DefaultPDFExportOptions())
 	assert.NoError(t, err)
 	assert.NotNil(t, report)
 	assert.Equal(t, int64(999), report.ID)
@@ -80,10 +327,10 @@ func TestGeneratePDFReport_Error(t *testing.T) {
 	mockClient := new(MockAllureClient)
 	service := service.NewAllureService(mockClient)
 
-	mockClient.On("GeneratePDFReport", mock.Anything, int64(123), "Test Run").
+	mockClient.On("GeneratePDFReport", mock.Anything, int64(123), "Test Run", mock.Anything).
 		Return((*adapter.PDFReport)(nil), errors.New("ошибка генерации PDF"))
 
-	report, err := service.GeneratePDFReport(123, "Test Run")
+	report, err := service.GeneratePDFReport(context.Background(), 0, 123, "Test Run", adapter.DefaultPDFExportOptions())
 	assert.Error(t, err)
 	assert.Nil(t, report)
 }
@@ -96,12 +343,18 @@ func TestDownloadPDFReport_Success(t *testing.T) {
 	pdfContent := []byte("PDF FILE CONTENT")
 	fileName := "allure-report-999.pdf"
 
-	mockClient.On("DownloadPDFReport", mock.Anything, "999").Return(pdfContent, fileName, nil)
+	mockClient.On("GetPDFReport", mock.Anything, "999").Return(&adapter.PDFReport{ID: 999, Status: "READY"}, nil)
+	mockClient.On("DownloadPDFReport", mock.Anything, "999").Return(newPDFContent(string(pdfContent), fileName), nil)
 
-	data, name, err := service.DownloadPDFReport("999")
+	download, err := service.DownloadPDFReport(context.Background(), 0, "999")
 	assert.NoError(t, err)
-	assert.NotNil(t, data)
-	assert.Equal(t, fileName, name)
+	defer download.Body.Close()
+	data, _ := io.ReadAll(download.Body)
+	assert.Equal(t, pdfContent, data)
+	assert.Equal(t, fileName, download.FileName)
+	assert.Equal(t, int64(len(pdfContent)), download.Size)
+	assert.Empty(t, download.ETag) // Без кэша ETag не вычисляется, чтобы не буферизовать отчет
+	assert.False(t, download.Cached)
 }
 
 // ❌ **Тест: Ошибка скачивания PDF**
@@ -109,13 +362,13 @@ func TestDownloadPDFReport_Error(t *testing.T) {
 	mockClient := new(MockAllureClient)
 	service := service.NewAllureService(mockClient)
 
+	mockClient.On("GetPDFReport", mock.Anything, "999").Return(&adapter.PDFReport{ID: 999, Status: "READY"}, nil)
 	mockClient.On("DownloadPDFReport", mock.Anything, "999").
-		Return(([]byte)(nil), "", errors.New("ошибка скачивания PDF")) // ✅ Теперь безопасно
+		Return(nil, errors.New("ошибка скачивания PDF")) // ✅ Теперь безопасно
 
-	data, name, err := service.DownloadPDFReport("999")
+	download, err := service.DownloadPDFReport(context.Background(), 0, "999")
 	assert.Error(t, err)
-	assert.Nil(t, data)
-	assert.Equal(t, "", name)
+	assert.Nil(t, download)
 }
 
 func TestGeneratePDFReport_InvalidParameters(t *testing.T) {
@@ -123,19 +376,19 @@ func TestGeneratePDFReport_InvalidParameters(t *testing.T) {
 	service := service.NewAllureService(mockClient)
 
 	// Добавляем мокирование для вызовов с некорректными параметрами
-	mockClient.On("GeneratePDFReport", mock.Anything, int64(0), "Test").
+	mockClient.On("GeneratePDFReport", mock.Anything, int64(0), "Test", mock.Anything).
 		Return(nil, errors.New("invalid launch ID"))
 
-	mockClient.On("GeneratePDFReport", mock.Anything, int64(123), "").
+	mockClient.On("GeneratePDFReport", mock.Anything, int64(123), "", mock.Anything).
 		Return(nil, errors.New("empty launch name"))
 
 	// Тест с нулевым LaunchID
-	_, err := service.GeneratePDFReport(0, "Test")
+	_, err := service.GeneratePDFReport(context.Background(), 0, 0, "Test", adapter.DefaultPDFExportOptions())
 	assert.Error(t, err)
 	assert.Contains(t, err.Error(), "invalid launch ID")
 
 	// Тест с пустым именем
-	_, err = service.GeneratePDFReport(123, "")
+	_, err = service.GeneratePDFReport(context.Background(), 0, 123, "", adapter.DefaultPDFExportOptions())
 	assert.Error(t, err)
 	assert.Contains(t, err.Error(), "empty launch name")
 
@@ -147,26 +400,476 @@ func TestDownloadPDFReport_EdgeCases(t *testing.T) {
 	mockClient := new(MockAllureClient)
 	service := service.NewAllureService(mockClient)
 
+	// Отчеты считаются готовыми, проверяем только ошибки скачивания
+	mockClient.On("GetPDFReport", mock.Anything, mock.Anything).Return(&adapter.PDFReport{Status: "READY"}, nil)
+
 	// Добавляем мокирование вызова с пустым reportID
 	mockClient.On("DownloadPDFReport", mock.Anything, "").Return(
-		nil, "", errors.New("empty report ID"),
+		nil, errors.New("empty report ID"),
 	)
 
 	// Добавляем мокирование вызова с неверным форматом ID
 	mockClient.On("DownloadPDFReport", mock.Anything, "invalid").Return(
-		nil, "", errors.New("invalid report ID"),
+		nil, errors.New("invalid report ID"),
 	)
 
 	// Тест с пустым reportID
-	_, _, err := service.DownloadPDFReport("")
+	_, err := service.DownloadPDFReport(context.Background(), 0, "")
 	assert.Error(t, err)
 	assert.Contains(t, err.Error(), "empty report ID")
 
 	// Тест с неверным форматом ID
-	_, _, err = service.DownloadPDFReport("invalid")
+	_, err = service.DownloadPDFReport(context.Background(), 0, "invalid")
 	assert.Error(t, err)
 	assert.Contains(t, err.Error(), "invalid report ID")
 
 	// Проверяем, что моки были вызваны
 	mockClient.AssertExpectations(t)
 }
+
+// ✅ **Тест: PDF копируется в кэш во время отдачи, повторное скачивание идет из кэша с ETag**
+func TestDownloadPDFReport_Cache(t *testing.T) {
+	reports, err := reportcache.New(t.TempDir(), 1<<20)
+	assert.NoError(t, err)
+
+	mockClient := new(MockAllureClient)
+	allureService := service.NewAllureService(mockClient, service.WithReportCache(reports))
+
+	mockClient.On("GetPDFReport", mock.Anything, "999").Return(&adapter.PDFReport{ID: 999, Status: "READY"}, nil)
+	mockClient.On("DownloadPDFReport", mock.Anything, "999").Return(newPDFContent("PDF FILE CONTENT", "allure-report-999.pdf"), nil).Once()
+
+	// Первое скачивание отдается из Allure потоком без ETag: контрольная сумма еще не известна
+	first, err := allureService.DownloadPDFReport(context.Background(), 0, "999")
+	assert.NoError(t, err)
+	assert.False(t, first.Cached)
+	assert.Empty(t, first.ETag)
+	firstData, _ := io.ReadAll(first.Body)
+	first.Body.Close()
+
+	second, err := allureService.DownloadPDFReport(context.Background(), 0, "999")
+	assert.NoError(t, err)
+	secondData, _ := io.ReadAll(second.Body)
+	second.Body.Close()
+	assert.True(t, second.Cached)
+	assert.Equal(t, "PDF FILE CONTENT", string(firstData))
+	assert.Equal(t, firstData, secondData)
+	// Со второго скачивания отчет отдается из кэша с ETag содержимого
+	checksum := sha256.Sum256(firstData)
+	assert.Equal(t, reportcache.ETag(hex.EncodeToString(checksum[:])), second.ETag)
+	assert.Equal(t, int64(16), second.Size)
+	assert.Equal(t, "allure-report-999.pdf", second.FileName)
+
+	mockClient.AssertNumberOfCalls(t, "DownloadPDFReport", 1)
+}
+
+// ✅ **Тест: Недочитанный отчет и отчет неизвестного размера в кэш не попадают и не запрашиваются повторно**
+func TestDownloadPDFReport_CacheBypass(t *testing.T) {
+	reports, err := reportcache.New(t.TempDir(), 1<<20)
+	assert.NoError(t, err)
+
+	mockClient := new(MockAllureClient)
+	allureService := service.NewAllureService(mockClient, service.WithReportCache(reports))
+
+	mockClient.On("GetPDFReport", mock.Anything, mock.Anything).Return(&adapter.PDFReport{Status: "READY"}, nil)
+	unknownSize := newPDFContent("PDF FILE CONTENT", "allure-report-999.pdf")
+	unknownSize.ContentLength = -1
+	mockClient.On("DownloadPDFReport", mock.Anything, "999").Return(unknownSize, nil).Once()
+	mockClient.On("DownloadPDFReport", mock.Anything, "998").Return(newPDFContent("PDF FILE CONTENT", "allure-report-998.pdf"), nil).Once()
+
+	download, err := allureService.DownloadPDFReport(context.Background(), 0, "999")
+	assert.NoError(t, err)
+	data, _ := io.ReadAll(download.Body)
+	download.Body.Close()
+	assert.Equal(t, "PDF FILE CONTENT", string(data))
+	assert.False(t, download.Cached)
+	assert.Empty(t, download.ETag)
+
+	download, err = allureService.DownloadPDFReport(context.Background(), 0, "998")
+	assert.NoError(t, err)
+	download.Body.Read(make([]byte, 3))
+	download.Body.Close()
+
+	_, ok := reports.Get("999")
+	assert.False(t, ok)
+	_, ok = reports.Get("998")
+	assert.False(t, ok)
+	mockClient.AssertNumberOfCalls(t, "DownloadPDFReport", 2)
+}
+
+// fastExportPolling - быстрый опрос статуса экспорта для тестов
+var fastExportPolling = service.ExportPolling{
+	Interval:     10 * time.Millisecond,
+	Timeout:      time.Second,
+	DownloadWait: 200 * time.Millisecond,
+}
+
+// ✅ **Тест: Статус экспорта отслеживается в фоне до готовности**
+func TestExportStatus_PollsUntilReady(t *testing.T) {
+	mockClient := new(MockAllureClient)
+	allureService := service.NewAllureService(mockClient, service.WithExportPolling(fastExportPolling))
+
+	mockClient.On("GeneratePDFReport", mock.Anything, int64(123), "Test Run", mock.Anything).
+		Return(&adapter.PDFReport{ID: 456, Name: "Test Run", Status: "IN_PROGRESS"}, nil)
+	mockClient.On("GetPDFReport", mock.Anything, "456").
+		Return(&adapter.PDFReport{ID: 456, Status: "IN_PROGRESS"}, nil).Twice()
+	mockClient.On("GetPDFReport", mock.Anything, "456").
+		Return(&adapter.PDFReport{ID: 456, Status: "DONE"}, nil)
+
+	_, err := allureService.GeneratePDFReport(context.Background(), 0, 123, "Test Run", adapter.DefaultPDFExportOptions())
+	assert.NoError(t, err)
+
+	job, err := allureService.GetExportStatus(context.Background(), 0, "456")
+	assert.NoError(t, err)
+	assert.Equal(t, "pending", job.Status)
+	assert.Equal(t, int64(123), job.LaunchID)
+
+	assert.Eventually(t, func() bool {
+		job, err := allureService.GetExportStatus(context.Background(), 0, "456")
+		return err == nil && job.Status == "ready"
+	}, time.Second, 10*time.Millisecond)
+}
+
+// ✅ **Тест: Скачивание дожидается готовности отчета**
+func TestDownloadPDFReport_WaitsForReady(t *testing.T) {
+	mockClient := new(MockAllureClient)
+	allureService := service.NewAllureService(mockClient, service.WithExportPolling(fastExportPolling))
+
+	mockClient.On("GeneratePDFReport", mock.Anything, int64(123), "Test Run", mock.Anything).
+		Return(&adapter.PDFReport{ID: 456, Status: "IN_PROGRESS"}, nil)
+	mockClient.On("GetPDFReport", mock.Anything, "456").
+		Return(&adapter.PDFReport{ID: 456, Status: "IN_PROGRESS"}, nil).Once()
+	mockClient.On("GetPDFReport", mock.Anything, "456").
+		Return(&adapter.PDFReport{ID: 456, Status: "DONE"}, nil)
+	mockClient.On("DownloadPDFReport", mock.Anything, "456").Return(newPDFContent("PDF", "report.pdf"), nil)
+
+	_, err := allureService.GeneratePDFReport(context.Background(), 0, 123, "Test Run", adapter.DefaultPDFExportOptions())
+	assert.NoError(t, err)
+
+	download, err := allureService.DownloadPDFReport(context.Background(), 0, "456")
+	assert.NoError(t, err)
+	defer download.Body.Close()
+	data, _ := io.ReadAll(download.Body)
+	assert.Equal(t, []byte("PDF"), data)
+}
+
+// ❌ **Тест: Неготовый и упавший отчеты не скачиваются**
+func TestDownloadPDFReport_NotReadyAndFailed(t *testing.T) {
+	mockClient := new(MockAllureClient)
+	allureService := service.NewAllureService(mockClient, service.WithExportPolling(fastExportPolling))
+
+	mockClient.On("GetPDFReport", mock.Anything, "456").Return(&adapter.PDFReport{ID: 456, Status: "IN_PROGRESS"}, nil)
+	mockClient.On("GetPDFReport", mock.Anything, "789").Return(&adapter.PDFReport{ID: 789, Status: "FAILED"}, nil)
+
+	_, err := allureService.DownloadPDFReport(context.Background(), 0, "456")
+	assert.ErrorIs(t, err, service.ErrReportNotReady)
+
+	_, err = allureService.DownloadPDFReport(context.Background(), 0, "789")
+	assert.ErrorIs(t, err, service.ErrReportFailed)
+
+	mockClient.AssertNotCalled(t, "DownloadPDFReport", mock.Anything, mock.Anything)
+}
+
+// ✅ **Тест: Скачанный PDF-отчет архивируется под своим запуском**
+func TestDownloadPDFReport_Archive(t *testing.T) {
+	reportArchive, err := archive.NewLocal(t.TempDir())
+	assert.NoError(t, err)
+
+	mockClient := new(MockAllureClient)
+	allureService := service.NewAllureService(mockClient, service.WithReportArchive(reportArchive))
+
+	mockClient.On("GeneratePDFReport", mock.Anything, int64(123), "Test Run", mock.Anything).
+		Return(&adapter.PDFReport{ID: 456, Status: "DONE"}, nil)
+	mockClient.On("DownloadPDFReport", mock.Anything, "456").Return(newPDFContent("PDF FILE CONTENT", "allure-report-456.pdf"), nil)
+
+	_, err = allureService.GeneratePDFReport(context.Background(), 0, 123, "Test Run", adapter.DefaultPDFExportOptions())
+	assert.NoError(t, err)
+
+	download, err := allureService.DownloadPDFReport(context.Background(), 0, "456")
+	assert.NoError(t, err)
+	io.ReadAll(download.Body)
+	download.Body.Close()
+
+	// Архив дописывается в фоне после отдачи отчета
+	assert.Eventually(t, func() bool {
+		reports, err := allureService.ListArchivedReports(context.Background(), 123)
+		return err == nil && len(reports) == 1
+	}, time.Second, 10*time.Millisecond)
+
+	archived, err := allureService.OpenArchivedReport(context.Background(), 123, "456")
+	assert.NoError(t, err)
+	data, _ := io.ReadAll(archived.Body)
+	archived.Body.Close()
+	assert.Equal(t, "PDF FILE CONTENT", string(data))
+	assert.Equal(t, "allure-report-456.pdf", archived.FileName)
+}
+
+// archived - ждет, пока отчет появится в архиве, и возвращает его содержимое
+func archived(t *testing.T, reportArchive archive.ReportArchive, launchID int64, reportID string) string {
+	var data []byte
+	assert.Eventually(t, func() bool {
+		_, body, err := reportArchive.Open(context.Background(), launchID, reportID)
+		if err != nil {
+			return false
+		}
+		defer body.Close()
+		data, err = io.ReadAll(body)
+		return err == nil
+	}, time.Second, 10*time.Millisecond)
+	return string(data)
+}
+
+// ✅ **Тест: Отчет архивируется и вместе с кэшем, и если клиент не дочитал его до конца**
+func TestDownloadPDFReport_ArchiveCachedAndIncomplete(t *testing.T) {
+	reports, err := reportcache.New(t.TempDir(), 1<<20)
+	assert.NoError(t, err)
+	reportArchive, err := archive.NewLocal(t.TempDir())
+	assert.NoError(t, err)
+
+	mockClient := new(MockAllureClient)
+	cached := service.NewAllureService(mockClient, service.WithReportCache(reports), service.WithReportArchive(reportArchive))
+	direct := service.NewAllureService(mockClient, service.WithReportArchive(reportArchive))
+
+	mockClient.On("GetPDFReport", mock.Anything, mock.Anything).Return(&adapter.PDFReport{Status: "DONE"}, nil)
+	mockClient.On("DownloadPDFReport", mock.Anything, "456").Return(newPDFContent("PDF FILE CONTENT", "456.pdf"), nil)
+	mockClient.On("DownloadPDFReport", mock.Anything, "789").Return(newPDFContent("PDF FILE CONTENT", "789.pdf"), nil)
+
+	download, err := cached.DownloadPDFReport(context.Background(), 0, "456")
+	assert.NoError(t, err)
+	io.ReadAll(download.Body)
+	download.Body.Close()
+
+	download, err = direct.DownloadPDFReport(context.Background(), 0, "789")
+	assert.NoError(t, err)
+	download.Body.Read(make([]byte, 3))
+	download.Body.Close()
+
+	// Запуск отчетов неизвестен, поэтому они попадают под запуск 0
+	assert.Equal(t, "PDF FILE CONTENT", archived(t, reportArchive, 0, "456"))
+	assert.Equal(t, "PDF FILE CONTENT", archived(t, reportArchive, 0, "789"))
+	_, ok := reports.Get("456")
+	assert.True(t, ok)
+}
+
+// ✅ **Тест: Отчет архивируется, даже если сохранить его в кэш не удалось**
+func TestDownloadPDFReport_ArchiveWhenCacheFails(t *testing.T) {
+	cacheDir := t.TempDir()
+	reports, err := reportcache.New(cacheDir, 1<<20)
+	assert.NoError(t, err)
+	reportArchive, err := archive.NewLocal(t.TempDir())
+	assert.NoError(t, err)
+
+	mockClient := new(MockAllureClient)
+	allureService := service.NewAllureService(mockClient, service.WithReportCache(reports), service.WithReportArchive(reportArchive))
+
+	mockClient.On("GetPDFReport", mock.Anything, "456").Return(&adapter.PDFReport{Status: "DONE"}, nil)
+	mockClient.On("DownloadPDFReport", mock.Anything, "456").Return(newPDFContent("PDF FILE CONTENT", "456.pdf"), nil)
+
+	download, err := allureService.DownloadPDFReport(context.Background(), 0, "456")
+	assert.NoError(t, err)
+
+	// Каталог кэша пропал во время скачивания: Commit не сможет перенести отчет в кэш
+	assert.NoError(t, os.RemoveAll(cacheDir))
+	data, err := io.ReadAll(download.Body)
+	assert.NoError(t, err)
+	download.Body.Close()
+	assert.Equal(t, "PDF FILE CONTENT", string(data))
+
+	_, ok := reports.Get("456")
+	assert.False(t, ok)
+	assert.Equal(t, "PDF FILE CONTENT", archived(t, reportArchive, 0, "456"))
+}
+
+// slowArchive - архив, загрузка в который ждет разрешения теста
+type slowArchive struct {
+	archive.ReportArchive
+	release chan struct{}
+}
+
+func (a *slowArchive) Put(ctx context.Context, report archive.Report, r io.Reader) (*archive.Report, error) {
+	<-a.release
+	return a.ReportArchive.Put(ctx, report, r)
+}
+
+// ✅ **Тест: Медленный архив не задерживает скачивание отчета**
+func TestDownloadPDFReport_SlowArchive(t *testing.T) {
+	local, err := archive.NewLocal(t.TempDir())
+	assert.NoError(t, err)
+	reportArchive := &slowArchive{ReportArchive: local, release: make(chan struct{})}
+
+	mockClient := new(MockAllureClient)
+	allureService := service.NewAllureService(mockClient, service.WithReportArchive(reportArchive))
+
+	mockClient.On("GetPDFReport", mock.Anything, "456").Return(&adapter.PDFReport{Status: "DONE"}, nil)
+	mockClient.On("DownloadPDFReport", mock.Anything, "456").Return(newPDFContent("PDF FILE CONTENT", "456.pdf"), nil)
+
+	download, err := allureService.DownloadPDFReport(context.Background(), 0, "456")
+	assert.NoError(t, err)
+
+	// Отчет дочитывается, пока архив еще не принял ни байта
+	data, err := io.ReadAll(download.Body)
+	assert.NoError(t, err)
+	assert.NoError(t, download.Body.Close())
+	assert.Equal(t, "PDF FILE CONTENT", string(data))
+
+	close(reportArchive.release)
+	assert.Eventually(t, func() bool {
+		_, body, err := local.Open(context.Background(), 0, "456")
+		if err == nil {
+			body.Close()
+		}
+		return err == nil
+	}, time.Second, 10*time.Millisecond)
+}
+
+// ❌ **Тест: Без настроенного архива просмотр архива недоступен**
+func TestArchivedReports_Disabled(t *testing.T) {
+	allureService := service.NewAllureService(new(MockAllureClient))
+
+	_, err := allureService.ListArchivedReports(context.Background(), 123)
+	assert.ErrorIs(t, err, service.ErrArchiveDisabled)
+
+	_, err = allureService.OpenArchivedReport(context.Background(), 123, "456")
+	assert.ErrorIs(t, err, service.ErrArchiveDisabled)
+}
+
+// ✅ **Тест: запросы ограничены разрешенными проектами**
+func TestProjects_AllowList(t *testing.T) {
+	mockClient := new(MockAllureClient)
+	allureService := service.NewAllureService(mockClient, service.WithProjects(1661, []int64{42}))
+
+	mockClient.On("GetProjects", mock.Anything).Return([]adapter.Project{{ID: 1661}, {ID: 42}, {ID: 7}}, nil)
+	mockClient.On("SearchLaunches", mock.Anything, mock.Anything).Return(&adapter.LaunchPage{}, nil)
+	mockClient.On("GetLaunch", mock.Anything, int64(5)).Return(&adapter.Launch{ID: 5, ProjectID: 42}, nil)
+
+	projects, err := allureService.GetProjects(context.Background())
+	assert.NoError(t, err)
+	assert.Equal(t, []adapter.Project{{ID: 1661}, {ID: 42}}, projects)
+
+	// Без проекта используется проект по умолчанию
+	_, err = allureService.GetLaunches(context.Background(), adapter.LaunchQuery{})
+	assert.NoError(t, err)
+	mockClient.AssertCalled(t, "SearchLaunches", mock.Anything, adapter.LaunchQuery{ProjectID: 1661})
+
+	_, err = allureService.GetLaunches(context.Background(), adapter.LaunchQuery{ProjectID: 7})
+	assert.ErrorIs(t, err, service.ErrProjectNotAllowed)
+	assert.ErrorIs(t, allureService.CheckProject(context.Background(), 7), service.ErrProjectNotAllowed)
+
+	// Запуск чужого проекта не раскрывается
+	_, err = allureService.GetTestResults(context.Background(), 1661, adapter.TestResultQuery{LaunchID: 5})
+	assert.ErrorIs(t, err, adapter.ErrNotFound)
+	mockClient.AssertNotCalled(t, "SearchTestResults", mock.Anything, mock.Anything)
+}
+
+// ❌ **Тест: пустой список разрешенных проектов не разрешает ни одного проекта**
+func TestProjects_EmptyAllowList(t *testing.T) {
+	mockClient := new(MockAllureClient)
+	allureService := service.NewAllureService(mockClient, service.WithProjects(0, nil))
+
+	assert.ErrorIs(t, allureService.CheckProject(context.Background(), 7), service.ErrProjectNotAllowed)
+
+	// Без WithProjects сервис проекты не ограничивает
+	assert.NoError(t, service.NewAllureService(mockClient).CheckProject(context.Background(), 7))
+}
+
+// ❌ **Тест: отчет чужого проекта не скачивается даже из кэша**
+func TestDownloadPDFReport_ForeignProject(t *testing.T) {
+	mockClient := new(MockAllureClient)
+	allureService := service.NewAllureService(mockClient, service.WithProjects(1661, []int64{42}))
+
+	mockClient.On("GetPDFReport", mock.Anything, "456").Return(&adapter.PDFReport{ID: 456, ProjectID: 42, Status: "DONE"}, nil)
+
+	_, err := allureService.DownloadPDFReport(context.Background(), 1661, "456")
+	assert.ErrorIs(t, err, adapter.ErrNotFound)
+	mockClient.AssertNotCalled(t, "DownloadPDFReport", mock.Anything, mock.Anything)
+
+	job, err := allureService.GetExportStatus(context.Background(), 42, "456")
+	assert.NoError(t, err)
+	assert.Equal(t, int64(42), job.ProjectID)
+}
+
+// deadlineWithin - контекст с дедлайном, наступающим не позже чем через timeout
+func deadlineWithin(timeout time.Duration) interface{} {
+	return mock.MatchedBy(func(ctx context.Context) bool {
+		deadline, ok := ctx.Deadline()
+		return ok && time.Until(deadline) <= timeout && time.Until(deadline) > timeout-time.Minute
+	})
+}
+
+// ✅ **Тест: у каждой операции свой таймаут из конфигурации**
+func TestTimeouts_PerOperation(t *testing.T) {
+	mockClient := new(MockAllureClient)
+	allureService := service.NewAllureService(mockClient, service.WithTimeouts(service.Timeouts{
+		List:     2 * time.Minute,
+		Export:   20 * time.Minute,
+		Download: time.Hour,
+	}))
+
+	mockClient.On("SearchLaunches", deadlineWithin(2*time.Minute), mock.Anything).Return(&adapter.LaunchPage{}, nil)
+	mockClient.On("GeneratePDFReport", deadlineWithin(20*time.Minute), int64(123), "Test Run", mock.Anything).
+		Return(&adapter.PDFReport{ID: 999, Status: "READY"}, nil)
+	mockClient.On("GetPDFReport", mock.Anything, "999").Return(&adapter.PDFReport{ID: 999, Status: "READY"}, nil).Maybe()
+	mockClient.On("DownloadPDFReport", deadlineWithin(time.Hour), "999").Return(newPDFContent("PDF", "999.pdf"), nil)
+
+	_, err := allureService.GetLaunches(context.Background(), adapter.LaunchQuery{})
+	assert.NoError(t, err)
+
+	_, err = allureService.GeneratePDFReport(context.Background(), 0, 123, "Test Run", adapter.DefaultPDFExportOptions())
+	assert.NoError(t, err)
+
+	download, err := allureService.DownloadPDFReport(context.Background(), 0, "999")
+	if assert.NoError(t, err) {
+		download.Body.Close()
+	}
+	mockClient.AssertExpectations(t)
+}
+
+// ✅ **Тест: перезагрузка конфигурации меняет разрешенные проекты и таймауты**
+func TestReload_ProjectsAndTimeouts(t *testing.T) {
+	mockClient := new(MockAllureClient)
+	allureService := service.NewAllureService(mockClient, service.WithProjects(1661, []int64{42}))
+
+	mockClient.On("SearchLaunches", deadlineWithin(time.Hour), adapter.LaunchQuery{ProjectID: 7}).Return(&adapter.LaunchPage{}, nil)
+
+	assert.ErrorIs(t, allureService.CheckProject(context.Background(), 7), service.ErrProjectNotAllowed)
+
+	allureService.Reload(service.Timeouts{List: time.Hour, Export: time.Hour, Download: time.Hour}, 7, []int64{8})
+
+	assert.NoError(t, allureService.CheckProject(context.Background(), 7))
+	assert.NoError(t, allureService.CheckProject(context.Background(), 8))
+	assert.ErrorIs(t, allureService.CheckProject(context.Background(), 42), service.ErrProjectNotAllowed)
+
+	_, err := allureService.GetLaunches(context.Background(), adapter.LaunchQuery{})
+	assert.NoError(t, err)
+	mockClient.AssertExpectations(t)
+}
+
+// ✅ **Тест: контекст запроса доходит до клиента Allure, а отмена прерывает ожидание отчета**
+func TestRequestContext_Propagation(t *testing.T) {
+	mockClient := new(MockAllureClient)
+	allureService := service.NewAllureService(mockClient, service.WithExportPolling(service.ExportPolling{
+		Interval:     time.Hour,
+		Timeout:      time.Hour,
+		DownloadWait: time.Hour,
+	}))
+
+	ctx := reqctx.WithRequestID(context.Background(), "req-42")
+	mockClient.On("SearchLaunches", mock.MatchedBy(func(ctx context.Context) bool {
+		_, hasDeadline := ctx.Deadline()
+		return reqctx.RequestID(ctx) == "req-42" && hasDeadline
+	}), mock.Anything).Return(&adapter.LaunchPage{}, nil)
+
+	_, err := allureService.GetLaunches(ctx, adapter.LaunchQuery{})
+	assert.NoError(t, err)
+	mockClient.AssertExpectations(t)
+
+	// Клиент отключился, пока отчет формируется - скачивание не ждет DownloadWait
+	mockClient.On("GetPDFReport", mock.Anything, "999").Return(&adapter.PDFReport{ID: 999, Status: "RUNNING"}, nil)
+	cancelled, cancel := context.WithCancel(ctx)
+	cancel()
+
+	started := time.Now()
+	_, err = allureService.DownloadPDFReport(cancelled, 0, "999")
+	assert.ErrorIs(t, err, service.ErrReportNotReady)
+	assert.Less(t, time.Since(started), time.Second)
+	mockClient.AssertNotCalled(t, "DownloadPDFReport", mock.Anything, mock.Anything)
+}